@@ -0,0 +1,596 @@
+// Command lidy checks YAML documents against lidy schemas.
+//
+// Usage:
+//
+//	lidy check -s schema.yaml [-t target] [-f text|json|github] file...
+//	lidy schema [-f text|json|github] schema.yaml...
+//	lidy describe schema.yaml rule...
+//	lidy jsonschema export [-t target] schema.yaml
+//	lidy jsonschema import schema.json
+//	lidy gen go [-p package] schema.yaml
+//	lidy doc [-format md|html] [-t target] schema.yaml
+//	lidy graph [-format dot|mermaid] [-from rule] schema.yaml
+//	lidy sample [-seed n] [-depth n] [-n count] schema.yaml [rule]
+//	lidy mutate [-seed n] [-depth n] schema.yaml
+//	lidy lsp
+//
+// The exit code is the number of errors found, capped at 100, or 125 if the
+// command line is invalid.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ditrit/lidy"
+	"github.com/ditrit/lidy/lsp"
+)
+
+const (
+	// exitErrorCountMax -- the exit code when there are this many errors, or more
+	exitErrorCountMax = 100
+	// exitUsage -- the exit code when the command line is invalid
+	exitUsage = 125
+)
+
+const usage = `Usage:
+  lidy check -s schema.yaml [-t target] [-f text|json|github] file...
+      check YAML, JSON, HJSON or TOML files against a lidy schema
+  lidy schema [-f text|json|github] schema.yaml...
+      check lidy schemas
+  lidy describe schema.yaml rule...
+      describe rules of a lidy schema
+  lidy jsonschema export [-t target] schema.yaml
+      write a lidy schema as a JSON Schema (draft 2020-12) document
+  lidy jsonschema import schema.json
+      write a JSON Schema document as a lidy schema
+  lidy gen go [-p package] schema.yaml
+      write the Go types of the exported rules, and their builders
+  lidy doc [-format md|html] [-t target] schema.yaml
+      write the reference documentation of a lidy schema
+  lidy graph [-format dot|mermaid] [-from rule] schema.yaml
+      draw the rules of a lidy schema and their references, all of them or
+      those used by a rule
+  lidy sample [-seed n] [-depth n] [-n count] schema.yaml [rule]
+      write random YAML documents matching a rule, main by default
+  lidy mutate [-seed n] [-depth n] schema.yaml
+      write a .spec.yaml test file, with a sample of the rule main, and
+      variants of it that the schema must reject
+  lidy lsp
+      run the Language Server Protocol server, on stdin and stdout
+
+The exit code is the number of errors found, capped at 100, or 125 if the
+command line is invalid.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line given as argList, and returns the exit code
+func run(argList []string, stdout io.Writer, stderr io.Writer) int {
+	if len(argList) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch argList[0] {
+	case "check":
+		return runCheck(argList[1:], stdout, stderr)
+	case "schema":
+		return runSchema(argList[1:], stdout, stderr)
+	case "describe":
+		return runDescribe(argList[1:], stdout, stderr)
+	case "jsonschema":
+		return runJsonSchema(argList[1:], stdout, stderr)
+	case "gen":
+		return runGen(argList[1:], stdout, stderr)
+	case "doc":
+		return runDoc(argList[1:], stdout, stderr)
+	case "graph":
+		return runGraph(argList[1:], stdout, stderr)
+	case "sample":
+		return runSample(argList[1:], stdout, stderr)
+	case "mutate":
+		return runMutate(argList[1:], stdout, stderr)
+	case "lsp":
+		return runLsp(argList[1:], os.Stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "lidy: unknown command '%s'\n%s", argList[0], usage)
+	return exitUsage
+}
+
+func runCheck(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("check", stderr)
+	schemaFilename := flagSet.String("s", "", "the lidy schema file (required)")
+	target := flagSet.String("t", "main", "the rule of the schema to check the files against")
+	format := flagSet.String("f", "text", "the output format: text, json or github")
+
+	if !parseFlagSet(flagSet, argList) || !checkFormat(*format, stderr) {
+		return exitUsage
+	}
+
+	if *schemaFilename == "" || flagSet.NArg() == 0 {
+		fmt.Fprint(stderr, "lidy check: a schema (-s) and at least one file are required\n")
+		return exitUsage
+	}
+
+	report := newReport(*format, stdout, stderr)
+
+	parser, ok := loadParser(*schemaFilename, report)
+	if ok {
+		parser.Target(*target)
+
+		erl := parser.Schema()
+		report.addErrorList(*schemaFilename, erl)
+
+		if len(erl) == 0 {
+			for _, filename := range flagSet.Args() {
+				checkStream(parser, filename, report)
+			}
+		}
+	}
+
+	return report.flush()
+}
+
+// checkStream checks each document of a file, e.g. a Kubernetes-style
+// multi-document file. The JSON, HJSON and TOML files hold a single document
+func checkStream(parser lidy.Parser, filename string, report *tReport) {
+	if lidy.ContentDecoderFor(filename) != nil {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			report.addErrorList(filename, []error{err})
+			return
+		}
+
+		_, warningList, erl := parser.ParseWithWarnings(lidy.NewFile(filepath.ToSlash(filename), content))
+		report.addErrorList(filename, erl)
+		report.addWarningList(filename, warningList)
+		return
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		report.addErrorList(filename, []error{err})
+		return
+	}
+	defer file.Close()
+
+	documentList, erl := parser.ParseStream(file)
+	report.addErrorList(filename, erl)
+
+	if len(documentList) == 0 && len(erl) == 0 {
+		report.addErrorList(filename, []error{fmt.Errorf("yaml: the file is empty")})
+	}
+
+	for _, document := range documentList {
+		report.addErrorList(filename, document.Errors)
+		report.addWarningList(filename, document.Warnings)
+	}
+}
+
+func runSchema(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("schema", stderr)
+	format := flagSet.String("f", "text", "the output format: text, json or github")
+
+	if !parseFlagSet(flagSet, argList) || !checkFormat(*format, stderr) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() == 0 {
+		fmt.Fprint(stderr, "lidy schema: at least one schema file is required\n")
+		return exitUsage
+	}
+
+	report := newReport(*format, stdout, stderr)
+
+	for _, filename := range flagSet.Args() {
+		parser, ok := loadParser(filename, report)
+		if !ok {
+			continue
+		}
+
+		parser.Option(lidy.Option{
+			WarnUnusedRule:     true,
+			IgnoreExtraBuilder: true,
+		})
+
+		report.addErrorList(filename, parser.Schema())
+		report.addWarningList(filename, parser.SchemaWarnings())
+	}
+
+	return report.flush()
+}
+
+func runDescribe(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("describe", stderr)
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() < 2 {
+		fmt.Fprint(stderr, "lidy describe: a schema file and at least one rule are required\n")
+		return exitUsage
+	}
+
+	report := newReport("text", stdout, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		for k, ruleName := range flagSet.Args()[1:] {
+			description, erl := parser.Describe(ruleName)
+			if len(erl) > 0 {
+				report.addErrorList(filename, erl)
+				break
+			}
+
+			if k > 0 {
+				fmt.Fprintln(stdout)
+			}
+			fmt.Fprintln(stdout, description)
+		}
+	}
+
+	return report.flush()
+}
+
+func runJsonSchema(argList []string, stdout io.Writer, stderr io.Writer) int {
+	if len(argList) > 0 {
+		switch argList[0] {
+		case "export":
+			return runJsonSchemaExport(argList[1:], stdout, stderr)
+		case "import":
+			return runJsonSchemaImport(argList[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "lidy jsonschema: expected the subcommand export or import\n%s", usage)
+	return exitUsage
+}
+
+func runJsonSchemaExport(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("jsonschema export", stderr)
+	target := flagSet.String("t", "main", "the rule of the schema that the document must match")
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy jsonschema export: a single schema file is required\n")
+		return exitUsage
+	}
+
+	// the document is written to stdout, the errors and warnings to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		content, warningList, erl := parser.Target(*target).JsonSchema()
+		report.addErrorList(filename, erl)
+		report.addWarningList(filename, warningList)
+
+		if len(erl) == 0 {
+			stdout.Write(content)
+		}
+	}
+
+	return report.flush()
+}
+
+func runJsonSchemaImport(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("jsonschema import", stderr)
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy jsonschema import: a single JSON Schema file is required\n")
+		return exitUsage
+	}
+
+	// the lidy schema is written to stdout, the errors and warnings to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		report.addErrorList(filename, []error{err})
+		return report.flush()
+	}
+
+	schema, warningList, erl := lidy.FromJsonSchema(lidy.NewFile(filepath.ToSlash(filename), content))
+	report.addErrorList(filename, erl)
+	report.addWarningList(filename, warningList)
+
+	if len(erl) == 0 {
+		stdout.Write(schema)
+	}
+
+	return report.flush()
+}
+
+func runGen(argList []string, stdout io.Writer, stderr io.Writer) int {
+	if len(argList) > 0 && argList[0] == "go" {
+		return runGenGo(argList[1:], stdout, stderr)
+	}
+
+	fmt.Fprintf(stderr, "lidy gen: expected the subcommand go\n%s", usage)
+	return exitUsage
+}
+
+func runGenGo(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("gen go", stderr)
+	packageName := flagSet.String("p", "schema", "the name of the generated package")
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy gen go: a single schema file is required\n")
+		return exitUsage
+	}
+
+	// the source is written to stdout, the errors and warnings to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		content, warningList, erl := parser.GoCode(*packageName)
+		report.addErrorList(filename, erl)
+		report.addWarningList(filename, warningList)
+
+		if len(erl) == 0 {
+			stdout.Write(content)
+		}
+	}
+
+	return report.flush()
+}
+
+func runDoc(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("doc", stderr)
+	format := flagSet.String("format", "md", "the output format, md or html")
+	target := flagSet.String("t", "main", "the rule documented first")
+
+	// the flags may follow the schema file, e.g. `lidy doc schema.yaml -format html`
+	if !parseInterspersedFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy doc: a single schema file is required\n")
+		return exitUsage
+	}
+
+	switch *format {
+	case "md", "html":
+	default:
+		fmt.Fprintf(stderr, "lidy doc: unknown documentation format '%s', expected md or html\n", *format)
+		return exitUsage
+	}
+
+	// the documentation is written to stdout, the errors to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		content, erl := parser.Target(*target).Doc(lidy.DocFormat(*format))
+		report.addErrorList(filename, erl)
+
+		if len(erl) == 0 {
+			stdout.Write(content)
+		}
+	}
+
+	return report.flush()
+}
+
+func runGraph(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("graph", stderr)
+	format := flagSet.String("format", "dot", "the output format, dot or mermaid")
+	from := flagSet.String("from", "", "draw only the rules used by this rule")
+
+	// the flags may follow the schema file, e.g. `lidy graph schema.yaml -format mermaid`
+	if !parseInterspersedFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy graph: a single schema file is required\n")
+		return exitUsage
+	}
+
+	switch *format {
+	case "dot", "mermaid":
+	default:
+		fmt.Fprintf(stderr, "lidy graph: unknown graph format '%s', expected dot or mermaid\n", *format)
+		return exitUsage
+	}
+
+	// the graph is written to stdout, the errors to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		content, erl := parser.Graph(lidy.GraphFormat(*format), *from)
+		report.addErrorList(filename, erl)
+
+		if len(erl) == 0 {
+			stdout.Write(content)
+		}
+	}
+
+	return report.flush()
+}
+
+func runSample(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("sample", stderr)
+	seed := flagSet.Int64("seed", 1, "the seed of the random choices")
+	depth := flagSet.Int("depth", 0, "the depth from which the facultative entries are left out (0: the default, 5)")
+	count := flagSet.Int("n", 1, "the number of documents")
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() < 1 || flagSet.NArg() > 2 || *count < 1 {
+		fmt.Fprint(stderr, "lidy sample: a schema file, and optionally a rule, are required\n")
+		return exitUsage
+	}
+
+	// the documents are written to stdout, the errors to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		if flagSet.NArg() == 2 {
+			parser.Target(flagSet.Arg(1))
+		}
+
+		for k := 0; k < *count; k++ {
+			// each document has its own seed, so that any of them can be
+			// produced again alone
+			content, erl := parser.Sample(lidy.SampleOption{Seed: *seed + int64(k), MaxDepth: *depth})
+			if len(erl) > 0 {
+				report.addErrorList(filename, erl)
+				break
+			}
+
+			if k > 0 {
+				fmt.Fprintln(stdout, "---")
+			}
+			stdout.Write(content)
+		}
+	}
+
+	return report.flush()
+}
+
+func runMutate(argList []string, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("mutate", stderr)
+	seed := flagSet.Int64("seed", 1, "the seed of the random choices")
+	depth := flagSet.Int("depth", 0, "the depth from which the facultative entries are left out (0: the default, 5)")
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprint(stderr, "lidy mutate: a single schema file is required\n")
+		return exitUsage
+	}
+
+	// the spec file is written to stdout, the errors to stderr
+	report := newReport("text", stderr, stderr)
+
+	filename := flagSet.Arg(0)
+	parser, ok := loadParser(filename, report)
+	if ok {
+		mutantSet, erl := parser.Mutate(lidy.SampleOption{Seed: *seed, MaxDepth: *depth})
+		report.addErrorList(filename, erl)
+
+		if len(erl) == 0 {
+			description := fmt.Sprintf("mutants of %s (seed %d)", filepath.Base(filename), *seed)
+			stdout.Write(mutantSet.Spec(description, parser.Content()))
+		}
+	}
+
+	return report.flush()
+}
+
+func runLsp(argList []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flagSet := newFlagSet("lsp", stderr)
+
+	if !parseFlagSet(flagSet, argList) {
+		return exitUsage
+	}
+
+	if flagSet.NArg() != 0 {
+		fmt.Fprint(stderr, "lidy lsp: no argument expected; the server talks through stdin and stdout\n")
+		return exitUsage
+	}
+
+	err := lsp.Serve(stdin, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "lidy lsp: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// loadParser reads a schema file, and creates a parser for it, which can
+// import files from the file system
+func loadParser(filename string, report *tReport) (lidy.Parser, bool) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		report.addErrorList(filename, []error{err})
+		return nil, false
+	}
+
+	parser := lidy.NewParser(filepath.ToSlash(filename), content).Loader(
+		func(filename string) ([]byte, error) {
+			return os.ReadFile(filepath.FromSlash(filename))
+		},
+	)
+
+	return parser, true
+}
+
+func newFlagSet(command string, stderr io.Writer) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("lidy "+command, flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	return flagSet
+}
+
+func parseFlagSet(flagSet *flag.FlagSet, argList []string) bool {
+	return flagSet.Parse(argList) == nil
+}
+
+// parseInterspersedFlagSet parses the flags placed before, between or after
+// the arguments
+func parseInterspersedFlagSet(flagSet *flag.FlagSet, argList []string) bool {
+	positionalList := []string{}
+
+	for {
+		if flagSet.Parse(argList) != nil {
+			return false
+		}
+		if flagSet.NArg() == 0 {
+			break
+		}
+
+		positionalList = append(positionalList, flagSet.Arg(0))
+		argList = flagSet.Args()[1:]
+	}
+
+	return flagSet.Parse(append([]string{"--"}, positionalList...)) == nil
+}
+
+func checkFormat(format string, stderr io.Writer) bool {
+	switch format {
+	case "text", "json", "github":
+		return true
+	}
+
+	fmt.Fprintf(stderr, "lidy: unknown output format '%s', expected text, json or github\n", format)
+	return false
+}
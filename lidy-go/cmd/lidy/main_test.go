@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLidyCommand(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "lidy command Suite")
+}
+
+var _ = Describe("The lidy command", func() {
+	var directory string
+	var stdout, stderr *bytes.Buffer
+
+	write := func(filename string, content string) string {
+		path := filepath.Join(directory, filename)
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		directory, err = os.MkdirTemp("", "lidy")
+		Expect(err).NotTo(HaveOccurred())
+
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+
+		write("schema.yaml", `
+_import: { net: net.yaml }
+main:
+  _map:
+    name: string
+    port: net.port
+`)
+		write("net.yaml", `
+port: { _range: (1 <= int <= 65535) }
+`)
+		write("valid.yaml", "{ name: a, port: 80 }")
+		write("invalid.yaml", "{ name: 1, port: 0 }")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(directory)
+	})
+
+	path := func(filename string) string {
+		return filepath.Join(directory, filename)
+	}
+
+	Describe("check", func() {
+		It("exits with 0 for valid files", func() {
+			code := run([]string{"check", "-s", path("schema.yaml"), path("valid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(BeEmpty())
+		})
+
+		It("exits with the number of errors", func() {
+			code := run([]string{"check", "-s", path("schema.yaml"), path("valid.yaml"), path("invalid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(2))
+			Expect(stdout.String()).To(ContainSubstring("invalid.yaml:1:9: error: [kind] at /name (rule main) expected a YAML string, got !!int"))
+			Expect(stdout.String()).To(ContainSubstring("[range] at /port (rule net.port)"))
+			Expect(stderr.String()).To(ContainSubstring("2 error(s)"))
+		})
+
+		It("counts the files which cannot be read", func() {
+			code := run([]string{"check", "-s", path("schema.yaml"), path("missing.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+		})
+
+		It("checks each document of the multi-document files", func() {
+			write("stream.yaml", "{ name: a, port: 80 }\n---\n{ name: b, port: 0 }\n")
+
+			code := run([]string{"check", "-f", "json", "-s", path("schema.yaml"), path("stream.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+			Expect(stdout.String()).To(ContainSubstring(`"line": 3,`))
+			Expect(stdout.String()).To(ContainSubstring(`"document": 1,`))
+		})
+
+		It("checks the JSON, HJSON and TOML files", func() {
+			write("valid.json", `{ "name": "a", "port": 80 }`)
+			write("valid.hjson", "name: a\nport: 80\n")
+			write("invalid.toml", "name = \"b\"\nport = 0\n")
+
+			code := run([]string{"check", "-f", "json", "-s", path("schema.yaml"), path("valid.json"), path("valid.hjson"), path("invalid.toml")}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+			Expect(stdout.String()).To(ContainSubstring(`invalid.toml"`))
+			Expect(stdout.String()).To(ContainSubstring(`"line": 2,`))
+			Expect(stdout.String()).To(ContainSubstring(`"column": 8,`))
+		})
+
+		It("reports the empty files", func() {
+			write("empty.yaml", "")
+
+			Expect(run([]string{"check", "-s", path("schema.yaml"), path("empty.yaml")}, stdout, stderr)).To(Equal(1))
+		})
+
+		It("uses the given target", func() {
+			write("port.yaml", "8080")
+
+			code := run([]string{"check", "-s", path("schema.yaml"), "-t", "net.port", path("port.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+		})
+
+		It("writes json", func() {
+			code := run([]string{"check", "-f", "json", "-s", path("schema.yaml"), path("invalid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(2))
+
+			entryList := []map[string]interface{}{}
+			Expect(json.Unmarshal(stdout.Bytes(), &entryList)).To(Succeed())
+			Expect(entryList).To(HaveLen(2))
+			Expect(entryList[0]["severity"]).To(Equal("error"))
+			Expect(entryList[0]["code"]).To(Equal("kind"))
+			Expect(entryList[0]["line"]).To(BeNumerically("==", 1))
+		})
+
+		It("writes github annotations", func() {
+			code := run([]string{"check", "-f", "github", "-s", path("schema.yaml"), path("invalid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(2))
+			Expect(stdout.String()).To(HavePrefix("::error file=" + filepath.ToSlash(path("invalid.yaml")) + ",line=1,col=9,endLine=1,endColumn=10,title=lidy kind::"))
+		})
+
+		It("reports the schema errors", func() {
+			write("schema.yaml", "main: { _map: { a: unknown } }")
+
+			code := run([]string{"check", "-s", path("schema.yaml"), path("valid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+			Expect(stdout.String()).To(ContainSubstring("[unknownRule]"))
+		})
+
+		It("reports the use of deprecated expressions without counting them", func() {
+			write("schema.yaml", `
+main:
+  _mapFacultative:
+    name: string
+    port: { _rule: int, _deprecated: use name }
+`)
+
+			code := run([]string{"check", "-f", "json", "-s", path("schema.yaml"), path("valid.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+
+			entryList := []map[string]interface{}{}
+			Expect(json.Unmarshal(stdout.Bytes(), &entryList)).To(Succeed())
+			Expect(entryList).To(HaveLen(1))
+			Expect(entryList[0]["severity"]).To(Equal("warning"))
+			Expect(entryList[0]["path"]).To(Equal("/port"))
+			Expect(entryList[0]["line"]).To(BeNumerically("==", 1))
+			Expect(entryList[0]["message"]).To(ContainSubstring("use name"))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"check", path("valid.yaml")}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"check", "-f", "xml", "-s", path("schema.yaml"), path("valid.yaml")}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"frobnicate"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("schema", func() {
+		It("checks the schemas, and reports the warnings without counting them", func() {
+			write("unused.yaml", "main: string\nunused: int")
+
+			code := run([]string{"schema", path("schema.yaml"), path("unused.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring("warning"))
+			Expect(stdout.String()).To(ContainSubstring("'unused'"))
+		})
+
+		It("exits with the number of errors", func() {
+			write("broken.yaml", "main: { _map: { a: unknown, b: { _regex: '(' } } }")
+
+			code := run([]string{"schema", path("broken.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(2))
+		})
+	})
+
+	Describe("describe", func() {
+		It("prints the description of the rules", func() {
+			code := run([]string{"describe", path("schema.yaml"), "main", "net.port"}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(Equal("Rule main (_map)\n_map:\n  name: (string)\n  port: (net.port)\n\nRule net.port (range)\nrange (1 <= int <= 65535)\n"))
+		})
+
+		It("reports unknown rules", func() {
+			code := run([]string{"describe", path("schema.yaml"), "nope"}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+		})
+	})
+
+	Describe("jsonschema export", func() {
+		It("writes the JSON Schema document to stdout", func() {
+			code := run([]string{"jsonschema", "export", path("schema.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+
+			document := map[string]interface{}{}
+			Expect(json.Unmarshal(stdout.Bytes(), &document)).To(Succeed())
+			Expect(document["$ref"]).To(Equal("#/$defs/main"))
+			Expect(document["$defs"]).To(HaveKey("net.port"))
+		})
+
+		It("writes the warnings to stderr", func() {
+			write("numbers.yaml", "main: { _mapOf: { int: string } }")
+
+			code := run([]string{"jsonschema", "export", path("numbers.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring(`"$defs"`))
+			Expect(stderr.String()).To(ContainSubstring("warning"))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"jsonschema"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"jsonschema", "export"}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("jsonschema import", func() {
+		It("writes the lidy schema to stdout, and the warnings to stderr", func() {
+			write("schema.json", `{ "type": "object", "properties": { "id": { "type": "string", "minLength": 1 } }, "required": ["id"], "additionalProperties": false }`)
+
+			code := run([]string{"jsonschema", "import", path("schema.json")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(Equal("main:\n  _map:\n    id: string\n"))
+			Expect(stderr.String()).To(ContainSubstring("the keyword 'minLength' is not supported"))
+		})
+
+		It("exits with the number of errors", func() {
+			write("schema.json", `{ "type": "text" }`)
+
+			code := run([]string{"jsonschema", "import", path("schema.json")}, stdout, stderr)
+
+			Expect(code).To(Equal(1))
+			Expect(stdout.String()).To(BeEmpty())
+		})
+	})
+
+	Describe("gen go", func() {
+		It("writes the Go source to stdout", func() {
+			write("exported.yaml", "main: server\nserver:: { _map: { host: string } }")
+
+			code := run([]string{"gen", "go", "-p", "config", path("exported.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring("package config\n"))
+			Expect(stdout.String()).To(ContainSubstring("type Server struct {"))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"gen"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"gen", "go"}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("doc", func() {
+		It("writes the documentation of the schema and of its imports to stdout", func() {
+			code := run([]string{"doc", path("schema.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(HavePrefix("# schema.yaml\n"))
+			Expect(stdout.String()).To(ContainSubstring("| `port` | [`net.port`](#rule-net.port) | yes |"))
+			Expect(stdout.String()).To(ContainSubstring("Declared in `"))
+		})
+
+		It("accepts the format after the schema file", func() {
+			code := run([]string{"doc", path("schema.yaml"), "--format", "html"}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(HavePrefix("<!DOCTYPE html>"))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"doc"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"doc", "-format", "pdf", path("schema.yaml")}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("graph", func() {
+		It("writes the DOT graph of the rules to stdout", func() {
+			code := run([]string{"graph", path("schema.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(HavePrefix(`digraph "schema.yaml" {`))
+			Expect(stdout.String()).To(ContainSubstring(`"main" -> "net.port" [label="port"];`))
+		})
+
+		It("accepts the format and the first rule after the schema file", func() {
+			code := run([]string{"graph", path("schema.yaml"), "--format", "mermaid", "--from", "net.port"}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring("flowchart LR\n"))
+			Expect(stdout.String()).To(ContainSubstring(`n0["net.port"]`))
+			Expect(stdout.String()).NotTo(ContainSubstring(`"main"`))
+		})
+
+		It("reports the unknown rules", func() {
+			Expect(run([]string{"graph", path("schema.yaml"), "-from", "nothing"}, stdout, stderr)).To(Equal(1))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"graph"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"graph", "-format", "svg", path("schema.yaml")}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("sample", func() {
+		It("writes documents matching the schema", func() {
+			code := run([]string{"sample", "-n", "3", path("schema.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring("\n---\nname: "))
+
+			for k, document := range strings.Split(stdout.String(), "---\n") {
+				sample := write(fmt.Sprintf("sample%d.yaml", k), document)
+				Expect(run([]string{"check", "-s", path("schema.yaml"), sample}, stdout, stderr)).To(Equal(0))
+			}
+		})
+
+		It("samples the given rule with the given seed", func() {
+			Expect(run([]string{"sample", "-seed", "4", path("schema.yaml"), "net.port"}, stdout, stderr)).To(Equal(0))
+			first := stdout.String()
+			Expect(first).To(MatchRegexp(`^[0-9]+\n$`))
+
+			stdout.Reset()
+			Expect(run([]string{"sample", "-seed", "4", path("schema.yaml"), "net.port"}, stdout, stderr)).To(Equal(0))
+			Expect(stdout.String()).To(Equal(first))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"sample"}, stdout, stderr)).To(Equal(exitUsage))
+			Expect(run([]string{"sample", "-n", "0", path("schema.yaml")}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("mutate", func() {
+		It("writes a spec file with the sample and the mutants", func() {
+			code := run([]string{"mutate", "-seed", "3", path("schema.yaml")}, stdout, stderr)
+
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(HavePrefix("mutants of schema.yaml (seed 3):\n  schema: |-\n"))
+			Expect(stdout.String()).To(ContainSubstring("  accept the sample:\n"))
+			Expect(stdout.String()).To(ContainSubstring("at /port (rule net.port, range)"))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"mutate"}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+
+	Describe("lsp", func() {
+		It("answers the client on stdout, until it exits", func() {
+			frame := func(body string) string {
+				return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+			}
+			stdin := strings.NewReader(
+				frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+					frame(`{"jsonrpc":"2.0","method":"exit"}`),
+			)
+
+			Expect(runLsp(nil, stdin, stdout, stderr)).To(Equal(0))
+			Expect(stdout.String()).To(HavePrefix("Content-Length: "))
+			Expect(stdout.String()).To(ContainSubstring(`"hoverProvider":true`))
+		})
+
+		It("rejects an invalid command line", func() {
+			Expect(run([]string{"lsp", "schema.yaml"}, stdout, stderr)).To(Equal(exitUsage))
+		})
+	})
+})
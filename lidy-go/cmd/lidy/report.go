@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ditrit/lidy"
+)
+
+// report.go
+//
+// Collect the errors and warnings, and write them in the requested format
+
+type tReport struct {
+	format     string
+	stdout     io.Writer
+	stderr     io.Writer
+	entryList  []tEntry
+	errorCount int
+}
+
+// tEntry -- one error or warning, as written in the json format
+type tEntry struct {
+	Severity  string   `json:"severity"`
+	File      string   `json:"file"`
+	Line      int      `json:"line,omitempty"`
+	Column    int      `json:"column,omitempty"`
+	LineEnd   int      `json:"lineEnd,omitempty"`
+	ColumnEnd int      `json:"columnEnd,omitempty"`
+	Document  int      `json:"document,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	Rule      string   `json:"rule,omitempty"`
+	Code      string   `json:"code,omitempty"`
+	Expected  string   `json:"expected,omitempty"`
+	Actual    string   `json:"actual,omitempty"`
+	Message   string   `json:"message"`
+	Nested    []tEntry `json:"nested,omitempty"`
+}
+
+func newReport(format string, stdout io.Writer, stderr io.Writer) *tReport {
+	return &tReport{
+		format:    format,
+		stdout:    stdout,
+		stderr:    stderr,
+		entryList: []tEntry{},
+	}
+}
+
+func (report *tReport) addErrorList(filename string, erl []error) {
+	for _, err := range erl {
+		report.entryList = append(report.entryList, newEntry("error", filename, err))
+	}
+	report.errorCount += len(erl)
+}
+
+func (report *tReport) addWarningList(filename string, warningList []lidy.Warning) {
+	for _, warning := range warningList {
+		report.entryList = append(report.entryList, newEntry("warning", filename, warning))
+	}
+}
+
+// newEntry extracts the position and the details of lidy errors
+func newEntry(severity string, filename string, err error) tEntry {
+	entry := tEntry{
+		Severity: severity,
+		File:     filename,
+		Message:  err.Error(),
+	}
+
+	var position lidy.Position
+	var nestedList []error
+
+	var contentError *lidy.ContentError
+	var schemaError *lidy.SchemaError
+	var contentWarning *lidy.ContentWarning
+
+	switch {
+	case errors.As(err, &contentError):
+		position = contentError
+		entry.Path = contentError.Path
+		entry.Rule = contentError.RuleName
+		entry.Code = string(contentError.Code)
+		entry.Expected = contentError.Expected
+		entry.Actual = contentError.Actual
+		nestedList = contentError.Nested
+	case errors.As(err, &schemaError):
+		position = schemaError
+		entry.Path = schemaError.Path
+		entry.Rule = schemaError.RuleName
+		entry.Code = string(schemaError.Code)
+		entry.Expected = schemaError.Expected
+		entry.Actual = schemaError.Actual
+	case errors.As(err, &contentWarning):
+		position = contentWarning
+		entry.Path = contentWarning.Path
+		entry.Rule = contentWarning.RuleName
+	}
+
+	if position != nil {
+		entry.File = position.Filename()
+		entry.Line = position.Line()
+		entry.Column = position.Column()
+		entry.LineEnd = position.LineEnd()
+		entry.ColumnEnd = position.ColumnEnd()
+		entry.Document = position.Document()
+	}
+
+	for _, nested := range nestedList {
+		entry.Nested = append(entry.Nested, newEntry(severity, entry.File, nested))
+	}
+
+	return entry
+}
+
+// flush writes the entries, and returns the exit code
+func (report *tReport) flush() int {
+	switch report.format {
+	case "json":
+		encoder := json.NewEncoder(report.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report.entryList)
+	case "github":
+		for _, entry := range report.entryList {
+			fmt.Fprintln(report.stdout, entry.github())
+		}
+	default:
+		for _, entry := range report.entryList {
+			fmt.Fprintln(report.stdout, entry.text(""))
+		}
+		if report.errorCount > 0 {
+			fmt.Fprintf(report.stderr, "lidy: %d error(s)\n", report.errorCount)
+		}
+	}
+
+	if report.errorCount > exitErrorCountMax {
+		return exitErrorCountMax
+	}
+	return report.errorCount
+}
+
+// summary is the message of the entry, without the position
+func (entry tEntry) summary() string {
+	if entry.Expected == "" {
+		return entry.Message
+	}
+
+	partList := []string{}
+	if entry.Code != "" {
+		partList = append(partList, "["+entry.Code+"]")
+	}
+	if entry.Path != "" {
+		partList = append(partList, "at "+entry.Path)
+	}
+	if entry.Rule != "" {
+		partList = append(partList, "(rule "+entry.Rule+")")
+	}
+	// the descriptions of the expressions may span several lines
+	expected := strings.Join(strings.Fields(entry.Expected), " ")
+	if entry.Actual != "" {
+		partList = append(partList, "expected "+expected+", got "+entry.Actual)
+	} else {
+		partList = append(partList, "expected "+expected)
+	}
+
+	return strings.Join(partList, " ")
+}
+
+// text formats the entry as `file:line:column: severity: summary`, followed
+// by its nested entries
+func (entry tEntry) text(indent string) string {
+	location := entry.File
+	if entry.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", entry.File, entry.Line, entry.Column)
+	}
+
+	lineList := []string{fmt.Sprintf("%s%s: %s: %s", indent, location, entry.Severity, entry.summary())}
+	for _, nested := range entry.Nested {
+		lineList = append(lineList, nested.text(indent+"  "))
+	}
+
+	return strings.Join(lineList, "\n")
+}
+
+// github formats the entry as a GitHub Actions workflow command, which
+// produces an annotation
+func (entry tEntry) github() string {
+	propertyList := []string{"file=" + escapeGithubProperty(entry.File)}
+	if entry.Line > 0 {
+		propertyList = append(propertyList,
+			fmt.Sprintf("line=%d", entry.Line),
+			fmt.Sprintf("col=%d", entry.Column),
+			fmt.Sprintf("endLine=%d", entry.LineEnd),
+			fmt.Sprintf("endColumn=%d", entry.ColumnEnd),
+		)
+	}
+	if entry.Code != "" {
+		propertyList = append(propertyList, "title="+escapeGithubProperty("lidy "+entry.Code))
+	}
+
+	message := entry.summary()
+	for _, nested := range entry.Nested {
+		message += "\n" + nested.text("  ")
+	}
+
+	return fmt.Sprintf("::%s %s::%s", entry.Severity, strings.Join(propertyList, ","), escapeGithubData(message))
+}
+
+func escapeGithubData(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(text)
+}
+
+func escapeGithubProperty(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(text)
+}
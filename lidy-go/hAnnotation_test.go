@@ -0,0 +1,184 @@
+package lidy_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hAnnotation_test.go
+
+var _ = Describe("The annotation keywords", func() {
+	schema := []byte(`main:
+  _description: A server
+  _map:
+    host: { _rule: string, _description: The name of the host, _examples: [localhost] }
+  _mapFacultative:
+    port: { _range: (1 <= int), _deprecated: use address }
+    address: address
+address:
+  _description: An IP address
+  _regex: '^[0-9.]+$'
+legacy:
+  _deprecated: true
+  _in: [a, b]
+`)
+
+	parser := func() lidy.Parser {
+		return lidy.NewParser("schema.yaml", schema)
+	}
+
+	It("do not constrain the content", func() {
+		_, erl := parser().Parse(lidy.NewFile("content.yaml", []byte("host: a\naddress: 1.2.3.4\n")))
+		Expect(erl).To(BeEmpty())
+
+		_, erl = parser().Parse(lidy.NewFile("content.yaml", []byte("host: 1\n")))
+		Expect(erl).To(HaveLen(1))
+	})
+
+	Describe("Annotation", func() {
+		It("gives the annotation of a rule", func() {
+			annotation, erl := parser().Annotation("/main")
+			Expect(erl).To(BeEmpty())
+			Expect(annotation.Description).To(Equal("A server"))
+		})
+
+		It("gives the annotation of a property", func() {
+			annotation, erl := parser().Annotation("/main/_map/host")
+			Expect(erl).To(BeEmpty())
+			Expect(annotation.Description).To(Equal("The name of the host"))
+			Expect(annotation.Examples).To(Equal([]interface{}{"localhost"}))
+
+			annotation, _ = parser().Annotation("/main/_mapFacultative/port")
+			Expect(annotation.Deprecated).To(BeTrue())
+			Expect(annotation.DeprecationMessage).To(Equal("use address"))
+		})
+
+		It("follows the rule references without annotation", func() {
+			annotation, erl := parser().Annotation("/main/_mapFacultative/address")
+			Expect(erl).To(BeEmpty())
+			Expect(annotation.Description).To(Equal("An IP address"))
+		})
+
+		It("reports the paths which do not exist", func() {
+			_, erl := parser().Annotation("/main/_map/nothing")
+			Expect(erl).To(HaveLen(1))
+
+			_, erl = parser().Annotation("/nothing")
+			Expect(erl).To(HaveLen(1))
+		})
+	})
+
+	Describe("_rule", func() {
+		It("references a rule, including a rule declared later", func() {
+			parser := lidy.NewParser("schema.yaml", []byte(`main: { _rule: later, _description: The later rule }
+later: { _listOf: int }
+`))
+			_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte("[1, 2]")))
+			Expect(erl).To(BeEmpty())
+
+			_, erl = parser.Parse(lidy.NewFile("content.yaml", []byte("[a]")))
+			Expect(erl).To(HaveLen(1))
+		})
+
+		It("annotates only the given use of the rule", func() {
+			parser := lidy.NewParser("schema.yaml", []byte(`main:
+  _map:
+    old: { _rule: name, _deprecated: true }
+    new: name
+name: string
+`))
+			_, warningList, erl := parser.ParseWithWarnings(lidy.NewFile("content.yaml", []byte("old: a\nnew: b\n")))
+			Expect(erl).To(BeEmpty())
+			Expect(warningList).To(HaveLen(1))
+			Expect(warningList[0].(*lidy.ContentWarning).Path).To(Equal("/old"))
+		})
+
+		It("rejects the unknown rules", func() {
+			erl := lidy.NewParser("schema.yaml", []byte("main: { _rule: nothing }")).Schema()
+			Expect(erl).To(HaveLen(1))
+			Expect(erl[0].(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeUnknownRule))
+		})
+	})
+
+	Describe("_deprecated", func() {
+		It("warns about the content nodes using a deprecated property", func() {
+			result, warningList, erl := parser().ParseWithWarnings(lidy.NewFile("content.yaml", []byte("host: a\nport: 80\n")))
+			Expect(erl).To(BeEmpty())
+			Expect(result).NotTo(BeNil())
+			Expect(warningList).To(HaveLen(1))
+
+			warning := warningList[0].(*lidy.ContentWarning)
+			Expect(warning.Path).To(Equal("/port"))
+			Expect(warning.RuleName).To(Equal("main"))
+			Expect(warning.Message).To(Equal("use address"))
+			Expect([]int{warning.Line(), warning.Column()}).To(Equal([]int{2, 7}))
+		})
+
+		It("warns about the content nodes using a deprecated rule", func() {
+			_, warningList, erl := parser().Target("legacy").ParseWithWarnings(lidy.NewFile("content.yaml", []byte("a")))
+			Expect(erl).To(BeEmpty())
+			Expect(warningList).To(HaveLen(1))
+			Expect(warningList[0].(*lidy.ContentWarning).RuleName).To(Equal("legacy"))
+		})
+
+		It("does not warn about the options of _oneOf which did not match", func() {
+			_, warningList, erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _oneOf:
+    - { _regex: '^old', _deprecated: true }
+    - string
+`)).ParseWithWarnings(lidy.NewFile("content.yaml", []byte("new")))
+			Expect(erl).To(BeEmpty())
+			Expect(warningList).To(BeEmpty())
+		})
+
+		It("does not warn about the defaults", func() {
+			_, warningList, erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _mapFacultative: { port: { _rule: int, _deprecated: true } }
+  _default: { port: 80 }
+`)).ParseWithWarnings(lidy.NewFile("content.yaml", []byte("{}")))
+			Expect(erl).To(BeEmpty())
+			Expect(warningList).To(BeEmpty())
+		})
+
+		It("is ignored by Parse", func() {
+			_, erl := parser().Parse(lidy.NewFile("content.yaml", []byte("host: a\nport: 80\n")))
+			Expect(erl).To(BeEmpty())
+		})
+	})
+
+	It("are rejected if they have the wrong kind", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _description: [a]
+  _examples: a
+  _deprecated: 1
+  _regex: a
+`)).Schema()
+
+		Expect(erl).To(HaveLen(3))
+		for _, err := range erl {
+			Expect(err.(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeKind))
+		}
+	})
+
+	It("are exported to JSON schema", func() {
+		content, _, erl := parser().JsonSchema()
+		Expect(erl).To(BeEmpty())
+
+		document := map[string]interface{}{}
+		Expect(json.Unmarshal(content, &document)).To(Succeed())
+
+		definitionMap := document["$defs"].(map[string]interface{})
+		Expect(definitionMap["main"].(map[string]interface{})["description"]).To(Equal("A server"))
+		Expect(definitionMap["legacy"].(map[string]interface{})["deprecated"]).To(Equal(true))
+	})
+
+	It("are described", func() {
+		description, erl := parser().Describe("legacy")
+		Expect(erl).To(BeEmpty())
+		Expect(strings.Contains(description, "_deprecated")).To(BeTrue())
+	})
+})
@@ -0,0 +1,89 @@
+package lidy_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hConcurrency_test.go
+//
+// Run with `go test -race` for these tests to be meaningful
+
+var _ = Describe("A parser used from several goroutines", func() {
+	const goroutineCount = 64
+
+	newParser := func() lidy.Parser {
+		return lidy.NewParser("schema.yaml", []byte(`
+main:
+  _map:
+    name: string
+    servers: { _listOf: server }
+server:
+  _map:
+    host: string
+    port: { _range: (1 <= int <= 65535) }
+`)).Option(lidy.Option{
+			StopAtFirstError: true,
+		})
+	}
+
+	// parseAll parses one file per goroutine, every other file being invalid,
+	// and returns the errors of each file
+	parseAll := func(parser lidy.Parser) [][]error {
+		erlList := make([][]error, goroutineCount)
+
+		waitGroup := sync.WaitGroup{}
+		for k := 0; k < goroutineCount; k++ {
+			waitGroup.Add(1)
+			go func(k int) {
+				defer GinkgoRecover()
+				defer waitGroup.Done()
+
+				port := k + 1
+				if k%2 == 1 {
+					port = 0
+				}
+
+				content := fmt.Sprintf("{ name: n%d, servers: [{ host: h, port: %d }] }", k, port)
+				_, erlList[k] = parser.Parse(lidy.NewFile(fmt.Sprintf("content%d.yaml", k), []byte(content)))
+			}(k)
+		}
+		waitGroup.Wait()
+
+		return erlList
+	}
+
+	checkAll := func(erlList [][]error) {
+		for k, erl := range erlList {
+			if k%2 == 0 {
+				Expect(erl).To(BeEmpty())
+				continue
+			}
+
+			Expect(erl).To(HaveLen(1))
+
+			var contentError *lidy.ContentError
+			Expect(errors.As(erl[0], &contentError)).To(BeTrue())
+			Expect(contentError.Filename()).To(Equal(fmt.Sprintf("content%d.yaml", k)))
+			Expect(contentError.Path).To(Equal("/servers/0/port"))
+			Expect(contentError.RuleName).To(Equal("server"))
+		}
+	}
+
+	It("compiles the schema once, on the first concurrent calls", func() {
+		checkAll(parseAll(newParser()))
+	})
+
+	It("reuses the compiled schema", func() {
+		parser := newParser()
+		Expect(parser.Schema()).To(BeEmpty())
+
+		checkAll(parseAll(parser))
+		checkAll(parseAll(parser))
+	})
+})
@@ -0,0 +1,260 @@
+package lidy_test
+
+import (
+	"encoding/json"
+
+	"github.com/ditrit/lidy"
+	"github.com/hjson/hjson-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+// hContentFormat_test.go
+
+var _ = Describe("The content decoders", func() {
+	parser := func() lidy.Parser {
+		return lidy.NewParser("schema.yaml", []byte(`main:
+  _map:
+    name: string
+    ports: { _listOf: { _range: (1 <= int <= 65535) } }
+  _mapFacultative:
+    ratio: float
+    enabled: boolean
+    since: timestamp
+    owner: { _map: { name: string } }
+`))
+	}
+
+	// position gives the line and the column of a content error
+	position := func(erl []error) []int {
+		Expect(erl).To(HaveLen(1))
+		contentError, ok := erl[0].(*lidy.ContentError)
+		Expect(ok).To(BeTrue(), "%v", erl[0])
+		return []int{contentError.Line(), contentError.Column()}
+	}
+
+	// sameAs compares a decoded document with the JSON encoding of a value
+	sameAs := func(node yaml.Node, value interface{}) {
+		var decoded interface{}
+		Expect(node.Decode(&decoded)).To(Succeed())
+
+		actual, err := json.Marshal(decoded)
+		Expect(err).NotTo(HaveOccurred())
+		expected, err := json.Marshal(value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(actual).To(MatchJSON(expected))
+	}
+
+	It("chooses the decoder by the file extension", func() {
+		Expect(lidy.ContentDecoderFor("a/config.json")).NotTo(BeNil())
+		Expect(lidy.ContentDecoderFor("config.HJSON")).NotTo(BeNil())
+		Expect(lidy.ContentDecoderFor("config.toml")).NotTo(BeNil())
+		Expect(lidy.ContentDecoderFor("config.yaml")).To(BeNil())
+		Expect(lidy.ContentDecoderFor("config")).To(BeNil())
+
+		_, erl := parser().Parse(lidy.NewFile("config.toml", []byte("name = \"a\"\nports = [80, 0]\n")))
+		Expect(position(erl)).To(Equal([]int{2, 14}))
+	})
+
+	It("uses the decoder given to NewFile", func() {
+		result, erl := parser().Parse(lidy.NewFile("config", []byte(`name = "a"`+"\nports = []\n"), lidy.DecodeToml))
+		Expect(erl).To(BeEmpty())
+		Expect(result.Data().(lidy.MapData).Map["name"].Data()).To(Equal("a"))
+
+		_, erl = parser().Parse(lidy.NewFile("config.json", []byte("name: a\nports: []\n"), lidy.DecodeYaml))
+		Expect(erl).To(BeEmpty())
+	})
+
+	Describe("JSON", func() {
+		It("gives the position of the values", func() {
+			_, erl := parser().Parse(lidy.NewFile("config.json", []byte(`{
+  "name": "a",
+  "ports": [80, 443, 70000]
+}`)))
+			Expect(position(erl)).To(Equal([]int{3, 22}))
+		})
+
+		It("gives the types of the values", func() {
+			result, erl := parser().Parse(lidy.NewFile("config.json", []byte(`{"name": "\u00e9\ud83d\ude00", "ports": [], "ratio": 1e3, "enabled": true, "owner": {"name": "b"}}`)))
+			Expect(erl).To(BeEmpty())
+
+			dataMap := result.Data().(lidy.MapData).Map
+			Expect(dataMap["name"].Data()).To(Equal("é😀"))
+			Expect(dataMap["ratio"].Data()).To(Equal(1000.0))
+			Expect(dataMap["enabled"].Data()).To(Equal(true))
+
+			_, erl = parser().Parse(lidy.NewFile("config.json", []byte(`{"name": "a", "ports": ["80"]}`)))
+			Expect(erl).To(HaveLen(1))
+		})
+
+		It("rejects the invalid documents with their position", func() {
+			for text, message := range map[string]string{
+				"":                         "json: line 1, column 1: the file is empty",
+				`{"name": "a", "name": 1}`: `json: line 1, column 15: duplicate key "name"`,
+				"[1,\n 2,]":                "json: line 2, column 4: unexpected character ']', expected ',' or ']'",
+				`{name: "a"}`:              "json: line 1, column 2: unexpected character 'n', expected a string key",
+				`{"a": 01}`:                "json: line 1, column 7: unexpected character '0', expected a JSON value",
+				"{} {}":                    "json: line 1, column 4: unexpected character '{', expected the end of the file",
+			} {
+				_, err := lidy.DecodeJson([]byte(text))
+				Expect(err).To(MatchError(message), text)
+			}
+		})
+	})
+
+	Describe("HJSON", func() {
+		text := `# the server
+name: my server
+ports: [
+  80
+  443 // https
+]
+ratio: 0.5
+owner: { name: 'b' }
+notes:
+  '''
+  first line
+    second line
+  '''
+quoted: "tab\tand\u00e9"
+`
+
+		It("decodes like the hjson package", func() {
+			node, err := lidy.DecodeHjson([]byte(text))
+			Expect(err).NotTo(HaveOccurred())
+
+			var value interface{}
+			Expect(hjson.Unmarshal([]byte(text), &value)).To(Succeed())
+			sameAs(node, value)
+		})
+
+		It("gives the position of the values", func() {
+			_, erl := parser().Parse(lidy.NewFile("config.hjson", []byte("name: a\nports: [\n  80\n  0\n]\n")))
+			Expect(position(erl)).To(Equal([]int{4, 3}))
+		})
+
+		It("reads the quoteless strings up to the end of the line", func() {
+			node, err := lidy.DecodeHjson([]byte("a: 80 ports\nb: 80\nc: true, d: null\ne: true or false\n"))
+			Expect(err).NotTo(HaveOccurred())
+			sameAs(node, map[string]interface{}{"a": "80 ports", "b": 80, "c": true, "d": nil, "e": "true or false"})
+		})
+
+		It("accepts the root braces and the trailing commas", func() {
+			node, err := lidy.DecodeHjson([]byte("{\n  a: 1,\n  b: [2, 3,],\n}\n"))
+			Expect(err).NotTo(HaveOccurred())
+			sameAs(node, map[string]interface{}{"a": 1, "b": []int{2, 3}})
+		})
+
+		It("rejects the duplicate keys", func() {
+			_, err := lidy.DecodeHjson([]byte("a: 1\na: 2\n"))
+			Expect(err).To(MatchError(`hjson: line 2, column 1: duplicate key "a"`))
+		})
+	})
+
+	Describe("TOML", func() {
+		It("decodes the tables, the arrays of tables and the dotted keys", func() {
+			node, err := lidy.DecodeToml([]byte(`# a comment
+title = "servers"
+owner.name = "Tom"
+
+[database]
+ports = [
+  8000, # the first
+  8001,
+]
+limits = { cpu = 2, memory.max = 1_024 }
+
+[[servers]]
+name = 'alpha'
+
+[[servers]]
+name = "beta"
+
+[servers.labels]
+zone = "eu"
+`))
+			Expect(err).NotTo(HaveOccurred())
+			sameAs(node, map[string]interface{}{
+				"title": "servers",
+				"owner": map[string]interface{}{"name": "Tom"},
+				"database": map[string]interface{}{
+					"ports":  []int{8000, 8001},
+					"limits": map[string]interface{}{"cpu": 2, "memory": map[string]interface{}{"max": 1024}},
+				},
+				"servers": []interface{}{
+					map[string]interface{}{"name": "alpha"},
+					map[string]interface{}{"name": "beta", "labels": map[string]interface{}{"zone": "eu"}},
+				},
+			})
+		})
+
+		It("decodes the strings, the numbers and the dates", func() {
+			node, err := lidy.DecodeToml([]byte(`basic = "a\tb\u00e9"
+literal = 'C:\path'
+multiline = """
+one \
+  two"""
+rawMultiline = '''
+line 1
+line 2'''
+hexadecimal = 0xff
+octal = 0o17
+binary = 0b101
+float = -6.5e-1
+infinity = -inf
+date = 1979-05-27
+dateTime = 1979-05-27 07:32:00Z
+time = 07:32:00
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			valueMap := map[string]*yaml.Node{}
+			root := node.Content[0]
+			for k := 0; k < len(root.Content); k += 2 {
+				valueMap[root.Content[k].Value] = root.Content[k+1]
+			}
+
+			type scalar struct{ tag, value string }
+			check := func(key, tag, value string) {
+				Expect(scalar{valueMap[key].Tag, valueMap[key].Value}).To(Equal(scalar{tag, value}), key)
+			}
+
+			check("basic", "!!str", "a\tbé")
+			check("literal", "!!str", `C:\path`)
+			check("multiline", "!!str", "one two")
+			check("rawMultiline", "!!str", "line 1\nline 2")
+			check("hexadecimal", "!!int", "255")
+			check("octal", "!!int", "15")
+			check("binary", "!!int", "5")
+			check("float", "!!float", "-6.5e-1")
+			check("infinity", "!!float", "-.inf")
+			check("date", "!!timestamp", "1979-05-27")
+			check("dateTime", "!!timestamp", "1979-05-27T07:32:00Z")
+			check("time", "!!str", "07:32:00")
+		})
+
+		It("gives the position of the keys and the values", func() {
+			_, erl := parser().Parse(lidy.NewFile("config.toml", []byte("name = \"a\"\nports = []\n\n[owner]\nname = 1\n")))
+			Expect(position(erl)).To(Equal([]int{5, 8}))
+
+			_, erl = parser().Parse(lidy.NewFile("config.toml", []byte("name = \"a\"\nports = []\n\n[owner]\n")))
+			Expect(position(erl)).To(Equal([]int{4, 1}))
+		})
+
+		It("rejects the duplicate keys and the tables defined twice", func() {
+			for text, message := range map[string]string{
+				"":                       "toml: line 1, column 1: the file is empty",
+				"a = 1\na = 2\n":         `toml: line 2, column 1: duplicate key "a"`,
+				"[a]\n[a]\n":             "toml: line 2, column 1: table [a] is already defined",
+				"a.b = 1\n[a]\n":         "toml: line 2, column 1: table [a] is already defined",
+				"a = { b = 1 }\na.c = 2": `toml: line 2, column 1: key "a" is already defined`,
+				"a = 1 b = 2":            "toml: line 1, column 7: unexpected character 'b', expected the end of the line",
+				"a = 0x_1":               `toml: line 1, column 5: invalid value "0x_1"`,
+			} {
+				_, err := lidy.DecodeToml([]byte(text))
+				Expect(err).To(MatchError(message), text)
+			}
+		})
+	})
+})
@@ -0,0 +1,182 @@
+package lidy_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hDecode_test.go
+
+type tDecodeServer struct {
+	Host    string
+	Port    uint16
+	Primary *bool `lidy:"primary"`
+}
+
+type tDecodeBase struct {
+	Name string `lidy:"name"`
+}
+
+type tDecodeTopology struct {
+	tDecodeBase
+	Servers  []tDecodeServer   `lidy:"servers"`
+	Labels   map[string]string `lidy:"labels"`
+	Weights  [2]float64        `lidy:"weights"`
+	Created  time.Time         `lidy:"created"`
+	Owner    *tDecodeOwner     `lidy:"owner"`
+	Extra    interface{}       `lidy:"extra"`
+	Raw      lidy.Result       `lidy:"raw"`
+	Ignored  string            `lidy:"-"`
+	Optional *string           `lidy:"optional"`
+}
+
+type tDecodeOwner struct {
+	Initial string
+}
+
+var _ = Describe("Decode", func() {
+	parser := lidy.NewParser("schema.yaml", []byte(`
+main:
+  _merge: [base]
+  _map:
+    servers: { _listOf: server }
+    labels: { _mapOf: { string: string } }
+    weights: { _list: [float, float] }
+    created: timestamp
+    owner: owner
+    extra: { _mapOf: { string: { _listOf: int } } }
+    raw: string
+  _mapFacultative:
+    optional: string
+base:
+  _map:
+    name: string
+server:
+  _map:
+    host: string
+    port: int
+  _mapFacultative:
+    primary: boolean
+owner:: string
+`)).With(map[string]lidy.Builder{
+		"owner": func(input lidy.Result) (interface{}, []error) {
+			return tDecodeOwner{Initial: input.Data().(string)[:1]}, nil
+		},
+	})
+
+	content := `
+name: topo
+servers:
+  - { host: a, port: 80, primary: true }
+  - { host: b, port: 443 }
+labels: { env: prod }
+weights: [0.5, 2]
+created: 2020-06-17T10:13:46Z
+owner: Alice
+extra: { a: [1, 2] }
+raw: text
+`
+
+	It("fills structs, slices, arrays, maps, pointers and interfaces", func() {
+		result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+		Expect(erl).To(BeEmpty())
+
+		topology := tDecodeTopology{Ignored: "kept"}
+		Expect(lidy.Decode(result, &topology)).To(Succeed())
+
+		Expect(topology.Name).To(Equal("topo"))
+		Expect(topology.Servers).To(HaveLen(2))
+		Expect(topology.Servers[0].Host).To(Equal("a"))
+		Expect(topology.Servers[0].Port).To(Equal(uint16(80)))
+		Expect(*topology.Servers[0].Primary).To(BeTrue())
+		Expect(topology.Servers[1].Primary).To(BeNil())
+		Expect(topology.Labels).To(Equal(map[string]string{"env": "prod"}))
+		Expect(topology.Weights).To(Equal([2]float64{0.5, 2}))
+		Expect(topology.Created).To(Equal(time.Date(2020, 6, 17, 10, 13, 46, 0, time.UTC)))
+		Expect(topology.Extra).To(Equal(map[string]interface{}{"a": []interface{}{1, 2}}))
+		Expect(topology.Raw.Data()).To(Equal("text"))
+		Expect(topology.Raw.Line()).To(Equal(11))
+		Expect(topology.Ignored).To(Equal("kept"))
+		Expect(topology.Optional).To(BeNil())
+	})
+
+	It("assigns the outputs of the builders", func() {
+		result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+		Expect(erl).To(BeEmpty())
+
+		topology := tDecodeTopology{}
+		Expect(lidy.Decode(result, &topology)).To(Succeed())
+
+		Expect(topology.Owner).To(Equal(&tDecodeOwner{Initial: "A"}))
+
+		wrong := struct {
+			Owner string `lidy:"owner"`
+		}{}
+		err := lidy.Decode(result, &wrong)
+
+		var decodeError *lidy.DecodeError
+		Expect(errors.As(err, &decodeError)).To(BeTrue())
+		Expect(decodeError.Path).To(Equal("/owner"))
+		Expect(decodeError.Line()).To(Equal(9))
+		Expect(err.Error()).To(ContainSubstring("built by rule 'owner'"))
+	})
+
+	It("reports the position of the node which cannot be decoded", func() {
+		result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+		Expect(erl).To(BeEmpty())
+
+		wrong := struct {
+			Servers []struct {
+				Port int8
+			} `lidy:"servers"`
+		}{}
+		err := lidy.Decode(result, &wrong)
+
+		var decodeError *lidy.DecodeError
+		Expect(errors.As(err, &decodeError)).To(BeTrue())
+		Expect(decodeError.Filename()).To(Equal("content.yaml"))
+		Expect(decodeError.Path).To(Equal("/servers/1/port"))
+		Expect(decodeError.Line()).To(Equal(5))
+		Expect(decodeError.Reason).To(ContainSubstring("overflows"))
+	})
+
+	It("rejects a non-pointer output", func() {
+		result, _ := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+
+		Expect(lidy.Decode(result, tDecodeTopology{})).NotTo(Succeed())
+	})
+
+	It("rejects a nil result", func() {
+		topology := tDecodeTopology{}
+		Expect(lidy.Decode(nil, &topology)).NotTo(Succeed())
+	})
+
+	It("zeroes the elements of an array beyond the end of the list", func() {
+		result, erl := lidy.NewParser("schema.yaml", []byte(`main: { _listOf: int }`)).Parse(lidy.NewFile("content.yaml", []byte(`[1]`)))
+		Expect(erl).To(BeEmpty())
+
+		array := [3]int{7, 8, 9}
+		Expect(lidy.Decode(result, &array)).To(Succeed())
+		Expect(array).To(Equal([3]int{1, 0, 0}))
+	})
+
+	It("prefers the exact name of a field, and rejects several keys matching it case-insensitively", func() {
+		parser := lidy.NewParser("schema.yaml", []byte(`main: { _mapOf: { string: int } }`))
+		server := tDecodeServer{}
+
+		result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(`{ Port: 1, PORT: 2 }`)))
+		Expect(erl).To(BeEmpty())
+		Expect(lidy.Decode(result, &server)).To(Succeed())
+		Expect(server.Port).To(Equal(uint16(1)))
+
+		result, erl = parser.Parse(lidy.NewFile("content.yaml", []byte(`{ port: 1, PORT: 2 }`)))
+		Expect(erl).To(BeEmpty())
+		err := lidy.Decode(result, &server)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("the keys 'PORT', 'port' all match the field Port"))
+	})
+})
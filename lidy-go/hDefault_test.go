@@ -0,0 +1,151 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hDefault_test.go
+
+var _ = Describe("The _default keyword", func() {
+	schema := []byte(`main:
+  _map:
+    host: string
+  _mapFacultative:
+    port: int
+    tags: { _listOf: string }
+  _default:
+    port: 8080
+    tags: [web]
+`)
+
+	parse := func(schema []byte, content string) (lidy.Result, []error) {
+		return lidy.NewParser("schema.yaml", schema).Parse(lidy.NewFile("content.yaml", []byte(content)))
+	}
+
+	It("completes the missing facultative properties", func() {
+		result, erl := parse(schema, "host: a\n")
+		Expect(erl).To(BeEmpty())
+
+		port := result.Data().(lidy.MapData).Map["port"]
+		Expect(port.Data()).To(Equal(8080))
+		Expect(port.IsSynthesized()).To(BeTrue())
+
+		tags := result.Data().(lidy.MapData).Map["tags"]
+		Expect(tags.Data().(lidy.ListData).ListOf[0].Data()).To(Equal("web"))
+	})
+
+	It("points the synthesized results at the schema", func() {
+		result, _ := parse(schema, "host: a\n")
+
+		port := result.Data().(lidy.MapData).Map["port"]
+		Expect(port.Filename()).To(Equal("schema.yaml"))
+		Expect([]int{port.Line(), port.Column()}).To(Equal([]int{8, 11}))
+	})
+
+	It("keeps the properties of the content", func() {
+		result, erl := parse(schema, "host: a\nport: 80\n")
+		Expect(erl).To(BeEmpty())
+
+		port := result.Data().(lidy.MapData).Map["port"]
+		Expect(port.Data()).To(Equal(80))
+		Expect(port.IsSynthesized()).To(BeFalse())
+		Expect(port.Filename()).To(Equal("content.yaml"))
+	})
+
+	It("runs the builders on the default values", func() {
+		result, erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _mapFacultative: { port: port }
+  _default: { port: 8080 }
+port:: int
+`)).With(map[string]lidy.Builder{
+			"port": func(input lidy.Result) (interface{}, []error) {
+				return input.Data().(int) + 1, nil
+			},
+		}).Parse(lidy.NewFile("content.yaml", []byte("{}")))
+		Expect(erl).To(BeEmpty())
+
+		port := result.Data().(lidy.MapData).Map["port"]
+		Expect(port.Data()).To(Equal(8081))
+		Expect(port.HasBeenBuilt()).To(BeTrue())
+		Expect(port.IsSynthesized()).To(BeTrue())
+	})
+
+	It("lets a merged map give the property", func() {
+		result, erl := parse([]byte(`main:
+  _mapFacultative: { port: int }
+  _default: { port: 8080 }
+  _merge: [named]
+named:
+  _mapFacultative: { name: string }
+  _default: { name: unnamed }
+`), "port: 80\n")
+		Expect(erl).To(BeEmpty())
+
+		mapData := result.Data().(lidy.MapData)
+		Expect(mapData.Map["port"].Data()).To(Equal(80))
+		Expect(mapData.Map["name"].Data()).To(Equal("unnamed"))
+	})
+
+	It("rejects, at schema time, the defaults which do not match their property", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _mapFacultative: { port: { _range: (1 <= int <= 65535) } }
+  _default: { port: 0 }
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		schemaError := erl[0].(*lidy.SchemaError)
+		Expect(schemaError.Code).To(Equal(lidy.ErrorCodeValue))
+		Expect(schemaError.Path).To(Equal("/main/_default/port"))
+		Expect(schemaError.Line()).To(Equal(3))
+	})
+
+	It("rejects the defaults of the properties which are not facultative", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _map: { port: int }
+  _default: { port: 8080 }
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeValue))
+	})
+
+	It("points a _default given to a property to the _default of the map", func() {
+		for _, property := range []string{
+			"{ _range: (1 <= int <= 65535), _default: 8080 }",
+			"{ _default: 8080, _range: (1 <= int <= 65535) }",
+			"{ _rule: int, _default: 8080 }",
+		} {
+			erl := lidy.NewParser("schema.yaml", []byte("main:\n  _mapFacultative:\n    port: "+property+"\n")).Schema()
+
+			Expect(erl).NotTo(BeEmpty(), property)
+			Expect(erl[0].(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeConflict))
+			Expect(erl[0].Error()).To(ContainSubstring("_default only in the checker of a map"))
+			Expect(erl[0].Error()).To(ContainSubstring("_default: { port: 8080 }"))
+		}
+	})
+
+	It("points the defaults of the merged properties to the map declaring them", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _merge: [base]
+  _default: { port: 8080 }
+base:
+  _mapFacultative: { port: int }
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeValue))
+		Expect(erl[0].Error()).To(ContainSubstring("the _mapFacultative declaring it"))
+	})
+
+	It("rejects a default which needs itself to be completed", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _mapFacultative: { child: main }
+  _default: { child: {} }
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("needs itself"))
+	})
+})
@@ -0,0 +1,123 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hDependency_test.go
+
+var _ = Describe("The rule dependency graph", func() {
+	schema := []byte(`main:
+  _map:
+    servers: { _listOf: server }
+  _merge: [base]
+base:
+  _mapFacultative:
+    name: string
+server:
+  _oneOf:
+    - address
+    - { _rule: port, _description: a port }
+address: string
+port: { _range: (1 <= int <= 65535) }
+`)
+
+	dependencies := func() []lidy.Dependency {
+		dependencyList, erl := lidy.NewParser("schema.yaml", schema).Dependencies()
+		Expect(erl).To(BeEmpty())
+		return dependencyList
+	}
+
+	type edge struct {
+		from, to, path string
+		guarded        bool
+	}
+
+	It("lists the references of each rule, sorted by rule", func() {
+		edgeList := []edge{}
+		for _, dependency := range dependencies() {
+			edgeList = append(edgeList, edge{dependency.From.Name(), dependency.To.Name(), dependency.Path, dependency.Guarded})
+		}
+
+		Expect(edgeList).To(Equal([]edge{
+			{"address", "string", "/address", false},
+			{"base", "string", "/base/_mapFacultative/name", true},
+			{"main", "server", "/main/_map/servers/_listOf", true},
+			{"main", "base", "/main/_merge/0", false},
+			{"server", "address", "/server/_oneOf/0", false},
+			{"server", "port", "/server/_oneOf/1", false},
+		}))
+	})
+
+	It("gives the references, with their position and annotation", func() {
+		dependency := dependencies()[5]
+		Expect(dependency.Kind).To(Equal(lidy.DependencyOption))
+
+		reference := dependency.Reference
+		Expect(reference.Rule().Name()).To(Equal("port"))
+		Expect(reference.Annotation().Description).To(Equal("a port"))
+		Expect([]int{reference.Line(), reference.Column()}).To(Equal([]int{11, 7}))
+	})
+
+	It("includes the imported rules", func() {
+		parser := lidy.NewParser("schema.yaml", []byte("_import: { net: net.yaml }\nmain: net.address\n")).
+			Loader(func(string) ([]byte, error) { return []byte("address: { _listOf: string }\n"), nil })
+
+		dependencyList, erl := parser.Dependencies()
+		Expect(erl).To(BeEmpty())
+		Expect(dependencyList).To(HaveLen(2))
+		Expect(dependencyList[1].From.Name()).To(Equal("net.address"))
+		Expect(dependencyList[1].Path).To(Equal("/address/_listOf"))
+	})
+
+	Describe("recursion", func() {
+		It("accepts the cycles going through a map or a list", func() {
+			erl := lidy.NewParser("schema.yaml", []byte(`tree:
+  _oneOf:
+    - string
+    - { _listOf: tree }
+    - { _mapOf: { string: tree } }
+node:
+  _map: { children: { _listOf: node } }
+  _merge: [named]
+named:
+  _mapFacultative: { parent: node }
+`)).Schema()
+			Expect(erl).To(BeEmpty())
+		})
+
+		It("rejects the cycles through _oneOf, _rule and the aliases", func() {
+			erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _oneOf: [string, alias]
+alias: { _rule: main }
+`)).Schema()
+			Expect(erl).To(HaveLen(1))
+
+			schemaError := erl[0].(*lidy.SchemaError)
+			Expect(schemaError.Code).To(Equal(lidy.ErrorCodeRecursion))
+			Expect(schemaError.RuleName).To(Equal("alias"))
+			Expect(schemaError.Expected).To(ContainSubstring("(alias -> main -> alias)"))
+			Expect([]int{schemaError.Line(), schemaError.Column()}).To(Equal([]int{3, 1}))
+		})
+
+		It("rejects the cycles through _merge and _switch", func() {
+			erl := lidy.NewParser("schema.yaml", []byte(`main:
+  _merge: [animal]
+animal:
+  _discriminator: kind
+  _switch:
+    dog: main
+`)).Schema()
+			Expect(erl).To(HaveLen(1))
+			Expect(erl[0].(*lidy.SchemaError).Code).To(Equal(lidy.ErrorCodeRecursion))
+		})
+
+		It("rejects the rules referring to themselves", func() {
+			erl := lidy.NewParser("schema.yaml", []byte("main: main")).Schema()
+			Expect(erl).To(HaveLen(1))
+			Expect(erl[0].(*lidy.SchemaError).Expected).To(ContainSubstring("(main -> main)"))
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package lidy_test
+
+import (
+	"strings"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hDoc_test.go
+
+var _ = Describe("The reference documentation", func() {
+	schema := []byte(`server:
+  _description: A server of the cluster
+  _map:
+    host: { _rule: string, _examples: [localhost] }
+    port: port
+  _mapFacultative:
+    address: { _rule: string, _deprecated: use host }
+    limits:
+      _mapFacultative: { cpu: float }
+    level: { _in: [debug, info] }
+  _default: { level: info }
+main:
+  _map:
+    servers: { _listOf: server, _min: 1 }
+    mode: mode
+port:
+  _range: (1 <= int <= 65535)
+mode:
+  _oneOf:
+    - { _regex: '^a|b$', _description: a or b }
+    - int
+tuple: { _list: [string], _listFacultative: [int] }
+`)
+
+	doc := func(format lidy.DocFormat) string {
+		content, erl := lidy.NewParser("schema/cluster.yaml", schema).Doc(format)
+		Expect(erl).To(BeEmpty())
+		return string(content)
+	}
+
+	Describe("in Markdown", func() {
+		It("has a section per rule, the target first, then in the order of declaration", func() {
+			content := doc(lidy.DocMarkdown)
+
+			Expect(content).To(HavePrefix("# cluster.yaml\n\n- [`main`](#rule-main)\n- [`server`](#rule-server) -- A server of the cluster\n"))
+
+			indexList := []int{}
+			for _, ruleName := range []string{"main", "server", "port", "mode", "tuple"} {
+				indexList = append(indexList, strings.Index(content, `## <a id="rule-`+ruleName+`"></a>`+ruleName+"\n"))
+			}
+			Expect(indexList[0]).To(BeNumerically(">", 0))
+			for k := 1; k < len(indexList); k++ {
+				Expect(indexList[k]).To(BeNumerically(">", indexList[k-1]))
+			}
+		})
+
+		It("lists the properties, with their type, default and annotations", func() {
+			content := doc(lidy.DocMarkdown)
+
+			Expect(content).To(ContainSubstring("| `host` | `string` | yes |  | Examples: `localhost` |\n"))
+			Expect(content).To(ContainSubstring("| `port` | [`port`](#rule-port) | yes |  |  |\n"))
+			Expect(content).To(ContainSubstring("| `address` | `string` | no |  | Deprecated: use host. |\n"))
+			Expect(content).To(ContainSubstring("| `level` | one of `debug`, `info` | no | `info` |  |\n"))
+			Expect(content).To(ContainSubstring("| `servers` | list of [`server`](#rule-server) (at least 1 item) | yes |  |  |\n"))
+		})
+
+		It("lists the properties of the maps declared inline", func() {
+			Expect(doc(lidy.DocMarkdown)).To(ContainSubstring("| `limits.cpu` | `float` | no |  |  |\n"))
+		})
+
+		It("describes the options, ranges, patterns and lists", func() {
+			content := doc(lidy.DocMarkdown)
+
+			Expect(content).To(ContainSubstring("One of:\n\n- string matching `^a|b$` -- a or b\n- `int`\n"))
+			Expect(content).To(ContainSubstring("A number in the range `(1 <= int <= 65535)`."))
+			Expect(content).To(ContainSubstring("| 1 | `int` | no |  |\n"))
+		})
+
+		It("escapes the pipes of the tables", func() {
+			content, erl := lidy.NewParser("schema.yaml", []byte(`main: { _map: { id: { _regex: 'a|b' } } }`)).Doc(lidy.DocMarkdown)
+			Expect(erl).To(BeEmpty())
+			Expect(string(content)).To(ContainSubstring("| `id` | string matching `a\\|b` | yes |"))
+		})
+
+		It("tells which rules use a rule", func() {
+			Expect(doc(lidy.DocMarkdown)).To(ContainSubstring("Used by [`server`](#rule-server).\n"))
+		})
+	})
+
+	It("writes a standalone HTML page", func() {
+		content := doc(lidy.DocHTML)
+
+		Expect(content).To(HavePrefix("<!DOCTYPE html>"))
+		Expect(content).To(HaveSuffix("</html>\n"))
+		Expect(content).To(ContainSubstring(`<h2 id="rule-server">server</h2>`))
+		Expect(content).To(ContainSubstring(`<td><a href="#rule-port"><code>port</code></a></td>`))
+		Expect(content).To(ContainSubstring("<code>(1 &lt;= int &lt;= 65535)</code>"))
+	})
+
+	It("reports the schema errors, and the unknown formats", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte("main: unknown")).Doc(lidy.DocMarkdown)
+		Expect(erl).To(HaveLen(1))
+
+		_, erl = lidy.NewParser("schema.yaml", schema).Doc("pdf")
+		Expect(erl).To(HaveLen(1))
+	})
+})
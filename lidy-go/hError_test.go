@@ -0,0 +1,194 @@
+package lidy_test
+
+import (
+	"errors"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hError_test.go
+
+var _ = Describe("Content errors", func() {
+	parser := lidy.NewParser("schema.yaml", []byte(`
+main: topology
+topology:
+  _map:
+    nodes:
+      _listOf: node
+node:
+  _map:
+    name: string
+    properties:
+      _mapOf: { string: port }
+port:
+  _range: (1 <= int <= 65535)
+`))
+
+	getContentError := func(content string) *lidy.ContentError {
+		_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+		Expect(erl).To(HaveLen(1))
+
+		var contentError *lidy.ContentError
+		Expect(errors.As(erl[0], &contentError)).To(BeTrue())
+		return contentError
+	}
+
+	It("locates the error with a path, a position and the rule being matched", func() {
+		contentError := getContentError(`
+nodes:
+  - name: a
+    properties: {}
+  - name: b
+    properties:
+      http: 80
+      https: 100000
+`)
+
+		Expect(contentError.Path).To(Equal("/nodes/1/properties/https"))
+		Expect(contentError.Filename()).To(Equal("content.yaml"))
+		Expect(contentError.Line()).To(Equal(8))
+		Expect(contentError.Column()).To(Equal(14))
+		Expect(contentError.LineEnd()).To(Equal(8))
+		Expect(contentError.ColumnEnd()).To(Equal(20))
+		Expect(contentError.RuleName).To(Equal("port"))
+		Expect(contentError.Code).To(Equal(lidy.ErrorCodeRange))
+		Expect(contentError.Actual).To(Equal("!!int"))
+		Expect(contentError.Expected).To(ContainSubstring("1 <= int <= 65535"))
+		Expect(contentError.Error()).To(ContainSubstring("content.yaml:8:14"))
+	})
+
+	It("uses stable codes", func() {
+		Expect(getContentError(`nodes: {}`).Code).To(Equal(lidy.ErrorCodeKind))
+		Expect(getContentError(`{}`).Code).To(Equal(lidy.ErrorCodeMissingProperty))
+		Expect(getContentError(`{ nodes: [], extra: 1 }`).Code).To(Equal(lidy.ErrorCodeExtraEntry))
+	})
+
+	It("reports the path of extra keys", func() {
+		contentError := getContentError(`{ nodes: [{ name: a, properties: {}, color: red }] }`)
+
+		Expect(contentError.Path).To(Equal("/nodes/0/color"))
+		Expect(contentError.RuleName).To(Equal("node"))
+	})
+})
+
+var _ = Describe("Schema errors", func() {
+	It("locates the error in the schema", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`
+main:
+  _map:
+    name: string
+    age: { _range: (10 <= int <= 1) }
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+
+		var schemaError *lidy.SchemaError
+		Expect(errors.As(erl[0], &schemaError)).To(BeTrue())
+
+		Expect(schemaError.Path).To(Equal("/main/_map/age/_range"))
+		Expect(schemaError.RuleName).To(Equal("main"))
+		Expect(schemaError.Code).To(Equal(lidy.ErrorCodeValue))
+		Expect(schemaError.Filename()).To(Equal("schema.yaml"))
+		Expect(schemaError.Line()).To(Equal(5))
+		Expect(schemaError.Column()).To(Equal(20))
+	})
+
+	It("reports references to unknown rules", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`main: { _listOf: tree }`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+
+		var schemaError *lidy.SchemaError
+		Expect(errors.As(erl[0], &schemaError)).To(BeTrue())
+
+		Expect(schemaError.Path).To(Equal("/main/_listOf"))
+		Expect(schemaError.Code).To(Equal(lidy.ErrorCodeUnknownRule))
+	})
+})
+
+var _ = Describe("_oneOf errors", func() {
+	parser := lidy.NewParser("schema.yaml", []byte(`
+main:
+  _oneOf:
+    - string
+    - { _listOf: string }
+    - http
+    - grpc
+http:
+  _map:
+    url: string
+    method: { _in: [GET, POST] }
+grpc:
+  _map:
+    service: string
+`))
+
+	getOneOfError := func(content string) *lidy.ContentError {
+		_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+		Expect(erl).To(HaveLen(1))
+
+		var contentError *lidy.ContentError
+		Expect(errors.As(erl[0], &contentError)).To(BeTrue())
+		Expect(contentError.Code).To(Equal(lidy.ErrorCodeOneOf))
+		return contentError
+	}
+
+	It("keeps the errors of every option", func() {
+		contentError := getOneOfError(`{ url: a, method: PUT }`)
+
+		Expect(contentError.OptionErrorList).To(HaveLen(4))
+		for _, erl := range contentError.OptionErrorList {
+			Expect(erl).NotTo(BeEmpty())
+		}
+	})
+
+	It("reports the errors of the option which got the furthest", func() {
+		contentError := getOneOfError(`{ url: a, method: PUT }`)
+
+		Expect(contentError.BestOption).To(Equal(2))
+		Expect(contentError.Nested).To(HaveLen(1))
+
+		var nested *lidy.ContentError
+		Expect(errors.As(contentError.Nested[0], &nested)).To(BeTrue())
+		Expect(nested.Path).To(Equal("/method"))
+		Expect(nested.Code).To(Equal(lidy.ErrorCodeIn))
+
+		Expect(contentError.Error()).To(ContainSubstring("best match: option #2"))
+		Expect(contentError.Error()).To(ContainSubstring("path /method"))
+	})
+
+	It("prefers the option whose kind matches", func() {
+		contentError := getOneOfError(`[a, [b]]`)
+
+		Expect(contentError.BestOption).To(Equal(1))
+	})
+})
+
+var _ = Describe("_switch errors", func() {
+	parser := lidy.NewParser("schema.yaml", []byte(`
+main:
+  _discriminator: type
+  _switch:
+    http: { _map: { url: string } }
+    grpc: { _map: { host: string } }
+`))
+
+	It("reports an unknown discriminator once, and not as an extra entry", func() {
+		for content, actual := range map[string]string{
+			"{ type: ftp }":    "unknown type 'ftp'",
+			"{ type: [http] }": "unknown type !!seq",
+			"{ type: {} }":     "unknown type !!map",
+		} {
+			_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+			Expect(erl).To(HaveLen(1), content)
+
+			var contentError *lidy.ContentError
+			Expect(errors.As(erl[0], &contentError)).To(BeTrue())
+			Expect(contentError.Code).To(Equal(lidy.ErrorCodeSwitch))
+			Expect(contentError.Path).To(Equal("/type"))
+			Expect(contentError.Error()).To(ContainSubstring(actual + ", expected one of: grpc, http"))
+		}
+	})
+})
@@ -0,0 +1,169 @@
+package lidy_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hGoCode_test.go
+
+const goCodeSchema = `
+main: config
+config::
+  _map:
+    name: string
+    servers: { _listOf: server }
+    shape: shape
+  _mapFacultative:
+    labels: { _mapOf: { string: string } }
+    level: level
+    pet: animal
+    tls: { _map: { cert: string } }
+    tree: tree
+server::
+  _merge: [base]
+  _map:
+    host: string
+    port: { _range: (1 <= int <= 65535) }
+base::
+  _mapFacultative: { id: int }
+tree: { _mapFacultative: { children: { _listOf: tree } } }
+level:: { _in: [low, high] }
+animal:: { _oneOf: [dog, string, nullType] }
+dog:: { _map: { bark: boolean } }
+shape::
+  _discriminator: type
+  _switch:
+    circle: { _map: { radius: float } }
+    square: side
+side: { _map: { side: float } }
+`
+
+var _ = Describe("The Go code generation", func() {
+	generate := func(schema string) (string, []string) {
+		content, warningList, erl := lidy.NewParser("schema.yaml", []byte(schema)).GoCode("config")
+		Expect(erl).To(BeEmpty())
+
+		textList := []string{}
+		for _, warning := range warningList {
+			textList = append(textList, warning.Error())
+		}
+
+		return string(content), textList
+	}
+
+	It("declares a type for each exported rule, and their builders", func() {
+		source, warningList := generate(goCodeSchema)
+
+		Expect(warningList).To(BeEmpty())
+		Expect(source).To(HavePrefix("// Code generated by lidy from schema.yaml; DO NOT EDIT.\n\npackage config\n"))
+
+		for _, snippet := range []string{
+			// _map and _mapFacultative
+			"type Config struct {",
+			"\tName    string            `lidy:\"name\"`",
+			"\tTls     *ConfigTls        `lidy:\"tls\"`",
+			// _listOf, _mapOf
+			"\tServers []Server          `lidy:\"servers\"`",
+			"\tLabels  map[string]string `lidy:\"labels\"`",
+			// _merge of an exported rule
+			"type Server struct {\n\tBase\n",
+			// a rule which is not exported
+			"type Tree struct {\n\tChildren []Tree `lidy:\"children\"`\n}",
+			// _in
+			"type Level string",
+			"LevelHigh Level = \"high\"",
+			// _oneOf
+			"type Animal interface {\n\tisAnimal()\n}",
+			"type AnimalString string",
+			"func (Dog) isAnimal() {}",
+			// _switch
+			"type Shape interface {\n\tisShape()\n}",
+			"func (ShapeCircle) isShape() {}",
+			"func (Side) isShape() {}",
+			"\t\t\"config\": buildConfig,",
+		} {
+			Expect(source).To(ContainSubstring(snippet))
+		}
+	})
+
+	It("does not use pointers for the facultative properties with a _default", func() {
+		source, _ := generate(`
+main:: { _mapFacultative: { port: int, host: string }, _default: { port: 80 } }
+`)
+
+		Expect(source).To(ContainSubstring("\tHost *string `lidy:\"host\"`"))
+		Expect(source).To(ContainSubstring("\tPort int     `lidy:\"port\"`"))
+	})
+
+	It("generates the same source for the same schema", func() {
+		first, _ := generate(goCodeSchema)
+
+		for k := 0; k < 8; k++ {
+			source, _ := generate(goCodeSchema)
+			Expect(source).To(Equal(first))
+		}
+	})
+
+	It("reports what it cannot generate", func() {
+		_, warningList := generate(`
+main: extensible
+extensible:: { _map: { name: string }, _mapOf: { string: int } }
+`)
+
+		Expect(warningList).To(HaveLen(1))
+		Expect(warningList[0]).To(ContainSubstring("the _mapOf entries of Extensible are not generated"))
+	})
+
+	It("generates a package which builds the documents into its types", func() {
+		goCommand, err := exec.LookPath("go")
+		if err != nil {
+			Skip("the go command is not available")
+		}
+
+		source, _ := generate(goCodeSchema)
+
+		// the directories starting with `_` are not part of `./...`
+		directory, err := os.MkdirTemp(".", "_gocode")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(directory)
+
+		program := strings.Replace(source, "package config", "package main", 1) + `
+func main() {
+	parser := lidy.NewParser("schema.yaml", []byte(schema)).With(BuilderMap())
+	result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+	if len(erl) > 0 {
+		fmt.Println(erl)
+		return
+	}
+	config := result.Data().(Config)
+	fmt.Println(config.Name, config.Servers[0].Port, *config.Servers[0].Id, config.Shape, config.Pet, *config.Level, len(config.Tree.Children))
+}
+
+const schema = ` + "`" + goCodeSchema + "`" + `
+
+const content = ` + "`" + `
+name: demo
+servers: [{ host: a, port: 80, id: 1 }]
+shape: { type: circle, radius: 2.5 }
+pet: { bark: true }
+tree: { children: [{}, { children: [] }] }
+level: low
+` + "`\n"
+
+		Expect(os.WriteFile(filepath.Join(directory, "main.go"), []byte(program), 0o644)).To(Succeed())
+
+		command := exec.Command(goCommand, "run", ".")
+		command.Dir = directory
+		output, err := command.CombinedOutput()
+
+		Expect(err).NotTo(HaveOccurred(), string(output))
+		Expect(string(output)).To(Equal("demo 80 1 {2.5} {true} low 2\n"))
+	})
+})
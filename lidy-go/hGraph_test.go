@@ -0,0 +1,95 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hGraph_test.go
+
+var _ = Describe("The rule graph", func() {
+	schema := []byte(`main::
+  _map:
+    servers: { _listOf: server }
+  _mapFacultative:
+    labels: { _mapOf: { string: label } }
+  _merge: [base]
+base:
+  _map: { name: string }
+server:
+  _oneOf:
+    - address
+    - { _list: [address, int] }
+address: string
+label: { _regex: '^[a-z]+$' }
+unused: { _listOf: label }
+`)
+
+	graph := func(format lidy.GraphFormat, from string) string {
+		content, erl := lidy.NewParser("schema/cluster.yaml", schema).Graph(format, from)
+		Expect(erl).To(BeEmpty())
+		return string(content)
+	}
+
+	Describe("in DOT", func() {
+		It("draws the user rules, then the predefined rules they use", func() {
+			Expect(graph(lidy.GraphDot, "")).To(HavePrefix(`digraph "cluster.yaml" {
+  rankdir=LR;
+  node [shape=box];
+  "address";
+  "base";
+  "label";
+  "main" [peripheries=2];
+  "server";
+  "unused";
+  "int" [shape=ellipse, style=dashed];
+  "string" [shape=ellipse, style=dashed];
+`))
+		})
+
+		It("labels the edges with how the reference is made", func() {
+			content := graph(lidy.GraphDot, "")
+
+			Expect(content).To(ContainSubstring(`"address" -> "string";`))
+			Expect(content).To(ContainSubstring(`"base" -> "string" [label="name"];`))
+			Expect(content).To(ContainSubstring(`"main" -> "string" [label="labels? / _mapOf key"];`))
+			Expect(content).To(ContainSubstring(`"main" -> "label" [label="labels? / _mapOf value"];`))
+			Expect(content).To(ContainSubstring(`"main" -> "server" [label="servers / _listOf"];`))
+			Expect(content).To(ContainSubstring(`"main" -> "base" [label="_merge"];`))
+			Expect(content).To(ContainSubstring(`"server" -> "address" [label="_oneOf 0"];`))
+			Expect(content).To(ContainSubstring(`"server" -> "int" [label="_oneOf 1 / _list 1"];`))
+		})
+
+		It("draws only the rules used by the rule given", func() {
+			content := graph(lidy.GraphDot, "server")
+
+			Expect(content).To(ContainSubstring(`"address";`))
+			Expect(content).To(ContainSubstring(`"int" [shape=ellipse, style=dashed];`))
+			Expect(content).NotTo(ContainSubstring(`"main"`))
+			Expect(content).NotTo(ContainSubstring(`"label"`))
+		})
+	})
+
+	It("writes a Mermaid flowchart", func() {
+		content := graph(lidy.GraphMermaid, "main")
+
+		Expect(content).To(HavePrefix("---\ntitle: \"cluster.yaml\"\n---\nflowchart LR\n"))
+		Expect(content).To(ContainSubstring("  n3[[\"main\"]]:::exported\n"))
+		Expect(content).To(ContainSubstring("  n6([\"string\"]):::predefined\n"))
+		Expect(content).To(ContainSubstring("  n3 -->|\"servers / _listOf\"| n4\n"))
+		Expect(content).To(ContainSubstring("  n0 --> n6\n"))
+		Expect(content).NotTo(ContainSubstring("unused"))
+	})
+
+	It("reports the schema errors, the unknown rules and the unknown formats", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte("main: unknown")).Graph(lidy.GraphDot, "")
+		Expect(erl).To(HaveLen(1))
+
+		_, erl = lidy.NewParser("schema.yaml", schema).Graph(lidy.GraphDot, "nothing")
+		Expect(erl).To(HaveLen(1))
+
+		_, erl = lidy.NewParser("schema.yaml", schema).Graph("svg", "")
+		Expect(erl).To(HaveLen(1))
+	})
+})
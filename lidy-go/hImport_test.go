@@ -0,0 +1,154 @@
+package lidy_test
+
+import (
+	"errors"
+	"testing/fstest"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hImport_test.go
+
+var _ = Describe("Schema imports", func() {
+	fsys := fstest.MapFS{
+		"schema/net.lidy.yaml": {Data: []byte(`
+_import:
+  text: ../common/text.lidy.yaml
+ipv4:
+  _list: [octet, octet, octet, octet]
+octet:
+  _range: (0 <= int <= 255)
+host:
+  _oneOf: [ipv4, text.name]
+`)},
+		"common/text.lidy.yaml": {Data: []byte(`
+name:
+  _regex: '^[a-z]+$'
+`)},
+		"schema/broken.lidy.yaml": {Data: []byte(`
+good: string
+bad: { _map: { x: octet } }
+`)},
+		"schema/cycle/a.yaml": {Data: []byte(`
+_import: { b: b.yaml }
+a: b.b
+`)},
+		"schema/cycle/b.yaml": {Data: []byte(`
+_import: { a: ./a.yaml }
+b: string
+`)},
+		"schema/export.lidy.yaml": {Data: []byte(`
+size:: int
+`)},
+	}
+
+	newParser := func(schema string) lidy.Parser {
+		return lidy.NewParser("schema/main.yaml", []byte(schema)).Loader(lidy.NewFSLoader(fsys))
+	}
+
+	It("lets the rules refer to the rules of an imported file", func() {
+		parser := newParser(`
+_import:
+  net: ./net.lidy.yaml
+main:
+  _map:
+    address: net.ipv4
+    server: net.host
+`)
+
+		Expect(parser.Schema()).To(BeEmpty())
+
+		_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte("{ address: [10, 0, 0, 1], server: localhost }")))
+		Expect(erl).To(BeEmpty())
+
+		_, erl = parser.Parse(lidy.NewFile("content.yaml", []byte("{ address: [10, 0, 0, 256], server: Localhost }")))
+		Expect(erl).To(HaveLen(2))
+	})
+
+	It("resolves the imports relative to the importing file, with nested aliases", func() {
+		parser := newParser(`
+_import: { net: net.lidy.yaml }
+main: net.text.name
+`)
+
+		_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte("abc")))
+		Expect(erl).To(BeEmpty())
+	})
+
+	It("does not let the imported files see the rules of the importing file", func() {
+		erl := newParser(`
+_import: { broken: broken.lidy.yaml }
+main: broken.good
+octet: int
+`).Schema()
+
+		Expect(erl).To(HaveLen(1))
+
+		var schemaError *lidy.SchemaError
+		Expect(errors.As(erl[0], &schemaError)).To(BeTrue())
+		Expect(schemaError.Code).To(Equal(lidy.ErrorCodeUnknownRule))
+		Expect(schemaError.Filename()).To(Equal("schema/broken.lidy.yaml"))
+		Expect(schemaError.Line()).To(Equal(3))
+		Expect(schemaError.Path).To(Equal("/bad/_map/x"))
+		Expect(schemaError.RuleName).To(Equal("broken.bad"))
+	})
+
+	It("detects import cycles", func() {
+		erl := newParser(`
+_import: { a: cycle/a.yaml }
+main: a.a
+`).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("schema/cycle/a.yaml -> schema/cycle/b.yaml -> schema/cycle/a.yaml"))
+		Expect(erl[0].Error()).To(ContainSubstring("schema/cycle/b.yaml:2"))
+	})
+
+	It("reports the files which cannot be loaded", func() {
+		erl := newParser(`
+_import: { missing: missing.yaml }
+main: string
+`).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("schema/main.yaml:2"))
+		Expect(erl[0].Error()).To(ContainSubstring("schema/missing.yaml"))
+	})
+
+	It("requires a loader", func() {
+		erl := lidy.NewParser("schema.yaml", []byte(`
+_import: { net: net.lidy.yaml }
+main: net.ipv4
+`)).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("Loader"))
+	})
+
+	It("rejects invalid aliases", func() {
+		erl := newParser(`
+_import: { net.v4: net.lidy.yaml }
+main: string
+`).Schema()
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("alias"))
+	})
+
+	It("prefixes the export names of the imported rules with the alias", func() {
+		parser := newParser(`
+_import: { export: export.lidy.yaml }
+main: export.size
+`).With(map[string]lidy.Builder{
+			"export.size": func(input lidy.Result) (interface{}, []error) {
+				return "built", nil
+			},
+		})
+
+		result, erl := parser.Parse(lidy.NewFile("content.yaml", []byte("2")))
+		Expect(erl).To(BeEmpty())
+		Expect(result.Data()).To(Equal("built"))
+	})
+})
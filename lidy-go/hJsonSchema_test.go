@@ -0,0 +1,353 @@
+package lidy_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hJsonSchema_test.go
+
+var _ = Describe("The JSON Schema export", func() {
+	export := func(schema string) (map[string]interface{}, []string) {
+		content, warningList, erl := lidy.NewParser("schema.yaml", []byte(schema)).JsonSchema()
+		Expect(erl).To(BeEmpty())
+
+		document := map[string]interface{}{}
+		Expect(json.Unmarshal(content, &document)).To(Succeed())
+
+		textList := []string{}
+		for _, warning := range warningList {
+			textList = append(textList, warning.Error())
+		}
+
+		return document, textList
+	}
+
+	// definition returns the JSON of a rule of the exported document
+	definition := func(document map[string]interface{}, ruleName string) string {
+		content, err := json.Marshal(document["$defs"].(map[string]interface{})[ruleName])
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	It("produces a document referring to the target rule, with a definition per rule", func() {
+		document, warningList := export(`
+main: server
+server:
+  _map:
+    host: string
+    port: { _range: (1 <= int <= 65535) }
+  _mapFacultative:
+    tags: { _listOf: { _regex: '^[a-z]+$' }, _max: 3 }
+`)
+
+		Expect(warningList).To(BeEmpty())
+		Expect(document["$schema"]).To(Equal("https://json-schema.org/draft/2020-12/schema"))
+		Expect(document["$ref"]).To(Equal("#/$defs/main"))
+		Expect(definition(document, "main")).To(Equal(`{"$ref":"#/$defs/server"}`))
+		Expect(definition(document, "server")).To(MatchJSON(`{
+			"type": "object",
+			"properties": {
+				"host": { "type": "string" },
+				"port": { "type": "integer", "minimum": 1, "maximum": 65535 },
+				"tags": {
+					"type": "array",
+					"items": { "type": "string", "pattern": "^[a-z]+$" },
+					"maxItems": 3
+				}
+			},
+			"required": ["host", "port"],
+			"additionalProperties": false
+		}`))
+	})
+
+	It("translates _mapOf, _list, _oneOf and _in", func() {
+		document, warningList := export(`
+main:
+  _mapOf: { { _regex: '^x-' }: { _list: [string], _listFacultative: [int] } }
+  _min: 1
+labels:
+  _mapOf: { string: { _oneOf: [string, nullType] } }
+level:
+  _in: [low, 1, true]
+`)
+
+		Expect(warningList).To(BeEmpty())
+		Expect(definition(document, "main")).To(MatchJSON(`{
+			"type": "object",
+			"patternProperties": {
+				"^x-": {
+					"type": "array",
+					"prefixItems": [{ "type": "string" }, { "type": "integer" }],
+					"items": false,
+					"minItems": 1
+				}
+			},
+			"additionalProperties": false,
+			"minProperties": 1
+		}`))
+		Expect(definition(document, "labels")).To(MatchJSON(`{
+			"type": "object",
+			"additionalProperties": { "anyOf": [{ "type": "string" }, { "type": "null" }] }
+		}`))
+		Expect(definition(document, "level")).To(MatchJSON(`{ "enum": [true, 1, "low"] }`))
+	})
+
+	It("inlines the merged rules, and rejects the unevaluated properties", func() {
+		document, warningList := export(`
+main:
+  _merge: [base]
+  _map: { name: string }
+base:
+  _map: { id: int }
+`)
+
+		Expect(warningList).To(BeEmpty())
+		Expect(definition(document, "main")).To(MatchJSON(`{
+			"type": "object",
+			"properties": { "name": { "type": "string" } },
+			"required": ["name"],
+			"allOf": [{
+				"type": "object",
+				"properties": { "id": { "type": "integer" } },
+				"required": ["id"]
+			}],
+			"unevaluatedProperties": false
+		}`))
+	})
+
+	It("translates _default", func() {
+		document, _ := export(`
+main:
+  _mapFacultative: { port: int, peer: peer }
+  _default: { port: 80, peer: { host: localhost } }
+peer: { _map: { host: string } }
+`)
+
+		Expect(definition(document, "main")).To(MatchJSON(`{
+			"type": "object",
+			"properties": {
+				"port": { "type": "integer", "default": 80 },
+				"peer": { "$ref": "#/$defs/peer", "default": { "host": "localhost" } }
+			},
+			"additionalProperties": false
+		}`))
+	})
+
+	It("translates _switch with constant discriminators", func() {
+		document, _ := export(`
+main:
+  _discriminator: type
+  _switch:
+    a: { _map: { x: int } }
+`)
+
+		Expect(definition(document, "main")).To(MatchJSON(`{
+			"type": "object",
+			"required": ["type"],
+			"anyOf": [{
+				"properties": { "type": { "const": "a" } },
+				"allOf": [{
+					"type": "object",
+					"properties": { "x": { "type": "integer" } },
+					"required": ["x"]
+				}]
+			}],
+			"unevaluatedProperties": false
+		}`))
+	})
+
+	It("reports the constructs which cannot be translated exactly", func() {
+		_, warningList := export(`
+main:
+  _merge: [base]
+  _map: { id: string }
+base:
+  _map: { id: int }
+numbers:
+  _mapOf: { int: string }
+`)
+
+		Expect(warningList).To(HaveLen(2))
+		Expect(warningList[0]).To(ContainSubstring("rule 'main'"))
+		Expect(warningList[0]).To(ContainSubstring("overrides the properties 'id'"))
+		Expect(warningList[1]).To(ContainSubstring("rule 'numbers'"))
+	})
+
+	It("inlines a lidy default target rule", func() {
+		content, _, erl := lidy.NewParser("schema.yaml", []byte("main: string")).Target("timestamp").JsonSchema()
+
+		Expect(erl).To(BeEmpty())
+		Expect(string(content)).To(ContainSubstring(`"format": "date-time"`))
+	})
+})
+
+var _ = Describe("The JSON Schema import", func() {
+	convert := func(jsonSchema string) (string, []string) {
+		content, warningList, erl := lidy.FromJsonSchema(lidy.NewFile("schema.json", []byte(jsonSchema)))
+		Expect(erl).To(BeEmpty())
+
+		textList := []string{}
+		for _, warning := range warningList {
+			textList = append(textList, warning.Error())
+		}
+
+		return string(content), textList
+	}
+
+	// checkSchema asserts that the lidy schema is matched by the lidy
+	// meta-schema, and can be compiled
+	checkSchema := func(schema string) lidy.Parser {
+		metaContent, err := ioutil.ReadFile("schema.lidy.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, erl := lidy.NewParser("schema.lidy.yaml", metaContent).Parse(lidy.NewFile("schema.yaml", []byte(schema)))
+		Expect(erl).To(BeEmpty())
+
+		parser := lidy.NewParser("schema.yaml", []byte(schema))
+		Expect(parser.Schema()).To(BeEmpty())
+
+		return parser
+	}
+
+	It("translates $defs, $ref, properties, required, enum and pattern", func() {
+		schema, warningList := convert(`{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type": "object",
+			"properties": {
+				"name": { "type": "string", "pattern": "^[a-z]+$" },
+				"level": { "enum": ["low", "high", 3] },
+				"owner": { "$ref": "#/$defs/person" }
+			},
+			"required": ["name"],
+			"additionalProperties": false,
+			"$defs": {
+				"person": {
+					"type": "object",
+					"properties": { "age": { "type": "integer", "minimum": 0 } }
+				}
+			}
+		}`)
+
+		Expect(warningList).To(BeEmpty())
+		Expect(schema).To(Equal(`main:
+  _map:
+    name:
+      _regex: ^[a-z]+$
+  _mapFacultative:
+    level:
+      _in: [low, high, 3]
+    owner: person
+person:
+  _mapFacultative:
+    age:
+      _range: (0 <= int)
+  _mapOf:
+    string: any
+`))
+		checkSchema(schema)
+	})
+
+	It("produces a schema which checks the same documents", func() {
+		schema, _ := convert(`{
+			"type": "object",
+			"properties": {
+				"port": { "type": "integer", "minimum": 1, "exclusiveMaximum": 65536 },
+				"tags": { "type": "array", "items": { "type": "string" }, "maxItems": 2 },
+				"pair": { "prefixItems": [{ "type": "number" }, { "type": "boolean" }], "items": false, "minItems": 1 },
+				"labels": { "patternProperties": { "^x-": { "type": ["string", "null"] } }, "additionalProperties": false },
+				"node": { "allOf": [{ "$ref": "#/$defs/base" }, { "properties": { "b": { "type": "boolean" } }, "required": ["b"] }], "unevaluatedProperties": false }
+			},
+			"required": ["port"],
+			"additionalProperties": false,
+			"$defs": {
+				"base": { "type": "object", "properties": { "a": { "type": "integer" } }, "required": ["a"] }
+			}
+		}`)
+
+		parser := checkSchema(schema)
+
+		for _, content := range []string{
+			"{ port: 80 }",
+			"{ port: 80, tags: [a, b], pair: [1.5], labels: { x-a: a, x-b: null } }",
+			"{ port: 80, pair: [1, true], node: { a: 1, b: false } }",
+		} {
+			_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+			Expect(erl).To(BeEmpty(), content)
+		}
+
+		for _, content := range []string{
+			"{}",
+			"{ port: 65536 }",
+			"{ port: 80, other: 1 }",
+			"{ port: 80, tags: [a, b, c] }",
+			"{ port: 80, pair: [] }",
+			"{ port: 80, pair: [1, true, 2] }",
+			"{ port: 80, labels: { a: a } }",
+			"{ port: 80, node: { a: 1 } }",
+			"{ port: 80, node: { a: 1, b: true, c: 1 } }",
+		} {
+			_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(content)))
+			Expect(erl).NotTo(BeEmpty(), content)
+		}
+	})
+
+	It("reports the unsupported keywords", func() {
+		_, warningList := convert(`{
+			"type": "object",
+			"properties": {
+				"list": { "type": "array", "uniqueItems": true },
+				"host": { "type": "string", "format": "hostname" },
+				"id": { "type": "string", "pattern": "^(?!x)" }
+			},
+			"not": { "required": ["x"] }
+		}`)
+
+		Expect(warningList).To(HaveLen(4))
+		Expect(warningList[0]).To(ContainSubstring("schema.json:8:4, the keyword 'not' is not supported"))
+		Expect(warningList[1]).To(ContainSubstring("the keyword 'uniqueItems' is not supported"))
+		Expect(warningList[2]).To(ContainSubstring("the format 'hostname' is not supported"))
+		Expect(warningList[3]).To(ContainSubstring("the pattern is not supported by Go regular expressions"))
+	})
+
+	It("renames the definitions which are not lidy identifiers", func() {
+		schema, warningList := convert(`{
+			"$ref": "#/$defs/my-node",
+			"$defs": { "my-node": { "type": "string" }, "string": { "const": 1 } }
+		}`)
+
+		Expect(warningList).To(HaveLen(2))
+		Expect(warningList[0]).To(ContainSubstring("the definition 'my-node' is renamed to 'my_node'"))
+		Expect(warningList[1]).To(ContainSubstring("the definition 'string' is renamed to 'string_'"))
+		Expect(schema).To(HavePrefix("main: my_node\nmy_node: string\nstring_:\n"))
+		checkSchema(schema)
+	})
+
+	It("reports the invalid schemas", func() {
+		_, _, erl := lidy.FromJsonSchema(lidy.NewFile("schema.json", []byte(`{ "type": "text" }`)))
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("schema.json:1:11, expected a JSON type"))
+	})
+
+	It("imports the exported schemas", func() {
+		content, _, erl := lidy.NewParser("schema.yaml", []byte(`
+main:
+  _map:
+    name: { _regex: '^[a-z]+$' }
+    list: { _listOf: item }
+item:
+  _mapFacultative: { size: { _range: (0 <= float < 10) }, kind: { _in: [a, b] } }
+`)).JsonSchema()
+		Expect(erl).To(BeEmpty())
+
+		schema, warningList := convert(string(content))
+
+		Expect(warningList).To(BeEmpty())
+		checkSchema(schema)
+	})
+})
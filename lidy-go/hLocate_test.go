@@ -0,0 +1,84 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+// hLocate_test.go
+
+var _ = Describe("The location of a position in the content", func() {
+	locate := func(content string, line int, column int) lidy.Location {
+		location, erl := lidy.NewParser("schema.yaml", []byte(sampleSchema)).Locate(lidy.NewFile("content.yaml", []byte(content)), line, column)
+		Expect(erl).To(BeEmpty())
+		return location
+	}
+
+	It("lists the missing keys at the level of the keys of a map", func() {
+		location := locate("name: abc\nlevel: low\n", 3, 1)
+
+		Expect(location.Path).To(Equal("/"))
+		Expect(location.RuleName).To(Equal("config"))
+		Expect(location.KeyList).To(Equal([]string{
+			"created", "extra", "key", "labels", "pet", "ratio", "servers", "shape", "tree",
+		}))
+	})
+
+	It("descends into the value of the entry on the line, or indented further", func() {
+		content := "servers:\n  - host: a\n    \n"
+
+		location := locate(content, 3, 5)
+		Expect(location.Path).To(Equal("/servers/0"))
+		Expect(location.RuleName).To(Equal("server"))
+		Expect(location.KeyList).To(Equal([]string{"id", "port"}))
+
+		location = locate(content, 2, 11)
+		Expect(location.Path).To(Equal("/servers/0/host"))
+		Expect(location.RuleName).To(Equal("server"))
+		Expect(location.Description).To(Equal("Rule string (lidy default rule)"))
+	})
+
+	It("lists the keys of a map that is not written yet", func() {
+		location := locate("shape:\n  \n", 2, 3)
+
+		Expect(location.Path).To(Equal("/shape"))
+		Expect(location.KeyList).To(Equal([]string{"type"}))
+	})
+
+	It("follows the case of a _switch, and the option of a _oneOf", func() {
+		location := locate("shape: { type: circle }\npet: { }\n", 1, 10)
+		Expect(location.KeyList).To(Equal([]string{"radius"}))
+
+		location = locate("pet: { bark: true }\n", 1, 14)
+		Expect(location.Path).To(Equal("/pet/bark"))
+		Expect(location.Description).To(Equal("Rule boolean (lidy default rule)"))
+	})
+
+	It("describes the rules as Describe does", func() {
+		location := locate("tree: {}\n", 1, 7)
+
+		description, erl := lidy.NewParser("schema.yaml", []byte(sampleSchema)).Describe("tree")
+		Expect(erl).To(BeEmpty())
+		Expect(location.Description).To(Equal(description))
+		Expect(location.KeyList).To(Equal([]string{"children"}))
+	})
+
+	It("places a cursor in the entry on the line, or indented further", func() {
+		root := yaml.Node{}
+		Expect(yaml.Unmarshal([]byte("a: 1\nb:\n  - x\n  - y\n"), &root)).To(Succeed())
+		node := root.Content[0]
+
+		Expect(lidy.Cursor{Line: 1, Column: 4}.EntryAt(node)).To(Equal(0))
+		Expect(lidy.Cursor{Line: 3, Column: 1}.EntryAt(node)).To(Equal(-1))
+		Expect(lidy.Cursor{Line: 4, Column: 3}.EntryAt(node)).To(Equal(2))
+		Expect(lidy.Cursor{Line: 4, Column: 5}.ItemAt(node.Content[3])).To(Equal(1))
+		Expect(lidy.Cursor{Line: 1, Column: 4}.ItemAt(node)).To(Equal(-1))
+	})
+
+	It("reports the errors of the schema", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte("main: unknown")).Locate(lidy.NewFile("content.yaml", []byte("{}")), 1, 1)
+		Expect(erl).NotTo(BeEmpty())
+	})
+})
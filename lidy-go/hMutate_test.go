@@ -0,0 +1,112 @@
+package lidy_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	"github.com/ditrit/lidy/lidytest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hMutate_test.go
+
+var _ = Describe("The mutant generation", func() {
+	mutate := func(schema string, seed int64) lidy.MutantSet {
+		mutantSet, erl := lidy.NewParser("schema.yaml", []byte(schema)).Mutate(lidy.SampleOption{Seed: seed})
+		Expect(erl).To(BeEmpty())
+		return mutantSet
+	}
+
+	It("produces mutants that the schema rejects with the expected error", func() {
+		for seed := int64(0); seed < 16; seed++ {
+			mutantSet := mutate(sampleSchema, seed)
+			parser := lidy.NewParser("schema.yaml", []byte(sampleSchema))
+
+			_, erl := parser.Parse(lidy.NewFile("sample.yaml", mutantSet.Sample))
+			Expect(erl).To(BeEmpty())
+
+			Expect(mutantSet.MutantList).NotTo(BeEmpty())
+			for _, mutant := range mutantSet.MutantList {
+				_, erl := parser.Parse(lidy.NewFile("mutant.yaml", mutant.Content))
+				Expect(erl).NotTo(BeEmpty(), string(mutant.Content))
+				Expect(fmt.Sprint(erl)).To(ContainSubstring("(path "+mutant.ErrorPath+")"), string(mutant.Content))
+			}
+		}
+	})
+
+	It("covers the kinds of near misses, and pairs them with the violated rule", func() {
+		codeSet := map[lidy.ErrorCode]bool{}
+		ruleSet := map[string]bool{}
+
+		for seed := int64(0); seed < 16; seed++ {
+			for _, mutant := range mutate(sampleSchema, seed).MutantList {
+				codeSet[mutant.Code] = true
+				ruleSet[mutant.RuleName] = true
+
+				if mutant.Code == lidy.ErrorCodeMissingProperty && mutant.Path == "/servers/0" {
+					Expect(mutant.RuleName).To(Equal("server"))
+				}
+			}
+		}
+
+		Expect(codeSet).To(Equal(map[lidy.ErrorCode]bool{
+			lidy.ErrorCodeMissingProperty: true,
+			lidy.ErrorCodeExtraEntry:      true,
+			lidy.ErrorCodeKind:            true,
+			lidy.ErrorCodeSize:            true,
+			lidy.ErrorCodeIn:              true,
+			lidy.ErrorCodeRegex:           true,
+			lidy.ErrorCodeRange:           true,
+			lidy.ErrorCodeSwitch:          true,
+		}))
+		Expect(ruleSet).To(HaveKey("config"))
+		Expect(ruleSet).To(HaveKey("server"))
+		Expect(ruleSet).To(HaveKey("shape"))
+	})
+
+	It("drops the changes that the schema accepts", func() {
+		mutantSet := mutate(`main: { _mapOf: { string: any } }`, 1)
+
+		for _, mutant := range mutantSet.MutantList {
+			Expect(mutant.Code).NotTo(Equal(lidy.ErrorCodeKind))
+		}
+	})
+
+	It("writes the mutants in the layout of the .spec.yaml files", func() {
+		spec := mutate(sampleSchema, 3).Spec("config mutants", []byte(sampleSchema))
+
+		specFile, err := lidytest.Load(lidy.NewFile("config.spec.yaml", spec))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(specFile.GroupList).To(HaveLen(1))
+		group := specFile.GroupList[0]
+		Expect(group.Name).To(Equal("config mutants"))
+		Expect(group.SchemaList).To(Equal([]lidytest.Schema{{Text: strings.TrimSpace(sampleSchema)}}))
+		Expect(group.CriterionList[0].Name).To(Equal("accept the sample"))
+		Expect(group.CriterionList[0].Reject).To(BeFalse())
+
+		parser := lidy.NewParser("config.yaml", []byte(sampleSchema))
+		nameList := []string{}
+		for _, criterion := range group.CriterionList[1:] {
+			Expect(criterion.Reject).To(BeTrue())
+			nameList = append(nameList, criterion.Name)
+
+			// the check names the exact path of the expected error
+			testCase := criterion.CaseList[0]
+			Expect(testCase.Contain).To(MatchRegexp(`^\(path /.*\)$`))
+			_, erl := parser.Parse(lidy.NewFile("mutant.yaml", []byte(testCase.Text)))
+			Expect(fmt.Sprint(erl)).To(ContainSubstring(testCase.Contain), testCase.Text)
+		}
+		Expect(nameList).To(ContainElement("reject with the value 'unknown', which is not in _in at /level (rule config, in)"))
+	})
+
+	It("produces the same mutants for the same seed", func() {
+		first := mutate(sampleSchema, 5)
+
+		for k := 0; k < 4; k++ {
+			Expect(mutate(sampleSchema, 5)).To(Equal(first))
+		}
+	})
+})
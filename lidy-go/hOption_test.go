@@ -0,0 +1,155 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hOption_test.go
+
+var _ = Describe("The StopAtFirstError option", func() {
+	schema := []byte(`
+main:
+  _merge: [base]
+  _map:
+    servers:
+      _listOf: server
+    labels:
+      _mapOf: { string: int }
+
+base:
+  _map:
+    name: string
+
+server:
+  _map:
+    host: string
+    port: int
+`)
+	content := []byte(`
+name: 12
+servers:
+  - { host: a, port: a }
+  - { host: 1, port: 2 }
+  - { host: b }
+labels: { a: a, b: b }
+extra: entry
+`)
+
+	It("reports all the errors when it is not set", func() {
+		_, erl := lidy.NewParser("schema.yaml", schema).Parse(
+			lidy.NewFile("content.yaml", content),
+		)
+
+		Expect(len(erl)).To(BeNumerically(">=", 6))
+	})
+
+	It("reports only the first error when it is set", func() {
+		_, erl := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			StopAtFirstError: true,
+		}).Parse(
+			lidy.NewFile("content.yaml", content),
+		)
+
+		Expect(erl).To(HaveLen(1))
+	})
+
+	It("unwinds through _merge, _listOf and _mapOf", func() {
+		parser := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			StopAtFirstError: true,
+		})
+
+		for _, text := range []string{
+			"{ name: 1, servers: [], labels: {} }",
+			"{ name: a, servers: [{ host: a, port: a }, { host: 1 }], labels: {} }",
+			"{ name: a, servers: [], labels: { a: a, b: b } }",
+			"{ name: a, servers: [], labels: {}, x: 1, y: 2 }",
+		} {
+			_, erl := parser.Parse(lidy.NewFile("content.yaml", []byte(text)))
+
+			Expect(erl).To(HaveLen(1), text)
+		}
+	})
+
+	It("still accepts valid content", func() {
+		_, erl := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			StopAtFirstError: true,
+		}).Parse(
+			lidy.NewFile("content.yaml", []byte("{ name: a, servers: [{ host: a, port: 1 }], labels: { a: 1 } }")),
+		)
+
+		Expect(erl).To(BeEmpty())
+	})
+})
+
+var _ = Describe("The schema warning options", func() {
+	schema := []byte(`
+main: animal
+animal:: string
+plant:: string
+unused: { _map: { self: unused } }
+`)
+
+	warningTextList := func(parser lidy.Parser) []string {
+		textList := []string{}
+		for _, warning := range parser.SchemaWarnings() {
+			textList = append(textList, warning.Error())
+		}
+		return textList
+	}
+
+	Specify("no warning is produced for unrequested options", func() {
+		parser := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			IgnoreExtraBuilder: true,
+		})
+
+		Expect(parser.Schema()).To(BeEmpty())
+		Expect(parser.SchemaWarnings()).To(BeEmpty())
+	})
+
+	Specify("WarnUnusedRule reports the rules that are never referred to", func() {
+		parser := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			WarnUnusedRule:     true,
+			IgnoreExtraBuilder: true,
+		})
+
+		textList := warningTextList(parser)
+
+		Expect(textList).To(HaveLen(2))
+		Expect(textList[0]).To(ContainSubstring("'plant'"))
+		Expect(textList[0]).To(ContainSubstring("schema.yaml:4:1"))
+		Expect(textList[1]).To(ContainSubstring("'unused'"))
+	})
+
+	Specify("WarnUnimplementedBuilder reports the exported rules without a builder", func() {
+		parser := lidy.NewParser("schema.yaml", schema).Option(lidy.Option{
+			WarnUnimplementedBuilder: true,
+		}).With(map[string]lidy.Builder{
+			"animal": func(input lidy.Result) (interface{}, []error) { return nil, nil },
+		})
+
+		textList := warningTextList(parser)
+
+		Expect(textList).To(HaveLen(1))
+		Expect(textList[0]).To(ContainSubstring("'plant'"))
+	})
+
+	Specify("extra builders are reported unless IgnoreExtraBuilder is set", func() {
+		builderMap := map[string]lidy.Builder{
+			"animal": func(input lidy.Result) (interface{}, []error) { return nil, nil },
+			"anmial": func(input lidy.Result) (interface{}, []error) { return nil, nil },
+		}
+
+		textList := warningTextList(lidy.NewParser("schema.yaml", schema).With(builderMap))
+
+		Expect(textList).To(HaveLen(1))
+		Expect(textList[0]).To(ContainSubstring("'anmial'"))
+
+		ignoring := lidy.NewParser("schema.yaml", schema).With(builderMap).Option(lidy.Option{
+			IgnoreExtraBuilder: true,
+		})
+
+		Expect(ignoring.SchemaWarnings()).To(BeEmpty())
+	})
+})
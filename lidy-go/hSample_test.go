@@ -0,0 +1,173 @@
+package lidy_test
+
+import (
+	"io/ioutil"
+	"regexp"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+// hSample_test.go
+
+const sampleSchema = `
+main: config
+config:
+  _map:
+    name: { _regex: '^[a-z][a-z0-9-]{2,9}$' }
+    servers: { _listOf: server, _min: 1, _max: 4 }
+    level: { _in: [low, high, 3] }
+    shape: shape
+  _mapFacultative:
+    labels: { _mapOf: { string: string }, _max: 2 }
+    ratio: { _range: (0 < float < 1) }
+    pet: { _oneOf: [string, nullType, { _map: { bark: boolean } }] }
+    created: timestamp
+    key: binary
+    extra: any
+    tree: tree
+server:
+  _merge: [base]
+  _map:
+    host: string
+    port: { _range: (1 <= int <= 65535) }
+base:
+  _mapFacultative: { id: int }
+shape:
+  _discriminator: type
+  _switch:
+    circle: { _map: { radius: float } }
+    square: { _map: { side: float } }
+tree: { _mapFacultative: { children: { _listOf: tree } } }
+`
+
+var _ = Describe("The sample generation", func() {
+	sample := func(schema string, option lidy.SampleOption) []byte {
+		content, erl := lidy.NewParser("schema.yaml", []byte(schema)).Sample(option)
+		Expect(erl).To(BeEmpty())
+		return content
+	}
+
+	check := func(schema string, content []byte) {
+		_, erl := lidy.NewParser("schema.yaml", []byte(schema)).Parse(lidy.NewFile("sample.yaml", content))
+		Expect(erl).To(BeEmpty(), string(content))
+	}
+
+	It("produces documents matching the schema", func() {
+		for seed := int64(0); seed < 64; seed++ {
+			check(sampleSchema, sample(sampleSchema, lidy.SampleOption{Seed: seed}))
+		}
+	})
+
+	It("produces documents matching the lidy meta-schema", func() {
+		metaContent, err := ioutil.ReadFile("schema.lidy.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		for seed := int64(0); seed < 32; seed++ {
+			check(string(metaContent), sample(string(metaContent), lidy.SampleOption{Seed: seed}))
+		}
+	})
+
+	It("produces the same document for the same seed", func() {
+		first := sample(sampleSchema, lidy.SampleOption{Seed: 7})
+
+		for k := 0; k < 8; k++ {
+			Expect(sample(sampleSchema, lidy.SampleOption{Seed: 7})).To(Equal(first))
+		}
+		Expect(sample(sampleSchema, lidy.SampleOption{Seed: 8})).NotTo(Equal(first))
+	})
+
+	It("covers the facultative keys, the _in values and the sizes", func() {
+		keySet := map[string]bool{}
+		levelSet := map[string]bool{}
+		sizeSet := map[int]bool{}
+
+		for seed := int64(0); seed < 64; seed++ {
+			document := map[string]interface{}{}
+			Expect(yaml.Unmarshal(sample(sampleSchema, lidy.SampleOption{Seed: seed}), &document)).To(Succeed())
+
+			for key := range document {
+				keySet[key] = true
+			}
+			levelSet[toText(document["level"])] = true
+			sizeSet[len(document["servers"].([]interface{}))] = true
+
+			Expect(document["name"]).To(MatchRegexp(`^[a-z][a-z0-9-]{2,9}$`))
+		}
+
+		Expect(keySet).To(HaveLen(11))
+		Expect(levelSet).To(HaveLen(3))
+		Expect(sizeSet).To(Equal(map[int]bool{1: true, 2: true, 3: true, 4: true}))
+	})
+
+	It("bounds the depth of the recursive rules", func() {
+		schema := `main: { _map: { value: int }, _mapFacultative: { next: main } }`
+		depth := regexp.MustCompile(`(?m)^( *)value`)
+
+		for seed := int64(0); seed < 16; seed++ {
+			content := sample(schema, lidy.SampleOption{Seed: seed, MaxDepth: 3})
+			check(schema, content)
+
+			for _, match := range depth.FindAllStringSubmatch(string(content), -1) {
+				Expect(len(match[1]) / 2).To(BeNumerically("<=", 3))
+			}
+		}
+	})
+
+	It("picks a finite option of the recursive rules", func() {
+		schema := `main: { _oneOf: [{ _list: [main] }, { _map: { leaf: main } }, string] }`
+
+		for seed := int64(0); seed < 16; seed++ {
+			check(schema, sample(schema, lidy.SampleOption{Seed: seed}))
+		}
+	})
+
+	It("samples through the annotated rule references", func() {
+		schema := `main: { _listOf: { _rule: item, _description: An item }, _min: 1 }
+item: { _map: { id: int } }`
+
+		check(schema, sample(schema, lidy.SampleOption{Seed: 1}))
+	})
+
+	It("reports the rules which only accept infinite documents", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte(`main: { _map: { next: main } }`)).Sample(lidy.SampleOption{})
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("only accepts infinitely deep documents"))
+	})
+
+	It("reports the empty ranges", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte(`main: { _range: (1 < int < 2) }`)).Sample(lidy.SampleOption{})
+
+		Expect(erl).To(HaveLen(1))
+		Expect(erl[0].Error()).To(ContainSubstring("empty range"))
+	})
+
+	It("samples the ranges as wide as the 64-bit integers", func() {
+		for _, schema := range []string{
+			`main: { _range: (-9e18 <= int <= 9e18) }`,
+			`main: { _range: (-1e19 <= int <= 1e19) }`,
+			`main: { _range: (9e18 <= int) }`,
+			`main: { _range: (int < -9e18) }`,
+		} {
+			for seed := int64(0); seed < 16; seed++ {
+				check(schema, sample(schema, lidy.SampleOption{Seed: seed}))
+			}
+		}
+	})
+
+	It("samples the target rule", func() {
+		content, erl := lidy.NewParser("schema.yaml", []byte(sampleSchema)).Target("shape").Sample(lidy.SampleOption{Seed: 1})
+
+		Expect(erl).To(BeEmpty())
+		Expect(string(content)).To(MatchRegexp(`^type: (circle|square)\n`))
+	})
+})
+
+func toText(value interface{}) string {
+	text, err := yaml.Marshal(value)
+	Expect(err).NotTo(HaveOccurred())
+	return string(text)
+}
@@ -0,0 +1,131 @@
+package lidy_test
+
+import (
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hSchemaModel_test.go
+
+var _ = Describe("The schema model", func() {
+	schema := []byte(`main:
+  _description: A server
+  _map:
+    host: string
+    port: { _range: (1 <= int < 65536) }
+  _mapFacultative:
+    tags: { _listOf: tag, _max: 3 }
+    mode: { _in: [fast, 1] }
+  _default: { mode: fast }
+  _merge: [named]
+named::
+  _mapFacultative: { name: string }
+tag: { _regex: '^[a-z]+$' }
+pair: { _list: [string], _listFacultative: [int], _listOf: float, _nb: 2 }
+value: { _oneOf: [string, { _rule: int, _deprecated: true }] }
+shape:
+  _discriminator: kind
+  _switch: { circle: named }
+`)
+
+	parser := func() lidy.Parser {
+		return lidy.NewParser("schema.yaml", schema)
+	}
+
+	rule := func(ruleName string) lidy.Rule {
+		rule, erl := parser().Rule(ruleName)
+		Expect(erl).To(BeEmpty())
+		return rule
+	}
+
+	It("lists the user rules, sorted by name, with their position", func() {
+		ruleList, erl := parser().Rules()
+		Expect(erl).To(BeEmpty())
+
+		nameList := []string{}
+		for _, rule := range ruleList {
+			nameList = append(nameList, rule.Name())
+		}
+		Expect(nameList).To(Equal([]string{"main", "named", "pair", "shape", "tag", "value"}))
+
+		Expect(ruleList[1].Filename()).To(Equal("schema.yaml"))
+		Expect([]int{ruleList[1].Line(), ruleList[1].Column()}).To(Equal([]int{11, 1}))
+		Expect(ruleList[1].ExportName()).To(Equal("named"))
+	})
+
+	It("gives the predefined rules, without expression", func() {
+		stringRule := rule("string")
+		Expect(stringRule.IsPredefined()).To(BeTrue())
+		Expect(stringRule.Expression()).To(BeNil())
+
+		_, erl := parser().Rule("unknown")
+		Expect(erl).To(HaveLen(1))
+	})
+
+	It("gives the properties of the maps", func() {
+		mapExpression := rule("main").Expression().(lidy.MapExpression)
+		Expect(mapExpression.Annotation().Description).To(Equal("A server"))
+		Expect([]int{mapExpression.Line(), mapExpression.Column()}).To(Equal([]int{2, 3}))
+
+		propertyList := mapExpression.Properties()
+		Expect(propertyList).To(HaveLen(2))
+		Expect(propertyList[0].Name).To(Equal("host"))
+		Expect(propertyList[0].Expression.(lidy.RuleReference).Rule().Name()).To(Equal("string"))
+		Expect([]int{propertyList[0].Expression.Line(), propertyList[0].Expression.Column()}).To(Equal([]int{4, 11}))
+
+		portRange := propertyList[1].Expression.(lidy.RangeExpression)
+		Expect(portRange.Kind()).To(Equal("int"))
+		Expect(portRange.Min()).To(Equal(lidy.RangeBound{Present: true, Inclusive: true, Value: 1}))
+		Expect(portRange.Max()).To(Equal(lidy.RangeBound{Present: true, Inclusive: false, Value: 65536}))
+
+		facultativeList := mapExpression.FacultativeProperties()
+		Expect(facultativeList[0].Name).To(Equal("mode"))
+		Expect(facultativeList[0].Default.Value).To(Equal("fast"))
+		Expect(facultativeList[0].Expression.(lidy.InExpression).Values()).To(Equal([]lidy.InValue{
+			{Tag: "!!int", Value: "1"}, {Tag: "!!str", Value: "fast"},
+		}))
+		Expect(facultativeList[1].Default).To(BeNil())
+
+		key, value := mapExpression.MapOf()
+		Expect(key).To(BeNil())
+		Expect(value).To(BeNil())
+
+		mergeList := mapExpression.MergeList()
+		Expect(mergeList).To(HaveLen(1))
+		Expect(mergeList[0].(lidy.RuleReference).Rule().Name()).To(Equal("named"))
+	})
+
+	It("gives the items and the sizing of the lists", func() {
+		tags := rule("main").Expression().(lidy.MapExpression).FacultativeProperties()[1].Expression.(lidy.ListExpression)
+		Expect(tags.Sizing()).To(Equal(lidy.Sizing{Max: 3, HasMax: true}))
+
+		tag := tags.ListOf().(lidy.RuleReference).Rule()
+		Expect(tag.Expression().(lidy.RegexExpression).Regex()).To(Equal("^[a-z]+$"))
+		Expect(tag.Expression().(lidy.RegexExpression).Regexp().MatchString("abc")).To(BeTrue())
+
+		pair := rule("pair").Expression().(lidy.ListExpression)
+		Expect(pair.Items()).To(HaveLen(1))
+		Expect(pair.FacultativeItems()).To(HaveLen(1))
+		Expect(pair.ListOf().(lidy.RuleReference).Rule().Name()).To(Equal("float"))
+		Expect(pair.Sizing()).To(Equal(lidy.Sizing{Min: 2, Max: 2, HasMax: true}))
+	})
+
+	It("gives the options and the cases", func() {
+		optionList := rule("value").Expression().(lidy.OneOfExpression).Options()
+		Expect(optionList).To(HaveLen(2))
+		Expect(optionList[0].Annotation().Deprecated).To(BeFalse())
+		Expect(optionList[1].Annotation().Deprecated).To(BeTrue())
+		Expect(optionList[1].(lidy.RuleReference).Rule().Name()).To(Equal("int"))
+
+		shape := rule("shape").Expression().(lidy.SwitchExpression)
+		Expect(shape.Discriminator()).To(Equal("kind"))
+		Expect(shape.Cases()).To(HaveLen(1))
+		Expect(shape.Cases()[0].Value).To(Equal("circle"))
+	})
+
+	It("reports the schema errors", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte("main: unknown")).Rules()
+		Expect(erl).To(HaveLen(1))
+	})
+})
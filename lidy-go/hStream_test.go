@@ -0,0 +1,106 @@
+package lidy_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// hStream_test.go
+
+var _ = Describe("ParseStream", func() {
+	parser := func() lidy.Parser {
+		return lidy.NewParser("schema.yaml", []byte(`main:
+  _map:
+    kind: { _in: [Service, Deployment] }
+  _mapFacultative:
+    replicas: { _rule: int, _deprecated: true }
+`))
+	}
+
+	stream := `kind: Service
+---
+kind: Deployment
+replicas: 2
+---
+kind: Pod
+`
+
+	It("gives a result and an error list per document", func() {
+		documentList, erl := parser().ParseStream(strings.NewReader(stream))
+		Expect(erl).To(BeEmpty())
+		Expect(documentList).To(HaveLen(3))
+
+		for k, document := range documentList {
+			Expect(document.Index).To(Equal(k))
+		}
+
+		Expect(documentList[0].Errors).To(BeEmpty())
+		Expect(documentList[0].Result.Data().(lidy.MapData).Map["kind"].Data()).To(Equal("Service"))
+
+		Expect(documentList[1].Errors).To(BeEmpty())
+		Expect(documentList[1].Warnings).To(HaveLen(1))
+
+		Expect(documentList[2].Result).To(BeNil())
+		Expect(documentList[2].Errors).To(HaveLen(1))
+	})
+
+	It("gives the document index in the positions, whose lines count from the start of the stream", func() {
+		documentList, _ := parser().ParseStream(strings.NewReader(stream))
+
+		result := documentList[1].Result.Data().(lidy.MapData).Map["replicas"]
+		Expect([]int{result.Document(), result.Line(), result.Column()}).To(Equal([]int{1, 4, 11}))
+
+		contentError := documentList[2].Errors[0].(*lidy.ContentError)
+		Expect([]int{contentError.Document(), contentError.Line(), contentError.Column()}).To(Equal([]int{2, 6, 7}))
+
+		warning := documentList[1].Warnings[0].(*lidy.ContentWarning)
+		Expect(warning.Document()).To(Equal(1))
+	})
+
+	It("names the files of the readers which have a name", func() {
+		directory, err := os.MkdirTemp("", "lidy")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(directory)
+
+		filename := filepath.Join(directory, "stream.yaml")
+		Expect(os.WriteFile(filename, []byte(stream), 0o644)).To(Succeed())
+
+		file, err := os.Open(filename)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		documentList, erl := parser().ParseStream(file)
+		Expect(erl).To(BeEmpty())
+		Expect(documentList[2].Errors[0].(*lidy.ContentError).Filename()).To(Equal(filename))
+	})
+
+	It("ends the stream at the first syntax error", func() {
+		documentList, erl := parser().ParseStream(strings.NewReader("kind: Service\n---\nkind: [\n---\nkind: Service\n"))
+		Expect(erl).To(BeEmpty())
+		Expect(documentList).To(HaveLen(2))
+		Expect(documentList[0].Errors).To(BeEmpty())
+		Expect(documentList[1].Errors).To(HaveLen(1))
+	})
+
+	It("accepts the empty streams", func() {
+		documentList, erl := parser().ParseStream(strings.NewReader(""))
+		Expect(erl).To(BeEmpty())
+		Expect(documentList).To(BeEmpty())
+	})
+
+	It("reports the schema errors", func() {
+		_, erl := lidy.NewParser("schema.yaml", []byte("main: unknown")).ParseStream(strings.NewReader(stream))
+		Expect(erl).To(HaveLen(1))
+	})
+
+	It("leaves the document index of Parse at 0", func() {
+		result, erl := parser().Parse(lidy.NewFile("content.yaml", []byte("kind: Service\n")))
+		Expect(erl).To(BeEmpty())
+		Expect(result.Document()).To(Equal(0))
+	})
+})
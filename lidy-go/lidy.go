@@ -7,6 +7,9 @@ package lidy
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -37,11 +40,56 @@ type Parser interface {
 	With(builderMap map[string]Builder) Parser
 	// Option -- set the parser options
 	Option(option Option) Parser
+	// Loader -- set the loader used to read the files imported by the schema
+	Loader(loader Loader) Parser
 	// Schema -- assert that the file content is a valid schema
 	Schema() []error
+	// SchemaWarnings -- the warnings produced while processing the schema, if any. See Option
+	SchemaWarnings() []Warning
+	// Describe -- a human-readable description of a rule of the schema
+	Describe(ruleName string) (string, []error)
+	// JsonSchema -- export the schema as a JSON Schema (draft 2020-12) document
+	JsonSchema() ([]byte, []Warning, []error)
+	// GoCode -- generate a Go package with the types of the exported rules, and their builders
+	GoCode(packageName string) ([]byte, []Warning, []error)
+	// Rules -- the user rules of the schema, including the imported ones, sorted by name
+	Rules() ([]Rule, []error)
+	// Rule -- a rule of the schema, which may be a predefined rule, e.g. `string`
+	Rule(ruleName string) (Rule, []error)
+	// Dependencies -- the references of the user rules to the rules they use, sorted by the name of the referring rule
+	Dependencies() ([]Dependency, []error)
+	// Graph -- draw the rules and their references, in DOT or in Mermaid, optionally only the rules used by a rule
+	Graph(format GraphFormat, from string) ([]byte, []error)
+	// Doc -- write the reference documentation of the schema, in Markdown or in HTML
+	Doc(format DocFormat) ([]byte, []error)
+	// Sample -- generate a random YAML document matching the target rule. See SampleOption
+	Sample(option SampleOption) ([]byte, []error)
+	// Mutate -- generate a sample of the target rule, and variants of it that the schema rejects. See MutantSet
+	Mutate(option SampleOption) (MutantSet, []error)
+	// Annotation -- the `_description`, `_examples` and `_deprecated` of the expression at a path of the schema, e.g. `/server/_map/port`
+	Annotation(path string) (Annotation, []error)
+	// Locate -- find what the target rule expects at a position of a content document. See Location
+	Locate(file File, line int, column int) (Location, []error)
 	// Parse
-	// validate a yaml content, and deserialise it into a Lidy result
+	// validate a yaml content, and deserialise it into a Lidy result.
+	// Parse can be called from several goroutines at once
 	Parse(file File) (tResult, []error)
+	// ParseWithWarnings -- like Parse, also returning the warnings about the content, e.g. the use of expressions annotated with `_deprecated`
+	ParseWithWarnings(file File) (tResult, []Warning, []error)
+	// ParseStream -- validate the YAML documents of a stream, such as a multi-document file, decoding them one at a time. See StreamDocument
+	ParseStream(reader io.Reader) ([]StreamDocument, []error)
+}
+
+// StreamDocument -- the outcome of the validation of a document of a stream, see ParseStream
+type StreamDocument struct {
+	// Index the index of the document in the stream, from 0. It is also the Document() of the positions
+	Index int
+	// Result the result of the document, nil if there are errors
+	Result Result
+	// Warnings the warnings about the document
+	Warnings []Warning
+	// Errors the errors of the document. A YAML syntax error ends the stream
+	Errors []error
 }
 
 // Warning -- a non-fatal exception in Lidy
@@ -81,11 +129,47 @@ type Option struct {
 	StopAtFirstError bool
 }
 
+// SampleOption -- the settings of Sample
+type SampleOption struct {
+	// Seed the seed of the random choices; the same seed gives the same document
+	Seed int64
+	// MaxDepth the depth from which the facultative entries are left out, and the shallowest options chosen, so that the recursive rules end. 0 means 5
+	MaxDepth int
+}
+
+// MutantSet -- a valid sample, and near-miss variants of it, produced by Mutate
+type MutantSet struct {
+	// Sample the valid document, in YAML flow style
+	Sample []byte
+	// MutantList the variants of the sample, each rejected by the schema
+	MutantList []Mutant
+}
+
+// Mutant -- a variant of a sample, which breaks one constraint of the schema
+type Mutant struct {
+	// Content the document, in YAML flow style
+	Content []byte
+	// Mutation what was changed, e.g. "without the required key 'name'"
+	Mutation string
+	// Code the kind of the error that the document produces
+	Code ErrorCode
+	// Path the path of the changed node, e.g. `/servers/0`
+	Path string
+	// ErrorPath the path of the error, which may be within the changed node, e.g. `/servers/0/extra` for an extra key
+	ErrorPath string
+	// RuleName the rule which reports the error
+	RuleName string
+}
+
+// Loader -- read the content of a file imported by a schema, through `_import`.
+// The filename is slash-separated, and relative to the directory of the importing file
+type Loader func(filename string) ([]byte, error)
+
 // Builder -- user-implemented input-validation and creation of user objects
 type Builder func(input Result) (interface{}, []error)
 
 // tLidyMatcher -- Lidy default rules
-type tLidyMatcher func(content yaml.Node, p *tParser) (tResult, []error)
+type tLidyMatcher func(content yaml.Node, parser *tContentParser) (tResult, []error)
 
 //
 // Concrete types
@@ -97,6 +181,12 @@ type tFile struct {
 	name    string
 	content []byte
 	yaml    yaml.Node
+	// document
+	// the index of the document in its stream, for the documents of ParseStream
+	document int
+	// decoder
+	// reads the content into the yaml node; DecodeYaml if nil
+	decoder ContentDecoder
 }
 
 var _ Parser = &tParser{}
@@ -105,17 +195,33 @@ type tParser struct {
 	tFile
 	builderMap         map[string]Builder
 	lidyDefaultRuleMap map[string]*tRule
+	loader             Loader
 	option             Option
-	schema             tSchema
+	// schemaLock
+	// held while the schema is compiled. Once compiled, the schema is only read
+	schemaLock *sync.Mutex
+	schema     tSchema
 	// schemaErrorSlice
 	// memoizes the error output of .parseSchema()
 	schemaErrorSlice []error
+	// schemaWarningSlice
+	// the warnings produced by .parseSchema()
+	schemaWarningSlice []Warning
 	// target
 	// the rule which will be used for the root node of the content document
 	target string
-	// contentFile
-	// the Lidy file currently
-	contentFile tFile
+	// schemaPath
+	// used only at schema parse time. The keys and indexes leading from the
+	// root of the schema to the node being parsed
+	schemaPath []string
+	// currentFilename
+	// used only at schema parse time. The schema file being parsed; the file
+	// of the parser or an imported file
+	currentFilename string
+	// currentPrefix
+	// used only at schema parse time. The import aliases leading to the schema
+	// file being parsed, e.g. `net.`, prepended to the rule names
+	currentPrefix string
 	// currentRule
 	// used only at schema parse time, empty afterward. The rule being parsed.
 	// This is used to track rule dependency and provide more helpful error
@@ -123,6 +229,31 @@ type tParser struct {
 	currentRuleName string
 }
 
+// tContentParser
+// the context of one call to Parse. The compiled schema of the tParser is
+// only read while the content is matched, so each call gets its own context
+type tContentParser struct {
+	option Option
+	// contentFile
+	// the Lidy file currently
+	contentFile tFile
+	// contentPath
+	// the keys and indexes leading from the root of the content to the node being matched
+	contentPath []string
+	// contentRuleName
+	// the innermost rule being matched
+	contentRuleName string
+	// warningList
+	// the warnings about the content, e.g. the use of deprecated expressions
+	warningList []Warning
+	// defaultSet
+	// the `_default` values being matched, identified by their position in
+	// the schema, to reject a default which contains itself
+	defaultSet map[string]bool
+}
+
+var _ Warning = &tWarning{}
+
 type tWarning struct {
 	text string
 }
@@ -139,11 +270,18 @@ type tError struct {
 //
 
 // NewFile -- create a Lidy representation of a file
-// the filename is only used for error reporting
-func NewFile(filename string, content []byte) File {
+// the filename is used for error reporting, and to choose the decoder of the
+// content, see ContentDecoderFor. A decoder may be given explicitly instead
+func NewFile(filename string, content []byte, decoderList ...ContentDecoder) File {
+	decoder := ContentDecoderFor(filename)
+	if len(decoderList) > 0 {
+		decoder = decoderList[0]
+	}
+
 	return &tFile{
 		name:    filename,
 		content: content,
+		decoder: decoder,
 	}
 }
 
@@ -158,19 +296,17 @@ func (f *tFile) Content() []byte {
 // Yaml -- assert this file to be Yaml
 func (f *tFile) Yaml() error {
 	if f.yaml.Kind == yaml.Kind(0) {
-		// TODO
-		// Think of upgrading to using yaml.NewDecoder, and handle any io.Reader
-		err := yaml.Unmarshal(f.content, &f.yaml)
-		if err != nil {
-			return err
+		// a File holds a single document; see ParseStream for the streams
+		decoder := f.decoder
+		if decoder == nil {
+			decoder = DecodeYaml
 		}
 
-		if f.yaml.Kind == 0 {
-			if len(f.content) == 0 {
-				return fmt.Errorf("yaml: the file is empty")
-			}
-			return fmt.Errorf("INTERNAL yaml.Unmarshal failed silently for content [`%s`]. %s", string(f.content), pleaseReport)
+		node, err := decoder(f.content)
+		if err != nil {
+			return err
 		}
+		f.yaml = node
 	}
 	return nil
 }
@@ -183,6 +319,10 @@ func (*tFile) zzFile() {}
 // This method must exist to validate the interface
 func (*tWarning) zzWarning() {}
 
+func (warning *tWarning) Error() string {
+	return warning.text
+}
+
 // Error cannot be implemented by external libraries
 // This method must exist to validate the interface
 func (*tError) zzError() {}
@@ -195,6 +335,14 @@ func (err *tError) Error() string {
 	return err.text
 }
 
+// NewFSLoader -- create a Loader reading the imported files from a fs.FS,
+// e.g. an embed.FS or an os.DirFS
+func NewFSLoader(fsys fs.FS) Loader {
+	return func(filename string) ([]byte, error) {
+		return fs.ReadFile(fsys, filename)
+	}
+}
+
 //
 // Parser
 //
@@ -206,7 +354,8 @@ func NewParser(filename string, content []byte) Parser {
 			name:    filename,
 			content: content,
 		},
-		target: "main",
+		target:     "main",
+		schemaLock: &sync.Mutex{},
 	}
 }
 
@@ -228,6 +377,12 @@ func (p *tParser) Option(option Option) Parser {
 	return p
 }
 
+// Loader -- set the loader of the imported files. Return this
+func (p *tParser) Loader(loader Loader) Parser {
+	p.loader = loader
+	return p
+}
+
 // Schema -- assert the Schema of the parser to be valid. Return this and the list of encountered error, while processing the schema, if any.
 func (p *tParser) Schema() []error {
 	erl := p.parseSchema()
@@ -237,11 +392,46 @@ func (p *tParser) Schema() []error {
 	return nil
 }
 
+// SchemaWarnings -- process the schema if needed, and return the warnings it produced. Warnings are only produced for the schema-time options WarnUnusedRule, WarnUnimplementedBuilder and IgnoreExtraBuilder.
+func (p *tParser) SchemaWarnings() []Warning {
+	p.parseSchema()
+	return p.schemaWarningSlice
+}
+
+// Describe -- process the schema if needed, and describe the given rule: its name and form, followed by the description of its expression
+func (p *tParser) Describe(ruleName string) (string, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return "", erl
+	}
+
+	rule, ruleFound := p.schema.ruleMap[ruleName]
+	if !ruleFound {
+		return "", []error{fmt.Errorf("Could not find rule '%s' in grammar", ruleName)}
+	}
+
+	return describeExpression(rule), nil
+}
+
 // Parse -- use the parser to check the given YAML file, and produce a Lidy Result.
 func (p *tParser) Parse(file File) (tResult, []error) {
-	result, erl := p.parseContent(file)
+	result, _, erl := p.parseContent(file)
 	if len(erl) > 0 {
 		return tResult{}, erl
 	}
 	return result, nil
 }
+
+// ParseWithWarnings -- like Parse, also returning the warnings about the content. The warnings are returned even if there are errors
+func (p *tParser) ParseWithWarnings(file File) (tResult, []Warning, []error) {
+	result, warningList, erl := p.parseContent(file)
+	if len(erl) > 0 {
+		return tResult{}, warningList, erl
+	}
+	return result, warningList, nil
+}
+
+// ParseStream -- use the parser to check each YAML document of the stream, decoding them one at a time. The positions name the file of the reader if it has a `Name()`, like an *os.File, and give the index of their document. The errors returned are those of the schema; the errors of each document are in its StreamDocument, and a YAML syntax error ends the list of the documents.
+func (p *tParser) ParseStream(reader io.Reader) ([]StreamDocument, []error) {
+	return p.parseStream(reader)
+}
@@ -0,0 +1,206 @@
+package lidy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ditrit/lidy/errorlist"
+	"gopkg.in/yaml.v3"
+)
+
+// lidyAnnotation.go
+//
+// The annotation keywords `_description`, `_examples` and `_deprecated`,
+// which document the expressions without constraining the content, and the
+// `_rule` form, which allows annotating a rule reference
+
+// Annotation -- the documentation of an expression of the schema
+type Annotation struct {
+	// Description the text of `_description`
+	Description string
+	// Examples the values of `_examples`, decoded as by yaml.Unmarshal
+	Examples []interface{}
+	// Deprecated true if `_deprecated` is `true`, or a message
+	Deprecated bool
+	// DeprecationMessage the message of `_deprecated`, e.g. "use `hosts` instead", if any
+	DeprecationMessage string
+}
+
+// isEmpty tells whether no annotation keyword was given
+func (annotation Annotation) isEmpty() bool {
+	return annotation.Description == "" && annotation.Examples == nil && !annotation.Deprecated
+}
+
+// annotationForm reads the annotation keywords of a checker form
+func annotationForm(sp tSchemaParser, formMap tFormMap) (Annotation, []error) {
+	errList := errorlist.List{}
+	annotation := Annotation{}
+
+	if node, present := formMap["_description"]; present {
+		if node.Tag != "!!str" {
+			errList.Push(sp.at("_description").schemaError(node, ErrorCodeKind, "a string"))
+		}
+		annotation.Description = node.Value
+	}
+
+	if node, present := formMap["_examples"]; present {
+		if node.Kind != yaml.SequenceNode {
+			errList.Push(sp.at("_examples").schemaError(node, ErrorCodeKind, "a YAML sequence of example values"))
+		} else {
+			annotation.Examples = make([]interface{}, len(node.Content))
+			for k, exampleNode := range node.Content {
+				exampleNode.Decode(&annotation.Examples[k])
+			}
+		}
+	}
+
+	if node, present := formMap["_deprecated"]; present {
+		switch node.Tag {
+		case "!!bool":
+			annotation.Deprecated = node.Value == "true"
+		case "!!str":
+			annotation.Deprecated = true
+			annotation.DeprecationMessage = node.Value
+		default:
+			errList.Push(sp.at("_deprecated").schemaError(node, ErrorCodeKind, "a boolean, or a string (the deprecation message)"))
+		}
+	}
+
+	return annotation, errList.ConcatError()
+}
+
+// ruleChecker -- the `_rule` form, a rule reference which can be annotated.
+// Each reference is a copy of the rule, so the annotation only applies to
+// this use of the rule
+func ruleChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression, []error) {
+	ruleNode := formMap["_rule"]
+	if ruleNode.Tag != "!!str" {
+		return nil, sp.at("_rule").schemaError(ruleNode, ErrorCodeKind, "a string (a rule identifier)")
+	}
+
+	return sp.at("_rule").ruleReference(ruleNode)
+}
+
+// expressionAnnotation is the annotation of an expression. A rule reference
+// without annotation has the annotation of the expression of the rule
+func expressionAnnotation(expression tExpression) Annotation {
+	visitingSet := map[string]bool{}
+
+	for {
+		annotation := expression.Annotation()
+
+		rule, isRule := expression.(*tRule)
+		if !annotation.isEmpty() || !isRule || rule.expression == nil || visitingSet[rule.ruleName] {
+			return annotation
+		}
+
+		visitingSet[rule.ruleName] = true
+		expression = rule.expression
+	}
+}
+
+// Annotation -- process the schema if needed, and return the annotation of
+// the expression at the given path of the schema, as given by the Path of
+// the schema errors, e.g. `/server/_map/port`. A rule reference without
+// annotation has the annotation of the expression of the rule
+func (p *tParser) Annotation(path string) (Annotation, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return Annotation{}, erl
+	}
+
+	segmentList := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	rule, ruleFound := p.schema.ruleMap[segmentList[0]]
+	if !ruleFound {
+		return Annotation{}, []error{fmt.Errorf("Could not find rule '%s' in grammar", segmentList[0])}
+	}
+
+	expression := tExpression(rule)
+	if rule.expression != nil {
+		expression = rule.expression
+	}
+
+	for k := 1; k < len(segmentList); k++ {
+		var next tExpression
+		if k+1 < len(segmentList) {
+			next = childExpression(expression, segmentList[k], segmentList[k+1])
+		}
+		if next == nil {
+			next = childExpression(expression, segmentList[k], "")
+		} else {
+			k++
+		}
+
+		if next == nil {
+			return Annotation{}, []error{fmt.Errorf("Could not find the expression at '%s' in grammar", path)}
+		}
+		expression = next
+	}
+
+	return expressionAnnotation(expression), nil
+}
+
+// childExpression returns the sub-expression of an expression given by a
+// keyword and, for the keywords with several sub-expressions, a key or an
+// index. It returns nil if there is none
+func childExpression(expression tExpression, keyword string, key string) tExpression {
+	index := -1
+	fmt.Sscanf(key, "%d", &index)
+
+	switch expression := expression.(type) {
+	case tMap:
+		switch keyword {
+		case "_map":
+			return expression.form.propertyMap[key]
+		case "_mapFacultative":
+			return expression.form.optionalMap[key]
+		case "_merge":
+			if 0 <= index && index < len(expression.form.mergeList) {
+				return expression.form.mergeList[index]
+			}
+		}
+	case tList:
+		switch keyword {
+		case "_list":
+			if 0 <= index && index < len(expression.form.list) {
+				return expression.form.list[index]
+			}
+		case "_listFacultative":
+			if 0 <= index && index < len(expression.form.optionalList) {
+				return expression.form.optionalList[index]
+			}
+		case "_listOf":
+			if key == "" {
+				return expression.form.listOf
+			}
+		}
+	case tOneOf:
+		if keyword == "_oneOf" && 0 <= index && index < len(expression.optionList) {
+			return expression.optionList[index]
+		}
+	case tSwitch:
+		if keyword == "_switch" {
+			if mergeable, ok := expression.caseMap[key]; ok {
+				return mergeable
+			}
+		}
+	}
+
+	return nil
+}
+
+// warnDeprecated records a warning when a deprecated expression matched the
+// content
+func (parser *tContentParser) warnDeprecated(annotation Annotation, content yaml.Node) {
+	if !annotation.Deprecated {
+		return
+	}
+
+	parser.warningList = append(parser.warningList, &ContentWarning{
+		tPosition: parser.position(content),
+		Path:      pathString(parser.contentPath),
+		RuleName:  parser.contentRuleName,
+		Message:   annotation.DeprecationMessage,
+	})
+}
@@ -13,7 +13,7 @@ import (
 // Implement check() i.e. matchers that do not produce a result
 
 // Sizing check()
-func (sizing tSizingMinMax) check(content yaml.Node, parser *tParser) []error {
+func (sizing tSizingMinMax) check(content yaml.Node, parser *tContentParser) []error {
 	errList := errorlist.List{}
 
 	errList.Push(sizing.tSizingMin.check(content, parser))
@@ -22,7 +22,7 @@ func (sizing tSizingMinMax) check(content yaml.Node, parser *tParser) []error {
 	return errList.ConcatError()
 }
 
-func (sizing tSizingMin) check(content yaml.Node, parser *tParser) []error {
+func (sizing tSizingMin) check(content yaml.Node, parser *tContentParser) []error {
 	size, err := getSize(content)
 
 	if len(err) > 0 {
@@ -30,12 +30,12 @@ func (sizing tSizingMin) check(content yaml.Node, parser *tParser) []error {
 	}
 
 	if size < sizing.min {
-		return parser.contentError(content, "have at least "+strconv.Itoa(sizing.min)+" entries")
+		return parser.contentError(content, ErrorCodeSize, "have at least "+strconv.Itoa(sizing.min)+" entries")
 	}
 	return nil
 }
 
-func (sizing tSizingMax) check(content yaml.Node, parser *tParser) []error {
+func (sizing tSizingMax) check(content yaml.Node, parser *tContentParser) []error {
 	size, err := getSize(content)
 
 	if len(err) > 0 {
@@ -43,12 +43,12 @@ func (sizing tSizingMax) check(content yaml.Node, parser *tParser) []error {
 	}
 
 	if size > sizing.max {
-		return parser.contentError(content, "have at most "+strconv.Itoa(sizing.max)+" entries")
+		return parser.contentError(content, ErrorCodeSize, "have at most "+strconv.Itoa(sizing.max)+" entries")
 	}
 	return nil
 }
 
-func (sizing tSizingNb) check(content yaml.Node, parser *tParser) []error {
+func (sizing tSizingNb) check(content yaml.Node, parser *tContentParser) []error {
 	size, err := getSize(content)
 
 	if len(err) > 0 {
@@ -56,12 +56,12 @@ func (sizing tSizingNb) check(content yaml.Node, parser *tParser) []error {
 	}
 
 	if size != sizing.nb {
-		return parser.contentError(content, "have exactly "+strconv.Itoa(sizing.nb)+" entries")
+		return parser.contentError(content, ErrorCodeSize, "have exactly "+strconv.Itoa(sizing.nb)+" entries")
 	}
 	return nil
 }
 
-func (tSizingNone) check(content yaml.Node, parser *tParser) []error {
+func (tSizingNone) check(content yaml.Node, parser *tContentParser) []error {
 	return nil
 }
 
@@ -2,7 +2,10 @@ package lidy
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 
 	"github.com/ditrit/lidy/errorlist"
 	"gopkg.in/yaml.v3"
@@ -26,8 +29,8 @@ func mapChecker(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tExpression
 	errList.Push(erl)
 
 	return tMap{
-		form,
-		sizing,
+		form:   form,
+		sizing: sizing,
 	}, errList.ConcatError()
 }
 
@@ -41,18 +44,18 @@ func listChecker(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tExpressio
 	errList.Push(err)
 
 	if _, isNone := sizing.(tSizingNone); form.listOf == nil && !isNone {
-		errList.Push(sp.schemaError(node, "_min, _max or _nb can only be used together with _listOf"))
+		errList.Push(sp.schemaError(node, ErrorCodeConflict, "_min, _max or _nb can only be used together with _listOf"))
 	}
 
 	return tList{
-		form,
-		sizing,
+		form:   form,
+		sizing: sizing,
 	}, errList.ConcatError()
 }
 
 func mapParameter(sp tSchemaParser, node yaml.Node, errList *errorlist.List) map[string]tExpression {
 	if node.Kind != yaml.MappingNode {
-		errList.Push(sp.schemaError(node, "a YAML map"))
+		errList.Push(sp.schemaError(node, ErrorCodeKind, "a YAML map"))
 		return nil
 	}
 
@@ -63,11 +66,11 @@ func mapParameter(sp tSchemaParser, node yaml.Node, errList *errorlist.List) map
 		value := *node.Content[k+1]
 
 		if key.Tag != "!!str" {
-			errList.Push(sp.schemaError(key, "only string keys"))
+			errList.Push(sp.at(key.Value).schemaError(key, ErrorCodeKind, "only string keys"))
 			continue
 		}
 
-		expression, erl := sp.expression(value)
+		expression, erl := sp.at(key.Value).expression(value)
 		errList.Push(erl)
 
 		if len(erl) == 0 {
@@ -88,7 +91,7 @@ func checkMergeable(
 		if mapping.form.mapOf.key == nil {
 			return mapping, nil
 		}
-		return nil, sp.schemaError(node, fmt.Sprintf("a mergeable expression but got a map checker with a _mapOf keyword, which is forbidden"))
+		return nil, sp.schemaError(node, ErrorCodeMergeable, fmt.Sprintf("a mergeable expression but got a map checker with a _mapOf keyword, which is forbidden"))
 	}
 
 	if oneOf, ok := expression.(tOneOf); ok {
@@ -102,12 +105,16 @@ func checkMergeable(
 		return oneOf, errList.ConcatError()
 	}
 
+	if switchChecker, ok := expression.(tSwitch); ok {
+		return switchChecker, nil
+	}
+
 	if rule, ok := expression.(*tRule); ok {
 		*listOfMergedRules = append(*listOfMergedRules, rule.ruleName)
 		return rule, nil
 	}
 
-	return nil, sp.schemaError(node, fmt.Sprintf("a mergeable expression but got [%s]", expression.name()))
+	return nil, sp.schemaError(node, ErrorCodeMergeable, fmt.Sprintf("a mergeable expression but got [%s]", expression.name()))
 	// TODO
 	// the value of `node` is imprecise. The exact link to the node, or at least to it's position
 	// should be kept in all the checker types.
@@ -125,31 +132,35 @@ func mapForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tMapForm, []er
 	optionalMapNode, _mapFacultative := formMap["_mapFacultative"]
 	mapOfNode, _mapOf := formMap["_mapOf"]
 	mergeNode, _merge := formMap["_merge"]
+	defaultNode, _default := formMap["_default"]
 
 	propertyMap := map[string]tExpression{}
 	optionalMap := map[string]tExpression{}
 	mapOf := tKeyValueExpression{}
 	mergeList := []tMergeableExpression{}
+	defaultList := []tDefault{}
 	listOfMergedRules := []string{}
 
 	if _map {
-		propertyMap = mapParameter(sp, propertyMapNode, &errList)
+		propertyMap = mapParameter(sp.at("_map"), propertyMapNode, &errList)
 	}
 
 	if _mapFacultative {
-		optionalMap = mapParameter(sp, optionalMapNode, &errList)
+		optionalMap = mapParameter(sp.at("_mapFacultative"), optionalMapNode, &errList)
 	}
 
 	if _mapOf {
 		if mapOfNode.Kind != yaml.MappingNode || len(mapOfNode.Content) != 2 {
-			errList.Push(sp.schemaError(mapOfNode, "a YAML map, with a single key-value pair"))
+			errList.Push(sp.at("_mapOf").schemaError(mapOfNode, ErrorCodeKind, "a YAML map, with a single key-value pair"))
 		} else {
-			result, erl := sp.expression(*mapOfNode.Content[0])
+			mapOfSp := sp.at("_mapOf", mapOfNode.Content[0].Value)
+
+			result, erl := mapOfSp.expression(*mapOfNode.Content[0])
 			mapOf.key = result
 
 			errList.Push(erl)
 
-			result, erl = sp.expression(*mapOfNode.Content[1])
+			result, erl = mapOfSp.expression(*mapOfNode.Content[1])
 			mapOf.value = result
 
 			errList.Push(erl)
@@ -158,18 +169,20 @@ func mapForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tMapForm, []er
 
 	if _merge {
 		if mergeNode.Kind != yaml.SequenceNode {
-			errList.Push(sp.schemaError(mergeNode, "a YAML sequence of mergeable expressions"))
+			errList.Push(sp.at("_merge").schemaError(mergeNode, ErrorCodeKind, "a YAML sequence of mergeable expressions"))
 		} else {
-			mergeList := make([]tMergeableExpression, 0, len(mergeNode.Content))
+			mergeList = make([]tMergeableExpression, 0, len(mergeNode.Content))
 
-			for _, subNode := range mergeNode.Content {
-				expression, erl := sp.expression(*subNode)
+			for k, subNode := range mergeNode.Content {
+				mergeSp := sp.at("_merge", strconv.Itoa(k))
+
+				expression, erl := mergeSp.expression(*subNode)
 				errList.Push(erl)
 				if len(erl) != 0 {
 					continue
 				}
 
-				mergeable, erl := checkMergeable(sp, node, expression, &listOfMergedRules)
+				mergeable, erl := checkMergeable(mergeSp, *subNode, expression, &listOfMergedRules)
 				errList.Push(erl)
 				if len(erl) != 0 {
 					continue
@@ -180,6 +193,10 @@ func mapForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tMapForm, []er
 		}
 	}
 
+	if _default {
+		defaultList = defaultParameter(sp.at("_default"), defaultNode, optionalMap, optionalMapNode, _merge, &errList)
+	}
+
 	for _, ruleName := range listOfMergedRules {
 		sp.schema.ruleMap[ruleName]._mergeList = append(
 			sp.schema.ruleMap[ruleName]._mergeList,
@@ -188,14 +205,70 @@ func mapForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tMapForm, []er
 	}
 
 	return tMapForm{
-		propertyMap:     propertyMap,
-		optionalMap:     optionalMap,
-		mapOf:           mapOf,
-		mergeList:       mergeList,
-		_dependencyList: listOfMergedRules,
+		propertyMap: propertyMap,
+		optionalMap: optionalMap,
+		mapOf:       mapOf,
+		mergeList:   mergeList,
+		defaultList: defaultList,
 	}, errList.ConcatError()
 }
 
+// defaultParameter reads the `_default` values of the facultative
+// properties. The values are checked once all the rules are parsed, see
+// checkDefaultList, so they are recorded on the current rule. The properties
+// of the merged maps take their defaults from their own checker
+func defaultParameter(sp tSchemaParser, node yaml.Node, optionalMap map[string]tExpression, optionalMapNode yaml.Node, merging bool, errList *errorlist.List) []tDefault {
+	if node.Kind != yaml.MappingNode {
+		errList.Push(sp.schemaError(node, ErrorCodeKind, "a YAML map, of facultative properties to their default value"))
+		return nil
+	}
+
+	declaredSet := map[string]bool{}
+	for k := 0; k+1 < len(optionalMapNode.Content); k += 2 {
+		declaredSet[optionalMapNode.Content[k].Value] = true
+	}
+
+	defaultList := []tDefault{}
+
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		key := *node.Content[k]
+		value := *node.Content[k+1]
+
+		if !declaredSet[key.Value] {
+			expected := "the name of a property of _mapFacultative"
+			if merging {
+				expected += " (the default of a merged property goes in the _default next to the _mapFacultative declaring it)"
+			}
+			errList.Push(sp.at(key.Value).schemaError(key, ErrorCodeValue, expected))
+			continue
+		}
+
+		expression, present := optionalMap[key.Value]
+		if !present {
+			// the expression of the property is invalid, and already reported
+			continue
+		}
+
+		defaultList = append(defaultList, tDefault{
+			property:    key.Value,
+			expression:  expression,
+			node:        value,
+			filename:    sp.filename(),
+			_schemaPath: appendPath(sp.schemaPath, key.Value),
+		})
+	}
+
+	sort.Slice(defaultList, func(i, j int) bool {
+		return defaultList[i].property < defaultList[j].property
+	})
+
+	if rule, present := sp.schema.ruleMap[sp.currentRuleName]; present {
+		rule._defaultList = append(rule._defaultList, defaultList...)
+	}
+
+	return defaultList
+}
+
 func listForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tListForm, []error) {
 	errList := errorlist.List{}
 
@@ -213,11 +286,11 @@ func listForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tListForm, []
 		listList = make([]tExpression, len(listNode.Content))
 
 		if listNode.Kind != yaml.SequenceNode {
-			errList.Push(sp.schemaError(listNode, "a yaml sequence"))
+			errList.Push(sp.at("_list").schemaError(listNode, ErrorCodeKind, "a yaml sequence"))
 		}
 
 		for k, subNode := range listNode.Content {
-			res, erl := sp.expression(*subNode)
+			res, erl := sp.at("_list", strconv.Itoa(k)).expression(*subNode)
 			errList.Push(erl)
 			listList[k] = res
 		}
@@ -225,15 +298,20 @@ func listForm(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tListForm, []
 
 	if _listFacultative {
 		optionalList = make([]tExpression, len(optionalNode.Content))
-		for k, subNode := range listNode.Content {
-			res, erl := sp.expression(*subNode)
+
+		if optionalNode.Kind != yaml.SequenceNode {
+			errList.Push(sp.at("_listFacultative").schemaError(optionalNode, ErrorCodeKind, "a yaml sequence"))
+		}
+
+		for k, subNode := range optionalNode.Content {
+			res, erl := sp.at("_listFacultative", strconv.Itoa(k)).expression(*subNode)
 			errList.Push(erl)
 			optionalList[k] = res
 		}
 	}
 
 	if _listOf {
-		res, erl := sp.expression(listOfNode)
+		res, erl := sp.at("_listOf").expression(listOfNode)
 		errList.Push(erl)
 		listOfExpression = res
 	}
@@ -254,38 +332,38 @@ func sizingChecker(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tSizing,
 
 	var sizing tSizing
 
-	tryDecodeInteger := func(theNode yaml.Node) int {
+	tryDecodeInteger := func(theNode yaml.Node, keyword string) int {
 		var theInt int
 		err := theNode.Decode(&theInt)
 		if err != nil {
-			errList.Push(sp.schemaError(theNode, "an integer (yaml error happened trying to read the integer) "+err.Error()))
+			errList.Push(sp.at(keyword).schemaError(theNode, ErrorCodeValue, "an integer (yaml error happened trying to read the integer) "+err.Error()))
 		}
 		if theInt < 0 {
-			errList.Push(sp.schemaError(theNode, "a _positive_ integer"))
+			errList.Push(sp.at(keyword).schemaError(theNode, ErrorCodeValue, "a _positive_ integer"))
 		}
 		return theInt
 	}
 
 	switch {
 	case _nb && (_min || _max):
-		errList.Push(sp.schemaError(node, "no use of _nb, _min and _max together"))
+		errList.Push(sp.schemaError(node, ErrorCodeConflict, "no use of _nb, _min and _max together"))
 	case !_min && !_max && !_nb:
 		sizing = tSizingNone{}
 	case _nb:
-		sizing = tSizingNb{nb: tryDecodeInteger(nbNode)}
+		sizing = tSizingNb{nb: tryDecodeInteger(nbNode, "_nb")}
 	case _min && !_max:
-		sizing = tSizingMin{min: tryDecodeInteger(minNode)}
+		sizing = tSizingMin{min: tryDecodeInteger(minNode, "_min")}
 	case _max && !_min:
-		sizing = tSizingMax{max: tryDecodeInteger(maxNode)}
+		sizing = tSizingMax{max: tryDecodeInteger(maxNode, "_max")}
 	case _min && _max:
-		min := tryDecodeInteger(minNode)
-		max := tryDecodeInteger(maxNode)
+		min := tryDecodeInteger(minNode, "_min")
+		max := tryDecodeInteger(maxNode, "_max")
 		sizing = tSizingMinMax{
 			tSizingMin{min: min},
 			tSizingMax{max: max},
 		}
 	default:
-		errList.Push(sp.schemaError(node, "no unexpected combination of _nb, _min and _max (Internal error? "+pleaseReport+")"))
+		errList.Push(sp.schemaError(node, ErrorCodeInternal, "no unexpected combination of _nb, _min and _max (Internal error? "+pleaseReport+")"))
 	}
 
 	return sizing, errList.ConcatError()
@@ -295,13 +373,13 @@ func oneOfChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression,
 	oneOfValueNode := formMap["_oneOf"]
 
 	if oneOfValueNode.Kind != yaml.SequenceNode {
-		return nil, sp.schemaError(oneOfValueNode, "a sequence (of lidy expressions)")
+		return nil, sp.at("_oneOf").schemaError(oneOfValueNode, ErrorCodeKind, "a sequence (of lidy expressions)")
 	}
 	errList := errorlist.List{}
 	optionList := []tExpression{}
 
-	for _, subNode := range oneOfValueNode.Content {
-		expression, erl := sp.expression(*subNode)
+	for k, subNode := range oneOfValueNode.Content {
+		expression, erl := sp.at("_oneOf", strconv.Itoa(k)).expression(*subNode)
 		errList.Push(erl)
 		optionList = append(optionList, expression)
 	}
@@ -311,20 +389,84 @@ func oneOfChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression,
 	}, errList.ConcatError()
 }
 
+func switchChecker(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tExpression, []error) {
+	discriminatorNode, _discriminator := formMap["_discriminator"]
+	switchNode, _switch := formMap["_switch"]
+
+	if !_discriminator || !_switch {
+		return nil, sp.schemaError(node, ErrorCodeConflict, "_discriminator and _switch to be used together")
+	}
+
+	if discriminatorNode.Tag != "!!str" {
+		return nil, sp.at("_discriminator").schemaError(discriminatorNode, ErrorCodeKind, "a string (the name of the property selecting the case)")
+	}
+
+	if switchNode.Kind != yaml.MappingNode {
+		return nil, sp.at("_switch").schemaError(switchNode, ErrorCodeKind, "a YAML map (of the values of the property to mergeable expressions)")
+	}
+
+	errList := errorlist.List{}
+	caseMap := make(map[string]tMergeableExpression)
+	caseNameList := []string{}
+	listOfMergedRules := []string{}
+
+	for k := 0; k+1 < len(switchNode.Content); k += 2 {
+		key := *switchNode.Content[k]
+		value := *switchNode.Content[k+1]
+
+		caseSp := sp.at("_switch", key.Value)
+
+		if key.Kind != yaml.ScalarNode {
+			errList.Push(caseSp.schemaError(key, ErrorCodeKind, "a scalar value (a value of the property)"))
+			continue
+		}
+
+		expression, erl := caseSp.expression(value)
+		errList.Push(erl)
+		if len(erl) != 0 {
+			continue
+		}
+
+		mergeable, erl := checkMergeable(caseSp, value, expression, &listOfMergedRules)
+		errList.Push(erl)
+		if len(erl) != 0 {
+			continue
+		}
+
+		caseMap[key.Value] = mergeable
+		caseNameList = append(caseNameList, key.Value)
+	}
+
+	sort.Strings(caseNameList)
+
+	for _, ruleName := range listOfMergedRules {
+		sp.schema.ruleMap[ruleName]._mergeList = append(
+			sp.schema.ruleMap[ruleName]._mergeList,
+			sp.currentRuleName,
+		)
+	}
+
+	return tSwitch{
+		key:          discriminatorNode.Value,
+		caseMap:      caseMap,
+		caseNameList: caseNameList,
+	}, errList.ConcatError()
+}
+
 func inChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression, []error) {
 	inValueNode := formMap["_in"]
 
 	if inValueNode.Kind != yaml.SequenceNode {
-		return nil, sp.schemaError(inValueNode, "a sequence (of YAML scalars)")
+		return nil, sp.at("_in").schemaError(inValueNode, ErrorCodeKind, "a sequence (of YAML scalars)")
 	}
 	errList := errorlist.List{}
 	valueMap := make(map[string][]string)
 
 NodeContentLoop:
-	for _, value := range inValueNode.Content {
+	for k, value := range inValueNode.Content {
 		// scalar values only
 		if value.Kind != yaml.ScalarNode {
-			errList.Push(sp.schemaError(inValueNode, "a scalar value"))
+			errList.Push(sp.at("_in", strconv.Itoa(k)).schemaError(*value, ErrorCodeKind, "a scalar value"))
 			continue
 		}
 
@@ -335,7 +477,7 @@ NodeContentLoop:
 
 		for _, v := range valueMap[value.Tag] {
 			if v == value.Value {
-				errList.Push(sp.schemaError(*value, "no duplicated value"))
+				errList.Push(sp.at("_in", strconv.Itoa(k)).schemaError(*value, ErrorCodeValue, "no duplicated value"))
 				continue NodeContentLoop
 			}
 		}
@@ -352,20 +494,84 @@ NodeContentLoop:
 func regexChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression, []error) {
 	regexValueNode := formMap["_regex"]
 	if regexValueNode.Tag != "!!str" {
-		return nil, sp.schemaError(regexValueNode, "a string (a regex)")
+		return nil, sp.at("_regex").schemaError(regexValueNode, ErrorCodeKind, "a string (a regex)")
 	}
 
 	regexString := regexValueNode.Value
 
 	regex, err := regexp.Compile(regexString)
 	if err != nil {
-		return nil, sp.schemaError(regexValueNode, fmt.Sprintf(
+		return nil, sp.at("_regex").schemaError(regexValueNode, ErrorCodeValue, fmt.Sprintf(
 			"a valid regex (error: '%s')",
 			err.Error(),
 		))
 	}
 
 	return tRegex{
-		regex: regex,
+		regexString: regexString,
+		regex:       regex,
 	}, nil
 }
+
+const regexRangeNumber = `([-+]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][-+]?[0-9]+)?)`
+
+// regexRange
+// matches the range expressions `(1 <= int <= 9)`, `(0 < float)`, `(float < 10)`, ...
+// the parentheses are optional
+var regexRange = *regexp.MustCompile(`^\(?\s*` +
+	`(?:` + regexRangeNumber + `\s*(<=?)\s*)?` +
+	`(int|float)` +
+	`(?:\s*(<=?)\s*` + regexRangeNumber + `)?` +
+	`\s*\)?$`,
+)
+
+func rangeChecker(sp tSchemaParser, _ yaml.Node, formMap tFormMap) (tExpression, []error) {
+	rangeValueNode := formMap["_range"]
+	if rangeValueNode.Tag != "!!str" {
+		return nil, sp.at("_range").schemaError(rangeValueNode, ErrorCodeKind, "a string (a range, e.g. `(0 <= int < 10)`)")
+	}
+
+	rangeString := rangeValueNode.Value
+
+	submatch := regexRange.FindStringSubmatch(rangeString)
+	if submatch == nil || (submatch[1] == "" && submatch[5] == "") {
+		return nil, sp.at("_range").schemaError(rangeValueNode, ErrorCodeValue, "a valid range, such as `(1 <= int <= 9)`, `(0 < float)` or `(float < 10)`")
+	}
+
+	errList := errorlist.List{}
+
+	readBound := func(number string, operator string) tRangeBound {
+		if number == "" {
+			return tRangeBound{}
+		}
+
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			errList.Push(sp.at("_range").schemaError(rangeValueNode, ErrorCodeValue, fmt.Sprintf("a valid range bound (error: '%s')", err.Error())))
+		} else if submatch[3] == "int" && value != math.Trunc(value) {
+			errList.Push(sp.at("_range").schemaError(rangeValueNode, ErrorCodeValue, fmt.Sprintf("integer bounds for an int range (got %s)", number)))
+		}
+
+		return tRangeBound{
+			present:   true,
+			inclusive: operator == "<=",
+			value:     value,
+		}
+	}
+
+	rng := tRange{
+		rangeString: rangeString,
+		kind:        submatch[3],
+		min:         readBound(submatch[1], submatch[2]),
+		max:         readBound(submatch[5], submatch[4]),
+	}
+
+	if rng.min.present && rng.max.present {
+		if rng.min.value > rng.max.value ||
+			(rng.min.value == rng.max.value && !(rng.min.inclusive && rng.max.inclusive)) {
+			errList.Push(sp.at("_range").schemaError(rangeValueNode, ErrorCodeValue, "a non-empty range"))
+		}
+	}
+
+	return rng, errList.ConcatError()
+}
@@ -0,0 +1,213 @@
+package lidy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyContentFormat.go
+//
+// The content decoders, which read the JSON, HJSON and TOML documents into
+// yaml.Node trees, so that the schemas check them like YAML documents. See
+// lidyContentJson.go and lidyContentToml.go
+
+// ContentDecoder -- decode a content document into a YAML document node.
+// The nodes have the line and the column, from 1, of the values they come from
+type ContentDecoder func(content []byte) (yaml.Node, error)
+
+var _ ContentDecoder = DecodeYaml
+var _ ContentDecoder = DecodeJson
+var _ ContentDecoder = DecodeHjson
+var _ ContentDecoder = DecodeToml
+
+// ContentDecoderFor -- the decoder of a file, chosen by its extension:
+// DecodeJson for `.json`, DecodeHjson for `.hjson` and DecodeToml for
+// `.toml`. It is nil for the YAML files, and for the unknown extensions
+func ContentDecoderFor(filename string) ContentDecoder {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		return DecodeJson
+	case ".hjson":
+		return DecodeHjson
+	case ".toml":
+		return DecodeToml
+	}
+	return nil
+}
+
+// DecodeYaml -- decode a YAML document
+func DecodeYaml(content []byte) (yaml.Node, error) {
+	node := yaml.Node{}
+
+	err := yaml.Unmarshal(content, &node)
+	if err != nil {
+		return yaml.Node{}, err
+	}
+
+	if node.Kind == 0 {
+		if len(content) == 0 {
+			return yaml.Node{}, fmt.Errorf("yaml: the file is empty")
+		}
+		return yaml.Node{}, fmt.Errorf("INTERNAL yaml.Unmarshal failed silently for content [`%s`]. %s", string(content), pleaseReport)
+	}
+
+	return node, nil
+}
+
+//
+// Scanner
+//
+
+// tTextScanner -- reads a text rune by rune, tracking the line and the column
+// of the next rune
+type tTextScanner struct {
+	// format
+	// the name of the format, prefixing the errors, e.g. `json`
+	format string
+	text   []rune
+	offset int
+	line   int
+	column int
+}
+
+func newTextScanner(format string, content []byte) *tTextScanner {
+	return &tTextScanner{
+		format: format,
+		text:   []rune(string(content)),
+		line:   1,
+		column: 1,
+	}
+}
+
+// peek is the next rune, or -1 at the end of the text
+func (scanner *tTextScanner) peek() rune {
+	return scanner.peekAt(0)
+}
+
+// peekAt is the rune at the given distance from the next one, or -1
+func (scanner *tTextScanner) peekAt(distance int) rune {
+	if scanner.offset+distance >= len(scanner.text) {
+		return -1
+	}
+	return scanner.text[scanner.offset+distance]
+}
+
+// hasPrefix tells whether the text continues with the given text
+func (scanner *tTextScanner) hasPrefix(prefix string) bool {
+	for k, char := range []rune(prefix) {
+		if scanner.peekAt(k) != char {
+			return false
+		}
+	}
+	return true
+}
+
+func (scanner *tTextScanner) atEnd() bool {
+	return scanner.offset >= len(scanner.text)
+}
+
+// next consumes the next rune
+func (scanner *tTextScanner) next() rune {
+	char := scanner.peek()
+	if char < 0 {
+		return char
+	}
+
+	scanner.offset++
+	if char == '\n' {
+		scanner.line++
+		scanner.column = 1
+	} else {
+		scanner.column++
+	}
+	return char
+}
+
+// skip consumes the given number of runes
+func (scanner *tTextScanner) skip(count int) {
+	for k := 0; k < count; k++ {
+		scanner.next()
+	}
+}
+
+// skipLine consumes the runes up to the end of the line, excluded
+func (scanner *tTextScanner) skipLine() {
+	for !scanner.atEnd() && scanner.peek() != '\n' {
+		scanner.next()
+	}
+}
+
+// tMark -- a position of the text
+type tMark struct {
+	line   int
+	column int
+}
+
+func (scanner *tTextScanner) mark() tMark {
+	return tMark{line: scanner.line, column: scanner.column}
+}
+
+// error reports an error at the next rune
+func (scanner *tTextScanner) error(format string, argumentList ...interface{}) error {
+	return scanner.errorAt(scanner.mark(), format, argumentList...)
+}
+
+func (scanner *tTextScanner) errorAt(mark tMark, format string, argumentList ...interface{}) error {
+	return fmt.Errorf("%s: line %d, column %d: %s", scanner.format, mark.line, mark.column, fmt.Sprintf(format, argumentList...))
+}
+
+// unexpected reports the next rune as unexpected
+func (scanner *tTextScanner) unexpected(expected string) error {
+	if scanner.atEnd() {
+		return scanner.error("unexpected end of file, expected %s", expected)
+	}
+	return scanner.error("unexpected character %q, expected %s", scanner.peek(), expected)
+}
+
+//
+// Nodes
+//
+
+func scalarNodeAt(mark tMark, tag string, value string, style yaml.Style) *yaml.Node {
+	return &yaml.Node{
+		Kind:   yaml.ScalarNode,
+		Tag:    tag,
+		Value:  value,
+		Style:  style,
+		Line:   mark.line,
+		Column: mark.column,
+	}
+}
+
+func mappingNodeAt(mark tMark, style yaml.Style) *yaml.Node {
+	return &yaml.Node{
+		Kind:   yaml.MappingNode,
+		Tag:    "!!map",
+		Style:  style,
+		Line:   mark.line,
+		Column: mark.column,
+	}
+}
+
+func sequenceNodeAt(mark tMark, style yaml.Style) *yaml.Node {
+	return &yaml.Node{
+		Kind:   yaml.SequenceNode,
+		Tag:    "!!seq",
+		Style:  style,
+		Line:   mark.line,
+		Column: mark.column,
+	}
+}
+
+// documentNode wraps the root of a decoded document
+func documentNode(root *yaml.Node) yaml.Node {
+	return yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Line:    root.Line,
+		Column:  root.Column,
+		Content: []*yaml.Node{root},
+	}
+}
@@ -0,0 +1,439 @@
+package lidy
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyContentJson.go
+//
+// Decode the JSON and the HJSON documents. HJSON is JSON with comments,
+// optional commas and root braces, quoteless keys and strings, single-quoted
+// strings and `'''` multi-line strings; see https://hjson.github.io/syntax.html
+
+var regexJsonNumber = *regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// DecodeJson -- decode a JSON document. The keys of an object must be unique
+func DecodeJson(content []byte) (yaml.Node, error) {
+	return decodeJsonLike(content, false)
+}
+
+// DecodeHjson -- decode an HJSON document. The keys of an object must be unique
+func DecodeHjson(content []byte) (yaml.Node, error) {
+	return decodeJsonLike(content, true)
+}
+
+// tJsonParser -- a recursive descent parser of JSON, or of HJSON
+type tJsonParser struct {
+	*tTextScanner
+	hjson bool
+}
+
+func decodeJsonLike(content []byte, hjson bool) (yaml.Node, error) {
+	format := "json"
+	if hjson {
+		format = "hjson"
+	}
+
+	parser := tJsonParser{tTextScanner: newTextScanner(format, content), hjson: hjson}
+
+	parser.skipSpace()
+	if parser.atEnd() {
+		return yaml.Node{}, parser.error("the file is empty")
+	}
+
+	var root *yaml.Node
+	var err error
+
+	if hjson && parser.peek() != '{' && parser.peek() != '[' {
+		root, err = parser.rootObject()
+	} else {
+		root, err = parser.value()
+	}
+	if err != nil {
+		return yaml.Node{}, err
+	}
+
+	parser.skipSpace()
+	if !parser.atEnd() {
+		return yaml.Node{}, parser.unexpected("the end of the file")
+	}
+
+	return documentNode(root), nil
+}
+
+// rootObject parses the HJSON root object, whose braces are omitted, or the
+// root value, if the document is not an object
+func (parser *tJsonParser) rootObject() (*yaml.Node, error) {
+	scanner := *parser.tTextScanner
+
+	root, err := parser.members(mappingNodeAt(parser.mark(), 0), -1)
+	if err == nil {
+		return root, nil
+	}
+
+	// a single value, e.g. a quoteless string
+	*parser.tTextScanner = scanner
+	root, valueErr := parser.value()
+	parser.skipSpace()
+	if valueErr != nil || !parser.atEnd() {
+		return nil, err
+	}
+	return root, nil
+}
+
+// skipSpace skips the whitespace, and the comments of HJSON
+func (parser *tJsonParser) skipSpace() {
+	for {
+		switch char := parser.peek(); {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			parser.next()
+		case parser.hjson && (char == '#' || parser.hasPrefix("//")):
+			parser.skipLine()
+		case parser.hjson && parser.hasPrefix("/*"):
+			parser.skip(2)
+			for !parser.atEnd() && !parser.hasPrefix("*/") {
+				parser.next()
+			}
+			parser.skip(2)
+		default:
+			return
+		}
+	}
+}
+
+func (parser *tJsonParser) value() (*yaml.Node, error) {
+	mark := parser.mark()
+
+	switch char := parser.peek(); {
+	case char == '{':
+		parser.next()
+		return parser.members(mappingNodeAt(mark, yaml.FlowStyle), '}')
+	case char == '[':
+		return parser.array()
+	case char == '"':
+		value, err := parser.quotedString('"')
+		return scalarNodeAt(mark, "!!str", value, yaml.DoubleQuotedStyle), err
+	case parser.hjson && parser.hasPrefix("'''"):
+		value, err := parser.multilineString()
+		return scalarNodeAt(mark, "!!str", value, yaml.LiteralStyle), err
+	case parser.hjson && char == '\'':
+		value, err := parser.quotedString('\'')
+		return scalarNodeAt(mark, "!!str", value, yaml.SingleQuotedStyle), err
+	case parser.hjson:
+		return parser.quotelessValue()
+	}
+
+	// JSON literals
+	scanner := *parser.tTextScanner
+	token := ""
+	for char := parser.peek(); char == '-' || char == '+' || char == '.' || ('0' <= char && char <= '9') || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z'); char = parser.peek() {
+		token += string(parser.next())
+	}
+
+	node := jsonLiteral(mark, token)
+	if node == nil {
+		*parser.tTextScanner = scanner
+		return nil, parser.unexpected("a JSON value")
+	}
+	return node, nil
+}
+
+// jsonLiteral converts a number, `true`, `false` or `null`, or returns nil
+func jsonLiteral(mark tMark, token string) *yaml.Node {
+	switch token {
+	case "true", "false":
+		return scalarNodeAt(mark, "!!bool", token, 0)
+	case "null":
+		return scalarNodeAt(mark, "!!null", token, 0)
+	}
+
+	if !regexJsonNumber.MatchString(token) {
+		return nil
+	}
+	if strings.ContainsAny(token, ".eE") {
+		return scalarNodeAt(mark, "!!float", token, 0)
+	}
+	return scalarNodeAt(mark, "!!int", token, 0)
+}
+
+// members parses the members of an object, up to the closing brace, or up to
+// the end of the file if closing is -1. The opening brace is consumed
+func (parser *tJsonParser) members(mapping *yaml.Node, closing rune) (*yaml.Node, error) {
+	keySet := map[string]bool{}
+
+	for {
+		parser.skipSpace()
+
+		if closing < 0 && parser.atEnd() {
+			return mapping, nil
+		}
+		if closing > 0 && parser.peek() == closing {
+			parser.next()
+			return mapping, nil
+		}
+
+		keyMark := parser.mark()
+		key, err := parser.key()
+		if err != nil {
+			return nil, err
+		}
+		if keySet[key] {
+			return nil, parser.errorAt(keyMark, "duplicate key %q", key)
+		}
+		keySet[key] = true
+
+		parser.skipSpace()
+		if parser.peek() != ':' {
+			return nil, parser.unexpected("':'")
+		}
+		parser.next()
+		parser.skipSpace()
+
+		value, err := parser.value()
+		if err != nil {
+			return nil, err
+		}
+
+		mapping.Content = append(mapping.Content, scalarNodeAt(keyMark, "!!str", key, 0), value)
+
+		if !parser.separator(closing) {
+			return nil, parser.unexpected("',' or '" + string(closing) + "'")
+		}
+	}
+}
+
+func (parser *tJsonParser) array() (*yaml.Node, error) {
+	sequence := sequenceNodeAt(parser.mark(), yaml.FlowStyle)
+	parser.next()
+
+	for {
+		parser.skipSpace()
+
+		if parser.peek() == ']' {
+			parser.next()
+			return sequence, nil
+		}
+
+		value, err := parser.value()
+		if err != nil {
+			return nil, err
+		}
+		sequence.Content = append(sequence.Content, value)
+
+		if !parser.separator(']') {
+			return nil, parser.unexpected("',' or ']'")
+		}
+	}
+}
+
+// separator consumes the comma following a member or an item. In HJSON, the
+// comma is optional, and may precede the closing bracket. In JSON, it must be
+// followed by another member or item
+func (parser *tJsonParser) separator(closing rune) bool {
+	lineBefore := parser.line
+	parser.skipSpace()
+
+	if parser.peek() == ',' {
+		parser.next()
+		if parser.hjson {
+			return true
+		}
+
+		parser.skipSpace()
+		return parser.peek() != closing && !parser.atEnd()
+	}
+
+	if parser.peek() == closing || (closing < 0 && parser.atEnd()) {
+		return true
+	}
+
+	// a new line separates the members and items of HJSON
+	return parser.hjson && parser.line > lineBefore
+}
+
+// key parses the key of a member; a string, or a quoteless HJSON key
+func (parser *tJsonParser) key() (string, error) {
+	switch char := parser.peek(); {
+	case char == '"':
+		return parser.quotedString('"')
+	case parser.hjson && char == '\'':
+		return parser.quotedString('\'')
+	case !parser.hjson:
+		return "", parser.unexpected("a string key")
+	}
+
+	key := ""
+	for char := parser.peek(); char >= 0 && !strings.ContainsRune(" \t\r\n{}[],:", char); char = parser.peek() {
+		key += string(parser.next())
+	}
+
+	if key == "" {
+		return "", parser.unexpected("a key")
+	}
+	return key, nil
+}
+
+// quotedString parses a string delimited with the given quote, and its
+// escape sequences
+func (parser *tJsonParser) quotedString(quote rune) (string, error) {
+	parser.next()
+	builder := strings.Builder{}
+
+	for {
+		char := parser.peek()
+
+		switch {
+		case char < 0 || char == '\n':
+			return "", parser.unexpected("the end of the string")
+		case char == quote:
+			parser.next()
+			return builder.String(), nil
+		case char < ' ':
+			return "", parser.error("control character %q in string", char)
+		case char == '\\':
+			escaped, err := parser.escape(quote)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteRune(escaped)
+		default:
+			builder.WriteRune(parser.next())
+		}
+	}
+}
+
+// escape parses an escape sequence, including the surrogate pairs of `\u`
+func (parser *tJsonParser) escape(quote rune) (rune, error) {
+	mark := parser.mark()
+	parser.next()
+
+	char := parser.next()
+	switch char {
+	case '"', '\\', '/':
+		return char, nil
+	case '\'':
+		if quote == '\'' {
+			return char, nil
+		}
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		high, ok := parser.hexRune()
+		if !ok {
+			return 0, parser.errorAt(mark, "invalid \\u escape sequence")
+		}
+		if !utf16.IsSurrogate(high) {
+			return high, nil
+		}
+		if !parser.hasPrefix(`\u`) {
+			return utf16.DecodeRune(high, 0), nil
+		}
+		parser.skip(2)
+		low, ok := parser.hexRune()
+		if !ok {
+			return 0, parser.errorAt(mark, "invalid \\u escape sequence")
+		}
+		return utf16.DecodeRune(high, low), nil
+	}
+
+	return 0, parser.errorAt(mark, "invalid escape sequence")
+}
+
+// hexRune reads the 4 hexadecimal digits of a `\u` escape sequence
+func (parser *tJsonParser) hexRune() (rune, bool) {
+	digitList := ""
+	for k := 0; k < 4; k++ {
+		digitList += string(parser.next())
+	}
+
+	value, err := strconv.ParseUint(digitList, 16, 16)
+	return rune(value), err == nil
+}
+
+// multilineString parses a triple-quoted HJSON string. The indentation of
+// the opening quotes is removed from each line, as are the new lines
+// following the opening quotes and preceding the closing quotes
+func (parser *tJsonParser) multilineString() (string, error) {
+	indentation := parser.column - 1
+	parser.skip(3)
+
+	// the rest of the first line, if it is blank, is ignored
+	for parser.peek() == ' ' || parser.peek() == '\t' {
+		parser.next()
+	}
+	if parser.peek() == '\r' {
+		parser.next()
+	}
+	if parser.peek() == '\n' {
+		parser.next()
+		parser.skipIndentation(indentation)
+	}
+
+	builder := strings.Builder{}
+	for !parser.hasPrefix("'''") {
+		if parser.atEnd() {
+			return "", parser.unexpected("'''")
+		}
+
+		char := parser.next()
+		if char == '\r' {
+			continue
+		}
+		builder.WriteRune(char)
+		if char == '\n' {
+			parser.skipIndentation(indentation)
+		}
+	}
+	parser.skip(3)
+
+	return strings.TrimSuffix(builder.String(), "\n"), nil
+}
+
+// skipIndentation skips up to the given number of spaces
+func (parser *tJsonParser) skipIndentation(indentation int) {
+	for k := 0; k < indentation && (parser.peek() == ' ' || parser.peek() == '\t'); k++ {
+		parser.next()
+	}
+}
+
+// quotelessValue parses an HJSON value without quotes. It is a number,
+// `true`, `false` or `null` if that is all the line holds, up to a comma, a
+// closing bracket or a comment. Otherwise, it is a string extending to the
+// end of the line
+func (parser *tJsonParser) quotelessValue() (*yaml.Node, error) {
+	mark := parser.mark()
+
+	if strings.ContainsRune("{}[],:", parser.peek()) {
+		return nil, parser.unexpected("a value")
+	}
+
+	text := ""
+	for {
+		char := parser.peek()
+
+		if char < 0 || char == '\n' || char == ',' || char == '}' || char == ']' || char == '#' || parser.hasPrefix("//") || parser.hasPrefix("/*") {
+			if node := jsonLiteral(mark, strings.TrimSpace(text)); node != nil {
+				return node, nil
+			}
+		}
+
+		if char < 0 || char == '\n' {
+			return scalarNodeAt(mark, "!!str", strings.TrimSpace(text), 0), nil
+		}
+
+		text += string(parser.next())
+	}
+}
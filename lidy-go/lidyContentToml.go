@@ -0,0 +1,634 @@
+package lidy
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyContentToml.go
+//
+// Decode the TOML documents; see https://toml.io/en/v1.0.0
+//
+// The integers are given in decimal, whatever their base. The date-times and
+// the local dates are `!!timestamp` scalars, whose date and time are joined
+// with a `T`. The local times are strings.
+
+var regexTomlBareKey = *regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var regexTomlDecimal = *regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+var regexTomlPrefixed = *regexp.MustCompile(`^(0x[0-9A-Fa-f](_?[0-9A-Fa-f])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*)$`)
+var regexTomlFloat = *regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)((\.[0-9](_?[0-9])*)([eE][+-]?[0-9](_?[0-9])*)?|[eE][+-]?[0-9](_?[0-9])*)$`)
+var regexTomlDate = *regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+var regexTomlDateTime = *regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[+-][0-9]{2}:[0-9]{2})?$`)
+var regexTomlTime = *regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?$`)
+
+// DecodeToml -- decode a TOML document. The keys must be unique, and the
+// tables defined once
+func DecodeToml(content []byte) (yaml.Node, error) {
+	parser := tTomlParser{
+		tTextScanner: newTextScanner("toml", content),
+		keyMap:       map[*yaml.Node]map[string]*yaml.Node{},
+		definedSet:   map[*yaml.Node]bool{},
+		dottedSet:    map[*yaml.Node]bool{},
+		frozenSet:    map[*yaml.Node]bool{},
+		tableListSet: map[*yaml.Node]bool{},
+	}
+
+	if len(content) == 0 {
+		return yaml.Node{}, parser.error("the file is empty")
+	}
+
+	root := mappingNodeAt(parser.mark(), 0)
+	table := root
+
+	for {
+		parser.skipBlank(true)
+		if parser.atEnd() {
+			return documentNode(root), nil
+		}
+
+		var err error
+		switch {
+		case parser.hasPrefix("[["):
+			table, err = parser.tableListHeader(root)
+		case parser.peek() == '[':
+			table, err = parser.tableHeader(root)
+		default:
+			err = parser.keyValue(table)
+		}
+		if err != nil {
+			return yaml.Node{}, err
+		}
+
+		if err := parser.endOfLine(); err != nil {
+			return yaml.Node{}, err
+		}
+	}
+}
+
+// tTomlParser -- a recursive descent parser of TOML
+type tTomlParser struct {
+	*tTextScanner
+	// keyMap
+	// the value of each key of each table, to find the tables and reject the
+	// duplicate keys
+	keyMap map[*yaml.Node]map[string]*yaml.Node
+	// definedSet
+	// the tables defined by a header, which cannot be defined again
+	definedSet map[*yaml.Node]bool
+	// dottedSet
+	// the tables defined by a dotted key, which no header can define
+	dottedSet map[*yaml.Node]bool
+	// frozenSet
+	// the inline tables and the arrays, which are complete once written
+	frozenSet map[*yaml.Node]bool
+	// tableListSet
+	// the arrays of tables, which `[[...]]` headers append to
+	tableListSet map[*yaml.Node]bool
+}
+
+// skipBlank skips the spaces and the comments, and the new lines if asked
+func (parser *tTomlParser) skipBlank(newLine bool) {
+	for {
+		switch char := parser.peek(); {
+		case char == ' ' || char == '\t':
+			parser.next()
+		case char == '#':
+			parser.skipLine()
+		case newLine && (char == '\n' || parser.hasPrefix("\r\n")):
+			parser.next()
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine consumes the rest of the line, which must be blank
+func (parser *tTomlParser) endOfLine() error {
+	parser.skipBlank(false)
+
+	if parser.peek() == '\r' {
+		parser.next()
+	}
+	if !parser.atEnd() && parser.peek() != '\n' {
+		return parser.unexpected("the end of the line")
+	}
+	return nil
+}
+
+//
+// Keys and tables
+//
+
+// tTomlKey -- a key, and the position where it is written
+type tTomlKey struct {
+	name string
+	mark tMark
+}
+
+// key parses a dotted key, of bare and quoted keys
+func (parser *tTomlParser) key() ([]tTomlKey, error) {
+	keyList := []tTomlKey{}
+
+	for {
+		parser.skipBlank(false)
+		mark := parser.mark()
+
+		var name string
+		var err error
+
+		switch parser.peek() {
+		case '"':
+			name, err = parser.basicString()
+		case '\'':
+			name, err = parser.literalString()
+		default:
+			for char := parser.peek(); char >= 0 && strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-", char); char = parser.peek() {
+				name += string(parser.next())
+			}
+			if !regexTomlBareKey.MatchString(name) {
+				return nil, parser.unexpected("a key")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keyList = append(keyList, tTomlKey{name: name, mark: mark})
+
+		parser.skipBlank(false)
+		if parser.peek() != '.' {
+			return keyList, nil
+		}
+		parser.next()
+	}
+}
+
+// set adds a key to a table
+func (parser *tTomlParser) set(table *yaml.Node, key tTomlKey, value *yaml.Node) {
+	if parser.keyMap[table] == nil {
+		parser.keyMap[table] = map[string]*yaml.Node{}
+	}
+	parser.keyMap[table][key.name] = value
+	table.Content = append(table.Content, scalarNodeAt(key.mark, "!!str", key.name, 0), value)
+}
+
+// subTable finds or creates the table of a key of a dotted key or of a
+// header. The last table of an array of tables is used. Only headers may go
+// through the tables of headers, and nothing goes into an inline table
+func (parser *tTomlParser) subTable(table *yaml.Node, key tTomlKey, header bool) (*yaml.Node, error) {
+	value := parser.keyMap[table][key.name]
+
+	if value == nil {
+		value = mappingNodeAt(key.mark, 0)
+		parser.set(table, key, value)
+		if !header {
+			parser.dottedSet[value] = true
+		}
+		return value, nil
+	}
+
+	if value.Kind == yaml.SequenceNode && parser.tableListSet[value] && header {
+		return value.Content[len(value.Content)-1], nil
+	}
+
+	if value.Kind != yaml.MappingNode || parser.frozenSet[value] || (!header && parser.definedSet[value]) {
+		return nil, parser.errorAt(key.mark, "key %q is already defined", key.name)
+	}
+	return value, nil
+}
+
+// tableHeader parses a `[...]` header, giving the table it defines
+func (parser *tTomlParser) tableHeader(root *yaml.Node) (*yaml.Node, error) {
+	mark := parser.mark()
+	parser.next()
+
+	keyList, err := parser.key()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek() != ']' {
+		return nil, parser.unexpected("']'")
+	}
+	parser.next()
+
+	table := root
+	for _, key := range keyList[:len(keyList)-1] {
+		table, err = parser.subTable(table, key, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastKey := keyList[len(keyList)-1]
+	value := parser.keyMap[table][lastKey.name]
+
+	if value == nil {
+		value = mappingNodeAt(mark, 0)
+		parser.set(table, lastKey, value)
+	} else if value.Kind != yaml.MappingNode || parser.definedSet[value] || parser.dottedSet[value] || parser.frozenSet[value] {
+		return nil, parser.errorAt(mark, "table [%s] is already defined", tomlKeyText(keyList))
+	}
+	parser.definedSet[value] = true
+
+	return value, nil
+}
+
+// tableListHeader parses a `[[...]]` header, appending a table to an array
+// of tables, and giving that table
+func (parser *tTomlParser) tableListHeader(root *yaml.Node) (*yaml.Node, error) {
+	mark := parser.mark()
+	parser.skip(2)
+
+	keyList, err := parser.key()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.hasPrefix("]]") {
+		return nil, parser.unexpected("']]'")
+	}
+	parser.skip(2)
+
+	table := root
+	for _, key := range keyList[:len(keyList)-1] {
+		table, err = parser.subTable(table, key, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastKey := keyList[len(keyList)-1]
+	tableList := parser.keyMap[table][lastKey.name]
+
+	if tableList == nil {
+		tableList = sequenceNodeAt(lastKey.mark, 0)
+		parser.tableListSet[tableList] = true
+		parser.set(table, lastKey, tableList)
+	} else if !parser.tableListSet[tableList] {
+		return nil, parser.errorAt(mark, "key %q is already defined, as something else than an array of tables", tomlKeyText(keyList))
+	}
+
+	table = mappingNodeAt(mark, 0)
+	tableList.Content = append(tableList.Content, table)
+
+	return table, nil
+}
+
+func tomlKeyText(keyList []tTomlKey) string {
+	nameList := []string{}
+	for _, key := range keyList {
+		nameList = append(nameList, key.name)
+	}
+	return strings.Join(nameList, ".")
+}
+
+// keyValue parses a `key = value` pair into the table
+func (parser *tTomlParser) keyValue(table *yaml.Node) error {
+	keyList, err := parser.key()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keyList[:len(keyList)-1] {
+		table, err = parser.subTable(table, key, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	lastKey := keyList[len(keyList)-1]
+	if parser.keyMap[table][lastKey.name] != nil {
+		return parser.errorAt(lastKey.mark, "duplicate key %q", tomlKeyText(keyList))
+	}
+
+	if parser.peek() != '=' {
+		return parser.unexpected("'='")
+	}
+	parser.next()
+	parser.skipBlank(false)
+
+	value, err := parser.value()
+	if err != nil {
+		return err
+	}
+
+	parser.set(table, lastKey, value)
+	return nil
+}
+
+//
+// Values
+//
+
+func (parser *tTomlParser) value() (*yaml.Node, error) {
+	mark := parser.mark()
+
+	switch char := parser.peek(); {
+	case parser.hasPrefix(`"""`):
+		value, err := parser.multilineString('"')
+		return scalarNodeAt(mark, "!!str", value, yaml.LiteralStyle), err
+	case char == '"':
+		value, err := parser.basicString()
+		return scalarNodeAt(mark, "!!str", value, yaml.DoubleQuotedStyle), err
+	case parser.hasPrefix("'''"):
+		value, err := parser.multilineString('\'')
+		return scalarNodeAt(mark, "!!str", value, yaml.LiteralStyle), err
+	case char == '\'':
+		value, err := parser.literalString()
+		return scalarNodeAt(mark, "!!str", value, yaml.SingleQuotedStyle), err
+	case char == '[':
+		return parser.array()
+	case char == '{':
+		return parser.inlineTable()
+	}
+
+	token := parser.token()
+
+	// a date followed by a space and a time is a single date-time
+	if regexTomlDate.MatchString(token) && parser.peek() == ' ' && '0' <= parser.peekAt(1) && parser.peekAt(1) <= '9' {
+		scanner := *parser.tTextScanner
+		parser.next()
+		if dateTime := token + " " + parser.token(); regexTomlDateTime.MatchString(dateTime) {
+			token = dateTime
+		} else {
+			*parser.tTextScanner = scanner
+		}
+	}
+
+	node := tomlLiteral(mark, token)
+	if node == nil {
+		return nil, parser.errorAt(mark, "invalid value %q", token)
+	}
+	return node, nil
+}
+
+// token reads the characters of a number, a boolean or a date-time
+func (parser *tTomlParser) token() string {
+	token := ""
+	for char := parser.peek(); char >= 0 && strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_+-.:", char); char = parser.peek() {
+		token += string(parser.next())
+	}
+	return token
+}
+
+// tomlLiteral converts a boolean, a number or a date-time, or returns nil
+func tomlLiteral(mark tMark, token string) *yaml.Node {
+	switch token {
+	case "true", "false":
+		return scalarNodeAt(mark, "!!bool", token, 0)
+	case "inf", "+inf":
+		return scalarNodeAt(mark, "!!float", ".inf", 0)
+	case "-inf":
+		return scalarNodeAt(mark, "!!float", "-.inf", 0)
+	case "nan", "+nan", "-nan":
+		return scalarNodeAt(mark, "!!float", ".nan", 0)
+	}
+
+	switch {
+	case regexTomlDecimal.MatchString(token), regexTomlPrefixed.MatchString(token):
+		value, err := strconv.ParseInt(strings.ReplaceAll(token, "_", ""), 0, 64)
+		if err != nil {
+			return nil
+		}
+		return scalarNodeAt(mark, "!!int", strconv.FormatInt(value, 10), 0)
+	case regexTomlFloat.MatchString(token):
+		return scalarNodeAt(mark, "!!float", strings.ReplaceAll(token, "_", ""), 0)
+	case regexTomlDate.MatchString(token):
+		return scalarNodeAt(mark, "!!timestamp", token, 0)
+	case regexTomlDateTime.MatchString(token):
+		return scalarNodeAt(mark, "!!timestamp", token[:10]+"T"+token[11:], 0)
+	case regexTomlTime.MatchString(token):
+		return scalarNodeAt(mark, "!!str", token, 0)
+	}
+
+	return nil
+}
+
+// array parses an array, which may span several lines and hold comments
+func (parser *tTomlParser) array() (*yaml.Node, error) {
+	sequence := sequenceNodeAt(parser.mark(), yaml.FlowStyle)
+	parser.frozenSet[sequence] = true
+	parser.next()
+
+	for {
+		parser.skipBlank(true)
+		if parser.peek() == ']' {
+			parser.next()
+			return sequence, nil
+		}
+
+		value, err := parser.value()
+		if err != nil {
+			return nil, err
+		}
+		sequence.Content = append(sequence.Content, value)
+
+		parser.skipBlank(true)
+		switch parser.peek() {
+		case ',':
+			parser.next()
+		case ']':
+		default:
+			return nil, parser.unexpected("',' or ']'")
+		}
+	}
+}
+
+// inlineTable parses a `{...}` table, which fits on one line
+func (parser *tTomlParser) inlineTable() (*yaml.Node, error) {
+	table := mappingNodeAt(parser.mark(), yaml.FlowStyle)
+	parser.next()
+	parser.skipBlank(false)
+
+	if parser.peek() == '}' {
+		parser.next()
+		parser.frozenSet[table] = true
+		return table, nil
+	}
+
+	for {
+		if err := parser.keyValue(table); err != nil {
+			return nil, err
+		}
+
+		parser.skipBlank(false)
+		switch parser.peek() {
+		case ',':
+			parser.next()
+			parser.skipBlank(false)
+			continue
+		case '}':
+			parser.next()
+			parser.freeze(table)
+			return table, nil
+		}
+		return nil, parser.unexpected("',' or '}'")
+	}
+}
+
+// freeze marks an inline table and the tables of its dotted keys as complete
+func (parser *tTomlParser) freeze(table *yaml.Node) {
+	parser.frozenSet[table] = true
+	for k := 1; k < len(table.Content); k += 2 {
+		if table.Content[k].Kind == yaml.MappingNode {
+			parser.freeze(table.Content[k])
+		}
+	}
+}
+
+//
+// Strings
+//
+
+// basicString parses a `"` string, and its escape sequences
+func (parser *tTomlParser) basicString() (string, error) {
+	parser.next()
+	builder := strings.Builder{}
+
+	for {
+		char := parser.peek()
+
+		switch {
+		case char < 0 || char == '\n':
+			return "", parser.unexpected("'\"'")
+		case char == '"':
+			parser.next()
+			return builder.String(), nil
+		case char == '\\':
+			escaped, err := parser.escape()
+			if err != nil {
+				return "", err
+			}
+			builder.WriteRune(escaped)
+		case char < ' ' && char != '\t':
+			return "", parser.error("control character %q in string", char)
+		default:
+			builder.WriteRune(parser.next())
+		}
+	}
+}
+
+// literalString parses a `'` string, which has no escape sequence
+func (parser *tTomlParser) literalString() (string, error) {
+	parser.next()
+	builder := strings.Builder{}
+
+	for {
+		char := parser.peek()
+
+		switch {
+		case char < 0 || char == '\n':
+			return "", parser.unexpected("\"'\"")
+		case char == '\'':
+			parser.next()
+			return builder.String(), nil
+		default:
+			builder.WriteRune(parser.next())
+		}
+	}
+}
+
+// escape parses an escape sequence of a basic string
+func (parser *tTomlParser) escape() (rune, error) {
+	mark := parser.mark()
+	parser.next()
+
+	char := parser.next()
+	switch char {
+	case '"', '\\':
+		return char, nil
+	case 'b':
+		return '\b', nil
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'f':
+		return '\f', nil
+	case 'r':
+		return '\r', nil
+	case 'u', 'U':
+		length := 4
+		if char == 'U' {
+			length = 8
+		}
+
+		digitList := ""
+		for k := 0; k < length; k++ {
+			digitList += string(parser.next())
+		}
+
+		value, err := strconv.ParseUint(digitList, 16, 32)
+		if err == nil && value <= 0x10FFFF && !(0xD800 <= value && value <= 0xDFFF) {
+			return rune(value), nil
+		}
+		return 0, parser.errorAt(mark, "invalid \\%c escape sequence", char)
+	}
+
+	return 0, parser.errorAt(mark, "invalid escape sequence")
+}
+
+// multilineString parses a triple-quoted string, basic or literal. A new line
+// following the opening quotes is removed. In the basic strings, a backslash
+// ending a line removes the new line and the whitespace that follow
+func (parser *tTomlParser) multilineString(quote rune) (string, error) {
+	delimiter := strings.Repeat(string(quote), 3)
+	parser.skip(3)
+
+	if parser.hasPrefix("\r\n") {
+		parser.next()
+	}
+	if parser.peek() == '\n' {
+		parser.next()
+	}
+
+	builder := strings.Builder{}
+	for {
+		char := parser.peek()
+
+		switch {
+		case char < 0:
+			return "", parser.unexpected(delimiter)
+		case parser.hasPrefix(delimiter):
+			// up to two quotes may precede the closing ones
+			count := 3
+			for count < 5 && parser.peekAt(count) == quote {
+				count++
+			}
+			builder.WriteString(strings.Repeat(string(quote), count-3))
+			parser.skip(count)
+			return builder.String(), nil
+		case quote == '"' && char == '\\' && parser.lineEndingBackslash():
+			parser.next()
+			for char := parser.peek(); char == ' ' || char == '\t' || char == '\r' || char == '\n'; char = parser.peek() {
+				parser.next()
+			}
+		case quote == '"' && char == '\\':
+			escaped, err := parser.escape()
+			if err != nil {
+				return "", err
+			}
+			builder.WriteRune(escaped)
+		case char == '\r' && parser.peekAt(1) == '\n':
+			parser.next()
+		default:
+			builder.WriteRune(parser.next())
+		}
+	}
+}
+
+// lineEndingBackslash tells whether the backslash to read is only followed by
+// whitespace on its line
+func (parser *tTomlParser) lineEndingBackslash() bool {
+	for k := 1; ; k++ {
+		switch parser.peekAt(k) {
+		case ' ', '\t', '\r':
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -2,8 +2,12 @@ package lidy
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 
 	"github.com/ditrit/lidy/errorlist"
+	"gopkg.in/yaml.v3"
 )
 
 // lidyCore.go
@@ -17,6 +21,9 @@ type tSchemaParser tParser
 // parseSchema parses the schema as yaml and lidy schema and stores it
 // in the parser object, or return a non-empty error slice.
 func (p *tParser) parseSchema() []error {
+	p.schemaLock.Lock()
+	defer p.schemaLock.Unlock()
+
 	if p.schema.ruleMap != nil {
 		return p.schemaErrorSlice
 	}
@@ -56,36 +63,228 @@ func (p *tParser) parseSchema() []error {
 		errList.Push(schemaParser.processRule(ruleName))
 	}
 
+	// the dependency graph requires the expressions of all the rules
+	if len(errList.ConcatError()) == 0 {
+		p.schema.dependencyList = schemaParser.dependencyGraph()
+		errList.Push(schemaParser.checkRecursion())
+	}
+
+	// the expressions of all the rules are needed to match the defaults, and
+	// the matching must not recurse forever
+	if len(errList.ConcatError()) == 0 {
+		errList.Push(schemaParser.checkDefaultList())
+	}
+
 	p.schemaErrorSlice = errList.ConcatError()
+	p.schemaWarningSlice = schemaParser.warningList()
 
 	return p.schemaErrorSlice
 }
 
+// warningList produces the warnings requested through the parser options
+func (schemaParser *tSchemaParser) warningList() []Warning {
+	warningList := []Warning{}
+
+	userRuleNameList := []string{}
+	for ruleName := range schemaParser.schema.ruleMap {
+		if _, present := schemaParser.lidyDefaultRuleMap[ruleName]; !present {
+			userRuleNameList = append(userRuleNameList, ruleName)
+		}
+	}
+	sort.Strings(userRuleNameList)
+
+	exportNameSet := make(map[string]bool)
+
+	for _, ruleName := range userRuleNameList {
+		rule := schemaParser.schema.ruleMap[ruleName]
+
+		ruleParser := *schemaParser
+		ruleParser.currentFilename = rule._filename
+
+		// the rules of imported documents are not required to be used
+		if schemaParser.option.WarnUnusedRule && !rule._isReferenced && rule._prefix == "" && ruleName != schemaParser.target {
+			warningList = append(warningList, ruleParser.schemaWarning(
+				rule._keyNode,
+				fmt.Sprintf("rule '%s' is declared but never used", ruleName),
+			))
+		}
+
+		if rule.exportName == "" {
+			continue
+		}
+		exportNameSet[rule.exportName] = true
+
+		if schemaParser.option.WarnUnimplementedBuilder && rule.builder == nil {
+			warningList = append(warningList, ruleParser.schemaWarning(
+				rule._keyNode,
+				fmt.Sprintf("rule '%s' is exported as '%s' but no builder was provided for it", ruleName, rule.exportName),
+			))
+		}
+	}
+
+	if !schemaParser.option.IgnoreExtraBuilder {
+		builderNameList := []string{}
+		for builderName := range schemaParser.builderMap {
+			if !exportNameSet[builderName] {
+				builderNameList = append(builderNameList, builderName)
+			}
+		}
+		sort.Strings(builderNameList)
+
+		for _, builderName := range builderNameList {
+			warningList = append(warningList, &tWarning{
+				text: fmt.Sprintf("warning in schema %s, the builder '%s' does not match any exported rule", schemaParser.name, builderName),
+			})
+		}
+	}
+
+	return warningList
+}
+
 func (schemaParser *tSchemaParser) processRule(ruleName string) []error {
 	errList := errorlist.List{}
 
+	rule := schemaParser.schema.ruleMap[ruleName]
+
 	schemaParser.currentRuleName = ruleName
-	expression, erl := schemaParser.expression(schemaParser.schema.ruleMap[ruleName]._node)
-	schemaParser.currentRuleName = ""
+	schemaParser.currentFilename = rule._filename
+	schemaParser.currentPrefix = rule._prefix
+	schemaParser.schemaPath = []string{rule._keyNode.Value}
+	expression, erl := schemaParser.expression(rule._node)
 
 	if len(erl) == 0 && expression == nil {
-		node := schemaParser.schema.ruleMap[ruleName]._node
 		message := "unknown resolution error. This should not happen, " + pleaseReport
-		errList.Push(schemaParser.schemaError(node, message))
+		errList.Push(schemaParser.schemaError(rule._node, ErrorCodeInternal, message))
 	}
 
+	schemaParser.currentRuleName = ""
+	schemaParser.currentFilename = ""
+	schemaParser.currentPrefix = ""
+	schemaParser.schemaPath = nil
+
 	errList.Push(erl)
 	schemaParser.schema.ruleMap[ruleName].expression = expression
+	for _, reference := range rule._referenceList {
+		reference.expression = expression
+	}
 
 	return errList.ConcatError()
 }
 
-// parseContent apply the schema to the content
-func (p *tParser) parseContent(file File) (tResult, []error) {
+// checkDefaultList checks that the `_default` values match the expression of
+// their property. The values are matched like content, so the builders run
+func (schemaParser *tSchemaParser) checkDefaultList() []error {
+	errList := errorlist.List{}
+
+	ruleNameList := []string{}
+	for ruleName, rule := range schemaParser.schema.ruleMap {
+		if len(rule._defaultList) > 0 {
+			ruleNameList = append(ruleNameList, ruleName)
+		}
+	}
+	sort.Strings(ruleNameList)
+
+	for _, ruleName := range ruleNameList {
+		for _, propertyDefault := range schemaParser.schema.ruleMap[ruleName]._defaultList {
+			contentParser := &tContentParser{option: schemaParser.option}
+
+			_, erl := propertyDefault.match(contentParser)
+			if len(erl) == 0 {
+				continue
+			}
+
+			reasonList := []string{}
+			for _, err := range erl {
+				if contentError, ok := err.(*ContentError); ok {
+					reasonList = append(reasonList, contentError.Expected)
+				} else {
+					reasonList = append(reasonList, err.Error())
+				}
+			}
+
+			ruleParser := *schemaParser
+			ruleParser.currentRuleName = ruleName
+			ruleParser.currentFilename = propertyDefault.filename
+			ruleParser.schemaPath = propertyDefault._schemaPath
+
+			errList.Push(ruleParser.schemaError(propertyDefault.node, ErrorCodeValue, fmt.Sprintf(
+				"a _default for the property %s matching %s, i.e. %s",
+				propertyDefault.property, propertyDefault.expression.name(), strings.Join(reasonList, "; "),
+			)))
+		}
+	}
+
+	return errList.ConcatError()
+}
+
+// parseContent apply the schema to the content, and return the warnings
+// about the content
+func (p *tParser) parseContent(file File) (tResult, []Warning, []error) {
+	targetRule, erl := p.contentTarget()
+	if len(erl) > 0 {
+		return tResult{}, nil, erl
+	}
+
+	// Parsing the content
+	err := file.Yaml()
+	if err != nil {
+		return tResult{}, nil, []error{err}
+	}
+
+	return p.matchDocument(targetRule, *file.(*tFile))
+}
+
+// parseStream decodes the YAML documents of the stream one at a time, and
+// applies the schema to each of them
+func (p *tParser) parseStream(reader io.Reader) ([]StreamDocument, []error) {
+	targetRule, erl := p.contentTarget()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	// e.g. an *os.File
+	filename := ""
+	if namedReader, ok := reader.(interface{ Name() string }); ok {
+		filename = namedReader.Name()
+	}
+
+	documentList := []StreamDocument{}
+	decoder := yaml.NewDecoder(reader)
+
+	for index := 0; ; index++ {
+		contentFile := tFile{
+			name:     filename,
+			document: index,
+		}
+
+		err := decoder.Decode(&contentFile.yaml)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the decoder cannot resume after a syntax or a read error
+			documentList = append(documentList, StreamDocument{Index: index, Errors: []error{err}})
+			break
+		}
+
+		result, warningList, erl := p.matchDocument(targetRule, contentFile)
+
+		document := StreamDocument{Index: index, Warnings: warningList, Errors: erl}
+		if len(erl) == 0 {
+			document.Result = result
+		}
+		documentList = append(documentList, document)
+	}
+
+	return documentList, nil
+}
+
+// contentTarget makes sure the schema is loaded, and returns the target rule
+func (p *tParser) contentTarget() (*tRule, []error) {
 	// make sure the schema is loaded
 	erl := p.Schema()
 	if len(erl) > 0 {
-		return tResult{}, erl
+		return nil, erl
 	}
 
 	// assert that the schema is valid; that the schema parser works
@@ -105,24 +304,23 @@ func (p *tParser) parseContent(file File) (tResult, []error) {
 	// Checking that the target rule is present
 	targetRule, ruleFound := p.schema.ruleMap[p.target]
 	if !ruleFound {
-		return tResult{}, []error{fmt.Errorf("Could not find target rule '%s' in grammar", p.target)}
+		return nil, []error{fmt.Errorf("Could not find target rule '%s' in grammar", p.target)}
 	}
 
-	// Parsing the content
-	err := file.Yaml()
-	if err != nil {
-		return tResult{}, []error{err}
-	}
-
-	contentFile := file.(*tFile)
+	return targetRule, nil
+}
 
-	p.contentFile = *contentFile
-	defer (func() { p.contentFile = tFile{} })()
+// matchDocument applies the target rule to the root of a content document
+func (p *tParser) matchDocument(targetRule *tRule, contentFile tFile) (tResult, []Warning, []error) {
+	contentParser := &tContentParser{
+		option:      p.option,
+		contentFile: contentFile,
+	}
 
 	contentRoot, erl := getRoot(contentFile.yaml)
 
 	if len(erl) > 0 {
-		return tResult{}, erl
+		return tResult{}, nil, erl
 	}
 
 	// defer func() {
@@ -134,5 +332,12 @@ func (p *tParser) parseContent(file File) (tResult, []error) {
 	// 	}
 	// }()
 
-	return targetRule.match(*contentRoot, p)
+	result, erl := targetRule.match(*contentRoot, contentParser)
+
+	if contentParser.option.StopAtFirstError && len(erl) > 1 {
+		// e.g. a builder returning several errors
+		erl = erl[:1]
+	}
+
+	return result, contentParser.warningList, erl
 }
@@ -0,0 +1,383 @@
+package lidy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lidyDecode.go
+//
+// Decode Lidy results into Go values
+
+var resultType = reflect.TypeOf((*Result)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+// Decode -- fill the Go value pointed to by out with the data of a Lidy result
+//
+//   - MapData is decoded into structs and maps. The struct fields are matched
+//     with the keys of the map using the `lidy:"name"` tag, or else their name,
+//     compared case-insensitively if no key is the exact name; several keys
+//     matching that way are an error. `lidy:"-"` skips the field. Embedded
+//     structs are filled from the same map
+//   - ListData is decoded into slices and arrays; the elements of an array
+//     beyond the end of the list are zeroed
+//   - Scalars are decoded into the Go types of the same kind, and timestamps
+//     into time.Time
+//   - The outputs of the builders (HasBeenBuilt) are assigned as they are
+//   - A Result is stored as it is into fields of type Result, and as plain Go
+//     maps, slices and scalars into empty interfaces
+//
+// The returned error is a *DecodeError, carrying the position of the node
+// which could not be decoded, or an error about result or out themselves.
+func Decode(result Result, out interface{}) error {
+	if result == nil {
+		return fmt.Errorf("lidy.Decode expects a result, got nil")
+	}
+
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("lidy.Decode expects a non-nil pointer, got %T", out)
+	}
+
+	return tDecoder{}.decode(result, value.Elem())
+}
+
+type tDecoder struct {
+	// path
+	// the keys and indexes leading from the decoded result to the node being decoded
+	path []string
+}
+
+// at returns a copy of the decoder, whose path is extended by the given key or index
+func (decoder tDecoder) at(segment string) tDecoder {
+	decoder.path = appendPath(decoder.path, segment)
+	return decoder
+}
+
+func (decoder tDecoder) decode(result Result, value reflect.Value) error {
+	if value.Type() == resultType {
+		value.Set(reflect.ValueOf(result))
+		return nil
+	}
+
+	data := result.Data()
+
+	if data == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+
+	if result.HasBeenBuilt() {
+		return decoder.assign(result, data, value)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decoder.decode(result, value.Elem())
+	case reflect.Interface:
+		if value.NumMethod() == 0 {
+			value.Set(reflect.ValueOf(plainData(result)))
+			return nil
+		}
+		return decoder.assign(result, data, value)
+	}
+
+	switch data := data.(type) {
+	case MapData:
+		switch value.Kind() {
+		case reflect.Struct:
+			return decoder.decodeStruct(result, entryMap(data), value)
+		case reflect.Map:
+			return decoder.decodeMap(result, data, value)
+		}
+	case ListData:
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array:
+			return decoder.decodeList(result, append(append([]Result{}, data.List...), data.ListOf...), value)
+		}
+	case string:
+		if value.Type() == timeType {
+			timestamp, err := time.Parse(time.RFC3339Nano, data)
+			if err != nil {
+				return decoder.error(result, value.Type(), err.Error())
+			}
+			value.Set(reflect.ValueOf(timestamp))
+			return nil
+		}
+		if value.Kind() == reflect.String {
+			value.SetString(data)
+			return nil
+		}
+	case int:
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value.OverflowInt(int64(data)) {
+				return decoder.error(result, value.Type(), fmt.Sprintf("the integer %d overflows it", data))
+			}
+			value.SetInt(int64(data))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if data < 0 || value.OverflowUint(uint64(data)) {
+				return decoder.error(result, value.Type(), fmt.Sprintf("the integer %d overflows it", data))
+			}
+			value.SetUint(uint64(data))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			value.SetFloat(float64(data))
+			return nil
+		}
+	case float64:
+		switch value.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if value.OverflowFloat(data) {
+				return decoder.error(result, value.Type(), fmt.Sprintf("the float %g overflows it", data))
+			}
+			value.SetFloat(data)
+			return nil
+		}
+	case bool:
+		if value.Kind() == reflect.Bool {
+			value.SetBool(data)
+			return nil
+		}
+	}
+
+	return decoder.error(result, value.Type(), fmt.Sprintf("cannot decode a %s into it", dataName(data)))
+}
+
+// assign stores data, the output of a builder, into value, or a pointer to it
+func (decoder tDecoder) assign(result Result, data interface{}, value reflect.Value) error {
+	dataValue := reflect.ValueOf(data)
+
+	if dataValue.Type().AssignableTo(value.Type()) {
+		value.Set(dataValue)
+		return nil
+	}
+
+	if value.Kind() == reflect.Ptr && dataValue.Type().AssignableTo(value.Type().Elem()) {
+		pointer := reflect.New(value.Type().Elem())
+		pointer.Elem().Set(dataValue)
+		value.Set(pointer)
+		return nil
+	}
+
+	return decoder.error(result, value.Type(), fmt.Sprintf("the value of type %s built by rule '%s' is not assignable to it", dataValue.Type(), result.RuleName()))
+}
+
+func (decoder tDecoder) decodeStruct(result Result, entryMap map[string]Result, value reflect.Value) error {
+	structType := value.Type()
+
+	for k := 0; k < structType.NumField(); k++ {
+		field := structType.Field(k)
+		name, tagged := field.Tag.Lookup("lidy")
+
+		if name == "-" {
+			continue
+		}
+
+		// embedded structs
+		if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+			err := decoder.decodeStruct(result, entryMap, value.Field(k))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+
+		key, found := name, false
+		if tagged {
+			_, found = entryMap[key]
+		} else {
+			keyList := lookupFieldName(entryMap, field.Name)
+			if len(keyList) > 1 {
+				return decoder.error(result, structType, fmt.Sprintf("the keys '%s' all match the field %s", strings.Join(keyList, "', '"), field.Name))
+			}
+			if len(keyList) == 1 {
+				key, found = keyList[0], true
+			}
+		}
+
+		if !found {
+			continue
+		}
+
+		err := decoder.at(key).decode(entryMap[key], value.Field(k))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (decoder tDecoder) decodeMap(result Result, data MapData, value reflect.Value) error {
+	mapType := value.Type()
+
+	if value.IsNil() {
+		value.Set(reflect.MakeMap(mapType))
+	}
+
+	setEntry := func(keyDecoder tDecoder, key reflect.Value, entry Result) error {
+		element := reflect.New(mapType.Elem()).Elem()
+		err := keyDecoder.decode(entry, element)
+		if err != nil {
+			return err
+		}
+		value.SetMapIndex(key, element)
+		return nil
+	}
+
+	for name, entry := range data.Map {
+		if mapType.Key().Kind() != reflect.String {
+			return decoder.error(result, mapType, fmt.Sprintf("cannot decode the property '%s' into a key of type %s", name, mapType.Key()))
+		}
+
+		err := setEntry(decoder.at(name), reflect.ValueOf(name).Convert(mapType.Key()), entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, keyValue := range data.MapOf {
+		keyDecoder := decoder.at(fmt.Sprint(keyValue.Key.Data()))
+
+		key := reflect.New(mapType.Key()).Elem()
+		err := keyDecoder.decode(keyValue.Key, key)
+		if err != nil {
+			return err
+		}
+
+		err = setEntry(keyDecoder, key, keyValue.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (decoder tDecoder) decodeList(result Result, entryList []Result, value reflect.Value) error {
+	if value.Kind() == reflect.Array {
+		if len(entryList) > value.Len() {
+			return decoder.error(result, value.Type(), fmt.Sprintf("the list has %d entries", len(entryList)))
+		}
+	} else {
+		value.Set(reflect.MakeSlice(value.Type(), len(entryList), len(entryList)))
+	}
+
+	for k, entry := range entryList {
+		err := decoder.at(strconv.Itoa(k)).decode(entry, value.Index(k))
+		if err != nil {
+			return err
+		}
+	}
+
+	// the rest of an array
+	for k := len(entryList); k < value.Len(); k++ {
+		value.Index(k).Set(reflect.Zero(value.Type().Elem()))
+	}
+
+	return nil
+}
+
+func (decoder tDecoder) error(result Result, goType reflect.Type, reason string) error {
+	return &DecodeError{
+		tPosition: tPosition{
+			filename:  result.Filename(),
+			line:      result.Line(),
+			column:    result.Column(),
+			lineEnd:   result.LineEnd(),
+			columnEnd: result.ColumnEnd(),
+			document:  result.Document(),
+		},
+		Path:   pathString(decoder.path),
+		Type:   goType,
+		Reason: reason,
+	}
+}
+
+// entryMap gathers the entries of a MapData whose keys are strings
+func entryMap(data MapData) map[string]Result {
+	entryMap := make(map[string]Result, len(data.Map)+len(data.MapOf))
+
+	for _, keyValue := range data.MapOf {
+		if key, ok := keyValue.Key.Data().(string); ok {
+			entryMap[key] = keyValue.Value
+		}
+	}
+
+	for key, entry := range data.Map {
+		entryMap[key] = entry
+	}
+
+	return entryMap
+}
+
+// lookupFieldName finds the keys matching the name of a struct field, exactly
+// or else case-insensitively, sorted. More than one key is ambiguous
+func lookupFieldName(entryMap map[string]Result, fieldName string) []string {
+	if _, found := entryMap[fieldName]; found {
+		return []string{fieldName}
+	}
+
+	keyList := []string{}
+	for key := range entryMap {
+		if strings.EqualFold(key, fieldName) {
+			keyList = append(keyList, key)
+		}
+	}
+	sort.Strings(keyList)
+
+	return keyList
+}
+
+// plainData converts the data of a result to Go maps, slices and scalars
+func plainData(result Result) interface{} {
+	if result.HasBeenBuilt() {
+		return result.Data()
+	}
+
+	switch data := result.Data().(type) {
+	case MapData:
+		plainMap := make(map[string]interface{}, len(data.Map)+len(data.MapOf))
+		for _, keyValue := range data.MapOf {
+			plainMap[fmt.Sprint(plainData(keyValue.Key))] = plainData(keyValue.Value)
+		}
+		for key, entry := range data.Map {
+			plainMap[key] = plainData(entry)
+		}
+		return plainMap
+	case ListData:
+		plainList := make([]interface{}, 0, len(data.List)+len(data.ListOf))
+		for _, entry := range append(append([]Result{}, data.List...), data.ListOf...) {
+			plainList = append(plainList, plainData(entry))
+		}
+		return plainList
+	default:
+		return data
+	}
+}
+
+// dataName names the kind of the data of a result, for error messages
+func dataName(data interface{}) string {
+	switch data.(type) {
+	case MapData:
+		return "map"
+	case ListData:
+		return "list"
+	default:
+		return reflect.TypeOf(data).String()
+	}
+}
@@ -19,14 +19,14 @@ const regexBase64Source = `^[a-zA-Z0-9_\- \n]*[= \n]*$`
 var regexBase64 = *regexp.MustCompile(regexBase64Source)
 
 var lidyDefaultRuleMatcherMap map[string]tLidyMatcher = map[string]tLidyMatcher{
-	"string": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"string": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag == "!!str" {
 			return parser.wrap(content.Value, content), nil
 		}
-		return tResult{}, parser.contentError(content, "a YAML string")
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML string")
 	},
 
-	"int": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"int": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag == "!!int" {
 			var result int
 			err := content.Decode(&result)
@@ -34,10 +34,10 @@ var lidyDefaultRuleMatcherMap map[string]tLidyMatcher = map[string]tLidyMatcher{
 				return parser.wrap(result, content), nil
 			}
 		}
-		return tResult{}, parser.contentError(content, "a YAML integer")
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML integer")
 	},
 
-	"float": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"float": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag == "!!float" || content.Tag == "!!int" {
 			var result float64
 			err := content.Decode(&result)
@@ -45,24 +45,24 @@ var lidyDefaultRuleMatcherMap map[string]tLidyMatcher = map[string]tLidyMatcher{
 				return parser.wrap(result, content), nil
 			}
 		}
-		return tResult{}, parser.contentError(content, "a YAML float")
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML float")
 	},
 
-	"binary": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"binary": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag != "!!str" && content.Tag != "!!binary" {
-			return tResult{}, parser.contentError(content, "a YAML binary (a base64 string)")
+			return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML binary (a base64 string)")
 		}
 
 		if !regexBase64.MatchString(content.Value) {
-			return tResult{}, parser.contentError(content, "a base64 string; it must match: /"+regexBase64Source+"/")
+			return tResult{}, parser.contentError(content, ErrorCodeFormat, "a base64 string; it must match: /"+regexBase64Source+"/")
 		}
 
 		return parser.wrap(content.Value, content), nil
 	},
 
-	"boolean": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"boolean": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag != "!!bool" {
-			return tResult{}, parser.contentError(content, "a YAML boolean")
+			return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML boolean")
 		}
 
 		var result bool
@@ -70,28 +70,28 @@ var lidyDefaultRuleMatcherMap map[string]tLidyMatcher = map[string]tLidyMatcher{
 		return parser.wrap(result, content), nil
 	},
 
-	"nullType": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"nullType": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag != "!!null" {
-			return tResult{}, parser.contentError(content, "the YAML null value")
+			return tResult{}, parser.contentError(content, ErrorCodeKind, "the YAML null value")
 		}
 
 		return tResult{}, nil
 	},
 
-	"timestamp": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"timestamp": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		if content.Tag != "!!str" && content.Tag != "!!timestamp" {
-			return tResult{}, parser.contentError(content, "a YAML timestamp (an ISO 8601 datetime)")
+			return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML timestamp (an ISO 8601 datetime)")
 		}
 
 		_, err := time.Parse(time.RFC3339Nano, content.Value)
 		if err != nil {
-			return tResult{}, parser.contentError(content, fmt.Sprintf("a YAML timestamp (an ISO 8601 datetime; got error [%s])", err.Error()))
+			return tResult{}, parser.contentError(content, ErrorCodeFormat, fmt.Sprintf("a YAML timestamp (an ISO 8601 datetime; got error [%s])", err.Error()))
 		}
 
 		return parser.wrap(content.Value, content), nil
 	},
 
-	"any": func(content yaml.Node, parser *tParser) (tResult, []error) {
+	"any": func(content yaml.Node, parser *tContentParser) (tResult, []error) {
 		return parser.wrap(content.Value, content), nil
 	},
 }
@@ -122,19 +122,19 @@ func (sp *tSchemaParser) precomputeLidyDefaultRules() {
 			sp.lidyDefaultRuleMap["float"],
 			sp.lidyDefaultRuleMap["nullType"],
 			tMap{
-				tMapForm{
+				form: tMapForm{
 					mapOf: tKeyValueExpression{
 						key:   ruleAny,
 						value: ruleAny,
 					},
 				},
-				tSizingNone{},
+				sizing: tSizingNone{},
 			},
 			tList{
-				tListForm{
+				form: tListForm{
 					listOf: ruleAny,
 				},
-				tSizingNone{},
+				sizing: tSizingNone{},
 			},
 		},
 	}
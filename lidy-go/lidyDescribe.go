@@ -2,6 +2,7 @@ package lidy
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -16,41 +17,53 @@ func (rule *tRule) name() string {
 }
 
 func (rule *tRule) description() string {
+	if rule.lidyMatcher != nil {
+		return fmt.Sprintf("Rule %s (lidy default rule)", rule.ruleName)
+	}
 	return fmt.Sprintf("Rule %s %s", rule.ruleName, rule.expression.name())
 }
 
 // Map
 func (mapChecker tMap) name() string {
-	namePartList := []string{"("}
+	namePartList := []string{}
 
-	if mapChecker.form.propertyMap != nil {
+	if len(mapChecker.form.propertyMap) > 0 {
 		namePartList = append(namePartList, "_map")
 	}
+	if len(mapChecker.form.optionalMap) > 0 {
+		namePartList = append(namePartList, "_mapFacultative")
+	}
 	if mapChecker.form.mapOf.key != nil {
 		namePartList = append(namePartList, "_mapOf")
 	}
 	if len(mapChecker.form.mergeList) > 0 {
 		namePartList = append(namePartList, "_merge")
 	}
-	namePartList = append(namePartList, ")")
 
-	return strings.Join(namePartList, "&")
+	return "(" + strings.Join(namePartList, "&") + ")"
 }
 
 func (mapChecker tMap) description() string {
-	partList := []string{}
+	lineList := []string{}
 
 	mForm := mapChecker.form
 
-	if mForm.propertyMap != nil {
-		partList = append(partList, "_map:")
-
-		for key, value := range mForm.propertyMap {
-			partList = append(partList, "  ", key, ": ", value.name(), "\n")
+	if len(mForm.propertyMap) > 0 {
+		lineList = append(lineList, "_map:")
+		lineList = append(lineList, propertyLineList(mForm.propertyMap)...)
+	}
+	if len(mForm.optionalMap) > 0 {
+		lineList = append(lineList, "_mapFacultative:")
+		lineList = append(lineList, propertyLineList(mForm.optionalMap)...)
+	}
+	if len(mForm.defaultList) > 0 {
+		lineList = append(lineList, "_default:")
+		for _, propertyDefault := range mForm.defaultList {
+			lineList = append(lineList, "  "+propertyDefault.property+": "+string(flowText(&propertyDefault.node)))
 		}
 	}
 	if m := mForm.mapOf; m.key != nil {
-		partList = append(partList, "_mapOf: { ", m.key.name(), ": ", m.value.name(), " }\n")
+		lineList = append(lineList, "_mapOf: { "+m.key.name()+": "+m.value.name()+" }")
 	}
 	if len(mForm.mergeList) > 0 {
 		inner := []string{}
@@ -60,25 +73,43 @@ func (mapChecker tMap) description() string {
 		}
 		innerString := strings.Join(inner, ", ")
 
-		partList = append(partList, "_merge: [", innerString, "]")
+		lineList = append(lineList, "_merge: ["+innerString+"]")
 	}
 
-	return strings.Join(partList, "\n")
+	return strings.Join(lineList, "\n")
+}
+
+// propertyLineList lists the properties of a map, sorted by key
+func propertyLineList(propertyMap map[string]tExpression) []string {
+	keyList := make([]string, 0, len(propertyMap))
+	for key := range propertyMap {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	lineList := make([]string, len(keyList))
+	for k, key := range keyList {
+		lineList[k] = "  " + key + ": " + propertyMap[key].name()
+	}
+
+	return lineList
 }
 
 // List
 func (list tList) name() string {
-	namePartList := []string{"("}
+	namePartList := []string{}
 
 	if list.form.list != nil {
 		namePartList = append(namePartList, "_list")
 	}
+	if list.form.optionalList != nil {
+		namePartList = append(namePartList, "_listFacultative")
+	}
 	if list.form.listOf != nil {
 		namePartList = append(namePartList, "_listOf")
 	}
-	namePartList = append(namePartList, ")")
 
-	return strings.Join(namePartList, "&")
+	return "(" + strings.Join(namePartList, "&") + ")"
 }
 
 func (list tList) description() string {
@@ -92,10 +123,20 @@ func (list tList) description() string {
 		}
 		innerString := strings.Join(inner, ", ")
 
-		partList = append(partList, "_list: [", innerString, "]")
+		partList = append(partList, "_list: ["+innerString+"]")
+	}
+	if list.form.optionalList != nil {
+		inner := []string{}
+
+		for _, expression := range list.form.optionalList {
+			inner = append(inner, expression.name())
+		}
+		innerString := strings.Join(inner, ", ")
+
+		partList = append(partList, "_listFacultative: ["+innerString+"]")
 	}
 	if list.form.listOf != nil {
-		partList = append(partList, "_listOf: ", list.form.listOf.name())
+		partList = append(partList, "_listOf: "+list.form.listOf.name())
 	}
 
 	return strings.Join(partList, "\n")
@@ -119,6 +160,20 @@ func (oneOf tOneOf) description() string {
 	return strings.Join(partList, "")
 }
 
+// Switch
+func (switchChecker tSwitch) name() string {
+	return "(switch)"
+}
+
+func (switchChecker tSwitch) description() string {
+	partList := []string{"switch on " + switchChecker.key + ":\n"}
+	for _, caseName := range switchChecker.caseNameList {
+		partList = append(partList, "- ", caseName, ": ", switchChecker.caseMap[caseName].name(), "\n")
+	}
+
+	return strings.Join(partList, "")
+}
+
 // In
 func (in tIn) name() string {
 	return "(in)"
@@ -129,15 +184,21 @@ func (in tIn) description() string {
 		return "in: []"
 	}
 
-	partList := []string{"in: "}
+	tagList := make([]string, 0, len(in.valueMap))
+	for tag := range in.valueMap {
+		tagList = append(tagList, tag)
+	}
+	sort.Strings(tagList)
+
+	partList := []string{}
 
-	for tag, valueList := range in.valueMap {
-		innerString := strings.Join(valueList, ", ")
+	for _, tag := range tagList {
+		innerString := strings.Join(in.valueMap[tag], ", ")
 
-		partList = append(partList, "[", tag, "][", innerString, "]")
+		partList = append(partList, "["+tag+"]["+innerString+"]")
 	}
 
-	return strings.Join(partList, ", ")
+	return "in: " + strings.Join(partList, ", ")
 }
 
 // Regex
@@ -148,3 +209,12 @@ func (regex tRegex) name() string {
 func (regex tRegex) description() string {
 	return "/" + regex.regexString + "/"
 }
+
+// Range
+func (rng tRange) name() string {
+	return "(range)"
+}
+
+func (rng tRange) description() string {
+	return "range " + rng.rangeString
+}
@@ -0,0 +1,767 @@
+package lidy
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyDoc.go
+//
+// Generate the reference documentation of a schema, in Markdown or in HTML
+
+// DocFormat -- the output format of Doc
+type DocFormat string
+
+const (
+	// DocMarkdown GitHub flavored Markdown
+	DocMarkdown DocFormat = "md"
+	// DocHTML a standalone HTML page
+	DocHTML DocFormat = "html"
+)
+
+// Doc -- process the schema if needed, and write its reference
+// documentation: a section per user rule, with its annotations, the table of
+// its properties or items, its options, values or pattern, and the rules
+// using it. The target rule comes first, then the rules in the order of their
+// declaration, the imported rules last.
+func (p *tParser) Doc(format DocFormat) ([]byte, []error) {
+	var writer tDocWriter
+	switch format {
+	case DocMarkdown:
+		writer = &tMarkdownWriter{}
+	case DocHTML:
+		writer = &tHtmlWriter{}
+	default:
+		return nil, []error{fmt.Errorf("unknown documentation format '%s', expected md or html", format)}
+	}
+
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	generator := tDocGenerator{
+		parser:    p,
+		writer:    writer,
+		usedByMap: map[string][]string{},
+	}
+	generator.generate()
+
+	return []byte(writer.String()), nil
+}
+
+//
+// Text
+//
+
+type tDocSpanKind int
+
+const (
+	docPlain tDocSpanKind = iota
+	docCode
+	docLink
+)
+
+// tDocSpan -- a piece of inline text; the text of a link is the name of the
+// rule it links to
+type tDocSpan struct {
+	kind tDocSpanKind
+	text string
+}
+
+// tDocText -- inline text, written by the writers in their format
+type tDocText []tDocSpan
+
+func docPlainText(text string) tDocText {
+	return tDocText{{kind: docPlain, text: text}}
+}
+
+func docCodeText(text string) tDocText {
+	return tDocText{{kind: docCode, text: text}}
+}
+
+func (text tDocText) plain(value string) tDocText {
+	return append(text, tDocSpan{kind: docPlain, text: value})
+}
+
+func (text tDocText) code(value string) tDocText {
+	return append(text, tDocSpan{kind: docCode, text: value})
+}
+
+func (text tDocText) link(ruleName string) tDocText {
+	return append(text, tDocSpan{kind: docLink, text: ruleName})
+}
+
+func (text tDocText) concat(other tDocText) tDocText {
+	return append(text, other...)
+}
+
+// joinDocText concatenates texts, with the separator between them
+func joinDocText(textList []tDocText, separator string) tDocText {
+	joined := tDocText{}
+	for k, text := range textList {
+		if k > 0 {
+			joined = joined.plain(separator)
+		}
+		joined = append(joined, text...)
+	}
+	return joined
+}
+
+//
+// Writers
+//
+
+// tDocWriter -- the output format of the documentation
+type tDocWriter interface {
+	title(text string)
+	// ruleHeading the heading of the section of a rule, which the links to the rule lead to
+	ruleHeading(ruleName string)
+	heading(text string)
+	paragraph(text tDocText)
+	list(itemList []tDocText)
+	table(headerList []string, rowList [][]tDocText)
+	String() string
+}
+
+// docAnchor is the id of the section of a rule
+func docAnchor(ruleName string) string {
+	return "rule-" + ruleName
+}
+
+// tMarkdownWriter -- GitHub flavored Markdown
+type tMarkdownWriter struct {
+	strings.Builder
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`,
+)
+
+func (writer *tMarkdownWriter) inline(text tDocText, isInTable bool) string {
+	builder := strings.Builder{}
+	for _, span := range text {
+		switch span.kind {
+		case docPlain:
+			builder.WriteString(markdownEscaper.Replace(span.text))
+		case docCode:
+			builder.WriteString(markdownCode(span.text, isInTable))
+		case docLink:
+			fmt.Fprintf(&builder, "[%s](#%s)", markdownCode(span.text, isInTable), docAnchor(span.text))
+		}
+	}
+	return strings.ReplaceAll(builder.String(), "\n", " ")
+}
+
+// markdownCode is a code span, with enough backticks to hold the text. In the
+// tables, the pipes must be escaped, even in code
+func markdownCode(text string, isInTable bool) string {
+	if isInTable {
+		text = strings.ReplaceAll(text, "|", `\|`)
+	}
+
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+
+	return fence + text + fence
+}
+
+func (writer *tMarkdownWriter) title(text string) {
+	fmt.Fprintf(writer, "# %s\n\n", markdownEscaper.Replace(text))
+}
+
+func (writer *tMarkdownWriter) ruleHeading(ruleName string) {
+	fmt.Fprintf(writer, "## <a id=\"%s\"></a>%s\n\n", docAnchor(ruleName), markdownEscaper.Replace(ruleName))
+}
+
+func (writer *tMarkdownWriter) heading(text string) {
+	fmt.Fprintf(writer, "#### %s\n\n", markdownEscaper.Replace(text))
+}
+
+func (writer *tMarkdownWriter) paragraph(text tDocText) {
+	fmt.Fprintf(writer, "%s\n\n", writer.inline(text, false))
+}
+
+func (writer *tMarkdownWriter) list(itemList []tDocText) {
+	for _, item := range itemList {
+		fmt.Fprintf(writer, "- %s\n", writer.inline(item, false))
+	}
+	writer.WriteString("\n")
+}
+
+func (writer *tMarkdownWriter) table(headerList []string, rowList [][]tDocText) {
+	separatorList := make([]string, len(headerList))
+	for k := range headerList {
+		separatorList[k] = "---"
+	}
+
+	fmt.Fprintf(writer, "| %s |\n", strings.Join(headerList, " | "))
+	fmt.Fprintf(writer, "| %s |\n", strings.Join(separatorList, " | "))
+	for _, row := range rowList {
+		cellList := make([]string, len(row))
+		for k, cell := range row {
+			cellList[k] = writer.inline(cell, true)
+		}
+		fmt.Fprintf(writer, "| %s |\n", strings.Join(cellList, " | "))
+	}
+	writer.WriteString("\n")
+}
+
+// tHtmlWriter -- a standalone HTML page
+type tHtmlWriter struct {
+	strings.Builder
+	// isStarted
+	// true once the title, and the head of the page, are written
+	isStarted bool
+}
+
+const htmlStyle = `body { font-family: sans-serif; max-width: 60em; margin: auto; padding: 1em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code { background: #f4f4f4; padding: 0 0.2em; }`
+
+func (writer *tHtmlWriter) inline(text tDocText) string {
+	builder := strings.Builder{}
+	for _, span := range text {
+		switch span.kind {
+		case docPlain:
+			builder.WriteString(html.EscapeString(span.text))
+		case docCode:
+			fmt.Fprintf(&builder, "<code>%s</code>", html.EscapeString(span.text))
+		case docLink:
+			fmt.Fprintf(&builder, "<a href=\"#%s\"><code>%s</code></a>", html.EscapeString(docAnchor(span.text)), html.EscapeString(span.text))
+		}
+	}
+	return builder.String()
+}
+
+func (writer *tHtmlWriter) title(text string) {
+	fmt.Fprintf(writer, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(text), htmlStyle)
+	fmt.Fprintf(writer, "<h1>%s</h1>\n", html.EscapeString(text))
+	writer.isStarted = true
+}
+
+func (writer *tHtmlWriter) ruleHeading(ruleName string) {
+	fmt.Fprintf(writer, "<h2 id=\"%s\">%s</h2>\n", html.EscapeString(docAnchor(ruleName)), html.EscapeString(ruleName))
+}
+
+func (writer *tHtmlWriter) heading(text string) {
+	fmt.Fprintf(writer, "<h4>%s</h4>\n", html.EscapeString(text))
+}
+
+func (writer *tHtmlWriter) paragraph(text tDocText) {
+	fmt.Fprintf(writer, "<p>%s</p>\n", writer.inline(text))
+}
+
+func (writer *tHtmlWriter) list(itemList []tDocText) {
+	writer.WriteString("<ul>\n")
+	for _, item := range itemList {
+		fmt.Fprintf(writer, "<li>%s</li>\n", writer.inline(item))
+	}
+	writer.WriteString("</ul>\n")
+}
+
+func (writer *tHtmlWriter) table(headerList []string, rowList [][]tDocText) {
+	writer.WriteString("<table>\n<tr>")
+	for _, header := range headerList {
+		fmt.Fprintf(writer, "<th>%s</th>", html.EscapeString(header))
+	}
+	writer.WriteString("</tr>\n")
+	for _, row := range rowList {
+		writer.WriteString("<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(writer, "<td>%s</td>", writer.inline(cell))
+		}
+		writer.WriteString("</tr>\n")
+	}
+	writer.WriteString("</table>\n")
+}
+
+func (writer *tHtmlWriter) String() string {
+	if !writer.isStarted {
+		return writer.Builder.String()
+	}
+	return writer.Builder.String() + "</body>\n</html>\n"
+}
+
+//
+// Generator
+//
+
+type tDocGenerator struct {
+	parser *tParser
+	writer tDocWriter
+	// ruleNameList
+	// the user rules, in the order of the documentation
+	ruleNameList []string
+	// usedByMap
+	// the rules referring to each rule, sorted
+	usedByMap map[string][]string
+}
+
+func (generator *tDocGenerator) generate() {
+	generator.sortRuleNameList()
+	generator.collectUsedBy()
+
+	generator.writer.title(path.Base(generator.parser.name))
+
+	if len(generator.ruleNameList) == 0 {
+		generator.writer.paragraph(docPlainText("The schema has no rules."))
+		return
+	}
+
+	indexList := []tDocText{}
+	for _, ruleName := range generator.ruleNameList {
+		item := tDocText{}.link(ruleName)
+		if summary := docSummary(generator.rule(ruleName).expression.Annotation().Description); summary != "" {
+			item = item.plain(" -- " + summary)
+		}
+		indexList = append(indexList, item)
+	}
+	generator.writer.list(indexList)
+
+	for _, ruleName := range generator.ruleNameList {
+		generator.ruleSection(generator.rule(ruleName))
+	}
+}
+
+func (generator *tDocGenerator) rule(ruleName string) *tRule {
+	return generator.parser.schema.ruleMap[ruleName]
+}
+
+// isUserRule tells the rules which are documented; the lidy default rules,
+// including `any`, are not
+func (generator *tDocGenerator) isUserRule(rule *tRule) bool {
+	return rule.lidyMatcher == nil && generator.parser.lidyDefaultRuleMap[rule.ruleName] != rule.origin()
+}
+
+// sortRuleNameList puts the target rule first, then the rules of the schema
+// file in the order of their declaration, then the imported rules, by file
+func (generator *tDocGenerator) sortRuleNameList() {
+	parser := generator.parser
+
+	for ruleName, rule := range parser.schema.ruleMap {
+		if generator.isUserRule(rule) {
+			generator.ruleNameList = append(generator.ruleNameList, ruleName)
+		}
+	}
+
+	rank := func(rule *tRule) int {
+		switch {
+		case rule.ruleName == parser.target:
+			return 0
+		case rule._filename == parser.name:
+			return 1
+		}
+		return 2
+	}
+
+	sort.Slice(generator.ruleNameList, func(i, j int) bool {
+		a, b := generator.rule(generator.ruleNameList[i]), generator.rule(generator.ruleNameList[j])
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if a._filename != b._filename {
+			return a._filename < b._filename
+		}
+		if a._keyNode.Line != b._keyNode.Line {
+			return a._keyNode.Line < b._keyNode.Line
+		}
+		return a.ruleName < b.ruleName
+	})
+}
+
+// collectUsedBy lists, for each rule, the rules whose expression refers to it
+func (generator *tDocGenerator) collectUsedBy() {
+	for _, ruleName := range generator.ruleNameList {
+		for _, reference := range generator.rule(ruleName).expression.dependencyList() {
+			generator.usedByMap[reference] = append(generator.usedByMap[reference], ruleName)
+		}
+	}
+
+	for _, usedByList := range generator.usedByMap {
+		sort.Strings(usedByList)
+	}
+}
+
+// ruleSection documents a rule
+func (generator *tDocGenerator) ruleSection(rule *tRule) {
+	writer := generator.writer
+	expression := rule.expression
+	annotation := expression.Annotation()
+
+	writer.ruleHeading(rule.ruleName)
+
+	if annotation.Deprecated {
+		writer.paragraph(docDeprecation(annotation))
+	}
+	if annotation.Description != "" {
+		writer.paragraph(docPlainText(annotation.Description))
+	}
+	if rule._filename != generator.parser.name {
+		writer.paragraph(docPlainText("Declared in ").code(rule._filename).plain("."))
+	}
+
+	generator.body(expression)
+
+	if len(annotation.Examples) > 0 {
+		writer.heading("Examples")
+		writer.list(docExampleList(annotation.Examples))
+	}
+
+	if usedByList := generator.usedByMap[rule.ruleName]; len(usedByList) > 0 {
+		usedBy := docPlainText("Used by ")
+		for k, ruleName := range usedByList {
+			if k > 0 {
+				usedBy = usedBy.plain(", ")
+			}
+			usedBy = usedBy.link(ruleName)
+		}
+		writer.paragraph(usedBy.plain("."))
+	}
+}
+
+// body documents the constraints of the expression of a rule
+func (generator *tDocGenerator) body(expression tExpression) {
+	writer := generator.writer
+
+	switch expression := expression.(type) {
+	case *tRule:
+		writer.paragraph(docPlainText("Same as ").concat(docTypeText(expression)).plain("."))
+	case tMap:
+		generator.mapBody(expression)
+	case tList:
+		generator.listBody(expression)
+	case tOneOf:
+		if len(expression.optionList) == 0 {
+			writer.paragraph(docPlainText("Nothing is accepted (empty _oneOf)."))
+			return
+		}
+		writer.paragraph(docPlainText("One of:"))
+		optionList := []tDocText{}
+		for _, option := range expression.optionList {
+			item := docTypeText(option)
+			if annotationText := docAnnotationText(option.Annotation()); len(annotationText) > 0 {
+				item = item.plain(" -- ").concat(annotationText)
+			}
+			optionList = append(optionList, item)
+		}
+		writer.list(optionList)
+	case tSwitch:
+		writer.paragraph(docPlainText("A map, whose property ").code(expression.key).plain(" selects the other properties."))
+		for _, caseName := range expression.caseNameList {
+			mergeable := expression.caseMap[caseName]
+
+			writer.heading(expression.key + ": " + caseName)
+			if annotation := mergeable.Annotation(); !annotation.isEmpty() {
+				writer.paragraph(docAnnotationText(annotation))
+			}
+			generator.body(mergeable)
+		}
+	case tIn:
+		writer.paragraph(docPlainText("One of the values:"))
+		valueList := []tDocText{}
+		for _, value := range docInValueList(expression) {
+			valueList = append(valueList, docCodeText(value))
+		}
+		writer.list(valueList)
+	case tRegex:
+		writer.paragraph(docPlainText("A string matching the regular expression ").code(expression.regexString).plain("."))
+	case tRange:
+		writer.paragraph(docPlainText("A number in the range ").code(expression.rangeString).plain("."))
+	}
+}
+
+// mapBody documents a map checker: a table of its properties, including
+// those of the maps declared inline, then its other entries and the merged
+// expressions
+func (generator *tDocGenerator) mapBody(mapChecker tMap) {
+	writer := generator.writer
+	form := mapChecker.form
+
+	rowList := docPropertyRowList(mapChecker, "")
+	if len(rowList) > 0 {
+		writer.table([]string{"Property", "Type", "Required", "Default", "Description"}, rowList)
+	} else if form.mapOf.key == nil && len(form.mergeList) == 0 {
+		writer.paragraph(docPlainText("An empty map."))
+	}
+
+	if form.mapOf.key != nil {
+		writer.paragraph(docPlainText("Other entries: the keys are ").concat(docTypeText(form.mapOf.key)).
+			plain(", the values are ").concat(docTypeText(form.mapOf.value)).plain("."))
+	}
+
+	if len(form.mergeList) > 0 {
+		mergeList := []tDocText{}
+		for _, mergeable := range form.mergeList {
+			mergeList = append(mergeList, docTypeText(mergeable))
+		}
+		writer.paragraph(docPlainText("Also has the properties of ").concat(joinDocText(mergeList, ", ")).plain("."))
+	}
+
+	if sizing := docSizing(mapChecker.sizing, "entry", "entries"); sizing != "" {
+		writer.paragraph(docPlainText("Has " + sizing + "."))
+	}
+}
+
+// docPropertyRowList lists the properties of a map, sorted, the required ones
+// first. The properties of the maps declared inline, and of the maps of the
+// lists declared inline, follow their property, e.g. `servers[].host`
+func docPropertyRowList(mapChecker tMap, prefix string) [][]tDocText {
+	form := mapChecker.form
+
+	defaultMap := map[string]string{}
+	for _, propertyDefault := range form.defaultList {
+		defaultMap[propertyDefault.property] = string(flowText(&propertyDefault.node))
+	}
+
+	rowList := [][]tDocText{}
+
+	addRowList := func(propertyMap map[string]tExpression, required string) {
+		for _, key := range docSortedKeyList(propertyMap) {
+			value := propertyMap[key]
+			name := prefix + key
+
+			defaultText := tDocText{}
+			if defaultValue, present := defaultMap[key]; present {
+				defaultText = docCodeText(defaultValue)
+			}
+
+			rowList = append(rowList, []tDocText{
+				docCodeText(name), docTypeText(value), docPlainText(required), defaultText, docAnnotationText(value.Annotation()),
+			})
+
+			switch value := value.(type) {
+			case tMap:
+				rowList = append(rowList, docPropertyRowList(value, name+".")...)
+			case tList:
+				if inner, ok := value.form.listOf.(tMap); ok {
+					rowList = append(rowList, docPropertyRowList(inner, name+"[].")...)
+				}
+			}
+		}
+	}
+
+	addRowList(form.propertyMap, "yes")
+	addRowList(form.optionalMap, "no")
+
+	return rowList
+}
+
+func docSortedKeyList(propertyMap map[string]tExpression) []string {
+	keyList := make([]string, 0, len(propertyMap))
+	for key := range propertyMap {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	return keyList
+}
+
+// listBody documents a list checker: a table of its items, then the type of
+// the other items
+func (generator *tDocGenerator) listBody(list tList) {
+	writer := generator.writer
+	form := list.form
+
+	rowList := [][]tDocText{}
+	for k, item := range form.list {
+		rowList = append(rowList, []tDocText{docPlainText(fmt.Sprint(k)), docTypeText(item), docPlainText("yes"), docAnnotationText(item.Annotation())})
+	}
+	for k, item := range form.optionalList {
+		rowList = append(rowList, []tDocText{docPlainText(fmt.Sprint(len(form.list) + k)), docTypeText(item), docPlainText("no"), docAnnotationText(item.Annotation())})
+	}
+	if len(rowList) > 0 {
+		writer.table([]string{"Index", "Type", "Required", "Description"}, rowList)
+	}
+
+	switch {
+	case form.listOf != nil && len(rowList) > 0:
+		writer.paragraph(docPlainText("The other items are ").concat(docTypeText(form.listOf)).plain("."))
+	case form.listOf != nil:
+		writer.paragraph(docPlainText("A list of ").concat(docTypeText(form.listOf)).plain("."))
+		if inner, ok := form.listOf.(tMap); ok {
+			if innerRowList := docPropertyRowList(inner, "[]."); len(innerRowList) > 0 {
+				writer.table([]string{"Property", "Type", "Required", "Default", "Description"}, innerRowList)
+			}
+		}
+	case len(rowList) == 0:
+		writer.paragraph(docPlainText("An empty list."))
+	}
+
+	if sizing := docSizing(list.sizing, "item", "items"); sizing != "" {
+		writer.paragraph(docPlainText("Has " + sizing + "."))
+	}
+}
+
+// docTypeText is the short, inline description of an expression, linking to
+// the rules it refers to
+func docTypeText(expression tExpression) tDocText {
+	switch expression := expression.(type) {
+	case *tRule:
+		// the lidy default rules, including `any`, are declared without a file
+		if expression.lidyMatcher != nil || expression._filename == "" {
+			return docCodeText(expression.ruleName)
+		}
+		return tDocText{}.link(expression.ruleName)
+	case tMap:
+		form := expression.form
+		text := docPlainText("map")
+		if len(form.propertyMap) == 0 && len(form.optionalMap) == 0 && len(form.mergeList) == 0 && form.mapOf.key != nil {
+			text = text.plain(" of ").concat(docTypeText(form.mapOf.key)).plain(" to ").concat(docTypeText(form.mapOf.value))
+		}
+		if sizing := docSizing(expression.sizing, "entry", "entries"); sizing != "" {
+			text = text.plain(" (" + sizing + ")")
+		}
+		return text
+	case tList:
+		form := expression.form
+		text := docPlainText("list")
+		if len(form.list) == 0 && len(form.optionalList) == 0 && form.listOf != nil {
+			text = text.plain(" of ").concat(docTypeText(form.listOf))
+		} else {
+			itemList := []tDocText{}
+			for _, item := range form.list {
+				itemList = append(itemList, docTypeText(item))
+			}
+			for _, item := range form.optionalList {
+				itemList = append(itemList, docTypeText(item).plain("?"))
+			}
+			if form.listOf != nil {
+				itemList = append(itemList, docTypeText(form.listOf).plain("..."))
+			}
+			text = text.plain(" [").concat(joinDocText(itemList, ", ")).plain("]")
+		}
+		if sizing := docSizing(expression.sizing, "item", "items"); sizing != "" {
+			text = text.plain(" (" + sizing + ")")
+		}
+		return text
+	case tOneOf:
+		optionList := []tDocText{}
+		for _, option := range expression.optionList {
+			optionList = append(optionList, docTypeText(option))
+		}
+		if len(optionList) == 0 {
+			return docPlainText("nothing")
+		}
+		return joinDocText(optionList, " or ")
+	case tSwitch:
+		return docPlainText("map selected by ").code(expression.key)
+	case tIn:
+		valueList := []tDocText{}
+		for _, value := range docInValueList(expression) {
+			valueList = append(valueList, docCodeText(value))
+		}
+		return docPlainText("one of ").concat(joinDocText(valueList, ", "))
+	case tRegex:
+		return docPlainText("string matching ").code(expression.regexString)
+	case tRange:
+		return docCodeText(expression.rangeString)
+	}
+
+	return docPlainText(expression.name())
+}
+
+// docAnnotationText is the description and the deprecation of an expression,
+// in a table cell or a list item
+func docAnnotationText(annotation Annotation) tDocText {
+	text := tDocText{}
+	if annotation.Deprecated {
+		text = append(text, docDeprecation(annotation)...)
+	}
+	if annotation.Description != "" {
+		if len(text) > 0 {
+			text = text.plain(" ")
+		}
+		text = text.plain(strings.Join(strings.Fields(annotation.Description), " "))
+	}
+	if len(annotation.Examples) > 0 {
+		if len(text) > 0 {
+			text = text.plain(" ")
+		}
+		text = text.plain("Examples: ").concat(joinDocText(docExampleList(annotation.Examples), ", "))
+	}
+	return text
+}
+
+func docDeprecation(annotation Annotation) tDocText {
+	if annotation.DeprecationMessage == "" {
+		return docPlainText("Deprecated.")
+	}
+	return docPlainText("Deprecated: " + strings.TrimSuffix(annotation.DeprecationMessage, ".") + ".")
+}
+
+// docExampleList writes the examples in YAML flow style
+func docExampleList(exampleList []interface{}) []tDocText {
+	textList := []tDocText{}
+	for _, example := range exampleList {
+		node := yaml.Node{}
+		if node.Encode(example) != nil {
+			continue
+		}
+		textList = append(textList, docCodeText(string(flowText(&node))))
+	}
+	return textList
+}
+
+// docInValueList lists the values of an `_in`, sorted by tag, as they are
+// written in YAML
+func docInValueList(in tIn) []string {
+	tagList := make([]string, 0, len(in.valueMap))
+	for tag := range in.valueMap {
+		tagList = append(tagList, tag)
+	}
+	sort.Strings(tagList)
+
+	valueList := []string{}
+	for _, tag := range tagList {
+		for _, value := range in.valueMap[tag] {
+			node := yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+			valueList = append(valueList, string(flowText(&node)))
+		}
+	}
+	return valueList
+}
+
+// docSizing describes the `_min`, `_max` and `_nb` of a container
+func docSizing(sizing tSizing, singular string, plural string) string {
+	count := func(n int) string {
+		if n == 1 {
+			return "1 " + singular
+		}
+		return fmt.Sprintf("%d %s", n, plural)
+	}
+
+	switch sizing := sizing.(type) {
+	case tSizingMin:
+		return "at least " + count(sizing.min)
+	case tSizingMax:
+		return "at most " + count(sizing.max)
+	case tSizingMinMax:
+		return fmt.Sprintf("%d to %s", sizing.min, count(sizing.max))
+	case tSizingNb:
+		return "exactly " + count(sizing.nb)
+	}
+	return ""
+}
+
+// docSummary is the first sentence, or line, of a description
+func docSummary(description string) string {
+	summary := strings.TrimSpace(description)
+	if k := strings.Index(summary, "\n"); k >= 0 {
+		summary = summary[:k]
+	}
+	if k := strings.Index(summary, ". "); k >= 0 {
+		summary = summary[:k+1]
+	}
+	return summary
+}
@@ -0,0 +1,253 @@
+package lidy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyError.go
+//
+// Exported error types, the error codes, and the helpers used to locate the
+// errors
+
+// ErrorCode -- stable, machine-readable identifier of the kind of a ContentError or a SchemaError
+type ErrorCode string
+
+// Error codes shared by the content and the schema
+const (
+	// ErrorCodeKind -- the YAML node is not of the expected kind or tag (e.g. a map was expected)
+	ErrorCodeKind ErrorCode = "kind"
+	// ErrorCodeInternal -- something that should not happen happened
+	ErrorCodeInternal ErrorCode = "internal"
+)
+
+// Content error codes
+const (
+	// ErrorCodeMissingProperty -- a property required by `_map` is missing
+	ErrorCodeMissingProperty ErrorCode = "missingProperty"
+	// ErrorCodeExtraEntry -- the map has a key, or the list has an entry, that the schema does not accept
+	ErrorCodeExtraEntry ErrorCode = "extraEntry"
+	// ErrorCodeMissingEntry -- the list has fewer entries than `_list` requires
+	ErrorCodeMissingEntry ErrorCode = "missingEntry"
+	// ErrorCodeSize -- the container does not respect `_min`, `_max` or `_nb`
+	ErrorCodeSize ErrorCode = "size"
+	// ErrorCodeIn -- the scalar is not one of the values of `_in`
+	ErrorCodeIn ErrorCode = "in"
+	// ErrorCodeRegex -- the string does not match `_regex`
+	ErrorCodeRegex ErrorCode = "regex"
+	// ErrorCodeRange -- the number is outside of `_range`
+	ErrorCodeRange ErrorCode = "range"
+	// ErrorCodeOneOf -- none of the options of `_oneOf` matches
+	ErrorCodeOneOf ErrorCode = "oneOf"
+	// ErrorCodeSwitch -- the value of the `_discriminator` property is not one of the cases of `_switch`
+	ErrorCodeSwitch ErrorCode = "switch"
+	// ErrorCodeFormat -- the string does not have the format of the predefined rule (timestamp, binary)
+	ErrorCodeFormat ErrorCode = "format"
+)
+
+// Schema error codes
+const (
+	// ErrorCodeIdentifier -- invalid rule identifier or identifier declaration
+	ErrorCodeIdentifier ErrorCode = "identifier"
+	// ErrorCodeUnknownRule -- reference to a rule that is not declared
+	ErrorCodeUnknownRule ErrorCode = "unknownRule"
+	// ErrorCodeDuplicateRule -- rule declared twice, or redeclaration of a lidy default rule
+	ErrorCodeDuplicateRule ErrorCode = "duplicateRule"
+	// ErrorCodeKeyword -- unknown lidy keyword
+	ErrorCodeKeyword ErrorCode = "keyword"
+	// ErrorCodeConflict -- keywords which cannot be used together
+	ErrorCodeConflict ErrorCode = "conflict"
+	// ErrorCodeForm -- the expression is neither a rule identifier nor a recognizable checker form
+	ErrorCodeForm ErrorCode = "form"
+	// ErrorCodeMergeable -- `_merge` used with an expression that cannot be merged
+	ErrorCodeMergeable ErrorCode = "mergeable"
+	// ErrorCodeValue -- invalid keyword argument (e.g. a negative `_min`, an invalid regex)
+	ErrorCodeValue ErrorCode = "value"
+	// ErrorCodeImport -- invalid `_import`, file which cannot be loaded, or import cycle
+	ErrorCodeImport ErrorCode = "import"
+	// ErrorCodeRecursion -- cycle of rule references which do not go through a map or a list, e.g. `a: { _oneOf: [a, string] }`
+	ErrorCodeRecursion ErrorCode = "recursion"
+)
+
+var _ Position = &ContentError{}
+
+// ContentError -- an error found while checking the content against the schema
+type ContentError struct {
+	tPosition
+	// Path -- the path of the node from the root of the document, e.g. `/topology/nodes/3/properties`
+	Path string
+	// RuleName -- the innermost rule which was being matched, if any
+	RuleName string
+	// Expected -- a description of what was expected
+	Expected string
+	// Actual -- the YAML tag of the node, e.g. `!!map`, `!!str`
+	Actual string
+	// Code -- the kind of error
+	Code ErrorCode
+	// Nested -- the errors explaining this error. For ErrorCodeOneOf errors, the errors of the best option
+	Nested []error
+	// OptionErrorList -- for ErrorCodeOneOf errors, the errors of each option of the `_oneOf`, in order
+	OptionErrorList [][]error
+	// BestOption -- for ErrorCodeOneOf errors, the index of the option which got the furthest in matching the node, or -1
+	BestOption int
+
+	bestOptionName string
+	depth          int
+	kind           yaml.Kind
+	value          string
+}
+
+func (err *ContentError) Error() string {
+	text := fmt.Sprintf(
+		"error with content node, kind #%d, tag '%s', value '%s' at position %s:%d:%d (path %s), where [%s] was expected",
+		err.kind, err.Actual, err.value, err.filename, err.line, err.column, err.Path, err.Expected,
+	)
+
+	if len(err.Nested) == 0 {
+		return text
+	}
+
+	partList := []string{text}
+	if err.BestOption >= 0 {
+		partList = append(partList, fmt.Sprintf("best match: option #%d %s, which failed with:", err.BestOption, err.bestOptionName))
+	}
+	for _, nested := range err.Nested {
+		partList = append(partList, "- "+strings.ReplaceAll(nested.Error(), "\n", "\n  "))
+	}
+
+	return strings.Join(partList, "\n")
+}
+
+var _ Position = &SchemaError{}
+
+// SchemaError -- an error found in the schema
+type SchemaError struct {
+	tPosition
+	// Path -- the path of the node from the root of the schema document, e.g. `/main/_map/name`
+	Path string
+	// RuleName -- the rule in which the error was found, if any
+	RuleName string
+	// Expected -- a description of what was expected
+	Expected string
+	// Actual -- the YAML tag of the node, e.g. `!!map`, `!!str`
+	Actual string
+	// Code -- the kind of error
+	Code ErrorCode
+
+	kind  yaml.Kind
+	value string
+}
+
+func (err *SchemaError) Error() string {
+	return fmt.Sprintf(
+		"error in schema with yaml node, kind #%d, tag '%s', value '%s' at position %s:%d:%d (path %s), where [%s] was expected",
+		err.kind, err.Actual, err.value, err.filename, err.line, err.column, err.Path, err.Expected,
+	)
+}
+
+var _ Position = &ContentWarning{}
+var _ Warning = &ContentWarning{}
+
+// ContentWarning -- the content uses an expression of the schema annotated with `_deprecated`
+type ContentWarning struct {
+	tPosition
+	// Path -- the path of the node from the root of the document
+	Path string
+	// RuleName -- the innermost rule which was being matched, if any
+	RuleName string
+	// Message -- the deprecation message, if any
+	Message string
+}
+
+func (warning *ContentWarning) Error() string {
+	text := fmt.Sprintf(
+		"warning with content node at position %s:%d:%d (path %s), which uses a deprecated expression",
+		warning.filename, warning.line, warning.column, warning.Path,
+	)
+	if warning.RuleName != "" {
+		text += " of rule " + warning.RuleName
+	}
+	if warning.Message != "" {
+		text += ": " + warning.Message
+	}
+	return text
+}
+
+// Warning cannot be implemented by external libraries
+// This method must exist to validate the interface
+func (*ContentWarning) zzWarning() {}
+
+var _ Position = &DecodeError{}
+
+// DecodeError -- an error found while decoding a Result into a Go value. See Decode
+type DecodeError struct {
+	tPosition
+	// Path -- the path of the node from the decoded result, e.g. `/servers/3/port`
+	Path string
+	// Type -- the Go type which could not be filled
+	Type reflect.Type
+	// Reason -- why the node could not be decoded
+	Reason string
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf(
+		"error decoding content node at position %s:%d:%d (path %s) into Go type %s: %s",
+		err.filename, err.line, err.column, err.Path, err.Type, err.Reason,
+	)
+}
+
+// pathString produces a JSON-pointer-like path from a list of keys and indexes
+func pathString(segmentList []string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+
+	partList := make([]string, len(segmentList))
+	for k, segment := range segmentList {
+		partList[k] = escaper.Replace(segment)
+	}
+
+	return "/" + strings.Join(partList, "/")
+}
+
+// appendPath returns a new path, leaving the given one untouched
+func appendPath(path []string, segmentList ...string) []string {
+	return append(path[:len(path):len(path)], segmentList...)
+}
+
+// endPosition computes where the yaml node ends. The result is approximate
+// for multi-line scalars.
+func endPosition(node yaml.Node) (int, int) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.MappingNode, yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			if node.Style&yaml.FlowStyle != 0 {
+				return node.Line, node.Column + 2
+			}
+			return node.Line, node.Column
+		}
+		line, column := endPosition(*node.Content[len(node.Content)-1])
+		if node.Style&yaml.FlowStyle != 0 {
+			column++
+		}
+		return line, column
+	case yaml.AliasNode:
+		return node.Line, node.Column + 1 + len([]rune(node.Value))
+	}
+
+	lineList := strings.Split(node.Value, "\n")
+	lastLine := []rune(lineList[len(lineList)-1])
+
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return node.Line + len(lineList), len(lastLine) + 1
+	case len(lineList) > 1:
+		return node.Line + len(lineList) - 1, len(lastLine) + 1
+	case node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) != 0:
+		return node.Line, node.Column + len(lastLine) + 2
+	default:
+		return node.Line, node.Column + len(lastLine)
+	}
+}
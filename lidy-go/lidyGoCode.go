@@ -0,0 +1,684 @@
+package lidy
+
+import (
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lidyGoCode.go
+//
+// Generate the Go types of the exported rules, and the builders filling them
+
+var regexGoNameSeparator = *regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// GoCode -- process the schema if needed, and generate the source of a Go
+// package declaring a type for each exported rule (`name:` or
+// `name::Builder`), and a function BuilderMap returning the builders which fill
+// these types, to give to `.With()`. The output only depends on the schema, so
+// that regenerating it after a schema change gives a reviewable diff.
+func (p *tParser) GoCode(packageName string) ([]byte, []Warning, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, nil, erl
+	}
+
+	generator := tGoGenerator{
+		parser:      p,
+		typeNameMap: map[string]string{},
+		usedNameSet: map[string]bool{},
+		sumSet:      map[string]bool{},
+		aliasSet:    map[string]bool{},
+		warningList: &[]Warning{},
+		source:      &strings.Builder{},
+		builder:     &strings.Builder{},
+	}
+
+	body := generator.generate()
+
+	header := &strings.Builder{}
+	fmt.Fprintf(header, "// Code generated by lidy from %s; DO NOT EDIT.\n\n", p.name)
+	fmt.Fprintf(header, "package %s\n\n", packageName)
+	header.WriteString("import (\n")
+	if strings.Contains(body, "fmt.") {
+		header.WriteString("\t\"fmt\"\n")
+	}
+	if strings.Contains(body, "time.") {
+		header.WriteString("\t\"time\"\n")
+	}
+	header.WriteString("\n\t\"github.com/ditrit/lidy\"\n)\n")
+
+	content, err := format.Source([]byte(header.String() + body))
+	if err != nil {
+		return nil, nil, []error{fmt.Errorf("Lidy internal error -- the generated Go code is invalid (%s), %s", err.Error(), pleaseReport)}
+	}
+
+	return content, *generator.warningList, nil
+}
+
+type tGoGenerator struct {
+	parser *tParser
+	// ruleName
+	// the exported rule being generated, used in the warnings
+	ruleName string
+	// typeNameMap
+	// the Go type names of the rules which have one
+	typeNameMap map[string]string
+	// usedNameSet
+	// the Go identifiers declared at the package level
+	usedNameSet map[string]bool
+	// sumSet
+	// the names of the interfaces generated for `_oneOf` and `_switch`
+	sumSet map[string]bool
+	// aliasSet
+	// the names of the aliases of types of other packages, e.g. time.Time
+	aliasSet    map[string]bool
+	warningList *[]Warning
+	// source
+	// the type declarations
+	source *strings.Builder
+	// builder
+	// the builder functions
+	builder *strings.Builder
+}
+
+// tGoExport -- the rules sharing a builder, whose type is generated from the
+// first one
+type tGoExport struct {
+	exportName string
+	rule       *tRule
+	typeName   string
+}
+
+func (generator *tGoGenerator) warn(format string, argumentList ...interface{}) {
+	*generator.warningList = append(*generator.warningList, &tWarning{
+		text: fmt.Sprintf("warning in Go code generation, rule '%s': ", generator.ruleName) + fmt.Sprintf(format, argumentList...),
+	})
+}
+
+// declareName reserves a package-level Go identifier, derived from the given
+// name
+func (generator *tGoGenerator) declareName(name string) string {
+	goName := goIdentifier(name)
+	if generator.usedNameSet[goName] {
+		k := 2
+		for generator.usedNameSet[fmt.Sprintf("%s%d", goName, k)] {
+			k++
+		}
+		goName = fmt.Sprintf("%s%d", goName, k)
+	}
+	generator.usedNameSet[goName] = true
+	return goName
+}
+
+func (generator *tGoGenerator) generate() string {
+	exportMap := map[string][]string{}
+	for ruleName, rule := range generator.parser.schema.ruleMap {
+		if rule.exportName != "" && rule.lidyMatcher == nil {
+			exportMap[rule.exportName] = append(exportMap[rule.exportName], ruleName)
+		}
+	}
+
+	exportNameList := []string{}
+	for exportName := range exportMap {
+		exportNameList = append(exportNameList, exportName)
+	}
+	sort.Strings(exportNameList)
+
+	generator.usedNameSet["BuilderMap"] = true
+
+	// the names of the exported types are reserved first, so that they do not
+	// depend on the other rules
+	exportList := []tGoExport{}
+	for _, exportName := range exportNameList {
+		ruleNameList := exportMap[exportName]
+		sort.Strings(ruleNameList)
+
+		export := tGoExport{
+			exportName: exportName,
+			rule:       generator.parser.schema.ruleMap[ruleNameList[0]],
+			typeName:   generator.declareName(exportName),
+		}
+		for _, ruleName := range ruleNameList {
+			generator.typeNameMap[ruleName] = export.typeName
+		}
+		if len(ruleNameList) > 1 {
+			generator.ruleName = ruleNameList[0]
+			generator.warn("the rules %s share the builder '%s'; its type is generated from rule '%s'", strings.Join(ruleNameList, ", "), exportName, ruleNameList[0])
+		}
+
+		exportList = append(exportList, export)
+	}
+
+	for _, export := range exportList {
+		if generator.isSum(export.rule) {
+			generator.sumSet[export.typeName] = true
+		}
+	}
+
+	for _, export := range exportList {
+		generator.ruleName = export.rule.ruleName
+		generator.export(export)
+	}
+
+	output := generator.source.String()
+
+	output += "\n// BuilderMap -- the builders filling the generated types, to give to `.With()`\n"
+	output += "func BuilderMap() map[string]lidy.Builder {\n\treturn map[string]lidy.Builder{\n"
+	for _, export := range exportList {
+		output += fmt.Sprintf("\t\t%q: build%s,\n", export.exportName, export.typeName)
+	}
+	output += "\t}\n}\n"
+
+	return output + generator.builder.String()
+}
+
+// export declares the type of an exported rule, and its builder
+func (generator *tGoGenerator) export(export tGoExport) {
+	typeName := export.typeName
+	comment := fmt.Sprintf("\n// %s -- generated from rule %s\n", typeName, export.rule.ruleName)
+
+	annotation := expressionAnnotation(export.rule)
+	if annotation.Description != "" {
+		comment = fmt.Sprintf("\n// %s -- %s\n//\n// Generated from rule %s\n", typeName, strings.Join(strings.Fields(annotation.Description), " "), export.rule.ruleName)
+	}
+	if annotation.Deprecated {
+		// the paragraph recognized by the Go tools
+		message := strings.Join(strings.Fields(annotation.DeprecationMessage), " ")
+		if message == "" {
+			message = "rule " + export.rule.ruleName + " is deprecated."
+		}
+		comment += "//\n// Deprecated: " + message + "\n"
+	}
+
+	switch expression := export.rule.expression.(type) {
+	case tOneOf:
+		generator.source.WriteString(comment)
+		generator.oneOfSum(typeName, expression)
+		return
+	case tSwitch:
+		generator.source.WriteString(comment)
+		generator.switchSum(typeName, expression)
+		return
+	case tMap:
+		if !isPlainMap(expression) {
+			structType := generator.structType(typeName, expression)
+			generator.source.WriteString(comment)
+			generator.source.WriteString("type " + typeName + " " + structType + "\n")
+			generator.decodeBuilder(typeName)
+			return
+		}
+	case *tRule:
+		if targetName, exported := generator.typeNameMap[expression.ruleName]; exported && expression.lidyMatcher == nil {
+			generator.source.WriteString(comment)
+			generator.source.WriteString("type " + typeName + " = " + targetName + "\n")
+			generator.decodeBuilder(typeName)
+			return
+		}
+	case tIn:
+		generator.source.WriteString(comment)
+		generator.source.WriteString("type " + typeName + " string\n")
+		generator.constantList(typeName, expression)
+		generator.decodeBuilder(typeName)
+		return
+	}
+
+	goType := generator.goType(export.rule.expression, typeName)
+	generator.source.WriteString(comment)
+	if goType == "time.Time" {
+		// lidy.Decode only fills time.Time itself
+		generator.aliasSet[typeName] = true
+		generator.source.WriteString("type " + typeName + " = " + goType + "\n")
+	} else {
+		generator.source.WriteString("type " + typeName + " " + goType + "\n")
+	}
+	generator.decodeBuilder(typeName)
+}
+
+// decodeBuilder writes a builder filling the type with lidy.Decode
+func (generator *tGoGenerator) decodeBuilder(typeName string) {
+	fmt.Fprintf(generator.builder, `
+func build%[1]s(input lidy.Result) (interface{}, []error) {
+	var value %[1]s
+	err := lidy.Decode(input, &value)
+	if err != nil {
+		return nil, []error{err}
+	}
+	return value, nil
+}
+`, typeName)
+}
+
+// constantList declares a constant for each string of an `_in`
+func (generator *tGoGenerator) constantList(typeName string, in tIn) {
+	valueList := append([]string{}, in.valueMap["!!str"]...)
+	sort.Strings(valueList)
+
+	lineList := []string{}
+	for _, value := range valueList {
+		if goIdentifier(value) == "X" {
+			continue
+		}
+		lineList = append(lineList, fmt.Sprintf("\t%s %s = %q\n", generator.declareName(typeName+"_"+value), typeName, value))
+	}
+
+	if len(lineList) > 0 {
+		generator.source.WriteString("\nconst (\n" + strings.Join(lineList, "") + ")\n")
+	}
+}
+
+// goType returns the Go type of the data produced by the expression. The
+// maps with properties become structs, declared with the given name
+func (generator *tGoGenerator) goType(expression tExpression, nameHint string) string {
+	switch expression := expression.(type) {
+	case *tRule:
+		return generator.ruleType(expression)
+	case tMap:
+		form := expression.form
+		if form.mapOf.key != nil && len(form.propertyMap)+len(form.optionalMap)+len(form.mergeList) == 0 {
+			return "map[" + generator.keyType(form.mapOf.key, nameHint+"Key") + "]" + generator.goType(form.mapOf.value, nameHint+"Value")
+		}
+		typeName := generator.declareName(nameHint)
+		generator.declareStruct(typeName, generator.ruleName, expression)
+		return typeName
+	case tList:
+		return "[]" + generator.listElementType(expression, nameHint+"Item")
+	case tOneOf:
+		return generator.commonType(expression.optionList, nameHint)
+	case tSwitch:
+		generator.warn("the inline _switch is generated as interface{}; export it to generate a sum type")
+		return "interface{}"
+	case tIn, tRegex:
+		return "string"
+	case tRange:
+		if expression.kind == "int" {
+			return "int"
+		}
+		return "float64"
+	}
+
+	generator.warn("unknown expression %s, generated as interface{}", expression.name())
+	return "interface{}"
+}
+
+func (generator *tGoGenerator) ruleType(rule *tRule) string {
+	if typeName, found := generator.typeNameMap[rule.ruleName]; found {
+		return typeName
+	}
+
+	switch rule.ruleName {
+	case "string", "binary":
+		if rule.lidyMatcher != nil {
+			return "string"
+		}
+	case "int":
+		if rule.lidyMatcher != nil {
+			return "int"
+		}
+	case "float":
+		if rule.lidyMatcher != nil {
+			return "float64"
+		}
+	case "boolean":
+		if rule.lidyMatcher != nil {
+			return "bool"
+		}
+	case "timestamp":
+		if rule.lidyMatcher != nil {
+			return "time.Time"
+		}
+	}
+
+	if rule.lidyMatcher != nil || generator.parser.lidyDefaultRuleMap[rule.ruleName] == rule.origin() {
+		// nullType, any
+		return "interface{}"
+	}
+
+	// the maps of the rules which are not exported are declared once, under
+	// the name of the rule; the name is registered first for the recursive rules
+	if mapChecker, ok := rule.expression.(tMap); ok && !isPlainMap(mapChecker) {
+		typeName := generator.declareName(rule.ruleName)
+		generator.typeNameMap[rule.ruleName] = typeName
+		generator.declareStruct(typeName, rule.ruleName, mapChecker)
+		return typeName
+	}
+
+	// other recursive rules
+	generator.typeNameMap[rule.ruleName] = "interface{}"
+	goType := generator.goType(rule.expression, rule.ruleName)
+	generator.typeNameMap[rule.ruleName] = goType
+
+	return goType
+}
+
+// keyType is the type of the keys of a `_mapOf`, which must be comparable
+func (generator *tGoGenerator) keyType(expression tExpression, nameHint string) string {
+	goType := generator.goType(expression, nameHint)
+	if strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") {
+		return "interface{}"
+	}
+	return goType
+}
+
+// listElementType is the type shared by the entries of a list, or interface{}
+func (generator *tGoGenerator) listElementType(list tList, nameHint string) string {
+	expressionList := append(append([]tExpression{}, list.form.list...), list.form.optionalList...)
+	if list.form.listOf != nil {
+		expressionList = append(expressionList, list.form.listOf)
+	}
+
+	if len(expressionList) == 1 {
+		return generator.goType(expressionList[0], nameHint)
+	}
+
+	return generator.commonType(expressionList, nameHint)
+}
+
+// commonType is the type of several expressions if they all have the same,
+// or interface{}. The maps, lists and switches are not declared, and are
+// interface{}
+func (generator *tGoGenerator) commonType(expressionList []tExpression, nameHint string) string {
+	common := ""
+	for _, expression := range expressionList {
+		if rule, isRule := expression.(*tRule); isRule {
+			if _, named := generator.typeNameMap[rule.ruleName]; !named && scalarDataType(rule) == "" {
+				return "interface{}"
+			}
+		} else if scalarDataType(expression) == "" {
+			return "interface{}"
+		}
+
+		goType := generator.goType(expression, nameHint)
+		if common != "" && common != goType {
+			return "interface{}"
+		}
+		common = goType
+	}
+
+	if common == "" {
+		return "interface{}"
+	}
+	return common
+}
+
+func (generator *tGoGenerator) declareStruct(typeName string, ruleName string, mapChecker tMap) {
+	structType := generator.structType(typeName, mapChecker)
+	generator.source.WriteString(fmt.Sprintf("\n// %s -- generated from rule %s\ntype %s %s\n", typeName, ruleName, typeName, structType))
+}
+
+// structType returns a struct type with a field for each property of the map
+// checker, and of the maps it merges
+func (generator *tGoGenerator) structType(typeName string, mapChecker tMap) string {
+	if mapChecker.form.mapOf.key != nil {
+		generator.warn("the _mapOf entries of %s are not generated; only its properties are", typeName)
+	}
+
+	fieldList := []string{}
+	nameSet := map[string]bool{}
+	generator.addFieldList(typeName, mapChecker, &fieldList, nameSet, map[string]bool{})
+
+	if len(fieldList) == 0 {
+		return "struct{}"
+	}
+	return "struct {\n" + strings.Join(fieldList, "") + "}"
+}
+
+func (generator *tGoGenerator) addFieldList(typeName string, mapChecker tMap, fieldList *[]string, nameSet map[string]bool, visitingSet map[string]bool) {
+	form := mapChecker.form
+
+	for _, mergeable := range form.mergeList {
+		rule, isRule := mergeable.(*tRule)
+		if !isRule {
+			if merged, ok := mergeable.(tMap); ok {
+				generator.addFieldList(typeName, merged, fieldList, nameSet, visitingSet)
+				continue
+			}
+			generator.warn("the merged %s is not generated in %s", mergeable.name(), typeName)
+			continue
+		}
+
+		if embeddedName, exported := generator.typeNameMap[rule.ruleName]; exported && rule.exportName != "" {
+			if _, isMap := rule.expression.(tMap); isMap && !nameSet[embeddedName] {
+				nameSet[embeddedName] = true
+				*fieldList = append(*fieldList, "\t"+embeddedName+"\n")
+				continue
+			}
+		}
+
+		merged, isMap := rule.expression.(tMap)
+		if !isMap || visitingSet[rule.ruleName] {
+			generator.warn("the merged rule '%s' is not generated in %s", rule.ruleName, typeName)
+			continue
+		}
+
+		visitingSet[rule.ruleName] = true
+		generator.addFieldList(typeName, merged, fieldList, nameSet, visitingSet)
+		delete(visitingSet, rule.ruleName)
+	}
+
+	keyList := []string{}
+	for key := range form.propertyMap {
+		keyList = append(keyList, key)
+	}
+	for key := range form.optionalMap {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	// the facultative properties with a `_default` are always present in the MapData
+	defaultSet := map[string]bool{}
+	for _, propertyDefault := range form.defaultList {
+		defaultSet[propertyDefault.property] = true
+	}
+
+	for _, key := range keyList {
+		expression, required := form.propertyMap[key]
+		if !required {
+			expression = form.optionalMap[key]
+		}
+
+		fieldName := goIdentifier(key)
+		for k := 2; nameSet[fieldName]; k++ {
+			fieldName = fmt.Sprintf("%s%d", goIdentifier(key), k)
+		}
+		nameSet[fieldName] = true
+
+		fieldType := generator.goType(expression, typeName+goIdentifier(key))
+		if !required && !defaultSet[key] && !strings.HasPrefix(fieldType, "[]") && !strings.HasPrefix(fieldType, "map[") && fieldType != "interface{}" && !generator.sumSet[fieldType] {
+			fieldType = "*" + fieldType
+		}
+
+		*fieldList = append(*fieldList, fmt.Sprintf("\t%s %s `lidy:%q`\n", fieldName, fieldType, key))
+	}
+}
+
+// oneOfSum declares an interface implemented by the types of the options,
+// and a builder returning the built option. The options which are lidy
+// scalars, or checkers of scalars, are wrapped in types named after the kind
+func (generator *tGoGenerator) oneOfSum(typeName string, oneOf tOneOf) {
+	marker := "is" + typeName
+
+	generator.source.WriteString(fmt.Sprintf("type %s interface {\n\t%s()\n}\n", typeName, marker))
+
+	implementerList := []string{}
+	caseList := []string{}
+	wrapperSet := map[string]bool{}
+	nullable := false
+
+	for _, option := range oneOf.optionList {
+		rule, isRule := option.(*tRule)
+		if isRule && rule.exportName != "" && rule.lidyMatcher == nil {
+			optionType := generator.typeNameMap[rule.ruleName]
+			if generator.sumSet[optionType] || generator.aliasSet[optionType] {
+				generator.warn("the type of the option '%s' cannot implement %s; it is not generated", rule.ruleName, typeName)
+				continue
+			}
+			implementerList = append(implementerList, optionType)
+			continue
+		}
+
+		if isRule && rule.ruleName == "nullType" {
+			nullable = true
+			continue
+		}
+
+		dataType := scalarDataType(option)
+		if dataType == "" {
+			generator.warn("the option %s is neither an exported rule nor a scalar; it is not generated in %s", option.name(), typeName)
+			continue
+		}
+		if wrapperSet[dataType] {
+			continue
+		}
+		wrapperSet[dataType] = true
+
+		wrapperName := generator.declareName(typeName + goIdentifier(strings.TrimSuffix(dataType, "64")))
+		generator.source.WriteString(fmt.Sprintf("\n// %s -- the %s option of %s\ntype %s %s\n", wrapperName, dataType, typeName, wrapperName, dataType))
+		implementerList = append(implementerList, wrapperName)
+		caseList = append(caseList, fmt.Sprintf("\tcase %s:\n\t\treturn %s(data), nil\n", dataType, wrapperName))
+	}
+
+	generator.markerList(marker, implementerList)
+
+	if nullable {
+		caseList = append(caseList, "\tcase nil:\n\t\treturn nil, nil\n")
+	}
+
+	fmt.Fprintf(generator.builder, `
+func build%[1]s(input lidy.Result) (interface{}, []error) {
+	switch data := input.Data().(type) {
+	case %[1]s:
+		return data, nil
+%[2]s	}
+	return nil, []error{fmt.Errorf("no option of %[1]s holds the %%T value of rule '%%s'", input.Data(), input.RuleName())}
+}
+`, typeName, strings.Join(caseList, ""))
+}
+
+// switchSum declares an interface implemented by a type for each case, and a
+// builder decoding the case selected by the discriminator
+func (generator *tGoGenerator) switchSum(typeName string, switchChecker tSwitch) {
+	marker := "is" + typeName
+
+	generator.source.WriteString(fmt.Sprintf("type %s interface {\n\t%s()\n}\n", typeName, marker))
+
+	implementerList := []string{}
+	caseList := []string{}
+
+	for _, caseName := range switchChecker.caseNameList {
+		var caseType string
+
+		switch mergeable := switchChecker.caseMap[caseName].(type) {
+		case *tRule:
+			if _, isMap := mergeable.expression.(tMap); isMap {
+				caseType = generator.ruleType(mergeable)
+			}
+		case tMap:
+			caseType = generator.declareName(typeName + goIdentifier(caseName))
+			generator.source.WriteString(fmt.Sprintf("\n// %s -- the case %s of %s\ntype %s %s\n", caseType, caseName, typeName, caseType, generator.structType(caseType, mergeable)))
+		}
+
+		if caseType == "" {
+			generator.warn("the case '%s' is not a map checker; it is not generated", caseName)
+			continue
+		}
+
+		implementerList = append(implementerList, caseType)
+		caseList = append(caseList, fmt.Sprintf(`	case %q:
+		var value %s
+		err := lidy.Decode(input, &value)
+		if err != nil {
+			return nil, []error{err}
+		}
+		return value, nil
+`, caseName, caseType))
+	}
+
+	generator.markerList(marker, implementerList)
+
+	fmt.Fprintf(generator.builder, `
+func build%[1]s(input lidy.Result) (interface{}, []error) {
+	discriminator := input.Data().(lidy.MapData).Map[%[2]q].Data()
+	switch discriminator {
+%[3]s	}
+	return nil, []error{fmt.Errorf("no case of %[1]s for the %[2]s '%%v' of rule '%%s'", discriminator, input.RuleName())}
+}
+`, typeName, switchChecker.key, strings.Join(caseList, ""))
+}
+
+// markerList declares the marker method of a sum type on its implementers,
+// once per type
+func (generator *tGoGenerator) markerList(marker string, implementerList []string) {
+	doneSet := map[string]bool{}
+	for _, implementer := range implementerList {
+		if doneSet[implementer] {
+			continue
+		}
+		doneSet[implementer] = true
+		generator.source.WriteString(fmt.Sprintf("\nfunc (%s) %s() {}\n", implementer, marker))
+	}
+}
+
+// isSum tells the exported rules generated as sum types
+func (generator *tGoGenerator) isSum(rule *tRule) bool {
+	switch rule.expression.(type) {
+	case tOneOf, tSwitch:
+		return true
+	}
+	return false
+}
+
+// scalarDataType is the Go type of the data of the scalar expressions, or ""
+func scalarDataType(expression tExpression) string {
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.lidyMatcher == nil {
+			return ""
+		}
+		switch expression.ruleName {
+		case "string", "binary", "timestamp":
+			return "string"
+		case "int":
+			return "int"
+		case "float":
+			return "float64"
+		case "boolean":
+			return "bool"
+		}
+	case tIn, tRegex:
+		return "string"
+	case tRange:
+		if expression.kind == "int" {
+			return "int"
+		}
+		return "float64"
+	}
+	return ""
+}
+
+// isPlainMap tells the map checkers which only have a `_mapOf`, and are Go maps
+func isPlainMap(mapChecker tMap) bool {
+	form := mapChecker.form
+	return form.mapOf.key != nil && len(form.propertyMap)+len(form.optionalMap)+len(form.mergeList) == 0
+}
+
+// goIdentifier turns a name into an exported Go identifier, e.g. `net.port`
+// into `NetPort`
+func goIdentifier(name string) string {
+	partList := regexGoNameSeparator.Split(name, -1)
+
+	identifier := ""
+	for _, part := range partList {
+		if part != "" {
+			identifier += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	if identifier == "" || (identifier[0] >= '0' && identifier[0] <= '9') {
+		identifier = "X" + identifier
+	}
+
+	return identifier
+}
@@ -0,0 +1,289 @@
+package lidy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// lidyGraph.go
+//
+// Draw the rule dependency graph of a schema, in DOT or in Mermaid
+
+// GraphFormat -- the output format of Graph
+type GraphFormat string
+
+const (
+	// GraphDot the DOT language of Graphviz
+	GraphDot GraphFormat = "dot"
+	// GraphMermaid a Mermaid flowchart
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+// tGraphNodeKind -- how a rule is drawn
+type tGraphNodeKind int
+
+const (
+	graphRule tGraphNodeKind = iota
+	graphExportedRule
+	graphPredefinedRule
+)
+
+// Graph -- process the schema if needed, and draw its rules as nodes and
+// their references as edges, labelled with how the reference is made, e.g.
+// `servers / _listOf`. If from is not empty, only the rules it uses,
+// directly or not, are drawn. The user rules come first, sorted by name, then
+// the predefined rules they use.
+func (p *tParser) Graph(format GraphFormat, from string) ([]byte, []error) {
+	var writer tGraphWriter
+	switch format {
+	case GraphDot:
+		writer = &tDotWriter{}
+	case GraphMermaid:
+		writer = &tMermaidWriter{}
+	default:
+		return nil, []error{fmt.Errorf("unknown graph format '%s', expected dot or mermaid", format)}
+	}
+
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	ruleList := (*tSchemaParser)(p).userRuleList()
+	for _, ruleName := range referencedPredefinedRuleList(p.schema.dependencyList) {
+		ruleList = append(ruleList, p.schema.ruleMap[ruleName])
+	}
+
+	if from != "" {
+		fromRule, ruleFound := p.schema.ruleMap[from]
+		if !ruleFound {
+			return nil, []error{fmt.Errorf("Could not find rule '%s' in grammar", from)}
+		}
+
+		reachableSet := reachableRuleSet(fromRule, p.schema.dependencyList)
+		reachableList := []*tRule{}
+		for _, rule := range ruleList {
+			if reachableSet[rule] {
+				reachableList = append(reachableList, rule)
+			}
+		}
+		if fromRule.IsPredefined() && len(reachableList) == 0 {
+			reachableList = append(reachableList, fromRule)
+		}
+		ruleList = reachableList
+	}
+
+	indexMap := map[*tRule]int{}
+	writer.begin(path.Base(p.name))
+	for k, rule := range ruleList {
+		indexMap[rule] = k
+
+		kind := graphRule
+		if rule.IsPredefined() {
+			kind = graphPredefinedRule
+		} else if rule.exportName != "" {
+			kind = graphExportedRule
+		}
+		writer.node(k, rule.ruleName, kind)
+	}
+	for _, dependency := range p.schema.dependencyList {
+		fromIndex, fromPresent := indexMap[dependency.from]
+		toIndex, toPresent := indexMap[dependency.reference.origin()]
+		if fromPresent && toPresent {
+			writer.edge(fromIndex, ruleList[fromIndex].ruleName, toIndex, ruleList[toIndex].ruleName, graphLabel(dependency))
+		}
+	}
+	writer.end()
+
+	return []byte(writer.String()), nil
+}
+
+// referencedPredefinedRuleList lists the predefined rules used by the user
+// rules, sorted by name
+func referencedPredefinedRuleList(dependencyList []tDependency) []string {
+	ruleNameList := []string{}
+	ruleNameSet := map[string]bool{}
+
+	for _, dependency := range dependencyList {
+		rule := dependency.reference.origin()
+		if rule.IsPredefined() && !ruleNameSet[rule.ruleName] {
+			ruleNameSet[rule.ruleName] = true
+			ruleNameList = append(ruleNameList, rule.ruleName)
+		}
+	}
+	sort.Strings(ruleNameList)
+
+	return ruleNameList
+}
+
+// reachableRuleSet gives the rules used by a rule, directly or not, and the
+// rule itself
+func reachableRuleSet(rule *tRule, dependencyList []tDependency) map[*tRule]bool {
+	referenceMap := map[*tRule][]*tRule{}
+	for _, dependency := range dependencyList {
+		referenceMap[dependency.from] = append(referenceMap[dependency.from], dependency.reference.origin())
+	}
+
+	reachableSet := map[*tRule]bool{rule: true}
+	pendingList := []*tRule{rule}
+	for len(pendingList) > 0 {
+		current := pendingList[0]
+		pendingList = pendingList[1:]
+
+		for _, next := range referenceMap[current] {
+			if !reachableSet[next] {
+				reachableSet[next] = true
+				pendingList = append(pendingList, next)
+			}
+		}
+	}
+
+	return reachableSet
+}
+
+// graphLabel describes how a reference is made, from its path in the rule,
+// e.g. `servers / _listOf` for `/main/_map/servers/_listOf`. The references
+// which are the expression of their rule have no label
+func graphLabel(dependency tDependency) string {
+	segmentList := dependency.path[1:]
+	partList := []string{}
+
+	for k := 0; k < len(segmentList); k++ {
+		keyword := segmentList[k]
+
+		switch keyword {
+		case "_listOf":
+			partList = append(partList, keyword)
+			continue
+		case "_mapOf":
+			switch {
+			case k < len(segmentList)-1:
+				partList = append(partList, keyword)
+			case dependency.kind == DependencyMapKey:
+				partList = append(partList, "_mapOf key")
+			default:
+				partList = append(partList, "_mapOf value")
+			}
+			continue
+		}
+
+		// the other keywords are followed by a key or an index
+		if k+1 >= len(segmentList) {
+			break
+		}
+		k++
+		argument := segmentList[k]
+
+		switch keyword {
+		case "_map":
+			partList = append(partList, argument)
+		case "_mapFacultative":
+			partList = append(partList, argument+"?")
+		case "_merge":
+			partList = append(partList, keyword)
+		default:
+			// _list, _listFacultative, _oneOf and _switch
+			partList = append(partList, keyword+" "+argument)
+		}
+	}
+
+	return strings.Join(partList, " / ")
+}
+
+//
+// Writers
+//
+
+// tGraphWriter -- writes the graph in an output format. The nodes are
+// identified by their index, or by their name
+type tGraphWriter interface {
+	begin(title string)
+	node(index int, name string, kind tGraphNodeKind)
+	edge(fromIndex int, fromName string, toIndex int, toName string, label string)
+	end()
+	String() string
+}
+
+// DOT
+
+type tDotWriter struct {
+	strings.Builder
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotString(text string) string {
+	return `"` + dotEscaper.Replace(text) + `"`
+}
+
+func (writer *tDotWriter) begin(title string) {
+	writer.WriteString("digraph " + dotString(title) + " {\n")
+	writer.WriteString("  rankdir=LR;\n")
+	writer.WriteString("  node [shape=box];\n")
+}
+
+func (writer *tDotWriter) node(_ int, name string, kind tGraphNodeKind) {
+	attribute := ""
+	switch kind {
+	case graphExportedRule:
+		attribute = " [peripheries=2]"
+	case graphPredefinedRule:
+		attribute = " [shape=ellipse, style=dashed]"
+	}
+	writer.WriteString("  " + dotString(name) + attribute + ";\n")
+}
+
+func (writer *tDotWriter) edge(_ int, fromName string, _ int, toName string, label string) {
+	attribute := ""
+	if label != "" {
+		attribute = " [label=" + dotString(label) + "]"
+	}
+	writer.WriteString("  " + dotString(fromName) + " -> " + dotString(toName) + attribute + ";\n")
+}
+
+func (writer *tDotWriter) end() {
+	writer.WriteString("}\n")
+}
+
+// Mermaid
+
+type tMermaidWriter struct {
+	strings.Builder
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;")
+
+func mermaidString(text string) string {
+	return `"` + mermaidEscaper.Replace(text) + `"`
+}
+
+func (writer *tMermaidWriter) begin(title string) {
+	writer.WriteString("---\ntitle: " + mermaidString(title) + "\n---\n")
+	writer.WriteString("flowchart LR\n")
+	writer.WriteString("  classDef exported stroke-width:3px\n")
+	writer.WriteString("  classDef predefined stroke-dasharray:5 5\n")
+}
+
+// node uses the index as identifier, as the rule names may contain dots
+func (writer *tMermaidWriter) node(index int, name string, kind tGraphNodeKind) {
+	shape := "[" + mermaidString(name) + "]"
+	switch kind {
+	case graphExportedRule:
+		shape = "[[" + mermaidString(name) + "]]:::exported"
+	case graphPredefinedRule:
+		shape = "([" + mermaidString(name) + "]):::predefined"
+	}
+	writer.WriteString(fmt.Sprintf("  n%d%s\n", index, shape))
+}
+
+func (writer *tMermaidWriter) edge(fromIndex int, _ string, toIndex int, _ string, label string) {
+	arrow := "-->"
+	if label != "" {
+		arrow += "|" + mermaidString(label) + "|"
+	}
+	writer.WriteString(fmt.Sprintf("  n%d %s n%d\n", fromIndex, arrow, toIndex))
+}
+
+func (writer *tMermaidWriter) end() {}
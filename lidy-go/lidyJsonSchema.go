@@ -0,0 +1,561 @@
+package lidy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyJsonSchema.go
+//
+// Export the compiled schema as a JSON Schema (draft 2020-12) document
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JsonSchema -- process the schema if needed, and export it as a JSON Schema
+// (draft 2020-12) document. Each user rule becomes an entry of `$defs`, and
+// the document refers to the target rule. The warnings report the lidy
+// constructs which cannot be translated exactly.
+func (p *tParser) JsonSchema() ([]byte, []Warning, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, nil, erl
+	}
+
+	if _, ruleFound := p.schema.ruleMap[p.target]; !ruleFound {
+		return nil, nil, []error{fmt.Errorf("Could not find target rule '%s' in grammar", p.target)}
+	}
+
+	exporter := tJsonSchemaExporter{
+		parser:      p,
+		warningList: &[]Warning{},
+		visitingSet: map[string]bool{},
+	}
+
+	content, err := json.MarshalIndent(exporter.document(), "", "  ")
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+
+	return append(content, '\n'), *exporter.warningList, nil
+}
+
+type tJsonSchemaExporter struct {
+	parser *tParser
+	// ruleName
+	// the rule being exported, used in the warnings
+	ruleName    string
+	warningList *[]Warning
+	// visitingSet
+	// the rules being inlined through `_merge`, to detect self-references
+	visitingSet map[string]bool
+}
+
+func (exporter tJsonSchemaExporter) document() map[string]interface{} {
+	ruleNameList := []string{}
+	for ruleName, rule := range exporter.parser.schema.ruleMap {
+		if !exporter.isLidyDefaultRule(rule) {
+			ruleNameList = append(ruleNameList, ruleName)
+		}
+	}
+	sort.Strings(ruleNameList)
+
+	defMap := map[string]interface{}{}
+	for _, ruleName := range ruleNameList {
+		exporter.ruleName = ruleName
+		defMap[ruleName] = exporter.expression(exporter.parser.schema.ruleMap[ruleName].expression)
+	}
+
+	document := map[string]interface{}{
+		"$schema": jsonSchemaDialect,
+		"$defs":   defMap,
+	}
+
+	targetRule := exporter.parser.schema.ruleMap[exporter.parser.target]
+	if exporter.isLidyDefaultRule(targetRule) {
+		for key, value := range lidyDefaultRuleJsonSchema(targetRule.ruleName) {
+			document[key] = value
+		}
+	} else {
+		document["$ref"] = jsonSchemaRef(targetRule.ruleName)
+	}
+
+	return document
+}
+
+// isLidyDefaultRule tells the rules which are translated inline, including
+// `any`, which is defined by an expression
+func (exporter tJsonSchemaExporter) isLidyDefaultRule(rule *tRule) bool {
+	return rule.lidyMatcher != nil || exporter.parser.lidyDefaultRuleMap[rule.ruleName] == rule.origin()
+}
+
+func (exporter tJsonSchemaExporter) warn(format string, argumentList ...interface{}) {
+	*exporter.warningList = append(*exporter.warningList, &tWarning{
+		text: fmt.Sprintf("warning in JSON Schema export, rule '%s': ", exporter.ruleName) + fmt.Sprintf(format, argumentList...),
+	})
+}
+
+// expression translates any lidy expression, and its annotation
+func (exporter tJsonSchemaExporter) expression(expression tExpression) interface{} {
+	schema := exporter.checker(expression)
+
+	annotation := expression.Annotation()
+	if annotation.isEmpty() {
+		return schema
+	}
+
+	annotatedSchema := map[string]interface{}{}
+	if value, ok := schema.(map[string]interface{}); ok {
+		for keyword, argument := range value {
+			annotatedSchema[keyword] = argument
+		}
+	} else {
+		annotatedSchema["allOf"] = []interface{}{schema}
+	}
+
+	if annotation.Description != "" {
+		annotatedSchema["description"] = annotation.Description
+	}
+	if len(annotation.Examples) > 0 {
+		// the values which JSON cannot represent, e.g. maps with non-string keys, are left out
+		if _, err := json.Marshal(annotation.Examples); err == nil {
+			annotatedSchema["examples"] = annotation.Examples
+		}
+	}
+	if annotation.Deprecated {
+		annotatedSchema["deprecated"] = true
+	}
+
+	return annotatedSchema
+}
+
+// checker translates the constraints of any lidy expression
+func (exporter tJsonSchemaExporter) checker(expression tExpression) interface{} {
+	switch expression := expression.(type) {
+	case *tRule:
+		if exporter.isLidyDefaultRule(expression) {
+			return lidyDefaultRuleJsonSchema(expression.ruleName)
+		}
+		return map[string]interface{}{"$ref": jsonSchemaRef(expression.ruleName)}
+	case tMap:
+		return exporter.mapSchema(expression)
+	case tList:
+		return exporter.listSchema(expression)
+	case tOneOf:
+		if len(expression.optionList) == 0 {
+			return false
+		}
+		optionList := []interface{}{}
+		for _, option := range expression.optionList {
+			optionList = append(optionList, exporter.expression(option))
+		}
+		return map[string]interface{}{"anyOf": optionList}
+	case tSwitch:
+		schema := exporter.switchSchema(expression)
+		schema["unevaluatedProperties"] = false
+		return schema
+	case tIn:
+		return inJsonSchema(expression)
+	case tRegex:
+		return map[string]interface{}{
+			"type":    "string",
+			"pattern": expression.regexString,
+		}
+	case tRange:
+		return rangeJsonSchema(expression)
+	}
+
+	exporter.warn("unknown expression %s, exported as an unconstrained schema", expression.name())
+	return map[string]interface{}{}
+}
+
+// mapSchema translates a map checker; the properties which are not declared
+// by the map are rejected, or checked against _mapOf
+func (exporter tJsonSchemaExporter) mapSchema(mapChecker tMap) map[string]interface{} {
+	schema := exporter.openMapSchema(mapChecker)
+	mapOf := mapChecker.form.mapOf
+
+	if mapOf.key == nil {
+		if len(mapChecker.form.mergeList) > 0 {
+			schema["unevaluatedProperties"] = false
+		} else {
+			schema["additionalProperties"] = false
+		}
+		return schema
+	}
+
+	if len(mapChecker.form.mergeList) > 0 {
+		exporter.warn("the properties of the merged expressions are checked against _mapOf too, in JSON Schema")
+	}
+
+	value := exporter.expression(mapOf.value)
+
+	switch key := resolveRule(mapOf.key).(type) {
+	case tRegex:
+		schema["patternProperties"] = map[string]interface{}{key.regexString: value}
+		schema["additionalProperties"] = false
+	case tIn:
+		schema["propertyNames"] = inJsonSchema(key)
+		schema["additionalProperties"] = value
+	case *tRule:
+		switch key.ruleName {
+		case "string", "any":
+		case "timestamp", "binary":
+			schema["propertyNames"] = lidyDefaultRuleJsonSchema(key.ruleName)
+		default:
+			exporter.warn("the keys of _mapOf are %s, but the keys of JSON objects are strings; they are not checked", key.ruleName)
+		}
+		schema["additionalProperties"] = value
+	default:
+		exporter.warn("the keys of _mapOf are %s, which JSON Schema cannot check; they are not checked", mapOf.key.name())
+		schema["additionalProperties"] = value
+	}
+
+	return schema
+}
+
+// openMapSchema translates the properties, the merged expressions and the
+// sizing of a map checker, without rejecting the other properties, so that
+// the result can be merged
+func (exporter tJsonSchemaExporter) openMapSchema(mapChecker tMap) map[string]interface{} {
+	form := mapChecker.form
+
+	schema := map[string]interface{}{"type": "object"}
+
+	propertyMap := map[string]interface{}{}
+	for key, value := range form.optionalMap {
+		propertyMap[key] = exporter.expression(value)
+	}
+	for _, propertyDefault := range form.defaultList {
+		// the values which JSON cannot represent, e.g. maps with non-string keys, are left out
+		var data interface{}
+		if propertyDefault.node.Decode(&data) != nil {
+			continue
+		}
+		if _, err := json.Marshal(data); err != nil {
+			continue
+		}
+
+		propertySchema := map[string]interface{}{"default": data}
+		if value, ok := propertyMap[propertyDefault.property].(map[string]interface{}); ok {
+			for keyword, argument := range value {
+				propertySchema[keyword] = argument
+			}
+		} else {
+			propertySchema["allOf"] = []interface{}{propertyMap[propertyDefault.property]}
+		}
+		propertyMap[propertyDefault.property] = propertySchema
+	}
+	for key, value := range form.propertyMap {
+		propertyMap[key] = exporter.expression(value)
+	}
+	if len(propertyMap) > 0 {
+		schema["properties"] = propertyMap
+	}
+
+	if len(form.propertyMap) > 0 {
+		requiredList := []string{}
+		for key := range form.propertyMap {
+			requiredList = append(requiredList, key)
+		}
+		sort.Strings(requiredList)
+		schema["required"] = requiredList
+	}
+
+	if len(form.mergeList) > 0 {
+		exporter.checkOverride(mapChecker)
+
+		allOf := []interface{}{}
+		for _, mergeable := range form.mergeList {
+			allOf = append(allOf, exporter.openSchema(mergeable))
+		}
+		schema["allOf"] = allOf
+	}
+
+	addSizing(schema, mapChecker.sizing, "minProperties", "maxProperties")
+
+	return schema
+}
+
+// openSchema translates a mergeable expression, without rejecting the
+// properties it does not declare. The rules are inlined, since their exported
+// definition rejects the other properties
+func (exporter tJsonSchemaExporter) openSchema(mergeable tMergeableExpression) interface{} {
+	switch mergeable := mergeable.(type) {
+	case *tRule:
+		if exporter.visitingSet[mergeable.ruleName] {
+			exporter.warn("rule '%s' merges itself, which JSON Schema cannot express", mergeable.ruleName)
+			return map[string]interface{}{}
+		}
+
+		inner, ok := mergeable.expression.(tMergeableExpression)
+		if !ok {
+			exporter.warn("rule '%s' is merged, but is not a map checker", mergeable.ruleName)
+			return exporter.expression(mergeable)
+		}
+
+		exporter.visitingSet[mergeable.ruleName] = true
+		defer delete(exporter.visitingSet, mergeable.ruleName)
+
+		return exporter.openSchema(inner)
+	case tMap:
+		return exporter.openMapSchema(mergeable)
+	case tOneOf:
+		if len(mergeable.optionList) == 0 {
+			return false
+		}
+		optionList := []interface{}{}
+		for _, option := range mergeable.optionList {
+			if option, ok := option.(tMergeableExpression); ok {
+				optionList = append(optionList, exporter.openSchema(option))
+			}
+		}
+		return map[string]interface{}{"anyOf": optionList}
+	case tSwitch:
+		return exporter.switchSchema(mergeable)
+	}
+
+	exporter.warn("unknown mergeable expression %s, exported as an unconstrained schema", mergeable.name())
+	return map[string]interface{}{}
+}
+
+// switchSchema translates a switch into one branch per case, selected by the
+// constant value of the discriminator
+func (exporter tJsonSchemaExporter) switchSchema(switchChecker tSwitch) map[string]interface{} {
+	caseList := []interface{}{}
+	for _, caseName := range switchChecker.caseNameList {
+		caseList = append(caseList, map[string]interface{}{
+			"properties": map[string]interface{}{
+				switchChecker.key: map[string]interface{}{"const": caseName},
+			},
+			"allOf": []interface{}{exporter.openSchema(switchChecker.caseMap[caseName])},
+		})
+	}
+
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{switchChecker.key},
+	}
+
+	if len(caseList) == 0 {
+		schema["not"] = map[string]interface{}{}
+	} else {
+		schema["anyOf"] = caseList
+	}
+
+	return schema
+}
+
+// checkOverride warns about the properties declared both by a map checker
+// and by the expressions it merges, or by several of them. Lidy uses the
+// first declaration, while JSON Schema requires all of them to be satisfied
+func (exporter tJsonSchemaExporter) checkOverride(mapChecker tMap) {
+	ownerMap := map[string]string{}
+	for key := range mapChecker.form.propertyMap {
+		ownerMap[key] = "the map"
+	}
+	for key := range mapChecker.form.optionalMap {
+		ownerMap[key] = "the map"
+	}
+
+	overrideList := []string{}
+	for _, mergeable := range mapChecker.form.mergeList {
+		for key := range propertyNameSet(mergeable, map[string]bool{}) {
+			if owner, present := ownerMap[key]; present {
+				overrideList = append(overrideList, fmt.Sprintf("'%s' (%s and %s)", key, owner, mergeable.name()))
+			} else {
+				ownerMap[key] = mergeable.name()
+			}
+		}
+	}
+
+	if len(overrideList) > 0 {
+		sort.Strings(overrideList)
+		exporter.warn(
+			"_merge overrides the properties %s; JSON Schema requires all their declarations to be satisfied",
+			strings.Join(overrideList, ", "),
+		)
+	}
+}
+
+// propertyNameSet lists the properties declared by a mergeable expression
+func propertyNameSet(expression tExpression, visitingSet map[string]bool) map[string]bool {
+	nameSet := map[string]bool{}
+
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.expression != nil && !visitingSet[expression.ruleName] {
+			visitingSet[expression.ruleName] = true
+			nameSet = propertyNameSet(expression.expression, visitingSet)
+			delete(visitingSet, expression.ruleName)
+		}
+	case tMap:
+		for key := range expression.form.propertyMap {
+			nameSet[key] = true
+		}
+		for key := range expression.form.optionalMap {
+			nameSet[key] = true
+		}
+		for _, mergeable := range expression.form.mergeList {
+			for key := range propertyNameSet(mergeable, visitingSet) {
+				nameSet[key] = true
+			}
+		}
+	case tOneOf:
+		for _, option := range expression.optionList {
+			for key := range propertyNameSet(option, visitingSet) {
+				nameSet[key] = true
+			}
+		}
+	case tSwitch:
+		nameSet[expression.key] = true
+		for _, mergeable := range expression.caseMap {
+			for key := range propertyNameSet(mergeable, visitingSet) {
+				nameSet[key] = true
+			}
+		}
+	}
+
+	return nameSet
+}
+
+func (exporter tJsonSchemaExporter) listSchema(list tList) map[string]interface{} {
+	form := list.form
+
+	schema := map[string]interface{}{"type": "array"}
+
+	prefixItems := []interface{}{}
+	for _, expression := range append(append([]tExpression{}, form.list...), form.optionalList...) {
+		prefixItems = append(prefixItems, exporter.expression(expression))
+	}
+	if len(prefixItems) > 0 {
+		schema["prefixItems"] = prefixItems
+	}
+
+	if form.listOf != nil {
+		schema["items"] = exporter.expression(form.listOf)
+	} else {
+		schema["items"] = false
+	}
+
+	if len(form.list) > 0 {
+		schema["minItems"] = len(form.list)
+	}
+
+	addSizing(schema, list.sizing, "minItems", "maxItems")
+
+	return schema
+}
+
+// addSizing sets the size keywords, keeping the greatest minimum
+func addSizing(schema map[string]interface{}, sizing tSizing, minKey string, maxKey string) {
+	setMin := func(min int) {
+		if current, present := schema[minKey].(int); !present || current < min {
+			schema[minKey] = min
+		}
+	}
+
+	switch sizing := sizing.(type) {
+	case tSizingMin:
+		setMin(sizing.min)
+	case tSizingMax:
+		schema[maxKey] = sizing.max
+	case tSizingMinMax:
+		setMin(sizing.min)
+		schema[maxKey] = sizing.max
+	case tSizingNb:
+		setMin(sizing.nb)
+		schema[maxKey] = sizing.nb
+	}
+}
+
+func inJsonSchema(in tIn) map[string]interface{} {
+	tagList := []string{}
+	for tag := range in.valueMap {
+		tagList = append(tagList, tag)
+	}
+	sort.Strings(tagList)
+
+	enum := []interface{}{}
+	for _, tag := range tagList {
+		for _, value := range in.valueMap[tag] {
+			node := yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+
+			var data interface{}
+			if node.Decode(&data) != nil {
+				data = value
+			}
+			enum = append(enum, data)
+		}
+	}
+
+	return map[string]interface{}{"enum": enum}
+}
+
+func rangeJsonSchema(rng tRange) map[string]interface{} {
+	schema := map[string]interface{}{"type": "number"}
+	if rng.kind == "int" {
+		schema["type"] = "integer"
+	}
+
+	if rng.min.present {
+		if rng.min.inclusive {
+			schema["minimum"] = rng.min.value
+		} else {
+			schema["exclusiveMinimum"] = rng.min.value
+		}
+	}
+
+	if rng.max.present {
+		if rng.max.inclusive {
+			schema["maximum"] = rng.max.value
+		} else {
+			schema["exclusiveMaximum"] = rng.max.value
+		}
+	}
+
+	return schema
+}
+
+func lidyDefaultRuleJsonSchema(ruleName string) map[string]interface{} {
+	switch ruleName {
+	case "string":
+		return map[string]interface{}{"type": "string"}
+	case "int":
+		return map[string]interface{}{"type": "integer"}
+	case "float":
+		return map[string]interface{}{"type": "number"}
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case "nullType":
+		return map[string]interface{}{"type": "null"}
+	case "timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "binary":
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	}
+
+	// any
+	return map[string]interface{}{}
+}
+
+// resolveRule follows the rule references, down to a checker or a lidy
+// default rule
+func resolveRule(expression tExpression) tExpression {
+	for k := 0; k < 64; k++ {
+		rule, ok := expression.(*tRule)
+		if !ok || rule.expression == nil {
+			break
+		}
+		expression = rule.expression
+	}
+
+	return expression
+}
+
+func jsonSchemaRef(ruleName string) string {
+	return "#/$defs/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(ruleName)
+}
@@ -0,0 +1,856 @@
+package lidy
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ditrit/lidy/errorlist"
+	"gopkg.in/yaml.v3"
+)
+
+// lidyJsonSchemaImport.go
+//
+// Convert a JSON Schema document into a lidy schema document
+
+// jsonSchemaAnnotationSet -- the keywords which do not constrain the values,
+// and are dropped silently
+var jsonSchemaAnnotationSet = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "$defs": true, "definitions": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// jsonSchemaKeywordSet -- the keywords which are translated, at least in part
+var jsonSchemaKeywordSet = map[string]bool{
+	"$ref": true, "type": true, "enum": true, "const": true,
+	"anyOf": true, "oneOf": true, "allOf": true,
+	"properties": true, "required": true, "additionalProperties": true, "unevaluatedProperties": true,
+	"patternProperties": true, "propertyNames": true, "minProperties": true, "maxProperties": true,
+	"prefixItems": true, "items": true, "additionalItems": true, "minItems": true, "maxItems": true,
+	"pattern": true, "format": true, "contentEncoding": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+}
+
+var jsonSchemaObjectKeywordList = []string{"properties", "required", "additionalProperties", "unevaluatedProperties", "patternProperties", "propertyNames", "minProperties", "maxProperties"}
+var jsonSchemaArrayKeywordList = []string{"prefixItems", "items", "additionalItems", "minItems", "maxItems"}
+var jsonSchemaStringKeywordList = []string{"pattern", "format", "contentEncoding"}
+var jsonSchemaNumberKeywordList = []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"}
+
+var regexRuleNameInvalidCharacter = *regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// FromJsonSchema -- convert a JSON Schema document (draft 2020-12, or draft-07
+// `definitions`) into a lidy schema document. The root schema becomes the rule
+// `main`, and each entry of `$defs` a rule. The warnings report the keywords
+// which are not supported, and are thus not checked by the lidy schema.
+func FromJsonSchema(file File) ([]byte, []Warning, []error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(file.Content(), &document)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+	if len(document.Content) == 0 {
+		return nil, nil, []error{fmt.Errorf("yaml: the file is empty")}
+	}
+
+	importer := tJsonSchemaImporter{
+		filename:    file.Name(),
+		defMap:      map[string]yaml.Node{},
+		ruleNameMap: map[string]string{"#": "main", "": "main"},
+		warningList: &[]Warning{},
+		errList:     &errorlist.List{},
+	}
+
+	output := importer.document(*document.Content[0])
+
+	erl := importer.errList.ConcatError()
+	if len(erl) > 0 {
+		return nil, nil, erl
+	}
+
+	buffer := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err = encoder.Encode(output)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+
+	return buffer.Bytes(), *importer.warningList, nil
+}
+
+type tJsonSchemaImporter struct {
+	filename string
+	// defMap
+	// the schemas of `$defs`, by reference, e.g. `#/$defs/name`
+	defMap map[string]yaml.Node
+	// ruleNameMap
+	// the lidy rule names, by reference
+	ruleNameMap map[string]string
+	warningList *[]Warning
+	errList     *errorlist.List
+}
+
+// tJsonObject -- the object keywords of a schema, and of the schemas it
+// gathers through `allOf`
+type tJsonObject struct {
+	propertyNameList []string
+	propertyMap      map[string]yaml.Node
+	requiredList     []string
+	closed           bool
+	additional       *yaml.Node
+	patternKey       string
+	patternValue     *yaml.Node
+	propertyNames    *yaml.Node
+	min              *yaml.Node
+	max              *yaml.Node
+}
+
+func (importer tJsonSchemaImporter) warn(node yaml.Node, format string, argumentList ...interface{}) {
+	*importer.warningList = append(*importer.warningList, &tWarning{
+		text: fmt.Sprintf("warning in JSON Schema at position %s:%s, ", importer.filename, getPosition(node)) + fmt.Sprintf(format, argumentList...),
+	})
+}
+
+func (importer tJsonSchemaImporter) error(node yaml.Node, expected string) {
+	importer.errList.Push([]error{
+		fmt.Errorf("error in JSON Schema at position %s:%s, expected %s", importer.filename, getPosition(node), expected),
+	})
+}
+
+// document translates the root schema into `main`, and each definition into
+// a rule
+func (importer tJsonSchemaImporter) document(root yaml.Node) *yaml.Node {
+	output := &yaml.Node{Kind: yaml.MappingNode}
+
+	type tDefinition struct {
+		ruleName string
+		node     yaml.Node
+	}
+	definitionList := []tDefinition{}
+
+	usedSet := map[string]bool{"main": true}
+	for ruleName := range lidyDefaultRuleMatcherMap {
+		usedSet[ruleName] = true
+	}
+
+	// a root which only refers to the definition `main`, as in the exported
+	// documents, is that definition
+	rootIsMain := false
+	if root.Kind == yaml.MappingNode {
+		formMap := keywordMap(root)
+		refNode, present := formMap["$ref"]
+		rootIsMain = present && (jsonSchemaReference(refNode) == "#/$defs/main" || jsonSchemaReference(refNode) == "#/definitions/main")
+		for key := range formMap {
+			rootIsMain = rootIsMain && (key == "$ref" || jsonSchemaAnnotationSet[key])
+		}
+	}
+	if rootIsMain {
+		delete(usedSet, "main")
+	}
+
+	if root.Kind == yaml.MappingNode {
+		for _, keyword := range []string{"$defs", "definitions"} {
+			defsNode, present := keywordMap(root)[keyword]
+			if !present {
+				continue
+			}
+			if defsNode.Kind != yaml.MappingNode {
+				importer.error(defsNode, "an object of schemas")
+				continue
+			}
+
+			for k := 0; k < len(defsNode.Content); k += 2 {
+				keyNode, valueNode := *defsNode.Content[k], *defsNode.Content[k+1]
+
+				ruleName := importer.ruleName(keyNode, usedSet)
+				reference := "#/" + keyword + "/" + keyNode.Value
+
+				importer.defMap[reference] = valueNode
+				importer.ruleNameMap[reference] = ruleName
+				definitionList = append(definitionList, tDefinition{ruleName, valueNode})
+			}
+		}
+	}
+
+	if !rootIsMain {
+		addEntry(output, scalarNode("main"), importer.schema(root))
+	}
+	for _, definition := range definitionList {
+		addEntry(output, scalarNode(definition.ruleName), importer.schema(definition.node))
+	}
+
+	return output
+}
+
+// ruleName turns the name of a definition into a lidy identifier, distinct
+// from the other rules
+func (importer tJsonSchemaImporter) ruleName(keyNode yaml.Node, usedSet map[string]bool) string {
+	ruleName := regexRuleNameInvalidCharacter.ReplaceAllString(keyNode.Value, "_")
+	if !regexIdentifier.MatchString(ruleName) {
+		ruleName = "x" + ruleName
+	}
+	for usedSet[ruleName] {
+		ruleName += "_"
+	}
+	usedSet[ruleName] = true
+
+	if ruleName != keyNode.Value {
+		importer.warn(keyNode, "the definition '%s' is renamed to '%s'", keyNode.Value, ruleName)
+	}
+
+	return ruleName
+}
+
+// schema translates a schema into a lidy expression
+func (importer tJsonSchemaImporter) schema(node yaml.Node) *yaml.Node {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool" {
+		if node.Value == "true" {
+			return scalarNode("any")
+		}
+		// no value is accepted
+		return mappingNode("_oneOf", &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle})
+	}
+
+	if node.Kind != yaml.MappingNode {
+		importer.error(node, "a schema (an object or a boolean)")
+		return scalarNode("any")
+	}
+
+	formMap := keywordMap(node)
+	importer.warnUnsupported(node)
+
+	if refNode, present := formMap["$ref"]; present {
+		importer.warnIgnored(node, formMap, "$ref")
+		return importer.ref(refNode)
+	}
+
+	if enumNode, present := formMap["enum"]; present {
+		return importer.enum(enumNode)
+	}
+
+	if constNode, present := formMap["const"]; present {
+		return importer.enum(yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{&constNode}})
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if optionListNode, present := formMap[keyword]; present {
+			importer.warnIgnored(node, formMap, keyword)
+			if optionListNode.Kind != yaml.SequenceNode {
+				importer.error(optionListNode, "a list of schemas")
+				return scalarNode("any")
+			}
+
+			optionList := &yaml.Node{Kind: yaml.SequenceNode}
+			for _, option := range optionListNode.Content {
+				optionList.Content = append(optionList.Content, importer.schema(*option))
+			}
+			return mappingNode("_oneOf", optionList)
+		}
+	}
+
+	if allOfNode, present := formMap["allOf"]; present {
+		return importer.allOf(node, formMap, allOfNode)
+	}
+
+	typeNameList := importer.typeNameList(node, formMap)
+	if len(typeNameList) == 0 {
+		return scalarNode("any")
+	}
+
+	optionList := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, typeName := range typeNameList {
+		optionList.Content = append(optionList.Content, importer.typeSchema(node, formMap, typeName))
+	}
+
+	if len(optionList.Content) == 1 {
+		return optionList.Content[0]
+	}
+	return mappingNode("_oneOf", optionList)
+}
+
+func (importer tJsonSchemaImporter) warnUnsupported(node yaml.Node) {
+	for k := 0; k < len(node.Content); k += 2 {
+		keyNode := *node.Content[k]
+		if !jsonSchemaKeywordSet[keyNode.Value] && !jsonSchemaAnnotationSet[keyNode.Value] {
+			importer.warn(keyNode, "the keyword '%s' is not supported; it is not checked", keyNode.Value)
+		}
+	}
+}
+
+// warnIgnored reports the constraints which are dropped because the given
+// keyword is translated alone
+func (importer tJsonSchemaImporter) warnIgnored(node yaml.Node, formMap map[string]yaml.Node, keyword string) {
+	ignoredList := []string{}
+	for k := 0; k < len(node.Content); k += 2 {
+		key := node.Content[k].Value
+		if jsonSchemaKeywordSet[key] && key != keyword {
+			ignoredList = append(ignoredList, key)
+		}
+	}
+
+	if len(ignoredList) > 0 {
+		importer.warn(formMap[keyword], "the keywords beside '%s' are not supported (%s); they are not checked", keyword, strings.Join(ignoredList, ", "))
+	}
+}
+
+func (importer tJsonSchemaImporter) ref(refNode yaml.Node) *yaml.Node {
+	ruleName, found := importer.ruleNameMap[jsonSchemaReference(refNode)]
+	if !found {
+		importer.warn(refNode, "the reference '%s' is not supported, only `#/$defs/name` is; it is not checked", refNode.Value)
+		return scalarNode("any")
+	}
+
+	return scalarNode(ruleName)
+}
+
+func (importer tJsonSchemaImporter) enum(enumNode yaml.Node) *yaml.Node {
+	if enumNode.Kind != yaml.SequenceNode {
+		importer.error(enumNode, "a list of values")
+		return scalarNode("any")
+	}
+
+	valueList := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, value := range enumNode.Content {
+		if value.Kind != yaml.ScalarNode {
+			importer.warn(*value, "the enum value is not a scalar, which is not supported; it is not accepted")
+			continue
+		}
+		valueList.Content = append(valueList.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: value.ShortTag(), Value: value.Value})
+	}
+
+	return mappingNode("_in", valueList)
+}
+
+// allOf translates the conjunction of object schemas into a single map
+// checker; lidy cannot express the other conjunctions
+func (importer tJsonSchemaImporter) allOf(node yaml.Node, formMap map[string]yaml.Node, allOfNode yaml.Node) *yaml.Node {
+	if allOfNode.Kind != yaml.SequenceNode {
+		importer.error(allOfNode, "a list of schemas")
+		return scalarNode("any")
+	}
+
+	if len(allOfNode.Content) == 1 && len(importer.typeNameList(node, formMap)) == 0 {
+		return importer.schema(*allOfNode.Content[0])
+	}
+
+	object := tJsonObject{propertyMap: map[string]yaml.Node{}}
+	importer.readObject(formMap, &object)
+
+	isObject := true
+	for _, typeName := range importer.typeNameList(node, formMap) {
+		isObject = isObject && typeName == "object"
+	}
+	for _, part := range allOfNode.Content {
+		isObject = isObject && importer.collectObject(*part, &object, map[string]bool{})
+	}
+
+	if !isObject {
+		importer.warn(allOfNode, "allOf is only supported for object schemas; only the first schema is checked")
+		if len(allOfNode.Content) == 0 {
+			return scalarNode("any")
+		}
+		return importer.schema(*allOfNode.Content[0])
+	}
+
+	return importer.objectSchema(object)
+}
+
+// collectObject gathers the object keywords of an allOf part and of its own
+// parts, following the references. It fails if a part is not an object schema
+func (importer tJsonSchemaImporter) collectObject(node yaml.Node, object *tJsonObject, visitingSet map[string]bool) bool {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool" && node.Value == "true" {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+
+	formMap := keywordMap(node)
+
+	if refNode, present := formMap["$ref"]; present {
+		// the definition is translated as a rule too, which reports its
+		// unsupported keywords
+		reference := jsonSchemaReference(refNode)
+		definition, found := importer.defMap[reference]
+		if !found || visitingSet[reference] {
+			return false
+		}
+
+		visitingSet[reference] = true
+		defer delete(visitingSet, reference)
+
+		return importer.collectObject(definition, object, visitingSet)
+	}
+
+	for _, keyword := range []string{"enum", "const", "anyOf", "oneOf"} {
+		if _, present := formMap[keyword]; present {
+			return false
+		}
+	}
+
+	typeNameList := importer.typeNameList(node, formMap)
+	if len(typeNameList) > 1 || (len(typeNameList) == 1 && typeNameList[0] != "object") {
+		return false
+	}
+
+	importer.warnUnsupported(node)
+	importer.readObject(formMap, object)
+
+	if allOfNode, present := formMap["allOf"]; present {
+		if allOfNode.Kind != yaml.SequenceNode {
+			return false
+		}
+		for _, part := range allOfNode.Content {
+			if !importer.collectObject(*part, object, visitingSet) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// readObject adds the object keywords of a schema to object. The first
+// declaration of a property is kept
+func (importer tJsonSchemaImporter) readObject(formMap map[string]yaml.Node, object *tJsonObject) {
+	if propertiesNode, present := formMap["properties"]; present {
+		if propertiesNode.Kind != yaml.MappingNode {
+			importer.error(propertiesNode, "an object of schemas")
+		}
+		for k := 0; k+1 < len(propertiesNode.Content); k += 2 {
+			keyNode, valueNode := *propertiesNode.Content[k], *propertiesNode.Content[k+1]
+			if _, present := object.propertyMap[keyNode.Value]; present {
+				importer.warn(keyNode, "the property '%s' is declared several times; only the first declaration is checked", keyNode.Value)
+				continue
+			}
+			object.propertyNameList = append(object.propertyNameList, keyNode.Value)
+			object.propertyMap[keyNode.Value] = valueNode
+		}
+	}
+
+	if requiredNode, present := formMap["required"]; present {
+		if requiredNode.Kind != yaml.SequenceNode {
+			importer.error(requiredNode, "a list of property names")
+		}
+		for _, name := range requiredNode.Content {
+			object.requiredList = append(object.requiredList, name.Value)
+		}
+	}
+
+	for _, keyword := range []string{"additionalProperties", "unevaluatedProperties"} {
+		additionalNode, present := formMap[keyword]
+		switch {
+		case !present:
+		case additionalNode.ShortTag() == "!!bool":
+			object.closed = object.closed || additionalNode.Value == "false"
+		case object.additional == nil:
+			object.additional = &additionalNode
+		default:
+			importer.warn(additionalNode, "%s is declared several times; only the first declaration is checked", keyword)
+		}
+	}
+
+	if patternPropertiesNode, present := formMap["patternProperties"]; present {
+		if patternPropertiesNode.Kind != yaml.MappingNode {
+			importer.error(patternPropertiesNode, "an object of schemas")
+		}
+		for k := 0; k+1 < len(patternPropertiesNode.Content); k += 2 {
+			keyNode, valueNode := *patternPropertiesNode.Content[k], *patternPropertiesNode.Content[k+1]
+			if object.patternValue != nil {
+				importer.warn(keyNode, "only one pattern of patternProperties is supported; the pattern '%s' is not checked", keyNode.Value)
+				continue
+			}
+			if !importer.checkPattern(keyNode) {
+				continue
+			}
+			object.patternKey = keyNode.Value
+			object.patternValue = &valueNode
+		}
+	}
+
+	if propertyNamesNode, present := formMap["propertyNames"]; present && object.propertyNames == nil {
+		object.propertyNames = &propertyNamesNode
+	}
+
+	if minNode, present := formMap["minProperties"]; present {
+		object.min = &minNode
+	}
+	if maxNode, present := formMap["maxProperties"]; present {
+		object.max = &maxNode
+	}
+}
+
+// typeNameList lists the JSON types accepted by a schema, from `type`, or
+// else from the keywords it uses
+func (importer tJsonSchemaImporter) typeNameList(node yaml.Node, formMap map[string]yaml.Node) []string {
+	typeNode, present := formMap["type"]
+	if present {
+		typeNodeList := []*yaml.Node{&typeNode}
+		if typeNode.Kind == yaml.SequenceNode {
+			typeNodeList = typeNode.Content
+		}
+
+		typeNameList := []string{}
+		for _, typeNameNode := range typeNodeList {
+			switch typeNameNode.Value {
+			case "object", "array", "string", "integer", "number", "boolean", "null":
+				typeNameList = append(typeNameList, typeNameNode.Value)
+			default:
+				importer.error(*typeNameNode, "a JSON type (object, array, string, integer, number, boolean or null)")
+			}
+		}
+		return typeNameList
+	}
+
+	typeNameList := []string{}
+	for _, inference := range []struct {
+		typeName    string
+		keywordList []string
+	}{
+		{"object", jsonSchemaObjectKeywordList},
+		{"array", jsonSchemaArrayKeywordList},
+		{"string", jsonSchemaStringKeywordList},
+		{"number", jsonSchemaNumberKeywordList},
+	} {
+		for _, keyword := range inference.keywordList {
+			if _, present := formMap[keyword]; present {
+				typeNameList = append(typeNameList, inference.typeName)
+				break
+			}
+		}
+	}
+
+	return typeNameList
+}
+
+// typeSchema translates the keywords of a schema which apply to the given type
+func (importer tJsonSchemaImporter) typeSchema(node yaml.Node, formMap map[string]yaml.Node, typeName string) *yaml.Node {
+	switch typeName {
+	case "object":
+		object := tJsonObject{propertyMap: map[string]yaml.Node{}}
+		importer.readObject(formMap, &object)
+		return importer.objectSchema(object)
+	case "array":
+		return importer.arraySchema(formMap)
+	case "string":
+		return importer.stringSchema(formMap)
+	case "integer":
+		return importer.numberSchema(formMap, "int")
+	case "number":
+		return importer.numberSchema(formMap, "float")
+	case "boolean":
+		return scalarNode("boolean")
+	}
+
+	// null
+	return scalarNode("nullType")
+}
+
+func (importer tJsonSchemaImporter) objectSchema(object tJsonObject) *yaml.Node {
+	requiredSet := map[string]bool{}
+	for _, name := range object.requiredList {
+		requiredSet[name] = true
+	}
+
+	propertyNode := &yaml.Node{Kind: yaml.MappingNode}
+	optionalNode := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range object.propertyNameList {
+		if requiredSet[name] {
+			addEntry(propertyNode, scalarNode(name), importer.schema(object.propertyMap[name]))
+		} else {
+			addEntry(optionalNode, scalarNode(name), importer.schema(object.propertyMap[name]))
+		}
+	}
+	for _, name := range object.requiredList {
+		if _, declared := object.propertyMap[name]; !declared {
+			addEntry(propertyNode, scalarNode(name), scalarNode("any"))
+			object.propertyMap[name] = yaml.Node{}
+		}
+	}
+
+	output := &yaml.Node{Kind: yaml.MappingNode}
+
+	if len(propertyNode.Content) > 0 {
+		addEntry(output, scalarNode("_map"), propertyNode)
+	}
+	if len(optionalNode.Content) > 0 {
+		addEntry(output, scalarNode("_mapFacultative"), optionalNode)
+	}
+
+	if mapOf := importer.mapOf(object); mapOf != nil {
+		addEntry(output, scalarNode("_mapOf"), mapOf)
+	}
+
+	importer.addSize(output, object.min, "_min")
+	importer.addSize(output, object.max, "_max")
+
+	if len(output.Content) == 0 {
+		// a closed object without any property
+		addEntry(output, scalarNode("_map"), &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle})
+	}
+
+	return output
+}
+
+// mapOf translates the properties which are not declared in `properties`,
+// or returns nil if they are rejected
+func (importer tJsonSchemaImporter) mapOf(object tJsonObject) *yaml.Node {
+	var keyNode, valueNode *yaml.Node
+
+	if object.patternValue != nil {
+		keyNode = mappingNode("_regex", scalarNode(object.patternKey))
+		valueNode = importer.schema(*object.patternValue)
+
+		if object.additional != nil || !object.closed {
+			importer.warn(*object.patternValue, "patternProperties is combined with additionalProperties, which is not supported; the properties which do not match the pattern are rejected")
+		}
+	} else if object.additional != nil {
+		valueNode = importer.schema(*object.additional)
+	} else if !object.closed {
+		valueNode = scalarNode("any")
+	} else {
+		return nil
+	}
+
+	if object.propertyNames != nil {
+		if keyNode != nil {
+			importer.warn(*object.propertyNames, "propertyNames is combined with patternProperties, which is not supported; it is not checked")
+		} else {
+			keyNode = importer.schema(*object.propertyNames)
+		}
+	}
+
+	if keyNode == nil {
+		keyNode = scalarNode("string")
+	}
+	if keyNode.Kind == yaml.MappingNode {
+		keyNode.Style = yaml.FlowStyle
+	}
+
+	mapOf := &yaml.Node{Kind: yaml.MappingNode}
+	addEntry(mapOf, keyNode, valueNode)
+	return mapOf
+}
+
+func (importer tJsonSchemaImporter) arraySchema(formMap map[string]yaml.Node) *yaml.Node {
+	var prefixList []*yaml.Node
+	var restNode *yaml.Node
+
+	if prefixItemsNode, present := formMap["prefixItems"]; present {
+		prefixList = prefixItemsNode.Content
+		if itemsNode, present := formMap["items"]; present {
+			restNode = &itemsNode
+		}
+	} else if itemsNode, present := formMap["items"]; present {
+		if itemsNode.Kind == yaml.SequenceNode {
+			// draft-07 tuple
+			prefixList = itemsNode.Content
+			if additionalItemsNode, present := formMap["additionalItems"]; present {
+				restNode = &additionalItemsNode
+			}
+		} else {
+			restNode = &itemsNode
+		}
+	}
+
+	minItems := 0
+	minNode, minPresent := formMap["minItems"]
+	if minPresent {
+		minNode.Decode(&minItems)
+	}
+
+	output := &yaml.Node{Kind: yaml.MappingNode}
+
+	listNode := &yaml.Node{Kind: yaml.SequenceNode}
+	optionalNode := &yaml.Node{Kind: yaml.SequenceNode}
+	for k, prefix := range prefixList {
+		if k < minItems {
+			listNode.Content = append(listNode.Content, importer.schema(*prefix))
+		} else {
+			optionalNode.Content = append(optionalNode.Content, importer.schema(*prefix))
+		}
+	}
+	if len(listNode.Content) > 0 {
+		addEntry(output, scalarNode("_list"), listNode)
+	}
+	if len(optionalNode.Content) > 0 {
+		addEntry(output, scalarNode("_listFacultative"), optionalNode)
+	}
+
+	switch {
+	case restNode == nil:
+		addEntry(output, scalarNode("_listOf"), scalarNode("any"))
+	case restNode.ShortTag() == "!!bool" && restNode.Value == "false":
+	default:
+		addEntry(output, scalarNode("_listOf"), importer.schema(*restNode))
+	}
+
+	if minItems > len(listNode.Content) {
+		importer.addSize(output, &minNode, "_min")
+	}
+	if maxNode, present := formMap["maxItems"]; present {
+		importer.addSize(output, &maxNode, "_max")
+	}
+
+	if len(output.Content) == 0 {
+		// only the empty array is accepted
+		addEntry(output, scalarNode("_list"), &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle})
+	}
+
+	return output
+}
+
+func (importer tJsonSchemaImporter) stringSchema(formMap map[string]yaml.Node) *yaml.Node {
+	formatNode, formatPresent := formMap["format"]
+	encodingNode, encodingPresent := formMap["contentEncoding"]
+
+	if patternNode, present := formMap["pattern"]; present {
+		if formatPresent {
+			importer.warn(formatNode, "format is combined with pattern, which is not supported; it is not checked")
+		}
+		if importer.checkPattern(patternNode) {
+			return mappingNode("_regex", scalarNode(patternNode.Value))
+		}
+		return scalarNode("string")
+	}
+
+	if formatPresent {
+		if formatNode.Value == "date-time" {
+			return scalarNode("timestamp")
+		}
+		importer.warn(formatNode, "the format '%s' is not supported; it is not checked", formatNode.Value)
+	}
+
+	if encodingPresent {
+		if encodingNode.Value == "base64" {
+			return scalarNode("binary")
+		}
+		importer.warn(encodingNode, "the content encoding '%s' is not supported; it is not checked", encodingNode.Value)
+	}
+
+	return scalarNode("string")
+}
+
+// checkPattern tells whether the pattern is supported by Go regular
+// expressions, which do not support lookarounds and backreferences
+func (importer tJsonSchemaImporter) checkPattern(patternNode yaml.Node) bool {
+	_, err := regexp.Compile(patternNode.Value)
+	if err != nil {
+		importer.warn(patternNode, "the pattern is not supported by Go regular expressions (%s); it is not checked", err.Error())
+		return false
+	}
+	return true
+}
+
+func (importer tJsonSchemaImporter) numberSchema(formMap map[string]yaml.Node, kind string) *yaml.Node {
+	minText := importer.bound(formMap, "minimum", "exclusiveMinimum", true)
+	maxText := importer.bound(formMap, "maximum", "exclusiveMaximum", false)
+
+	if minText == "" && maxText == "" {
+		return scalarNode(kind)
+	}
+
+	rangeText := kind
+	if minText != "" {
+		rangeText = minText + " " + rangeText
+	}
+	if maxText != "" {
+		rangeText = rangeText + " " + maxText
+	}
+
+	return mappingNode("_range", scalarNode("("+rangeText+")"))
+}
+
+// bound translates an inclusive and an exclusive bound into one side of a
+// lidy range, keeping the strictest one, e.g. `1 <=` or `< 10`
+func (importer tJsonSchemaImporter) bound(formMap map[string]yaml.Node, inclusiveKeyword string, exclusiveKeyword string, isMin bool) string {
+	var inclusiveValue, exclusiveValue float64
+	inclusiveNode, inclusivePresent := formMap[inclusiveKeyword]
+	exclusiveNode, exclusivePresent := formMap[exclusiveKeyword]
+
+	for _, side := range []struct {
+		node    yaml.Node
+		present *bool
+		value   *float64
+	}{
+		{inclusiveNode, &inclusivePresent, &inclusiveValue},
+		{exclusiveNode, &exclusivePresent, &exclusiveValue},
+	} {
+		if *side.present && (side.node.Kind != yaml.ScalarNode || side.node.Decode(side.value) != nil) {
+			// draft-04 boolean exclusive bounds land here too
+			importer.warn(side.node, "the bound is not a number, which is not supported; it is not checked")
+			*side.present = false
+		}
+	}
+
+	useExclusive := exclusivePresent
+	if inclusivePresent && exclusivePresent {
+		if isMin {
+			useExclusive = exclusiveValue >= inclusiveValue
+		} else {
+			useExclusive = exclusiveValue <= inclusiveValue
+		}
+	}
+
+	switch {
+	case useExclusive && isMin:
+		return exclusiveNode.Value + " <"
+	case useExclusive:
+		return "< " + exclusiveNode.Value
+	case inclusivePresent && isMin:
+		return inclusiveNode.Value + " <="
+	case inclusivePresent:
+		return "<= " + inclusiveNode.Value
+	}
+
+	return ""
+}
+
+// addSize copies a size keyword, which must be a non-negative integer
+func (importer tJsonSchemaImporter) addSize(output *yaml.Node, sizeNode *yaml.Node, keyword string) {
+	if sizeNode == nil {
+		return
+	}
+
+	var size int
+	if sizeNode.ShortTag() != "!!int" || sizeNode.Decode(&size) != nil || size < 0 {
+		importer.error(*sizeNode, "a non-negative integer")
+		return
+	}
+
+	addEntry(output, scalarNode(keyword), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: sizeNode.Value})
+}
+
+// jsonSchemaReference decodes the URI fragment and the JSON pointer escapes
+// of a `$ref`
+func jsonSchemaReference(refNode yaml.Node) string {
+	reference, err := url.PathUnescape(refNode.Value)
+	if err != nil {
+		reference = refNode.Value
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(reference)
+}
+
+// keywordMap indexes the entries of a mapping node by key
+func keywordMap(node yaml.Node) map[string]yaml.Node {
+	formMap := map[string]yaml.Node{}
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		formMap[node.Content[k].Value] = *node.Content[k+1]
+	}
+	return formMap
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func mappingNode(key string, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalarNode(key), value}}
+}
+
+func addEntry(mapping *yaml.Node, key *yaml.Node, value *yaml.Node) {
+	mapping.Content = append(mapping.Content, key, value)
+}
@@ -0,0 +1,365 @@
+package lidy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyLocate.go
+//
+// Find what the schema expects at a position of a content document, for
+// editors: the rule to show on hover, and the keys to complete
+
+// locateRuleDepthMax -- the number of rule references followed in a row
+// before giving up, in case of a rule referring to itself
+const locateRuleDepthMax = 64
+
+// Location -- what the schema expects at a position of a content document. See Locate
+type Location struct {
+	// Path the path of the node at the position, e.g. `/servers/0/port`
+	Path string
+	// RuleName the innermost rule applying to the node, if any
+	RuleName string
+	// Description the description of the expression applying to the node, as given by Describe for rules
+	Description string
+	// Annotation the annotation of the expression applying to the node, or of its rule
+	Annotation Annotation
+	// KeyList the keys of `_map` and `_mapFacultative`, including the merged ones, that the map at the position does not have yet, sorted. Empty if no map is expected there
+	KeyList []string
+}
+
+// Cursor -- a position in a YAML document; Line and Column start at 1. It
+// finds the map entry or the list item containing the position, as Locate
+// does. The language server walks the schemas with it
+type Cursor struct {
+	Line   int
+	Column int
+}
+
+// tLocator -- the state of one call to Locate
+type tLocator struct {
+	Cursor
+	contentParser *tContentParser
+}
+
+// Locate -- process the schema if needed, and find what the target rule
+// expects at the given position of the content; line and column start at 1.
+// The position is in the map or the list whose entries start at its column,
+// and in the value of an entry if it is on the line of the entry, or further
+// right. The document does not have to match the schema; the options of
+// `_oneOf` are chosen by matching them against the node, which runs their
+// builders
+func (p *tParser) Locate(file File, line int, column int) (Location, []error) {
+	erl := p.Schema()
+	if len(erl) > 0 {
+		return Location{}, erl
+	}
+
+	targetRule, ruleFound := p.schema.ruleMap[p.target]
+	if !ruleFound {
+		return Location{}, []error{fmt.Errorf("Could not find target rule '%s' in grammar", p.target)}
+	}
+
+	err := file.Yaml()
+	if err != nil {
+		return Location{}, []error{err}
+	}
+
+	contentFile := file.(*tFile)
+
+	contentRoot, erl := getRoot(contentFile.yaml)
+	if len(erl) > 0 {
+		return Location{}, erl
+	}
+
+	locator := tLocator{
+		contentParser: &tContentParser{
+			option:      p.option,
+			contentFile: *contentFile,
+		},
+		Cursor: Cursor{Line: line, Column: column},
+	}
+
+	return locator.locate(targetRule, contentRoot), nil
+}
+
+// locate descends the content and the expressions together, down to the
+// innermost node containing the position
+func (locator *tLocator) locate(expression tExpression, node *yaml.Node) Location {
+	location := Location{
+		Path:        pathString(locator.contentParser.contentPath),
+		Description: describeExpression(expression),
+		Annotation:  expressionAnnotation(expression),
+	}
+
+	expression = locator.resolve(expression, node)
+	location.RuleName = locator.contentParser.contentRuleName
+
+	switch expression.(type) {
+	case tMap, tSwitch:
+		if k := locator.EntryAt(node); k >= 0 {
+			key := node.Content[k].Value
+			if property := locator.propertyExpression(expression, key, node, map[string]bool{}); property != nil {
+				locator.contentParser.enter(key)
+				return locator.locate(property, node.Content[k+1])
+			}
+		}
+
+		location.KeyList = locator.missingKeyList(expression, node)
+	case tList:
+		if k := locator.ItemAt(node); k >= 0 {
+			if item := listItemExpression(expression.(tList), k); item != nil {
+				locator.contentParser.enter(strconv.Itoa(k))
+				return locator.locate(item, node.Content[k])
+			}
+		}
+	}
+
+	return location
+}
+
+// resolve follows the rule references, and picks the option of the `_oneOf`
+// that suits the node, down to a checker or a lidy default rule. The
+// innermost rule is recorded in the content parser
+func (locator *tLocator) resolve(expression tExpression, node *yaml.Node) tExpression {
+	for k := 0; k < locateRuleDepthMax; k++ {
+		switch current := expression.(type) {
+		case *tRule:
+			if current.expression == nil {
+				return current
+			}
+			locator.contentParser.contentRuleName = current.ruleName
+			expression = current.expression
+		case tOneOf:
+			option := locator.chooseOption(current, node)
+			if option < 0 {
+				return current
+			}
+			expression = current.optionList[option]
+		default:
+			return expression
+		}
+	}
+
+	return expression
+}
+
+// chooseOption returns the first option matching the node, or else the one
+// which got the furthest, or -1 if there is no option
+func (locator *tLocator) chooseOption(oneOf tOneOf, node *yaml.Node) int {
+	optionErrorList := make([][]error, 0, len(oneOf.optionList))
+
+	for k, option := range oneOf.optionList {
+		// each option is matched with its own copy of the context, which the
+		// matching changes
+		contentParser := *locator.contentParser
+		contentParser.contentPath = appendPath(locator.contentParser.contentPath)
+
+		_, erl := option.match(*node, &contentParser)
+		if len(erl) == 0 {
+			return k
+		}
+		optionErrorList = append(optionErrorList, erl)
+	}
+
+	return bestOption(optionErrorList, len(locator.contentParser.contentPath))
+}
+
+// EntryAt returns the index of the key of the map entry containing the
+// position, or -1 if the position is at the level of the keys of the map, or
+// if the node is not a map
+func (cursor Cursor) EntryAt(node *yaml.Node) int {
+	if node.Kind != yaml.MappingNode {
+		return -1
+	}
+
+	found := -1
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		if cursor.IsAfter(node.Content[k]) {
+			found = k
+		}
+	}
+
+	if found >= 0 {
+		key := node.Content[found]
+		if key.Line == cursor.Line || cursor.Column > key.Column {
+			return found
+		}
+	}
+
+	return -1
+}
+
+// ItemAt returns the index of the list item containing the position, or -1
+func (cursor Cursor) ItemAt(node *yaml.Node) int {
+	if node.Kind != yaml.SequenceNode {
+		return -1
+	}
+
+	found := -1
+	for k, item := range node.Content {
+		if cursor.IsAfter(item) {
+			found = k
+		}
+	}
+
+	if found >= 0 {
+		item := node.Content[found]
+		if item.Line == cursor.Line || cursor.Column >= item.Column {
+			return found
+		}
+	}
+
+	return -1
+}
+
+// IsAfter tells whether the position is at the start of the node, or after it
+func (cursor Cursor) IsAfter(node *yaml.Node) bool {
+	return node.Line < cursor.Line || (node.Line == cursor.Line && node.Column <= cursor.Column)
+}
+
+// propertyExpression returns the expression of the value of a key of the
+// map, looking into the merged expressions, and then into `_mapOf`. It
+// returns nil for unknown keys, and for the `_discriminator` of a `_switch`
+func (locator *tLocator) propertyExpression(expression tExpression, key string, node *yaml.Node, visitingSet map[string]bool) tExpression {
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.expression != nil && !visitingSet[expression.ruleName] {
+			visitingSet[expression.ruleName] = true
+			return locator.propertyExpression(expression.expression, key, node, visitingSet)
+		}
+	case tMap:
+		if property, ok := expression.form.propertyMap[key]; ok {
+			return property
+		}
+		if property, ok := expression.form.optionalMap[key]; ok {
+			return property
+		}
+		for _, mergeable := range expression.form.mergeList {
+			if property := locator.propertyExpression(mergeable, key, node, visitingSet); property != nil {
+				return property
+			}
+		}
+		if expression.form.mapOf.value != nil {
+			return expression.form.mapOf.value
+		}
+	case tOneOf:
+		for _, option := range expression.optionList {
+			if property := locator.propertyExpression(option, key, node, visitingSet); property != nil {
+				return property
+			}
+		}
+	case tSwitch:
+		if mergeable, ok := expression.caseMap[discriminatorValue(expression, node)]; ok && key != expression.key {
+			return locator.propertyExpression(mergeable, key, node, visitingSet)
+		}
+	}
+
+	return nil
+}
+
+// missingKeyList lists the keys that the expression accepts and that the
+// node does not have yet
+func (locator *tLocator) missingKeyList(expression tExpression, node *yaml.Node) []string {
+	keySet := map[string]bool{}
+	locator.addKeySet(keySet, expression, node, map[string]bool{})
+
+	if node.Kind == yaml.MappingNode {
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			delete(keySet, node.Content[k].Value)
+		}
+	}
+
+	keyList := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	return keyList
+}
+
+// addKeySet adds the keys of `_map` and `_mapFacultative` of the expression
+// and of its merged expressions to the set. For a `_switch`, the keys are
+// those of the case selected by the node, if any
+func (locator *tLocator) addKeySet(keySet map[string]bool, expression tExpression, node *yaml.Node, visitingSet map[string]bool) {
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.expression != nil && !visitingSet[expression.ruleName] {
+			visitingSet[expression.ruleName] = true
+			locator.addKeySet(keySet, expression.expression, node, visitingSet)
+		}
+	case tMap:
+		for key := range expression.form.propertyMap {
+			keySet[key] = true
+		}
+		for key := range expression.form.optionalMap {
+			keySet[key] = true
+		}
+		for _, mergeable := range expression.form.mergeList {
+			locator.addKeySet(keySet, mergeable, node, visitingSet)
+		}
+	case tOneOf:
+		for _, option := range expression.optionList {
+			locator.addKeySet(keySet, option, node, visitingSet)
+		}
+	case tSwitch:
+		keySet[expression.key] = true
+		if mergeable, ok := expression.caseMap[discriminatorValue(expression, node)]; ok {
+			locator.addKeySet(keySet, mergeable, node, visitingSet)
+		}
+	}
+}
+
+// discriminatorValue returns the value of the `_discriminator` key of the
+// map node, or an empty string
+func discriminatorValue(switchChecker tSwitch, node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		if node.Content[k].Value == switchChecker.key && node.Content[k+1].Kind == yaml.ScalarNode {
+			return node.Content[k+1].Value
+		}
+	}
+
+	return ""
+}
+
+// listItemExpression returns the expression of the k-th item of the list,
+// or nil if the list has no room for it
+func listItemExpression(list tList, k int) tExpression {
+	switch {
+	case k < len(list.form.list):
+		return list.form.list[k]
+	case k < len(list.form.list)+len(list.form.optionalList):
+		return list.form.optionalList[k-len(list.form.list)]
+	}
+
+	return list.form.listOf
+}
+
+// describeExpression describes an expression; for rules, their name and
+// form, followed by the description of their expression
+func describeExpression(expression tExpression) string {
+	text := expression.description()
+	if rule, ok := expression.(*tRule); ok && rule.expression != nil {
+		text = rule.description() + "\n" + rule.expression.description()
+	}
+
+	annotation := expressionAnnotation(expression)
+	if annotation.Description != "" {
+		text += "\n_description: " + annotation.Description
+	}
+	if annotation.Deprecated && annotation.DeprecationMessage != "" {
+		text += "\n_deprecated: " + annotation.DeprecationMessage
+	} else if annotation.Deprecated {
+		text += "\n_deprecated: true"
+	}
+
+	return text
+}
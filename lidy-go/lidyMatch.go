@@ -2,6 +2,9 @@ package lidy
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/ditrit/lidy/errorlist"
 	"gopkg.in/yaml.v3"
@@ -12,32 +15,43 @@ import (
 // Implement match() and mergeMatch() on tExpression and tMergeableExpression
 
 // tRule
-func (rule *tRule) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (rule *tRule) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
 	if rule.lidyMatcher != nil {
-		return rule.lidyMatcher(content, parser)
+		result, erl := rule.lidyMatcher(content, parser)
+		if len(erl) == 0 {
+			parser.warnDeprecated(rule.annotation, content)
+		}
+		return result, erl
 	}
 
 	if rule.expression == nil {
 		panic("nil expression in rule " + rule.ruleName + "; " + pleaseReport)
 	}
 
+	outerRuleName := parser.contentRuleName
+	parser.contentRuleName = rule.ruleName
 	result, err := rule.expression.match(content, parser)
+	parser.contentRuleName = outerRuleName
 
 	if len(err) > 0 {
 		return tResult{}, err
 	}
 
+	parser.warnDeprecated(rule.annotation, content)
+
 	if rule.builder != nil {
 		data, err := rule.builder(result)
 		result := parser.wrap(data, content)
 		result.ruleName = rule.ruleName
+		result.hasBeenBuilt = true
+		result.isLidyData = false
 		return result, err
 	}
 
 	return result, err
 }
 
-func (rule tRule) mergeMatch(mapResult MapData, utilizationTrackingList []bool, content yaml.Node, parser *tParser) []error {
+func (rule tRule) mergeMatch(mapResult MapData, utilizationTrackingList []bool, content yaml.Node, parser *tContentParser) []error {
 	if mergeable, ok := rule.expression.(tMergeableExpression); ok {
 		return mergeable.mergeMatch(mapResult, utilizationTrackingList, content, parser)
 	}
@@ -50,10 +64,10 @@ func (rule tRule) mergeMatch(mapResult MapData, utilizationTrackingList []bool,
 }
 
 // Map
-func (mapChecker tMap) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (mapChecker tMap) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
 	// Non-maps
 	if content.Tag != "!!map" {
-		return tResult{}, parser.contentError(content, "a YAML map, "+mapChecker.description())
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML map, "+mapChecker.description())
 	}
 
 	// Extra key (preparation)
@@ -67,6 +81,9 @@ func (mapChecker tMap) match(content yaml.Node, parser *tParser) (tResult, []err
 	// mapResult.Map = make(map[string]Result)
 
 	erl := mapChecker.mergeMatch(mapData, utilizationTrackingList, content, parser)
+	if parser.mustStop(erl) {
+		return tResult{}, erl
+	}
 
 	errList := errorlist.List{}
 	errList.Push(erl)
@@ -80,29 +97,34 @@ func (mapChecker tMap) match(content yaml.Node, parser *tParser) (tResult, []err
 		value := content.Content[2*k+1]
 
 		if mapChecker.form.mapOf.key == nil {
-			keyValue := yaml.Node{
-				Kind:   yaml.ScalarNode,
-				Tag:    "!!lidyKvPair",
-				Value:  "[" + key.Value + ": " + value.Value + "]",
-				Line:   key.Line,
-				Column: key.Column,
+			parser.enter(key.Value)
+			erl := parser.contentError(*key, ErrorCodeExtraEntry, "no extra entry")
+			parser.leave()
+			if parser.mustStop(erl) {
+				return tResult{}, erl
 			}
-			errList.Push(parser.contentError(keyValue, "no extra entry"))
+			errList.Push(erl)
 			continue
 		}
 
 		// mapOf
+		parser.enter(key.Value)
+
 		// Checking the key
 		keyResult, erl := mapChecker.form.mapOf.key.match(*key, parser)
-		errList.Push(erl)
-
-		if len(erl) > 0 {
-			continue
-		}
 
 		// (if the key is valid)
 		// Checking the value
-		valueResult, erl := mapChecker.form.mapOf.value.match(*value, parser)
+		var valueResult tResult
+		if len(erl) == 0 {
+			valueResult, erl = mapChecker.form.mapOf.value.match(*value, parser)
+		}
+
+		parser.leave()
+
+		if parser.mustStop(erl) {
+			return tResult{}, erl
+		}
 		errList.Push(erl)
 
 		if len(erl) > 0 {
@@ -117,20 +139,29 @@ func (mapChecker tMap) match(content yaml.Node, parser *tParser) (tResult, []err
 		})
 	}
 
-	return parser.wrap(mapData, content), errList.ConcatError()
+	erl = errList.ConcatError()
+	if len(erl) == 0 {
+		parser.warnDeprecated(mapChecker.annotation, content)
+	}
+
+	return parser.wrap(mapData, content), erl
 }
 
 func (mapChecker tMap) mergeMatch(
 	mapResult MapData,
 	utilizzTrackingList []bool,
 	content yaml.Node,
-	parser *tParser,
+	parser *tContentParser,
 ) []error {
 	f := mapChecker.form
 	errList := errorlist.List{}
 
 	// Bad sizing
-	errList.Push(mapChecker.sizing.check(content, parser))
+	erl := mapChecker.sizing.check(content, parser)
+	if parser.mustStop(erl) {
+		return erl
+	}
+	errList.Push(erl)
 
 	// Missing key (preparation)
 	// "toBeFoundRequiredPropertySet"
@@ -160,7 +191,12 @@ func (mapChecker tMap) mergeMatch(
 
 		if propertyFound {
 			// Matching with the matcher specified for that property
+			parser.enter(key.Value)
 			result, erl := property.match(*value, parser)
+			parser.leave()
+			if parser.mustStop(erl) {
+				return erl
+			}
 
 			errList.Push(erl)
 
@@ -172,23 +208,80 @@ func (mapChecker tMap) mergeMatch(
 
 	// Missing keys (reporting)
 	for key := range requiredSet {
-		errList.Push(
-			parser.contentError(
-				content,
-				fmt.Sprintf("to find a property %s %s", key, f.propertyMap[key].name()),
-			),
+		erl := parser.contentError(
+			content,
+			ErrorCodeMissingProperty,
+			fmt.Sprintf("to find a property %s %s", key, f.propertyMap[key].name()),
 		)
+		if parser.mustStop(erl) {
+			return erl
+		}
+		errList.Push(erl)
+	}
+
+	// Defaults of the missing facultative properties
+	for _, propertyDefault := range f.defaultList {
+		if _, present := mapResult.Map[propertyDefault.property]; present {
+			continue
+		}
+
+		result, erl := propertyDefault.match(parser)
+		if parser.mustStop(erl) {
+			return erl
+		}
+		errList.Push(erl)
+
+		mapResult.Map[propertyDefault.property] = result
 	}
 
 	// Merges
 	for _, mergeable := range f.mergeList {
 		erl := mergeable.mergeMatch(mapResult, utilizzTrackingList, content, parser)
+		if parser.mustStop(erl) {
+			return erl
+		}
 		errList.Push(erl)
 	}
 
 	return errList.ConcatError()
 }
 
+// match matches the value of the default against the expression of its
+// property. The value is read from the schema, so the positions of the
+// results, and of the errors, are in the schema
+func (propertyDefault tDefault) match(parser *tContentParser) (tResult, []error) {
+	contentFile := parser.contentFile
+	parser.contentFile = tFile{name: propertyDefault.filename}
+	parser.enter(propertyDefault.property)
+
+	identifier := propertyDefault.filename + ":" + getPosition(propertyDefault.node)
+	if parser.defaultSet == nil {
+		parser.defaultSet = map[string]bool{}
+	}
+
+	var result tResult
+	var erl []error
+	if parser.defaultSet[identifier] {
+		erl = parser.contentError(propertyDefault.node, ErrorCodeValue, "no _default which needs itself to be completed")
+	} else {
+		// the content does not use the default, so its warnings are dropped
+		warningCount := len(parser.warningList)
+
+		parser.defaultSet[identifier] = true
+		result, erl = propertyDefault.expression.match(propertyDefault.node, parser)
+		delete(parser.defaultSet, identifier)
+
+		parser.warningList = parser.warningList[:warningCount]
+	}
+
+	parser.leave()
+	parser.contentFile = contentFile
+
+	result.isSynthesized = true
+
+	return result, erl
+}
+
 func getMapProperty(propertyMap map[string]tExpression, utilized *bool, key *yaml.Node) (tExpression, bool) {
 	if propertyMap != nil && key.Tag == "!!str" {
 		property, propertyFound := propertyMap[key.Value]
@@ -204,34 +297,42 @@ func getMapProperty(propertyMap map[string]tExpression, utilized *bool, key *yam
 }
 
 // List
-func (list tList) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (list tList) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
 	// Non-maps
 	if content.Tag != "!!seq" {
-		return tResult{}, parser.contentError(content, "a YAML list (seq), "+list.description())
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML list (seq), "+list.description())
 	}
 
 	listData := ListData{}
 	errList := errorlist.List{}
 
 	// Bad sizing
-	errList.Push(list.sizing.check(content, parser))
+	erl := list.sizing.check(content, parser)
+	if parser.mustStop(erl) {
+		return tResult{}, erl
+	}
+	errList.Push(erl)
 
 	// Going through the fields of the map
 	for k, value := range content.Content {
+		var erl []error
+
+		parser.enter(strconv.Itoa(k))
+
 		if k < len(list.form.list) {
 			// List (required)
-			result, erl := list.form.list[k].match(*value, parser)
-			errList.Push(erl)
+			var result tResult
+			result, erl = list.form.list[k].match(*value, parser)
 			listData.List = append(listData.List, result)
 		} else if k -= len(list.form.list); k < len(list.form.optionalList) {
 			// List (optional)
-			result, erl := list.form.optionalList[k].match(*value, parser)
-			errList.Push(erl)
+			var result tResult
+			result, erl = list.form.optionalList[k].match(*value, parser)
 			listData.List = append(listData.List, result)
 		} else if list.form.listOf != nil {
 			// ListOf (all the rest)
-			result, erl := list.form.listOf.match(*value, parser)
-			errList.Push(erl)
+			var result tResult
+			result, erl = list.form.listOf.match(*value, parser)
 			listData.ListOf = append(listData.ListOf, result)
 		} else {
 			// Rejecting extra entries (all the rest, if no ListOf)
@@ -239,8 +340,15 @@ func (list tList) match(content yaml.Node, parser *tParser) (tResult, []error) {
 				"no %dth entry (%s) `%s`",
 				k, value.Tag, value.Value,
 			)
-			errList.Push(parser.contentError(*value, message))
+			erl = parser.contentError(*value, ErrorCodeExtraEntry, message)
 		}
+
+		parser.leave()
+
+		if parser.mustStop(erl) {
+			return tResult{}, erl
+		}
+		errList.Push(erl)
 	}
 
 	// Signaling missing keys
@@ -249,32 +357,47 @@ func (list tList) match(content yaml.Node, parser *tParser) (tResult, []error) {
 			"a %dth entry %s",
 			k, list.form.list[k].description(),
 		)
-		errList.Push(parser.contentError(content, message))
+		erl := parser.contentError(content, ErrorCodeMissingEntry, message)
+		if parser.mustStop(erl) {
+			return tResult{}, erl
+		}
+		errList.Push(erl)
+	}
+
+	erl = errList.ConcatError()
+	if len(erl) == 0 {
+		parser.warnDeprecated(list.annotation, content)
 	}
 
-	return parser.wrap(listData, content), errList.ConcatError()
+	return parser.wrap(listData, content), erl
 }
 
 // OneOf
-func (oneOf tOneOf) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (oneOf tOneOf) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
+	optionErrorList := make([][]error, 0, len(oneOf.optionList))
+
 	for _, option := range oneOf.optionList {
-		if option == nil {
-			fmt.Printf("aaaaaa, %s\n", oneOf.optionList)
-		}
-		result, err := option.match(content, parser)
-		if len(err) == 0 {
+		// the warnings of the options which fail are dropped
+		warningCount := len(parser.warningList)
+
+		result, erl := option.match(content, parser)
+		if len(erl) == 0 {
+			parser.warnDeprecated(oneOf.annotation, content)
 			return result, nil
 		}
+		optionErrorList = append(optionErrorList, erl)
+
+		parser.warningList = parser.warningList[:warningCount]
 	}
 
-	return tResult{}, parser.contentError(content, oneOf.description())
+	return tResult{}, parser.oneOfError(content, oneOf, optionErrorList)
 }
 
 func (oneOf tOneOf) mergeMatch(
 	mapResult MapData,
 	utilizationTrackingList []bool,
 	content yaml.Node,
-	parser *tParser,
+	parser *tContentParser,
 ) []error {
 	const errorTemplate = "Lidy internal error -- " +
 		"_merge performed on a non-mergeable in _oneOf in the schema -- " +
@@ -282,14 +405,34 @@ func (oneOf tOneOf) mergeMatch(
 		"\n  expression: [%s]" +
 		"\n  content: [kind [%s], len %d, value [%s] at position %d:%d]"
 
+	optionErrorList := make([][]error, 0, len(oneOf.optionList))
+
 	for _, option := range oneOf.optionList {
 		if mergeable, ok := option.(tMergeableExpression); ok {
-			err := mergeable.mergeMatch(mapResult, utilizationTrackingList, content, parser)
-			// TODO
+			// the option may fail after having used some of the entries
+			// the state is saved so as to restore it in that case
+			savedTrackingList := append([]bool{}, utilizationTrackingList...)
+			savedMap := make(map[string]Result, len(mapResult.Map))
+			for key, value := range mapResult.Map {
+				savedMap[key] = value
+			}
+			warningCount := len(parser.warningList)
+
+			erl := mergeable.mergeMatch(mapResult, utilizationTrackingList, content, parser)
 
-			if len(err) == 0 {
+			if len(erl) == 0 {
 				return nil
 			}
+			optionErrorList = append(optionErrorList, erl)
+
+			parser.warningList = parser.warningList[:warningCount]
+
+			copy(utilizationTrackingList, savedTrackingList)
+			for key := range mapResult.Map {
+				if _, present := savedMap[key]; !present {
+					delete(mapResult.Map, key)
+				}
+			}
 		} else {
 			return []error{fmt.Errorf(
 				errorTemplate,
@@ -299,11 +442,195 @@ func (oneOf tOneOf) mergeMatch(
 		}
 	}
 
-	return parser.contentError(content, oneOf.description())
+	return parser.oneOfError(content, oneOf, optionErrorList)
+}
+
+// oneOfError reports that no option of the _oneOf matched, keeping the
+// errors of every option, and pointing out the option which got the furthest
+func (parser *tContentParser) oneOfError(content yaml.Node, oneOf tOneOf, optionErrorList [][]error) []error {
+	erl := parser.contentError(content, ErrorCodeOneOf, oneOf.description())
+
+	if contentError, ok := erl[0].(*ContentError); ok {
+		contentError.OptionErrorList = optionErrorList
+		contentError.BestOption = bestOption(optionErrorList, len(parser.contentPath))
+		if contentError.BestOption >= 0 {
+			contentError.Nested = optionErrorList[contentError.BestOption]
+			contentError.bestOptionName = oneOf.optionList[contentError.BestOption].name()
+		}
+	}
+
+	return erl
+}
+
+// tMatchProgress estimates how far an expression got in matching a node,
+// given the errors it produced
+type tMatchProgress struct {
+	// kindMatched is false if the node was rejected for its kind or its tag
+	kindMatched bool
+	// depth of the deepest error
+	depth int
+	// errorCount is the number of errors. The fewer the better
+	errorCount int
+}
+
+func (progress tMatchProgress) isAhead(other tMatchProgress) bool {
+	if progress.kindMatched != other.kindMatched {
+		return progress.kindMatched
+	}
+	if progress.depth != other.depth {
+		return progress.depth > other.depth
+	}
+	return progress.errorCount < other.errorCount
+}
+
+// bestOption picks the option which got the furthest, given the errors of
+// each option and the depth of the node in the document
+func bestOption(optionErrorList [][]error, depth int) int {
+	best := -1
+	bestProgress := tMatchProgress{}
+
+	for k, erl := range optionErrorList {
+		progress := tMatchProgress{
+			kindMatched: true,
+			depth:       depth,
+			errorCount:  len(erl),
+		}
+
+		for _, err := range erl {
+			contentError, ok := err.(*ContentError)
+			if !ok {
+				// the content has been fully matched, but a builder rejected it
+				progress.depth = math.MaxInt32
+				continue
+			}
+			if contentError.depth == depth && contentError.Code == ErrorCodeKind {
+				progress.kindMatched = false
+			}
+			if contentError.depth > progress.depth {
+				progress.depth = contentError.depth
+			}
+		}
+
+		if best < 0 || progress.isAhead(bestProgress) {
+			best = k
+			bestProgress = progress
+		}
+	}
+
+	return best
+}
+
+// Switch
+func (switchChecker tSwitch) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
+	if content.Tag != "!!map" {
+		return tResult{}, parser.contentError(content, ErrorCodeKind, "a YAML map, "+switchChecker.description())
+	}
+
+	utilizationTrackingList := make([]bool, len(content.Content)/2)
+
+	mapData := MapData{
+		Map: make(map[string]Result),
+	}
+
+	erl := switchChecker.mergeMatch(mapData, utilizationTrackingList, content, parser)
+	if parser.mustStop(erl) {
+		return tResult{}, erl
+	}
+
+	errList := errorlist.List{}
+	errList.Push(erl)
+
+	// the case has been selected and matched; the entries it left are extra
+	for k, used := range utilizationTrackingList {
+		if used {
+			continue
+		}
+
+		key := content.Content[2*k]
+
+		parser.enter(key.Value)
+		erl := parser.contentError(*key, ErrorCodeExtraEntry, "no extra entry")
+		parser.leave()
+		if parser.mustStop(erl) {
+			return tResult{}, erl
+		}
+		errList.Push(erl)
+	}
+
+	erl = errList.ConcatError()
+	if len(erl) == 0 {
+		parser.warnDeprecated(switchChecker.annotation, content)
+	}
+
+	return parser.wrap(mapData, content), erl
+}
+
+func (switchChecker tSwitch) mergeMatch(
+	mapResult MapData,
+	utilizationTrackingList []bool,
+	content yaml.Node,
+	parser *tContentParser,
+) []error {
+	expectedCaseText := "one of: " + strings.Join(switchChecker.caseNameList, ", ")
+
+	// Finding the discriminator
+	index := -1
+	for k := 0; k+1 < len(content.Content); k += 2 {
+		key := content.Content[k]
+		if key.Tag == "!!str" && key.Value == switchChecker.key {
+			index = k / 2
+			break
+		}
+	}
+
+	if index < 0 {
+		return parser.contentError(
+			content,
+			ErrorCodeMissingProperty,
+			fmt.Sprintf("to find the discriminator property %s (%s)", switchChecker.key, expectedCaseText),
+		)
+	}
+
+	value := content.Content[2*index+1]
+
+	// Selecting the case
+	mergeable, caseFound := tMergeableExpression(nil), false
+	if value.Kind == yaml.ScalarNode {
+		mergeable, caseFound = switchChecker.caseMap[value.Value]
+	}
+
+	if !caseFound {
+		// the discriminator is reported as unknown, not as an extra entry
+		utilizationTrackingList[index] = true
+
+		actual := "'" + value.Value + "'"
+		if value.Kind != yaml.ScalarNode {
+			actual = value.Tag
+		}
+
+		parser.enter(switchChecker.key)
+		erl := parser.contentError(
+			*value,
+			ErrorCodeSwitch,
+			fmt.Sprintf("a known %s (unknown %s %s, expected %s)", switchChecker.key, switchChecker.key, actual, expectedCaseText),
+		)
+		parser.leave()
+		return erl
+	}
+
+	erl := mergeable.mergeMatch(mapResult, utilizationTrackingList, content, parser)
+
+	// the case may not declare the discriminator; it is accepted anyway
+	if !utilizationTrackingList[index] {
+		utilizationTrackingList[index] = true
+		mapResult.Map[switchChecker.key] = parser.wrap(value.Value, *value)
+	}
+
+	return erl
 }
 
 // In
-func (in tIn) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (in tIn) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
 	if acceptList, found := in.valueMap[content.Tag]; found {
 		for _, accept := range acceptList {
 			if content.Value == accept {
@@ -311,27 +638,73 @@ func (in tIn) match(content yaml.Node, parser *tParser) (tResult, []error) {
 				// `data := content.Value, nil` only works if the value is supposed to be a string
 				// This returns the wrong type if the value must be a boolean or an integer or a float
 				data := content.Value
+				parser.warnDeprecated(in.annotation, content)
 				return parser.wrap(data, content), nil
 			}
 		}
 	}
 
-	return tResult{}, parser.contentError(content, in.description())
+	return tResult{}, parser.contentError(content, ErrorCodeIn, in.description())
 }
 
 // Regex
-func (rxp tRegex) match(content yaml.Node, parser *tParser) (tResult, []error) {
+func (rxp tRegex) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
 	if content.Tag != "!!str" || !rxp.regex.MatchString(content.Value) {
-		return tResult{}, parser.contentError(content, fmt.Sprintf("a string (matching the regex [%s])", rxp.regexString))
+		return tResult{}, parser.contentError(content, ErrorCodeRegex, fmt.Sprintf("a string (matching the regex [%s])", rxp.regexString))
 	}
 
+	parser.warnDeprecated(rxp.annotation, content)
+
 	return parser.wrap(content.Value, content), nil
 }
 
+// Range
+func (rng tRange) match(content yaml.Node, parser *tContentParser) (tResult, []error) {
+	var value float64
+	var data interface{}
+
+	switch {
+	case rng.kind == "int" && content.Tag == "!!int":
+		var integer int
+		if content.Decode(&integer) != nil {
+			break
+		}
+		value = float64(integer)
+		data = integer
+	case rng.kind == "float" && (content.Tag == "!!float" || content.Tag == "!!int"):
+		if content.Decode(&value) != nil {
+			break
+		}
+		data = value
+	}
+
+	if data == nil || !rng.min.accept(value, true) || !rng.max.accept(value, false) {
+		return tResult{}, parser.contentError(content, ErrorCodeRange, fmt.Sprintf("a YAML %s in the range %s", rng.kind, rng.rangeString))
+	}
+
+	parser.warnDeprecated(rng.annotation, content)
+
+	return parser.wrap(data, content), nil
+}
+
+// accept tells whether the value is on the right side of the bound
+func (bound tRangeBound) accept(value float64, isMin bool) bool {
+	switch {
+	case !bound.present:
+		return true
+	case value == bound.value:
+		return bound.inclusive
+	case isMin:
+		return value > bound.value
+	default:
+		return value < bound.value
+	}
+}
+
 // Add metadata to value, to create a Result
-func (parser tParser) wrap(data interface{}, content yaml.Node) tResult {
+func (parser tContentParser) wrap(data interface{}, content yaml.Node) tResult {
 	return tResult{
-		tPosition:    positionFromYamlNode(parser.contentFile.name, content),
+		tPosition:    parser.position(content),
 		isLidyData:   true,
 		hasBeenBuilt: false,
 		ruleName:     "",
@@ -340,13 +713,24 @@ func (parser tParser) wrap(data interface{}, content yaml.Node) tResult {
 }
 
 func positionFromYamlNode(filename string, node yaml.Node) tPosition {
+	lineEnd, columnEnd := endPosition(node)
+
 	return tPosition{
-		filename: filename,
-		line:     node.Line,
-		column:   node.Column,
+		filename:  filename,
+		line:      node.Line,
+		column:    node.Column,
+		lineEnd:   lineEnd,
+		columnEnd: columnEnd,
 	}
 }
 
+// position is the position of a content node, in its document
+func (parser *tContentParser) position(content yaml.Node) tPosition {
+	position := positionFromYamlNode(parser.contentFile.name, content)
+	position.document = parser.contentFile.document
+	return position
+}
+
 // Yaml document root
 func getRoot(documentNode yaml.Node) (*yaml.Node, []error) {
 	if documentNode.Kind != yaml.DocumentNode {
@@ -371,7 +755,7 @@ func getPosition(content yaml.Node) string {
 	return fmt.Sprintf("%d:%d", content.Line, content.Column)
 }
 
-func (parser *tParser) reportSchemaParserInternalError(context string, expression tExpression, content yaml.Node) []error {
+func (parser *tContentParser) reportSchemaParserInternalError(context string, expression tExpression, content yaml.Node) []error {
 	return []error{fmt.Errorf(""+
 		"Lidy internal error -- "+
 		"%s"+
@@ -381,14 +765,41 @@ func (parser *tParser) reportSchemaParserInternalError(context string, expressio
 		context,
 		expression.description(),
 		content.Tag, len(content.Content), content.Value,
-		parser.name, content.Line, content.Column,
+		parser.contentFile.name, content.Line, content.Column,
 	)}
 }
 
-func (parser *tParser) contentError(content yaml.Node, expected string) []error {
+// mustStop tells whether the matching must unwind right away, returning the
+// given errors, because the StopAtFirstError option is set and an error was found
+func (parser *tContentParser) mustStop(erl []error) bool {
+	return parser.option.StopAtFirstError && len(erl) > 0
+}
+
+// enter pushes a map key or a list index onto the content path
+func (parser *tContentParser) enter(segment string) {
+	parser.contentPath = append(parser.contentPath, segment)
+}
+
+// leave pops the last segment of the content path
+func (parser *tContentParser) leave() {
+	parser.contentPath = parser.contentPath[:len(parser.contentPath)-1]
+}
+
+func (parser *tContentParser) contentError(content yaml.Node, code ErrorCode, expected string) []error {
 	if content.Kind == yaml.Kind(0) {
 		return []error{fmt.Errorf("Tried to use uninitialized yaml node [node, expected: %s]; %s", expected, pleaseReport)}
 	}
 
-	return []error{fmt.Errorf("error with content node, kind #%d, tag '%s', value '%s' at position %s:%s, where [%s] was expected", content.Kind, content.Tag, content.Value, parser.contentFile.name, getPosition(content), expected)}
+	return []error{&ContentError{
+		tPosition:  parser.position(content),
+		Path:       pathString(parser.contentPath),
+		RuleName:   parser.contentRuleName,
+		Expected:   expected,
+		Actual:     content.Tag,
+		Code:       code,
+		BestOption: -1,
+		depth:      len(parser.contentPath),
+		kind:       content.Kind,
+		value:      content.Value,
+	}}
 }
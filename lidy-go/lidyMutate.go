@@ -0,0 +1,445 @@
+package lidy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidyMutate.go
+//
+// Generate near-miss documents, which the schema must reject
+
+// Mutate -- process the schema if needed, generate a sample of the target
+// rule, and variants of it which each break one constraint of the schema.
+// Only the variants rejected with the expected error are kept, paired with
+// the rule reporting it
+func (p *tParser) Mutate(option SampleOption) (MutantSet, []error) {
+	sampler, erl := p.newSampler(option)
+	if len(erl) > 0 {
+		return MutantSet{}, erl
+	}
+	sampler.originMap = map[*yaml.Node]tExpression{}
+
+	root, err := sampler.sample(p.schema.ruleMap[p.target], 0)
+	if err != nil {
+		return MutantSet{}, []error{err}
+	}
+
+	mutator := tMutator{
+		parser:      p,
+		sampler:     sampler,
+		root:        root,
+		contentSet:  map[string]bool{},
+		mutationSet: map[string]bool{},
+	}
+
+	mutantSet := MutantSet{Sample: flowText(root)}
+	mutator.contentSet[string(mutantSet.Sample)] = true
+
+	mutator.walk(root, []string{}, []string{})
+	mutantSet.MutantList = mutator.mutantList
+
+	return mutantSet, nil
+}
+
+type tMutator struct {
+	parser  *tParser
+	sampler *tSampler
+	root    *yaml.Node
+	// contentSet
+	// the documents produced so far, so that each mutant is different
+	contentSet map[string]bool
+	// mutationSet
+	// the mutations applied so far, by path where the list indexes are
+	// replaced by `#`, so that the items of a list are mutated once
+	mutationSet map[string]bool
+	mutantList  []Mutant
+}
+
+// tMutation -- a change to a copy of a node, and the error it must produce
+type tMutation struct {
+	description string
+	code        ErrorCode
+	apply       func(node *yaml.Node)
+}
+
+// walk mutates the nodes of the sample, depth first, the parents first.
+// shapePath is the path where the list indexes are replaced by `#`
+func (mutator *tMutator) walk(node *yaml.Node, path []string, shapePath []string) {
+	if expression, found := mutator.sampler.originMap[node]; found {
+		for _, mutation := range mutator.mutationList(expression, node) {
+			mutationKey := pathString(shapePath) + " " + mutation.description
+			if !mutator.mutationSet[mutationKey] && mutator.try(node, path, mutation) {
+				mutator.mutationSet[mutationKey] = true
+			}
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			key := node.Content[k].Value
+			mutator.walk(node.Content[k+1], appendPath(path, key), appendPath(shapePath, key))
+		}
+	case yaml.SequenceNode:
+		for k, item := range node.Content {
+			mutator.walk(item, appendPath(path, strconv.Itoa(k)), appendPath(shapePath, "#"))
+		}
+	}
+}
+
+// mutationList lists the changes which break the expression that produced the node
+func (mutator *tMutator) mutationList(expression tExpression, node *yaml.Node) []tMutation {
+	random := mutator.sampler.random
+	mutationList := []tMutation{}
+
+	switch expression := expression.(type) {
+	case *tRule:
+		if replacement, found := wrongTagMap[expression.ruleName]; found {
+			mutationList = append(mutationList, tMutation{
+				description: fmt.Sprintf("with a %s value where the rule %s is expected", replacement.Tag, expression.ruleName),
+				code:        ErrorCodeKind,
+				apply:       replace(replacement),
+			})
+		}
+	case tMap:
+		keyList := sortedKeyList(expression.form.propertyMap)
+		if len(keyList) > 0 {
+			key := keyList[random.Intn(len(keyList))]
+			mutationList = append(mutationList, tMutation{
+				description: fmt.Sprintf("without the required key '%s'", key),
+				code:        ErrorCodeMissingProperty,
+				apply:       removeKey(key),
+			})
+		}
+		if expression.form.mapOf.key == nil {
+			key := unusedKey(node)
+			mutationList = append(mutationList, tMutation{
+				description: fmt.Sprintf("with the extra key '%s'", key),
+				code:        ErrorCodeExtraEntry,
+				apply:       addKey(key),
+			})
+		}
+		mutationList = append(mutationList, sizeMutationList(expression.sizing)...)
+	case tList:
+		mutationList = append(mutationList, sizeMutationList(expression.sizing)...)
+	case tSwitch:
+		value := "unknown"
+		for expression.caseMap[value] != nil {
+			value += "_"
+		}
+		mutationList = append(mutationList, tMutation{
+			description: fmt.Sprintf("with the unknown %s '%s'", expression.key, value),
+			code:        ErrorCodeSwitch,
+			apply:       setKey(expression.key, value),
+		})
+	case tIn:
+		value := "unknown"
+		for containsString(expression.valueMap["!!str"], value) {
+			value += "_"
+		}
+		mutationList = append(mutationList, tMutation{
+			description: fmt.Sprintf("with the value '%s', which is not in _in", value),
+			code:        ErrorCodeIn,
+			apply:       replace(yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}),
+		})
+	case tRegex:
+		for _, value := range []string{"", "-", "0", "a", "A", "~ ~"} {
+			if !expression.regex.MatchString(value) {
+				mutationList = append(mutationList, tMutation{
+					description: fmt.Sprintf("with the string '%s', which does not match the regex", value),
+					code:        ErrorCodeRegex,
+					apply:       replace(yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}),
+				})
+				break
+			}
+		}
+	case tRange:
+		bound := expression.max
+		offset := 1.0
+		if !bound.present {
+			bound = expression.min
+			offset = -1.0
+		}
+		if bound.present {
+			value := bound.value + offset
+			if !bound.inclusive {
+				value = bound.value
+			}
+			replacement := yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: floatText(value)}
+			if expression.kind == "int" {
+				replacement = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(int(value))}
+			}
+			mutationList = append(mutationList, tMutation{
+				description: fmt.Sprintf("with the value %s, which is out of the range", replacement.Value),
+				code:        ErrorCodeRange,
+				apply:       replace(replacement),
+			})
+		}
+	}
+
+	return mutationList
+}
+
+// try applies the mutation to a copy of the sample, and keeps it if the
+// expected error is reported at the node, or within it
+func (mutator *tMutator) try(node *yaml.Node, path []string, mutation tMutation) bool {
+	copy, target := copyNode(mutator.root, node)
+	mutation.apply(target)
+
+	content := flowText(copy)
+	if mutator.contentSet[string(content)] {
+		return false
+	}
+	mutator.contentSet[string(content)] = true
+
+	_, erl := mutator.parser.Parse(NewFile("mutant.yaml", content))
+
+	contentError := findContentError(erl, mutation.code, pathString(path))
+	if contentError == nil {
+		return false
+	}
+
+	mutator.mutantList = append(mutator.mutantList, Mutant{
+		Content:   content,
+		Mutation:  mutation.description,
+		Code:      contentError.Code,
+		Path:      pathString(path),
+		ErrorPath: contentError.Path,
+		RuleName:  contentError.RuleName,
+	})
+
+	return true
+}
+
+// findContentError looks for an error with the given code, at the path or
+// within it, among the errors and the errors explaining them
+func findContentError(erl []error, code ErrorCode, path string) *ContentError {
+	for _, err := range erl {
+		contentError, ok := err.(*ContentError)
+		if !ok {
+			continue
+		}
+
+		within := path == "/" || contentError.Path == path || strings.HasPrefix(contentError.Path, path+"/")
+		if contentError.Code == code && within {
+			return contentError
+		}
+
+		if nested := findContentError(contentError.Nested, code, path); nested != nil {
+			return nested
+		}
+	}
+
+	return nil
+}
+
+//
+// Mutations
+//
+
+// wrongTagMap -- for each predefined rule, a value of another kind
+var wrongTagMap = map[string]yaml.Node{
+	"string":    {Kind: yaml.ScalarNode, Tag: "!!int", Value: "42"},
+	"int":       {Kind: yaml.ScalarNode, Tag: "!!str", Value: "text"},
+	"float":     {Kind: yaml.ScalarNode, Tag: "!!str", Value: "text"},
+	"boolean":   {Kind: yaml.ScalarNode, Tag: "!!str", Value: "text"},
+	"nullType":  {Kind: yaml.ScalarNode, Tag: "!!str", Value: "text"},
+	"timestamp": {Kind: yaml.ScalarNode, Tag: "!!int", Value: "42"},
+	"binary":    {Kind: yaml.ScalarNode, Tag: "!!int", Value: "42"},
+}
+
+func sizeMutationList(sizing tSizing) []tMutation {
+	min, max := sizingBound(sizing)
+	mutationList := []tMutation{}
+
+	if min > 0 {
+		mutationList = append(mutationList, tMutation{
+			description: fmt.Sprintf("with %d entries, one short of the minimum", min-1),
+			code:        ErrorCodeSize,
+			apply:       resize(min - 1),
+		})
+	}
+	if max != sampleInfinite {
+		mutationList = append(mutationList, tMutation{
+			description: fmt.Sprintf("with %d entries, one above the maximum", max+1),
+			code:        ErrorCodeSize,
+			apply:       resize(max + 1),
+		})
+	}
+
+	return mutationList
+}
+
+func replace(replacement yaml.Node) func(node *yaml.Node) {
+	return func(node *yaml.Node) {
+		*node = replacement
+	}
+}
+
+func removeKey(key string) func(node *yaml.Node) {
+	return func(node *yaml.Node) {
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			if node.Content[k].Value == key {
+				node.Content = append(node.Content[:k:k], node.Content[k+2:]...)
+				return
+			}
+		}
+	}
+}
+
+func addKey(key string) func(node *yaml.Node) {
+	return func(node *yaml.Node) {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "value"},
+		)
+	}
+}
+
+func setKey(key string, value string) func(node *yaml.Node) {
+	return func(node *yaml.Node) {
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			if node.Content[k].Value == key {
+				node.Content[k+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+			}
+		}
+	}
+}
+
+// resize removes entries, or repeats the last one, for a list or a map
+func resize(size int) func(node *yaml.Node) {
+	return func(node *yaml.Node) {
+		step := 1
+		if node.Kind == yaml.MappingNode {
+			step = 2
+		}
+
+		for len(node.Content) > size*step {
+			node.Content = node.Content[:len(node.Content)-step]
+		}
+
+		for k := 0; len(node.Content) < size*step && len(node.Content) > 0; k++ {
+			if step == 1 {
+				node.Content = append(node.Content, node.Content[len(node.Content)-1])
+			} else {
+				key := unusedKey(node)
+				node.Content = append(node.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+					node.Content[len(node.Content)-1],
+				)
+			}
+		}
+	}
+}
+
+// unusedKey returns a key that the map does not have
+func unusedKey(node *yaml.Node) string {
+	keySet := map[string]bool{}
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		keySet[node.Content[k].Value] = true
+	}
+
+	key := "unknownKey"
+	for k := 2; keySet[key]; k++ {
+		key = "unknownKey" + strconv.Itoa(k)
+	}
+	return key
+}
+
+func containsString(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
+
+// copyNode copies the tree, and returns the copy of the target node too
+func copyNode(node *yaml.Node, target *yaml.Node) (*yaml.Node, *yaml.Node) {
+	copy := *node
+	copy.Content = make([]*yaml.Node, len(node.Content))
+
+	var targetCopy *yaml.Node
+	if node == target {
+		targetCopy = &copy
+	}
+
+	for k, child := range node.Content {
+		childCopy, childTargetCopy := copyNode(child, target)
+		copy.Content[k] = childCopy
+		if childTargetCopy != nil {
+			targetCopy = childTargetCopy
+		}
+	}
+
+	return &copy, targetCopy
+}
+
+// flowText writes the node on a single line
+func flowText(node *yaml.Node) []byte {
+	copy, _ := copyNode(node, nil)
+	copy.Style = yaml.FlowStyle
+
+	content, err := yaml.Marshal(copy)
+	if err != nil {
+		panic(err)
+	}
+
+	return bytes.TrimSuffix(content, []byte("\n"))
+}
+
+//
+// Spec
+//
+
+// Spec -- the mutant set in the layout of the .spec.yaml files of the lidy
+// test data: a group with the schema, the sample to accept, and a criterion
+// per mutant to reject, named after the rule it violates. The group checks
+// the rule `main` of the schema
+func (mutantSet MutantSet) Spec(description string, schema []byte) []byte {
+	group := &yaml.Node{Kind: yaml.MappingNode}
+
+	addGroupEntry := func(key string, value *yaml.Node) {
+		group.Content = append(group.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+	testLine := func(content []byte, check *yaml.Node) *yaml.Node {
+		return &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(content)}, check,
+		}}
+	}
+
+	addGroupEntry("schema", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.LiteralStyle, Value: strings.TrimSpace(string(schema))})
+	addGroupEntry("accept the sample", testLine(mutantSet.Sample, &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}))
+
+	mutantList := append([]Mutant{}, mutantSet.MutantList...)
+	sort.SliceStable(mutantList, func(i, j int) bool {
+		return mutantList[i].Path < mutantList[j].Path
+	})
+
+	for _, mutant := range mutantList {
+		check := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "contain"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "(path " + mutant.ErrorPath + ")"},
+		}}
+		criterion := fmt.Sprintf("reject %s at %s (rule %s, %s)", mutant.Mutation, mutant.Path, mutant.RuleName, mutant.Code)
+		addGroupEntry(criterion, testLine(mutant.Content, check))
+	}
+
+	document := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: description}, group,
+	}}
+
+	content, err := encodeSample(document)
+	if err != nil {
+		panic(err)
+	}
+
+	return content
+}
@@ -16,6 +16,18 @@ func (p tPosition) Column() int {
 	return p.column
 }
 
+func (p tPosition) LineEnd() int {
+	return p.lineEnd
+}
+
+func (p tPosition) ColumnEnd() int {
+	return p.columnEnd
+}
+
+func (p tPosition) Document() int {
+	return p.document
+}
+
 //
 // Result, tResult
 //
@@ -32,6 +44,10 @@ func (r tResult) IsLidyData() bool {
 	return r.isLidyData
 }
 
+func (r tResult) IsSynthesized() bool {
+	return r.isSynthesized
+}
+
 func (r tResult) Data() interface{} {
 	return r.data
 }
@@ -7,6 +7,12 @@ type Position interface {
 	Line() int
 	// The beginning column in the line of the position
 	Column() int
+	// The ending line of the position
+	LineEnd() int
+	// The ending column of the position
+	ColumnEnd() int
+	// The index of the YAML document in the stream, from 0. See ParseStream
+	Document() int
 }
 
 var _ Position = tPosition{}
@@ -21,6 +27,8 @@ type tPosition struct {
 	lineEnd int
 	// The ending column of the position
 	columnEnd int
+	// The index of the YAML document in the stream; 0 outside of ParseStream
+	document int
 }
 
 //
@@ -36,6 +44,9 @@ type Result interface {
 	HasBeenBuilt() bool
 	// True if the type of the data is used by Lidy
 	IsLidyData() bool
+	// True if the data is the `_default` of a facultative property missing
+	// from the content. The position is then that of the value in the schema
+	IsSynthesized() bool
 	// Get the piece of data itself
 	Data() interface{}
 }
@@ -44,10 +55,11 @@ var _ Result = tResult{}
 
 type tResult struct {
 	tPosition
-	ruleName     string
-	hasBeenBuilt bool
-	isLidyData   bool
-	data         interface{}
+	ruleName      string
+	hasBeenBuilt  bool
+	isLidyData    bool
+	isSynthesized bool
+	data          interface{}
 }
 
 //
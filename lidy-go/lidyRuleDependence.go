@@ -1,19 +1,286 @@
 package lidy
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ditrit/lidy/errorlist"
+)
+
+// lidyRuleDependence.go
+//
+// The rule dependency graph: the references from the expression of each rule
+// to the rules it uses, and the detection of the unguarded recursions
+
+// Dependency -- a reference, in the expression of a rule, to a rule
+type Dependency struct {
+	// From the rule whose expression contains the reference
+	From Rule
+	// To the rule referred to, which may be a predefined rule
+	To Rule
+	// Path the path of the reference in the schema, e.g. `/main/_map/servers/_listOf`
+	Path string
+	// Kind the keyword whose expression is the reference, e.g. DependencyListOf
+	// for `_listOf: server`, or DependencyAlias if the reference is the
+	// expression of the rule
+	Kind DependencyKind
+	// Guarded true if the reference is matched against a child of the content
+	// node; a property, a key or an item. The references through the aliases,
+	// `_rule`, `_oneOf`, `_merge` and `_switch` are not guarded: a cycle of
+	// them would never end, and is a schema error
+	Guarded bool
+	// Reference the reference, with its position and its annotation
+	Reference RuleReference
+}
+
+// DependencyKind -- how a rule reference is made
+type DependencyKind string
+
+const (
+	// DependencyAlias the reference is the expression of the rule, e.g. `name: string`, or `_rule`
+	DependencyAlias DependencyKind = "alias"
+	// DependencyProperty the value of a property of `_map`
+	DependencyProperty DependencyKind = "property"
+	// DependencyFacultativeProperty the value of a property of `_mapFacultative`
+	DependencyFacultativeProperty DependencyKind = "facultativeProperty"
+	// DependencyMapKey the key of `_mapOf`
+	DependencyMapKey DependencyKind = "mapKey"
+	// DependencyMapValue the value of `_mapOf`
+	DependencyMapValue DependencyKind = "mapValue"
+	// DependencyItem an item of `_list`
+	DependencyItem DependencyKind = "item"
+	// DependencyFacultativeItem an item of `_listFacultative`
+	DependencyFacultativeItem DependencyKind = "facultativeItem"
+	// DependencyListOf the items of `_listOf`
+	DependencyListOf DependencyKind = "listOf"
+	// DependencyOption an option of `_oneOf`
+	DependencyOption DependencyKind = "option"
+	// DependencyMerge an expression of `_merge`
+	DependencyMerge DependencyKind = "merge"
+	// DependencyCase a case of `_switch`
+	DependencyCase DependencyKind = "case"
+)
+
+// tDependency -- an edge of the dependency graph
+type tDependency struct {
+	from      *tRule
+	reference *tRule
+	path      []string
+	kind      DependencyKind
+	guarded   bool
+}
+
+// Dependencies -- process the schema if needed, and return the references
+// of the user rules, including the imported ones, sorted by the name of the
+// referring rule
+func (p *tParser) Dependencies() ([]Dependency, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	dependencyList := make([]Dependency, len(p.schema.dependencyList))
+	for k, dependency := range p.schema.dependencyList {
+		dependencyList[k] = Dependency{
+			From:      dependency.from,
+			To:        dependency.reference.origin(),
+			Path:      pathString(dependency.path),
+			Kind:      dependency.kind,
+			Guarded:   dependency.guarded,
+			Reference: dependency.reference,
+		}
+	}
+
+	return dependencyList, nil
+}
+
+// dependencyGraph lists the references of the user rules, once all the rules
+// are parsed
+func (schemaParser *tSchemaParser) dependencyGraph() []tDependency {
+	dependencyList := []tDependency{}
+
+	for _, rule := range schemaParser.userRuleList() {
+		dependencyWalk(rule.expression, []string{rule._keyNode.Value}, DependencyAlias, false, func(reference *tRule, path []string, kind DependencyKind, guarded bool) {
+			dependencyList = append(dependencyList, tDependency{
+				from:      rule,
+				reference: reference,
+				path:      path,
+				kind:      kind,
+				guarded:   guarded,
+			})
+		})
+	}
+
+	return dependencyList
+}
+
+// userRuleList lists the user rules, sorted by name, without the aliases of
+// `any` added for BypassMissingRule
+func (schemaParser *tSchemaParser) userRuleList() []*tRule {
+	ruleNameList := []string{}
+	for ruleName, rule := range schemaParser.schema.ruleMap {
+		if _, present := schemaParser.lidyDefaultRuleMap[ruleName]; !present && rule.ruleName == ruleName {
+			ruleNameList = append(ruleNameList, ruleName)
+		}
+	}
+	sort.Strings(ruleNameList)
+
+	ruleList := make([]*tRule, len(ruleNameList))
+	for k, ruleName := range ruleNameList {
+		ruleList[k] = schemaParser.schema.ruleMap[ruleName]
+	}
+	return ruleList
+}
+
+// checkRecursion rejects the cycles of unguarded references, which would
+// recurse forever at match time, e.g. `a: { _oneOf: [a, string] }` or
+// `a: { _merge: [b] }, b: { _merge: [a] }`
+func (schemaParser *tSchemaParser) checkRecursion() []error {
+	errList := errorlist.List{}
+
+	unguardedMap := map[*tRule][]*tRule{}
+	for _, dependency := range schemaParser.schema.dependencyList {
+		if !dependency.guarded {
+			unguardedMap[dependency.from] = append(unguardedMap[dependency.from], dependency.reference.origin())
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	stateMap := map[*tRule]int{}
+	stack := []*tRule{}
+
+	var visit func(rule *tRule)
+	visit = func(rule *tRule) {
+		stateMap[rule] = visiting
+		stack = append(stack, rule)
+
+		for _, next := range unguardedMap[rule] {
+			switch stateMap[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				errList.Push(schemaParser.recursionError(stack, next))
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		stateMap[rule] = visited
+	}
+
+	for _, rule := range schemaParser.userRuleList() {
+		if stateMap[rule] == unvisited {
+			visit(rule)
+		}
+	}
+
+	return errList.ConcatError()
+}
+
+// recursionError reports the cycle going from the rule `start` of the stack
+// back to it, on the declaration of `start`
+func (schemaParser *tSchemaParser) recursionError(stack []*tRule, start *tRule) []error {
+	nameList := []string{}
+	for k := len(stack) - 1; k >= 0; k-- {
+		nameList = append([]string{stack[k].ruleName}, nameList...)
+		if stack[k] == start {
+			break
+		}
+	}
+	nameList = append(nameList, start.ruleName)
+
+	ruleParser := *schemaParser
+	ruleParser.currentRuleName = start.ruleName
+	ruleParser.currentFilename = start._filename
+
+	return ruleParser.at(start._keyNode.Value).schemaError(start._keyNode, ErrorCodeRecursion, fmt.Sprintf(
+		"no recursion through aliases, _rule, _oneOf, _merge or _switch only, as it would never end (%s)",
+		strings.Join(nameList, " -> "),
+	))
+}
+
+// dependencyWalk calls visit with each rule reference of the expression,
+// without following the references. path is the schema path of the
+// expression, kind the keyword it is the expression of, and guarded tells if
+// a child of the content node is matched
+func dependencyWalk(expression tExpression, path []string, kind DependencyKind, guarded bool, visit func(reference *tRule, path []string, kind DependencyKind, guarded bool)) {
+	switch expression := expression.(type) {
+	case *tRule:
+		visit(expression, path, kind, guarded)
+	case tMap:
+		for _, key := range sortedKeyList(expression.form.propertyMap) {
+			dependencyWalk(expression.form.propertyMap[key], appendPath(path, "_map", key), DependencyProperty, true, visit)
+		}
+		for _, key := range sortedKeyList(expression.form.optionalMap) {
+			dependencyWalk(expression.form.optionalMap[key], appendPath(path, "_mapFacultative", key), DependencyFacultativeProperty, true, visit)
+		}
+		if expression.form.mapOf.key != nil {
+			dependencyWalk(expression.form.mapOf.key, appendPath(path, "_mapOf"), DependencyMapKey, true, visit)
+			dependencyWalk(expression.form.mapOf.value, appendPath(path, "_mapOf"), DependencyMapValue, true, visit)
+		}
+		for k, mergeable := range expression.form.mergeList {
+			dependencyWalk(mergeable, appendPath(path, "_merge", strconv.Itoa(k)), DependencyMerge, guarded, visit)
+		}
+	case tList:
+		for k, item := range expression.form.list {
+			dependencyWalk(item, appendPath(path, "_list", strconv.Itoa(k)), DependencyItem, true, visit)
+		}
+		for k, item := range expression.form.optionalList {
+			dependencyWalk(item, appendPath(path, "_listFacultative", strconv.Itoa(k)), DependencyFacultativeItem, true, visit)
+		}
+		if expression.form.listOf != nil {
+			dependencyWalk(expression.form.listOf, appendPath(path, "_listOf"), DependencyListOf, true, visit)
+		}
+	case tOneOf:
+		for k, option := range expression.optionList {
+			dependencyWalk(option, appendPath(path, "_oneOf", strconv.Itoa(k)), DependencyOption, guarded, visit)
+		}
+	case tSwitch:
+		for _, caseName := range expression.caseNameList {
+			dependencyWalk(expression.caseMap[caseName], appendPath(path, "_switch", caseName), DependencyCase, guarded, visit)
+		}
+	}
+}
+
+// expressionDependencyList lists the names of the rules an expression refers
+// to, in the order of the walk, without repetition
+func expressionDependencyList(expression tExpression) []string {
+	nameList := []string{}
+	nameSet := map[string]bool{}
+
+	dependencyWalk(expression, nil, DependencyAlias, false, func(reference *tRule, _ []string, _ DependencyKind, _ bool) {
+		if !nameSet[reference.ruleName] {
+			nameSet[reference.ruleName] = true
+			nameList = append(nameList, reference.ruleName)
+		}
+	})
+
+	return nameList
+}
+
 func (rule tRule) dependencyList() []string {
-	return []string{rule.name()}
+	return []string{rule.ruleName}
 }
 
 func (mapChecker tMap) dependencyList() []string {
-	return mapChecker.form._dependencyList
+	return expressionDependencyList(mapChecker)
 }
 
 func (list tList) dependencyList() []string {
-	return []string{}
+	return expressionDependencyList(list)
 }
 
 func (oneOf tOneOf) dependencyList() []string {
-	return oneOf._dependencyList
+	return expressionDependencyList(oneOf)
+}
+
+func (switchChecker tSwitch) dependencyList() []string {
+	return expressionDependencyList(switchChecker)
 }
 
 func (in tIn) dependencyList() []string {
@@ -23,3 +290,7 @@ func (in tIn) dependencyList() []string {
 func (regex tRegex) dependencyList() []string {
 	return []string{}
 }
+
+func (rng tRange) dependencyList() []string {
+	return []string{}
+}
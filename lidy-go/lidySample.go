@@ -0,0 +1,859 @@
+package lidy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidySample.go
+//
+// Generate random documents matching a rule of the schema
+
+// sampleDefaultMaxDepth -- the MaxDepth used when SampleOption.MaxDepth is 0
+const sampleDefaultMaxDepth = 5
+
+// sampleInfinite -- the height of the expressions which only accept
+// infinitely deep documents
+const sampleInfinite = math.MaxInt32
+
+// sampleRangeWidth -- the width of the interval sampled on the unbounded side
+// of a range
+const sampleRangeWidth = 100
+
+// sampleExtraLimit -- the maximum number of _listOf or _mapOf entries added
+// to a container bounded by _max
+const sampleExtraLimit = 8
+
+// sampleAttemptCount -- the number of attempts to generate a string matching
+// a regex, or a key which is not used yet
+const sampleAttemptCount = 16
+
+// Sample -- process the schema if needed, and generate a random YAML document
+// matching the target rule. The same seed gives the same document. From
+// MaxDepth on, the facultative entries are left out and the shallowest options
+// are chosen, so that the recursive rules end. The builders are not run.
+func (p *tParser) Sample(option SampleOption) ([]byte, []error) {
+	sampler, erl := p.newSampler(option)
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	node, err := sampler.sample(p.schema.ruleMap[p.target], 0)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	content, err := encodeSample(node)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return content, nil
+}
+
+// newSampler processes the schema if needed, and prepares the sampling of the
+// target rule
+func (p *tParser) newSampler(option SampleOption) (*tSampler, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	rule, ruleFound := p.schema.ruleMap[p.target]
+	if !ruleFound {
+		return nil, []error{fmt.Errorf("Could not find target rule '%s' in grammar", p.target)}
+	}
+
+	maxDepth := option.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = sampleDefaultMaxDepth
+	}
+
+	sampler := &tSampler{
+		random:    rand.New(rand.NewSource(option.Seed)),
+		maxDepth:  maxDepth,
+		heightMap: map[*tRule]int{},
+	}
+	sampler.computeHeightMap(p.schema.ruleMap)
+
+	if sampler.height(rule) == sampleInfinite {
+		return nil, []error{fmt.Errorf("rule '%s' only accepts infinitely deep documents; it cannot be sampled", p.target)}
+	}
+
+	return sampler, nil
+}
+
+func encodeSample(node *yaml.Node) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+type tSampler struct {
+	random   *rand.Rand
+	maxDepth int
+	// heightMap
+	// the depth of the shallowest documents accepted by each rule
+	heightMap map[*tRule]int
+	// originMap
+	// if not nil, the checker or the predefined rule which produced each
+	// sampled node. Used by Mutate
+	originMap map[*yaml.Node]tExpression
+}
+
+//
+// Height
+//
+
+// computeHeightMap finds the height of every rule, as a fixed point, since
+// the rules may be recursive
+func (sampler *tSampler) computeHeightMap(ruleMap map[string]*tRule) {
+	for _, rule := range ruleMap {
+		sampler.heightMap[rule] = sampleInfinite
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, rule := range ruleMap {
+			if rule.lidyMatcher != nil || rule.expression == nil {
+				continue
+			}
+			height := sampler.height(rule.expression)
+			if height < sampler.heightMap[rule] {
+				sampler.heightMap[rule] = height
+				changed = true
+			}
+		}
+	}
+}
+
+// height is the depth of the shallowest documents accepted by the expression;
+// 0 for the scalars
+func (sampler *tSampler) height(expression tExpression) int {
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.lidyMatcher != nil {
+			return 0
+		}
+		height, found := sampler.heightMap[expression.origin()]
+		if !found {
+			return sampleInfinite
+		}
+		return height
+	case tMap:
+		return increment(sampler.mergeHeight(expression, map[*tRule]bool{}))
+	case tList:
+		height := 0
+		for _, item := range expression.form.list {
+			height = maxInt(height, sampler.height(item))
+		}
+		if min, _ := sizingBound(expression.sizing); min > len(expression.form.list) {
+			for _, item := range expression.form.optionalList {
+				height = maxInt(height, sampler.height(item))
+			}
+			if min > len(expression.form.list)+len(expression.form.optionalList) {
+				height = maxInt(height, sampler.heightOrInfinite(expression.form.listOf))
+			}
+		}
+		return increment(height)
+	case tOneOf:
+		height := sampleInfinite
+		for _, option := range expression.optionList {
+			height = minInt(height, sampler.height(option))
+		}
+		return height
+	case tSwitch:
+		return increment(sampler.mergeHeight(expression, map[*tRule]bool{}))
+	}
+
+	return 0
+}
+
+func (sampler *tSampler) heightOrInfinite(expression tExpression) int {
+	if expression == nil {
+		return sampleInfinite
+	}
+	return sampler.height(expression)
+}
+
+// mergeHeight is the height of the entries that a mergeable expression adds
+// to a map
+func (sampler *tSampler) mergeHeight(mergeable tExpression, visitingSet map[*tRule]bool) int {
+	switch mergeable := mergeable.(type) {
+	case *tRule:
+		rule := mergeable.origin()
+		if visitingSet[rule] || mergeable.expression == nil {
+			return sampleInfinite
+		}
+		visitingSet[rule] = true
+		defer delete(visitingSet, rule)
+		return sampler.mergeHeight(mergeable.expression, visitingSet)
+	case tMap:
+		height := 0
+		for _, value := range mergeable.form.propertyMap {
+			height = maxInt(height, sampler.height(value))
+		}
+		for _, merged := range mergeable.form.mergeList {
+			height = maxInt(height, sampler.mergeHeight(merged, visitingSet))
+		}
+		if min, _ := sizingBound(mergeable.sizing); min > len(mergeable.form.propertyMap)+len(mergeable.form.optionalMap) {
+			height = maxInt(height, sampler.heightOrInfinite(mergeable.form.mapOf.key))
+			height = maxInt(height, sampler.heightOrInfinite(mergeable.form.mapOf.value))
+		}
+		return height
+	case tOneOf:
+		height := sampleInfinite
+		for _, option := range mergeable.optionList {
+			height = minInt(height, sampler.mergeHeight(option, visitingSet))
+		}
+		return height
+	case tSwitch:
+		height := sampleInfinite
+		for _, caseName := range mergeable.caseNameList {
+			height = minInt(height, sampler.mergeHeight(mergeable.caseMap[caseName], visitingSet))
+		}
+		return height
+	}
+
+	return sampleInfinite
+}
+
+//
+// Sample
+//
+
+// sample generates a node matching the expression, at the given depth
+func (sampler *tSampler) sample(expression tExpression, depth int) (*yaml.Node, error) {
+	node, err := sampler.sampleExpression(expression, depth)
+
+	// the innermost expression is recorded first, and kept
+	if err == nil && sampler.originMap != nil {
+		if _, found := sampler.originMap[node]; !found {
+			sampler.originMap[node] = expression
+		}
+	}
+
+	return node, err
+}
+
+func (sampler *tSampler) sampleExpression(expression tExpression, depth int) (*yaml.Node, error) {
+	switch expression := expression.(type) {
+	case *tRule:
+		if expression.lidyMatcher != nil {
+			return sampler.sampleDefault(expression.ruleName), nil
+		}
+		return sampler.sample(expression.expression, depth)
+	case tMap:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		err := sampler.sampleMapInto(node, expression, depth, map[string]bool{})
+		return node, err
+	case tList:
+		return sampler.sampleList(expression, depth)
+	case tOneOf:
+		index, err := sampler.choose(expression.optionList, depth, sampler.height)
+		if err != nil {
+			return nil, err
+		}
+		return sampler.sample(expression.optionList[index], depth)
+	case tSwitch:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		err := sampler.mergeInto(node, expression, depth, map[string]bool{})
+		return node, err
+	case tIn:
+		return sampler.sampleIn(expression), nil
+	case tRegex:
+		value, err := sampler.sampleRegex(expression)
+		if err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	case tRange:
+		return sampler.sampleRange(expression)
+	}
+
+	return nil, fmt.Errorf("cannot sample the expression %s", expression.name())
+}
+
+// choose picks the index of a random option among the ones which accept
+// finite documents, or among the shallowest ones, from the maximum depth on
+func (sampler *tSampler) choose(optionList []tExpression, depth int, height func(tExpression) int) (int, error) {
+	candidateList := []int{}
+	best := sampleInfinite
+	for k, option := range optionList {
+		optionHeight := height(option)
+		switch {
+		case optionHeight == sampleInfinite:
+		case depth < sampler.maxDepth:
+			candidateList = append(candidateList, k)
+		case optionHeight < best:
+			best = optionHeight
+			candidateList = []int{k}
+		case optionHeight == best:
+			candidateList = append(candidateList, k)
+		}
+	}
+
+	if len(candidateList) == 0 {
+		return 0, fmt.Errorf("no option of the _oneOf accepts a finite document")
+	}
+
+	return candidateList[sampler.random.Intn(len(candidateList))], nil
+}
+
+// sampleMapInto adds the entries of a map checker to the node; keySet lists
+// the keys already present, added by an enclosing map or a previous merge
+func (sampler *tSampler) sampleMapInto(node *yaml.Node, mapChecker tMap, depth int, keySet map[string]bool) error {
+	form := mapChecker.form
+	deep := depth >= sampler.maxDepth
+
+	addEntry := func(key string, value tExpression) error {
+		valueNode, err := sampler.sample(value, depth+1)
+		if err != nil {
+			return err
+		}
+		keySet[key] = true
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+		return nil
+	}
+
+	for _, key := range sortedKeyList(form.propertyMap) {
+		if keySet[key] {
+			continue
+		}
+		if err := addEntry(key, form.propertyMap[key]); err != nil {
+			return err
+		}
+	}
+
+	for _, mergeable := range form.mergeList {
+		if err := sampler.mergeInto(node, mergeable, depth, keySet); err != nil {
+			return err
+		}
+	}
+
+	min, max := sizingBound(mapChecker.sizing)
+
+	optionalList := []string{}
+	for _, key := range sortedKeyList(form.optionalMap) {
+		if !keySet[key] && sampler.height(form.optionalMap[key]) != sampleInfinite {
+			optionalList = append(optionalList, key)
+		}
+	}
+	sampler.random.Shuffle(len(optionalList), func(i, j int) {
+		optionalList[i], optionalList[j] = optionalList[j], optionalList[i]
+	})
+
+	// the facultative properties are added at random, and then as needed to
+	// reach the minimum size, if there is no _mapOf to do it
+	for k, key := range optionalList {
+		count := len(node.Content) / 2
+		if count >= max {
+			break
+		}
+		wanted := !deep && sampler.random.Intn(2) == 0
+		needed := count < min && (form.mapOf.key == nil || k >= len(optionalList))
+		if wanted || needed {
+			if err := addEntry(key, form.optionalMap[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if form.mapOf.key != nil {
+		count := len(node.Content) / 2
+		extra := 0
+		if !deep {
+			extra = sampler.random.Intn(extraLimit(max-count, 2) + 1)
+		}
+		entryCount := minInt(maxInt(extra, min-count), max-count)
+
+		for k, attempt := 0, 0; k < entryCount && attempt < sampleAttemptCount*entryCount; attempt++ {
+			keyNode, err := sampler.sample(form.mapOf.key, depth+1)
+			if err != nil {
+				return err
+			}
+			// only the scalar keys can be compared to the other keys
+			if keyNode.Kind != yaml.ScalarNode || keySet[keyNode.Value] {
+				continue
+			}
+
+			valueNode, err := sampler.sample(form.mapOf.value, depth+1)
+			if err != nil {
+				return err
+			}
+
+			keySet[keyNode.Value] = true
+			node.Content = append(node.Content, keyNode, valueNode)
+			k++
+		}
+	}
+
+	count := len(node.Content) / 2
+	if count < min || count > max {
+		return fmt.Errorf("cannot sample a map of %s entries", sizingText(min, max))
+	}
+
+	return nil
+}
+
+// mergeInto adds the entries of a mergeable expression to the node
+func (sampler *tSampler) mergeInto(node *yaml.Node, mergeable tExpression, depth int, keySet map[string]bool) error {
+	mergeHeight := func(expression tExpression) int {
+		return sampler.mergeHeight(expression, map[*tRule]bool{})
+	}
+
+	switch mergeable := mergeable.(type) {
+	case *tRule:
+		return sampler.mergeInto(node, mergeable.expression, depth, keySet)
+	case tMap:
+		return sampler.sampleMapInto(node, mergeable, depth, keySet)
+	case tOneOf:
+		index, err := sampler.choose(mergeable.optionList, depth, mergeHeight)
+		if err != nil {
+			return err
+		}
+		return sampler.mergeInto(node, mergeable.optionList[index], depth, keySet)
+	case tSwitch:
+		caseList := []tExpression{}
+		for _, caseName := range mergeable.caseNameList {
+			caseList = append(caseList, mergeable.caseMap[caseName])
+		}
+		index, err := sampler.choose(caseList, depth, mergeHeight)
+		if err != nil {
+			return err
+		}
+
+		caseName := mergeable.caseNameList[index]
+		keySet[mergeable.key] = true
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: mergeable.key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: caseName},
+		)
+
+		return sampler.mergeInto(node, caseList[index], depth, keySet)
+	}
+
+	return fmt.Errorf("cannot sample the merged expression %s", mergeable.name())
+}
+
+func (sampler *tSampler) sampleList(list tList, depth int) (*yaml.Node, error) {
+	form := list.form
+	deep := depth >= sampler.maxDepth
+	min, max := sizingBound(list.sizing)
+
+	optionalCount, extraCount := 0, 0
+	if !deep {
+		optionalCount = sampler.random.Intn(len(form.optionalList) + 1)
+		if form.listOf != nil && sampler.height(form.listOf) != sampleInfinite {
+			extraCount = sampler.random.Intn(extraLimit(max-len(form.list)-len(form.optionalList), 3) + 1)
+		}
+	}
+	// the entries of _listOf come after all the facultative entries
+	if extraCount > 0 {
+		optionalCount = len(form.optionalList)
+	}
+
+	// reaching the minimum size
+	for len(form.list)+optionalCount+extraCount < min {
+		if optionalCount < len(form.optionalList) {
+			optionalCount++
+		} else if form.listOf != nil {
+			extraCount++
+		} else {
+			break
+		}
+	}
+
+	// keeping to the maximum size
+	for len(form.list)+optionalCount+extraCount > max && optionalCount+extraCount > 0 {
+		if extraCount > 0 {
+			extraCount--
+		} else {
+			optionalCount--
+		}
+	}
+
+	count := len(form.list) + optionalCount + extraCount
+	if count < min || count > max {
+		return nil, fmt.Errorf("cannot sample a list of %s entries", sizingText(min, max))
+	}
+
+	itemList := append(append([]tExpression{}, form.list...), form.optionalList[:optionalCount]...)
+	for k := 0; k < extraCount; k++ {
+		itemList = append(itemList, form.listOf)
+	}
+
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, item := range itemList {
+		itemNode, err := sampler.sample(item, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, itemNode)
+	}
+
+	return node, nil
+}
+
+func (sampler *tSampler) sampleDefault(ruleName string) *yaml.Node {
+	random := sampler.random
+
+	switch ruleName {
+	case "int":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(random.Intn(1000))}
+	case "float":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: fmt.Sprintf("%d.%02d", random.Intn(1000), random.Intn(100))}
+	case "boolean":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(random.Intn(2) == 0)}
+	case "nullType":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case "timestamp":
+		timestamp := time.Date(2000+random.Intn(30), time.Month(1+random.Intn(12)), 1+random.Intn(28), random.Intn(24), random.Intn(60), random.Intn(60), 0, time.UTC)
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: timestamp.Format(time.RFC3339)}
+	case "binary":
+		content := make([]byte, 3*(1+random.Intn(4)))
+		random.Read(content)
+		// the base64 alphabet accepted by lidy is the URL-safe one
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: base64.URLEncoding.EncodeToString(content)}
+	}
+
+	// string, any
+	word := make([]byte, 3+random.Intn(6))
+	for k := range word {
+		word[k] = byte('a' + random.Intn(26))
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(word)}
+}
+
+func (sampler *tSampler) sampleIn(in tIn) *yaml.Node {
+	tagList := []string{}
+	for tag := range in.valueMap {
+		tagList = append(tagList, tag)
+	}
+	sort.Strings(tagList)
+
+	type tValue struct{ tag, value string }
+	valueList := []tValue{}
+	for _, tag := range tagList {
+		for _, value := range in.valueMap[tag] {
+			valueList = append(valueList, tValue{tag, value})
+		}
+	}
+
+	chosen := valueList[sampler.random.Intn(len(valueList))]
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: chosen.tag, Value: chosen.value}
+}
+
+func (sampler *tSampler) sampleRange(rng tRange) (*yaml.Node, error) {
+	if rng.kind == "int" {
+		// the bounds are computed in 64-bit integers, as the floats lose
+		// precision; the exclusive bounds start from the next float
+		low, high := int64(math.MinInt64), int64(math.MaxInt64)
+		if rng.min.present {
+			bound := rng.min.value
+			if !rng.min.inclusive {
+				bound = math.Nextafter(bound, math.Inf(1))
+			}
+			low = clampInt(math.Ceil(bound))
+		}
+		if rng.max.present {
+			bound := rng.max.value
+			if !rng.max.inclusive {
+				bound = math.Nextafter(bound, math.Inf(-1))
+			}
+			high = clampInt(math.Floor(bound))
+		}
+		if !rng.min.present {
+			low = math.MinInt64
+			if high > math.MinInt64+sampleRangeWidth {
+				low = high - sampleRangeWidth
+			}
+		}
+		if !rng.max.present {
+			high = math.MaxInt64
+			if low < math.MaxInt64-sampleRangeWidth {
+				high = low + sampleRangeWidth
+			}
+		}
+		if low > high {
+			return nil, fmt.Errorf("cannot sample the empty range %s", rng.rangeString)
+		}
+		// the bounds lying beyond the 64-bit integers, e.g. `(1e19 <= int)`
+		if !rng.min.accept(float64(low), true) || !rng.max.accept(float64(high), false) {
+			return nil, fmt.Errorf("cannot sample the range %s with 64-bit integers", rng.rangeString)
+		}
+		value := low + int64(sampler.randomOffset(uint64(high)-uint64(low)))
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(value, 10)}, nil
+	}
+
+	lower, upper := rng.min.value, rng.max.value
+	if !rng.min.present {
+		lower = upper - sampleRangeWidth
+	}
+	if !rng.max.present {
+		upper = lower + sampleRangeWidth
+	}
+
+	value := lower + sampler.random.Float64()*(upper-lower)
+	if rounded := math.Round(value*100) / 100; rng.min.accept(rounded, true) && rng.max.accept(rounded, false) {
+		value = rounded
+	}
+	if !rng.min.accept(value, true) || !rng.max.accept(value, false) {
+		value = (lower + upper) / 2
+		if !rng.min.accept(value, true) || !rng.max.accept(value, false) {
+			return nil, fmt.Errorf("cannot sample the empty range %s", rng.rangeString)
+		}
+	}
+
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: floatText(value)}, nil
+}
+
+// floatText writes the number so that YAML reads it as a float
+func floatText(value float64) string {
+	text := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.ContainsAny(text, ".eE") {
+		text += ".0"
+	}
+	return text
+}
+
+// clampInt converts a whole number to an int64, saturating at the limits
+func clampInt(value float64) int64 {
+	switch {
+	case value >= math.MaxInt64:
+		return math.MaxInt64
+	case value <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(value)
+}
+
+// randomOffset draws an integer from 0 to span, included. The span may exceed
+// the limit of Int63n, up to the full range of the 64-bit integers
+func (sampler *tSampler) randomOffset(span uint64) uint64 {
+	switch {
+	case span < math.MaxInt32:
+		return uint64(sampler.random.Intn(int(span) + 1))
+	case span < math.MaxInt64:
+		return uint64(sampler.random.Int63n(int64(span) + 1))
+	}
+
+	// drawn again until it falls within the span, which is more than half of
+	// the draws
+	for {
+		if offset := sampler.random.Uint64(); offset <= span {
+			return offset
+		}
+	}
+}
+
+//
+// Regex
+//
+
+// sampleRegex generates a string matching the regex, by walking its syntax
+// tree. The assertions, such as word boundaries, are ignored, so the string
+// is checked, and generated again if needed
+func (sampler *tSampler) sampleRegex(regex tRegex) (string, error) {
+	tree, err := syntax.Parse(regex.regexString, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	tree = tree.Simplify()
+
+	for attempt := 0; attempt < sampleAttemptCount; attempt++ {
+		builder := strings.Builder{}
+		if sampler.regexNode(tree, &builder) && regex.regex.MatchString(builder.String()) {
+			return builder.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot sample a string matching the regex /%s/", regex.regexString)
+}
+
+// regexNode writes a string matching the syntax node, and returns false if
+// it cannot match anything
+func (sampler *tSampler) regexNode(tree *syntax.Regexp, builder *strings.Builder) bool {
+	random := sampler.random
+
+	repeat := func(min int, max int) bool {
+		if max < 0 {
+			max = min + 3
+		}
+		count := min + random.Intn(max-min+1)
+		for k := 0; k < count; k++ {
+			if !sampler.regexNode(tree.Sub[0], builder) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch tree.Op {
+	case syntax.OpNoMatch:
+		return false
+	case syntax.OpLiteral:
+		builder.WriteString(string(tree.Rune))
+	case syntax.OpCharClass:
+		character, ok := sampler.regexCharacter(tree.Rune)
+		if !ok {
+			return false
+		}
+		builder.WriteRune(character)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		builder.WriteRune(rune('a' + random.Intn(26)))
+	case syntax.OpCapture:
+		return sampler.regexNode(tree.Sub[0], builder)
+	case syntax.OpStar:
+		return repeat(0, 3)
+	case syntax.OpPlus:
+		return repeat(1, 3)
+	case syntax.OpQuest:
+		return repeat(0, 1)
+	case syntax.OpRepeat:
+		return repeat(tree.Min, tree.Max)
+	case syntax.OpConcat:
+		for _, sub := range tree.Sub {
+			if !sampler.regexNode(sub, builder) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		return sampler.regexNode(tree.Sub[random.Intn(len(tree.Sub))], builder)
+	}
+
+	// OpEmptyMatch and the assertions, such as OpBeginText, write nothing
+	return true
+}
+
+// regexCharacter picks a character in the ranges of a character class,
+// preferably a letter or a digit, or else a printable ASCII character
+func (sampler *tSampler) regexCharacter(rangeList []rune) (rune, bool) {
+	for _, preferredList := range [][]rune{{'0', '9', 'A', 'Z', 'a', 'z'}, {' ', '~'}, {0, math.MaxInt32}} {
+		intersectionList := []rune{}
+		total := 0
+		for k := 0; k+1 < len(rangeList); k += 2 {
+			for j := 0; j+1 < len(preferredList); j += 2 {
+				low, high := maxRune(rangeList[k], preferredList[j]), minRune(rangeList[k+1], preferredList[j+1])
+				if low <= high {
+					intersectionList = append(intersectionList, low, high)
+					total += int(high-low) + 1
+				}
+			}
+		}
+
+		if total == 0 {
+			continue
+		}
+
+		index := sampler.random.Intn(total)
+		for k := 0; k+1 < len(intersectionList); k += 2 {
+			size := int(intersectionList[k+1]-intersectionList[k]) + 1
+			if index < size {
+				return intersectionList[k] + rune(index), true
+			}
+			index -= size
+		}
+	}
+
+	return 0, false
+}
+
+//
+// Helpers
+//
+
+// sizingBound returns the minimum and the maximum number of entries of a
+// container; the maximum is sampleInfinite if there is none
+func sizingBound(sizing tSizing) (int, int) {
+	switch sizing := sizing.(type) {
+	case tSizingMin:
+		return sizing.min, sampleInfinite
+	case tSizingMax:
+		return 0, sizing.max
+	case tSizingMinMax:
+		return sizing.min, sizing.max
+	case tSizingNb:
+		return sizing.nb, sizing.nb
+	}
+	return 0, sampleInfinite
+}
+
+// extraLimit is the maximum number of _listOf or _mapOf entries to add at
+// random, given the room left by the _max of the container
+func extraLimit(room int, unboundedLimit int) int {
+	if room < 0 {
+		return 0
+	}
+	if room > sampleExtraLimit {
+		return unboundedLimit
+	}
+	return room
+}
+
+func sizingText(min int, max int) string {
+	if max == sampleInfinite {
+		return fmt.Sprintf("at least %d", min)
+	}
+	return fmt.Sprintf("%d to %d", min, max)
+}
+
+func sortedKeyList(expressionMap map[string]tExpression) []string {
+	keyList := make([]string, 0, len(expressionMap))
+	for key := range expressionMap {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	return keyList
+}
+
+func increment(height int) int {
+	if height == sampleInfinite {
+		return height
+	}
+	return height + 1
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minRune(a rune, b rune) rune {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxRune(a rune, b rune) rune {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,401 @@
+package lidy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lidySchemaModel.go
+//
+// The read-only model of a compiled schema: the rules, and the typed
+// expressions, implemented by the types of lidySchemaType.go
+
+// Rule -- a rule of the schema. Its position is that of its name, in the
+// schema or in the imported file declaring it; the lidy predefined rules have
+// no position
+type Rule interface {
+	Position
+	// Name the name of the rule, prefixed with the import aliases for the imported rules, e.g. `net.port`
+	Name() string
+	// IsPredefined true for the lidy predefined rules, such as `string` or `any`
+	IsPredefined() bool
+	// ExportName the builder name of the exported rules, `name:` or `name::Builder`, or ""
+	ExportName() string
+	// Expression the expression defining the rule; nil for the predefined rules
+	Expression() Expression
+	zzRule()
+}
+
+// Expression -- a node of the schema, and its annotation. It is one of
+// RuleReference, MapExpression, ListExpression, OneOfExpression,
+// SwitchExpression, InExpression, RegexExpression and RangeExpression
+type Expression interface {
+	Position
+	Annotation() Annotation
+	zzExpression()
+}
+
+// RuleReference -- a rule identifier, or the `_rule` form
+type RuleReference interface {
+	Expression
+	Rule() Rule
+}
+
+// MapExpression -- a map checker: `_map`, `_mapFacultative`, `_mapOf`,
+// `_merge`, `_default` and the sizing keywords
+type MapExpression interface {
+	Expression
+	// Properties the properties of `_map`, sorted by name
+	Properties() []Property
+	// FacultativeProperties the properties of `_mapFacultative`, sorted by name
+	FacultativeProperties() []Property
+	// MapOf the key and value expressions of `_mapOf`, or nil
+	MapOf() (key Expression, value Expression)
+	// MergeList the expressions of `_merge`
+	MergeList() []Expression
+	Sizing() Sizing
+}
+
+// Property -- a property of a map checker
+type Property struct {
+	Name       string
+	Expression Expression
+	// Default the `_default` value of a facultative property, or nil
+	Default *yaml.Node
+}
+
+// ListExpression -- a list checker: `_list`, `_listFacultative`, `_listOf`
+// and the sizing keywords
+type ListExpression interface {
+	Expression
+	// Items the expressions of `_list`
+	Items() []Expression
+	// FacultativeItems the expressions of `_listFacultative`
+	FacultativeItems() []Expression
+	// ListOf the expression of `_listOf`, or nil
+	ListOf() Expression
+	Sizing() Sizing
+}
+
+// Sizing -- the `_min`, `_max` and `_nb` of a container; `_nb` sets both bounds
+type Sizing struct {
+	// Min the minimum number of entries, 0 if there is none
+	Min int
+	// Max the maximum number of entries, if HasMax
+	Max    int
+	HasMax bool
+}
+
+// OneOfExpression -- the `_oneOf` checker
+type OneOfExpression interface {
+	Expression
+	Options() []Expression
+}
+
+// SwitchExpression -- the `_switch` checker
+type SwitchExpression interface {
+	Expression
+	// Discriminator the property whose value selects the case
+	Discriminator() string
+	// Cases the cases, sorted by value
+	Cases() []SwitchCase
+}
+
+// SwitchCase -- a case of a `_switch`
+type SwitchCase struct {
+	Value      string
+	Expression Expression
+}
+
+// InExpression -- the `_in` checker
+type InExpression interface {
+	Expression
+	// Values the accepted scalars, sorted by tag
+	Values() []InValue
+}
+
+// InValue -- a scalar accepted by `_in`
+type InValue struct {
+	// Tag the YAML tag, e.g. `!!str` or `!!int`
+	Tag   string
+	Value string
+}
+
+// RegexExpression -- the `_regex` checker
+type RegexExpression interface {
+	Expression
+	// Regex the regular expression, as written in the schema
+	Regex() string
+	Regexp() *regexp.Regexp
+}
+
+// RangeExpression -- the `_range` checker
+type RangeExpression interface {
+	Expression
+	// Range the range, as written in the schema, e.g. `(1 <= int <= 65535)`
+	Range() string
+	// Kind `int` or `float`
+	Kind() string
+	Min() RangeBound
+	Max() RangeBound
+}
+
+// RangeBound -- a bound of a `_range`
+type RangeBound struct {
+	// Present false if the range is unbounded on that side
+	Present   bool
+	Inclusive bool
+	Value     float64
+}
+
+// Rules -- process the schema if needed, and return its user rules,
+// including the imported ones, sorted by name
+func (p *tParser) Rules() ([]Rule, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	ruleNameList := []string{}
+	for ruleName, rule := range p.schema.ruleMap {
+		if !rule.IsPredefined() && rule.ruleName == ruleName {
+			ruleNameList = append(ruleNameList, ruleName)
+		}
+	}
+	sort.Strings(ruleNameList)
+
+	ruleList := make([]Rule, len(ruleNameList))
+	for k, ruleName := range ruleNameList {
+		ruleList[k] = p.schema.ruleMap[ruleName]
+	}
+
+	return ruleList, nil
+}
+
+// Rule -- process the schema if needed, and return a rule of the schema,
+// which may be a predefined rule
+func (p *tParser) Rule(ruleName string) (Rule, []error) {
+	erl := p.parseSchema()
+	if len(erl) > 0 {
+		return nil, erl
+	}
+
+	rule, ruleFound := p.schema.ruleMap[ruleName]
+	if !ruleFound {
+		return nil, []error{fmt.Errorf("Could not find rule '%s' in grammar", ruleName)}
+	}
+
+	return rule, nil
+}
+
+// publicExpression converts an expression, which may be nil
+func publicExpression(expression tExpression) Expression {
+	if expression == nil {
+		return nil
+	}
+	return expression
+}
+
+func publicExpressionList(expressionList []tExpression) []Expression {
+	publicList := make([]Expression, len(expressionList))
+	for k, expression := range expressionList {
+		publicList[k] = expression
+	}
+	return publicList
+}
+
+func publicSizing(sizing tSizing) Sizing {
+	switch sizing := sizing.(type) {
+	case tSizingMin:
+		return Sizing{Min: sizing.min}
+	case tSizingMax:
+		return Sizing{Max: sizing.max, HasMax: true}
+	case tSizingMinMax:
+		return Sizing{Min: sizing.min, Max: sizing.max, HasMax: true}
+	case tSizingNb:
+		return Sizing{Min: sizing.nb, Max: sizing.nb, HasMax: true}
+	}
+	return Sizing{}
+}
+
+// Rule
+
+func (rule *tRule) Name() string {
+	return rule.ruleName
+}
+
+func (rule *tRule) IsPredefined() bool {
+	return rule._filename == ""
+}
+
+func (rule *tRule) ExportName() string {
+	return rule.exportName
+}
+
+func (rule *tRule) Expression() Expression {
+	if rule.IsPredefined() {
+		return nil
+	}
+	return publicExpression(rule.expression)
+}
+
+// Rule is the rule that the reference refers to
+func (rule *tRule) Rule() Rule {
+	return rule.origin()
+}
+
+func (*tRule) zzRule() {}
+
+func (*tRule) zzExpression() {}
+
+// Map
+
+func (mapChecker tMap) Properties() []Property {
+	return propertyList(mapChecker.form.propertyMap, nil)
+}
+
+func (mapChecker tMap) FacultativeProperties() []Property {
+	return propertyList(mapChecker.form.optionalMap, mapChecker.form.defaultList)
+}
+
+// propertyList lists the properties of a map, sorted by name, with their default
+func propertyList(propertyMap map[string]tExpression, defaultList []tDefault) []Property {
+	defaultMap := map[string]*yaml.Node{}
+	for _, propertyDefault := range defaultList {
+		node := propertyDefault.node
+		defaultMap[propertyDefault.property] = &node
+	}
+
+	keyList := make([]string, 0, len(propertyMap))
+	for key := range propertyMap {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	list := make([]Property, len(keyList))
+	for k, key := range keyList {
+		list[k] = Property{Name: key, Expression: propertyMap[key], Default: defaultMap[key]}
+	}
+	return list
+}
+
+func (mapChecker tMap) MapOf() (Expression, Expression) {
+	return publicExpression(mapChecker.form.mapOf.key), publicExpression(mapChecker.form.mapOf.value)
+}
+
+func (mapChecker tMap) MergeList() []Expression {
+	mergeList := make([]Expression, len(mapChecker.form.mergeList))
+	for k, mergeable := range mapChecker.form.mergeList {
+		mergeList[k] = mergeable
+	}
+	return mergeList
+}
+
+func (mapChecker tMap) Sizing() Sizing {
+	return publicSizing(mapChecker.sizing)
+}
+
+func (tMap) zzExpression() {}
+
+// List
+
+func (list tList) Items() []Expression {
+	return publicExpressionList(list.form.list)
+}
+
+func (list tList) FacultativeItems() []Expression {
+	return publicExpressionList(list.form.optionalList)
+}
+
+func (list tList) ListOf() Expression {
+	return publicExpression(list.form.listOf)
+}
+
+func (list tList) Sizing() Sizing {
+	return publicSizing(list.sizing)
+}
+
+func (tList) zzExpression() {}
+
+// OneOf
+
+func (oneOf tOneOf) Options() []Expression {
+	return publicExpressionList(oneOf.optionList)
+}
+
+func (tOneOf) zzExpression() {}
+
+// Switch
+
+func (switchChecker tSwitch) Discriminator() string {
+	return switchChecker.key
+}
+
+func (switchChecker tSwitch) Cases() []SwitchCase {
+	caseList := make([]SwitchCase, len(switchChecker.caseNameList))
+	for k, caseName := range switchChecker.caseNameList {
+		caseList[k] = SwitchCase{Value: caseName, Expression: switchChecker.caseMap[caseName]}
+	}
+	return caseList
+}
+
+func (tSwitch) zzExpression() {}
+
+// In
+
+func (in tIn) Values() []InValue {
+	tagList := make([]string, 0, len(in.valueMap))
+	for tag := range in.valueMap {
+		tagList = append(tagList, tag)
+	}
+	sort.Strings(tagList)
+
+	valueList := []InValue{}
+	for _, tag := range tagList {
+		for _, value := range in.valueMap[tag] {
+			valueList = append(valueList, InValue{Tag: tag, Value: value})
+		}
+	}
+	return valueList
+}
+
+func (tIn) zzExpression() {}
+
+// Regex
+
+func (regex tRegex) Regex() string {
+	return regex.regexString
+}
+
+func (regex tRegex) Regexp() *regexp.Regexp {
+	return regex.regex
+}
+
+func (tRegex) zzExpression() {}
+
+// Range
+
+func (rng tRange) Range() string {
+	return rng.rangeString
+}
+
+func (rng tRange) Kind() string {
+	return rng.kind
+}
+
+func (rng tRange) Min() RangeBound {
+	return publicRangeBound(rng.min)
+}
+
+func (rng tRange) Max() RangeBound {
+	return publicRangeBound(rng.max)
+}
+
+func publicRangeBound(bound tRangeBound) RangeBound {
+	return RangeBound{Present: bound.present, Inclusive: bound.inclusive, Value: bound.value}
+}
+
+func (tRange) zzExpression() {}
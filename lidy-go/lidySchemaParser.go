@@ -3,6 +3,7 @@ package lidy
 import (
 	"fmt"
 	"log"
+	"path"
 	"regexp"
 	"strings"
 
@@ -20,77 +21,171 @@ var regexIdentifierDeclaration = *regexp.MustCompile("^" +
 	")?)?$",
 )
 
+var regexImportAlias = *regexp.MustCompile("^" +
+	"[a-zA-Z][a-zA-Z0-9_]*$",
+)
+
 // lidySchemaParser.go
 // implement methods tSchemaParser
 
 // tSchemaParser.hollowSchema parses the outline of a lidy schema document
 // it fills the `ruleMap` with `tRule` instances whose expression is uncomputed. It errors if the yaml node isn't a map.
+// The documents imported through `_import` are loaded at this step too.
 func (sp tSchemaParser) hollowSchema(documentNode yaml.Node) (tSchema, []error) {
 	// Note: The parsing is done in two steps
 	// - First create tRule{} entities for all rules of the document (THIS STEP)
 	// - Second, explore each rule value node, to populate the `expression` field of the rule node.
 	// This approach allows to substitute identifiers for rule entities while exploring the schema.
-	root, erl := getRoot(documentNode)
+	schema := tSchema{
+		ruleMap: make(map[string]*tRule),
+	}
+
+	// lidy default rules
+	for ruleName, rule := range sp.lidyDefaultRuleMap {
+		schema.ruleMap[ruleName] = rule
+	}
 
+	sp.schema = schema
+	sp.currentFilename = sp.name
+
+	erl := sp.hollowDocument(documentNode, []string{sp.name})
 	if len(erl) > 0 {
 		return tSchema{}, erl
 	}
 
-	if root.Kind != yaml.MappingNode {
-		return tSchema{}, sp.schemaError(*root, "a lidy schema document (kind map)")
+	return schema, nil
+}
+
+// hollowDocument adds the rules of a schema document, and of the documents
+// it imports, to the ruleMap of the schema. The rules are named with the
+// current prefix. importStack lists the files being imported, to detect cycles.
+func (sp tSchemaParser) hollowDocument(documentNode yaml.Node, importStack []string) []error {
+	root, erl := getRoot(documentNode)
+
+	if len(erl) > 0 {
+		return erl
 	}
 
-	schema := tSchema{
-		ruleMap: make(map[string]*tRule),
+	if root.Kind != yaml.MappingNode {
+		return sp.schemaError(*root, ErrorCodeKind, "a lidy schema document (kind map)")
 	}
 
 	errList := errorlist.List{}
 
-	// lidy default rules
-	for ruleName, rule := range sp.lidyDefaultRuleMap {
-		schema.ruleMap[ruleName] = rule
-	}
-
-	// user rules
 	for k := 1; k < len(root.Content); k += 2 {
-		rule, err := sp.createRule(*root.Content[k-1], *root.Content[k])
+		keyNode := *root.Content[k-1]
+
+		// imports
+		if keyNode.Tag == "!!str" && keyNode.Value == "_import" {
+			errList.Push(sp.at("_import").importDocumentList(*root.Content[k], importStack))
+			continue
+		}
+
+		// user rules
+		rule, err := sp.createRule(keyNode, *root.Content[k])
 		if err != nil {
-			return tSchema{}, err
+			return err
 		}
-		if _, present := schema.ruleMap[rule.ruleName]; present {
-			message := "no repeated rule declaration"
 
-			if _, isDefaultRule := sp.lidyDefaultRuleMap[rule.ruleName]; isDefaultRule {
-				message = "no redeclaration of lidy default rule"
-			}
+		localName := strings.TrimPrefix(rule.ruleName, sp.currentPrefix)
+		if _, isDefaultRule := sp.lidyDefaultRuleMap[localName]; isDefaultRule {
+			errList.Push(sp.at(rule._keyNode.Value).schemaError(keyNode, ErrorCodeDuplicateRule, "no redeclaration of lidy default rule"))
+		} else if _, present := sp.schema.ruleMap[rule.ruleName]; present {
+			errList.Push(sp.at(rule._keyNode.Value).schemaError(keyNode, ErrorCodeDuplicateRule, "no repeated rule declaration"))
+		}
+		sp.schema.ruleMap[rule.ruleName] = rule
+	}
+
+	return errList.ConcatError()
+}
+
+// importDocumentList loads the documents of an `_import` map, whose keys are
+// aliases and whose values are filenames, relative to the importing file
+func (sp tSchemaParser) importDocumentList(node yaml.Node, importStack []string) []error {
+	if node.Kind != yaml.MappingNode {
+		return sp.schemaError(node, ErrorCodeKind, "a YAML map, of aliases to filenames")
+	}
+
+	errList := errorlist.List{}
+
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		aliasNode := *node.Content[k]
+		filenameNode := *node.Content[k+1]
+
+		importSp := sp.at(aliasNode.Value)
+
+		if aliasNode.Tag != "!!str" || !regexImportAlias.MatchString(aliasNode.Value) {
+			errList.Push(importSp.schemaError(aliasNode, ErrorCodeImport, "a valid import alias (a-zA-Z)(a-zA-Z0-9_)*"))
+			continue
+		}
 
-			errList.Push(sp.schemaError(*root.Content[k-1], message))
+		if filenameNode.Tag != "!!str" {
+			errList.Push(importSp.schemaError(filenameNode, ErrorCodeImport, "a YAML string (the name of the file to import)"))
+			continue
 		}
-		schema.ruleMap[rule.ruleName] = rule
+
+		errList.Push(importSp.importDocument(aliasNode.Value, filenameNode, importStack))
 	}
 
-	return schema, nil
+	return errList.ConcatError()
+}
+
+// importDocument loads one imported document, and adds its rules to the
+// schema, prefixed with the alias
+func (sp tSchemaParser) importDocument(alias string, filenameNode yaml.Node, importStack []string) []error {
+	if sp.loader == nil {
+		return sp.schemaError(filenameNode, ErrorCodeImport, "a Loader to be set on the parser, to import files")
+	}
+
+	filename := path.Join(path.Dir(sp.currentFilename), filenameNode.Value)
+
+	for k, importer := range importStack {
+		if importer == filename {
+			cycle := strings.Join(append(importStack[k:len(importStack):len(importStack)], filename), " -> ")
+			return sp.schemaError(filenameNode, ErrorCodeImport, "no import cycle ("+cycle+")")
+		}
+	}
+
+	content, err := sp.loader(filename)
+	if err != nil {
+		return sp.schemaError(filenameNode, ErrorCodeImport, fmt.Sprintf("a file that can be loaded (%s)", err))
+	}
+
+	file := tFile{
+		name:    filename,
+		content: content,
+	}
+
+	err = file.Yaml()
+	if err != nil {
+		return sp.schemaError(filenameNode, ErrorCodeImport, fmt.Sprintf("a valid YAML file (%s: %s)", filename, err))
+	}
+
+	sp.currentFilename = filename
+	sp.currentPrefix = sp.currentPrefix + alias + "."
+	sp.schemaPath = nil
+
+	return sp.hollowDocument(file.yaml, append(importStack[:len(importStack):len(importStack)], filename))
 }
 
 // Create an unparsed rule.
 // This function parses the name of the rule to establish the local name and exported name, if the rule is exported
 func (sp tSchemaParser) createRule(key yaml.Node, value yaml.Node) (*tRule, []error) {
 	if key.Tag != "!!str" {
-		return nil, sp.schemaError(key, "a YAML string (an identifier declaration)")
+		return nil, sp.at(key.Value).schemaError(key, ErrorCodeIdentifier, "a YAML string (an identifier declaration)")
 	}
 
 	if !regexIdentifierDeclaration.MatchString(key.Value) {
-		return nil, sp.schemaError(key, "a valid identifier declaration")
+		return nil, sp.at(key.Value).schemaError(key, ErrorCodeIdentifier, "a valid identifier declaration")
 	}
 
 	nameSlice := strings.SplitN(key.Value, ":", 3)
 
-	localName := nameSlice[0]
+	localName := sp.currentPrefix + nameSlice[0]
 	var builder Builder
+	var exportName string
 	if strings.Contains(key.Value, ":") {
-		var exportName string
-
-		exportName = nameSlice[0]
+		exportName = localName
 
 		if nameSlice[1] != "" {
 			log.Fatalf("Internal error with rule name parsing of `%s`, %s", key.Value, pleaseReport)
@@ -102,14 +197,21 @@ func (sp tSchemaParser) createRule(key yaml.Node, value yaml.Node) (*tRule, []er
 			// exportName = nameSlice[3]
 			// it can't be 3, it must be 2:
 			//
-			exportName = nameSlice[2]
+			exportName = sp.currentPrefix + nameSlice[2]
 		}
 
 		builder, _ = sp.builderMap[exportName]
 	}
 
 	return &tRule{
+		tSchemaNode: tSchemaNode{
+			tPosition: positionFromYamlNode(sp.filename(), key),
+		},
 		_node:      value,
+		_keyNode:   key,
+		_filename:  sp.currentFilename,
+		_prefix:    sp.currentPrefix,
+		exportName: exportName,
 		builder:    builder,
 		ruleName:   localName,
 		expression: nil,
@@ -122,7 +224,7 @@ func (sp tSchemaParser) expression(node yaml.Node) (tExpression, []error) {
 	case node.Tag == "!!str":
 		return sp.ruleReference(node)
 	case node.Kind != yaml.MappingNode || len(node.Content) == 0:
-		return nil, sp.schemaError(node, "an expression (a rule identifier or a YAML map)")
+		return nil, sp.schemaError(node, ErrorCodeForm, "an expression (a rule identifier or a YAML map)")
 	}
 
 	return sp.formRecognizer(node)
@@ -130,19 +232,74 @@ func (sp tSchemaParser) expression(node yaml.Node) (tExpression, []error) {
 
 func (sp tSchemaParser) ruleReference(node yaml.Node) (tExpression, []error) {
 	if !regexIdentifier.MatchString(node.Value) {
-		return nil, sp.schemaError(node, "a valid identifier reference (a-zA-Z)(a-zA-Z0-9_)+")
+		return nil, sp.schemaError(node, ErrorCodeIdentifier, "a valid identifier reference (a-zA-Z)(a-zA-Z0-9_)+")
+	}
+
+	// in imported documents, the rule names are prefixed with the import
+	// alias, except for the lidy default rules
+	ruleName := node.Value
+	if _, isDefaultRule := sp.lidyDefaultRuleMap[ruleName]; !isDefaultRule {
+		ruleName = sp.currentPrefix + ruleName
 	}
 
-	if rule, ok := sp.schema.ruleMap[node.Value]; ok {
-		return rule, nil
+	if rule, ok := sp.schema.ruleMap[ruleName]; ok {
+		if rule.ruleName != sp.currentRuleName {
+			rule._isReferenced = true
+		}
+		return sp.referenceCopy(rule, node), nil
 	}
 
 	if sp.option.BypassMissingRule {
-		sp.schema.ruleMap[node.Value] = sp.schema.ruleMap["any"]
-		return sp.schema.ruleMap["any"], nil
+		sp.schema.ruleMap[ruleName] = sp.schema.ruleMap["any"]
+		return sp.referenceCopy(sp.schema.ruleMap["any"], node), nil
 	}
 
-	return nil, sp.schemaError(node, "the identifier to exist in the document")
+	return nil, sp.schemaError(node, ErrorCodeUnknownRule, "the identifier to exist in the document")
+}
+
+// referenceCopy copies a rule for a reference to it, so that the reference has
+// its own position and annotation. The expression of the copy is set by
+// processRule, if the rule is not processed yet
+func (sp tSchemaParser) referenceCopy(rule *tRule, node yaml.Node) *tRule {
+	reference := *rule
+	reference.tSchemaNode = tSchemaNode{tPosition: positionFromYamlNode(sp.filename(), node)}
+	reference._referenceList = nil
+	reference._original = rule
+
+	rule._referenceList = append(rule._referenceList, &reference)
+
+	return &reference
+}
+
+// withSchemaNode gives an expression the position of its node and its annotation
+func withSchemaNode(expression tExpression, schemaNode tSchemaNode) tExpression {
+	switch expression := expression.(type) {
+	case *tRule:
+		expression.tSchemaNode = schemaNode
+	case tMap:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tList:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tOneOf:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tSwitch:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tIn:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tRegex:
+		expression.tSchemaNode = schemaNode
+		return expression
+	case tRange:
+		expression.tSchemaNode = schemaNode
+		return expression
+	}
+
+	return expression
 }
 
 // formRecognizer
@@ -179,7 +336,7 @@ func (sp tSchemaParser) formRecognizer(node yaml.Node) (tExpression, []error) {
 
 		// reject non-string "keywords"
 		if keyNode.Tag != "!!str" {
-			errList.Push(sp.schemaError(*keyNode, "only string keys (lidy keywords)"))
+			errList.Push(sp.schemaError(*keyNode, ErrorCodeKeyword, "only string keys (lidy keywords)"))
 			continue
 		}
 
@@ -189,19 +346,27 @@ func (sp tSchemaParser) formRecognizer(node yaml.Node) (tExpression, []error) {
 
 		// identifying the form
 		switch key {
-		case "_map", "_mapFacultative", "_mapOf", "_merge":
+		case "_map", "_mapFacultative", "_mapOf", "_merge", "_default":
 			setForm("map", key, mapChecker)
 		case "_list", "_listFacultative", "_listOf":
 			setForm("sequence", key, listChecker)
 		case "_oneOf":
 			setForm("oneOf", key, oneOfChecker)
+		case "_discriminator", "_switch":
+			setForm("switch", key, switchChecker)
 		case "_in":
 			setForm("in", key, inChecker)
 		case "_regex":
 			setForm("regex", key, regexChecker)
+		case "_range":
+			setForm("range", key, rangeChecker)
+		case "_rule":
+			setForm("rule", key, ruleChecker)
+		case "_description", "_examples", "_deprecated":
+			// annotations, valid with any form
 		case "_min", "_max", "_nb":
 			if form != "" && form != "map" && form != "sequence" {
-				errList.Push(sp.schemaError(*keyNode, fmt.Sprintf(
+				errList.Push(sp.at(key).schemaError(*keyNode, ErrorCodeConflict, fmt.Sprintf(
 					"only keywords compatible with form '%s' (resulting from keyword '%s')",
 					form, keyword,
 				)))
@@ -212,37 +377,82 @@ func (sp tSchemaParser) formRecognizer(node yaml.Node) (tExpression, []error) {
 				keyword = key
 			}
 		default:
-			errList.Push(sp.schemaError(*keyNode, "a valid lidy keyword"))
+			errList.Push(sp.at(key).schemaError(*keyNode, ErrorCodeKeyword, "a valid lidy keyword"))
 		}
 
 		// process conflicts
 		if conflictingForm != "" {
-			errList.Push(sp.schemaError(*keyNode, fmt.Sprintf(
+			expected := fmt.Sprintf(
 				"no keyword whose form %s conflicts with keyword %s of form %s",
 				conflictingForm, keyword, form,
-			)))
+			)
+			// e.g. `port: { _rule: int, _default: 8080 }`
+			if key == "_default" || keyword == "_default" {
+				expected = "_default only in the checker of a map, giving values to the properties of its _mapFacultative, e.g. `{ _mapFacultative: { port: int }, _default: { port: 8080 } }`"
+			}
+			errList.Push(sp.at(key).schemaError(*keyNode, ErrorCodeConflict, expected))
 
 			conflictingForm = ""
 		}
 	}
 
 	result, erl := checker(sp, node, formMap)
+	errList.Push(erl)
 
+	annotation, erl := annotationForm(sp, formMap)
 	errList.Push(erl)
 
+	if result != nil {
+		result = withSchemaNode(result, tSchemaNode{
+			tPosition:  positionFromYamlNode(sp.filename(), node),
+			annotation: annotation,
+		})
+	}
+
 	return result, errList.ConcatError()
 }
 
 // missingChecker (formRecognizer didn't detect a form)
 func missingChecker(sp tSchemaParser, node yaml.Node, formMap tFormMap) (tExpression, []error) {
-	return nil, sp.schemaError(node, "a recognizable lidy form")
+	return nil, sp.schemaError(node, ErrorCodeForm, "a recognizable lidy form")
+}
+
+// Warning
+func (sp tSchemaParser) schemaWarning(node yaml.Node, message string) Warning {
+	return &tWarning{
+		text: fmt.Sprintf("warning in schema at position %s:%s, %s", sp.filename(), getPosition(node), message),
+	}
+}
+
+// filename is the name of the schema file being parsed; the file of the
+// parser, or an imported file
+func (sp tSchemaParser) filename() string {
+	if sp.currentFilename != "" {
+		return sp.currentFilename
+	}
+	return sp.name
+}
+
+// at returns a copy of the schema parser, whose schema path is extended by the given keys or indexes
+func (sp tSchemaParser) at(segmentList ...string) tSchemaParser {
+	sp.schemaPath = appendPath(sp.schemaPath, segmentList...)
+	return sp
 }
 
 // Error
-func (sp tSchemaParser) schemaError(node yaml.Node, expected string) []error {
+func (sp tSchemaParser) schemaError(node yaml.Node, code ErrorCode, expected string) []error {
 	if node.Kind == yaml.Kind(0) {
 		return []error{fmt.Errorf("Tried to use uninitialized yaml node [node, expected: %s]; %s", expected, pleaseReport)}
 	}
 
-	return []error{fmt.Errorf("error in schema with yaml node, kind #%d,, tag '%s', value '%s' at position %s:%s, where [%s] was expected", node.Kind, node.ShortTag(), node.Value, sp.name, getPosition(node), expected)}
+	return []error{&SchemaError{
+		tPosition: positionFromYamlNode(sp.filename(), node),
+		Path:      pathString(sp.schemaPath),
+		RuleName:  sp.currentRuleName,
+		Expected:  expected,
+		Actual:    node.ShortTag(),
+		Code:      code,
+		kind:      node.Kind,
+		value:     node.Value,
+	}}
 }
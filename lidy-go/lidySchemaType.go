@@ -7,7 +7,8 @@ import (
 )
 
 type tExpression interface {
-	match(content yaml.Node, parser *tParser) (tResult, []error)
+	Expression
+	match(content yaml.Node, parser *tContentParser) (tResult, []error)
 	name() string
 	description() string
 	dependencyList() []string
@@ -15,17 +16,37 @@ type tExpression interface {
 
 type tMergeableExpression interface {
 	tExpression
-	mergeMatch(mapResult MapData, usefulList []bool, content yaml.Node, parser *tParser) []error
+	mergeMatch(mapResult MapData, usefulList []bool, content yaml.Node, parser *tContentParser) []error
+}
+
+// tSchemaNode -- embedded in the expressions, to hold the position of their
+// node in the schema, and their annotation
+type tSchemaNode struct {
+	tPosition
+	annotation Annotation
+}
+
+// Annotation -- the `_description`, `_examples` and `_deprecated` of the expression
+func (schemaNode tSchemaNode) Annotation() Annotation {
+	return schemaNode.annotation
 }
 
 type tSchema struct {
 	ruleMap map[string]*tRule
+	// dependencyList
+	// the references of the user rules, see dependencyGraph
+	dependencyList []tDependency
 }
 
 var _ tExpression = &tRule{}
 var _ tMergeableExpression = &tRule{}
 
 type tRule struct {
+	// tSchemaNode
+	// - on the rules of the ruleMap, the position of the declaration
+	// - on the copies of the rule made for each reference, see ruleReference,
+	//   the position of the reference and its annotation
+	tSchemaNode
 	ruleName string
 	// On lidy default rules //
 	// lidyMatcher
@@ -33,9 +54,27 @@ type tRule struct {
 	lidyMatcher tLidyMatcher
 	//
 	// On user rules //
+	// exportName
+	// - present on exported rules. The name of the builder to use
+	exportName string
 	// builder
 	// - present on exported types if the user has provided one
 	builder Builder
+	// _keyNode
+	// - missing from rules with a lidyMatcher-s
+	// - the yaml node of the rule declaration, used to report warnings
+	_keyNode yaml.Node
+	// _filename
+	// - missing from rules with a lidyMatcher-s
+	// - the schema file in which the rule is declared; the parser file or an imported file
+	_filename string
+	// _prefix
+	// - the import aliases leading to the file of the rule, e.g. `net.`, or empty.
+	//   It is prepended to the identifiers referred to by the rule
+	_prefix string
+	// _isReferenced
+	// true if another rule of the schema refers to this rule
+	_isReferenced bool
 	// _node
 	// - missing from rules with a lidyMatcher-s
 	// - temporary value, used to keep the readily node available between the rule
@@ -48,6 +87,25 @@ type tRule struct {
 	// _mergeList
 	// list of rule
 	_mergeList []string
+	// _defaultList
+	// the `_default` values declared in the expression of the rule, checked
+	// against their property once all the rules are parsed
+	_defaultList []tDefault
+	// _referenceList
+	// the copies of the rule made for each reference, whose expression is set
+	// along with the expression of the rule
+	_referenceList []*tRule
+	// _original
+	// on the copies, the rule of the ruleMap
+	_original *tRule
+}
+
+// origin is the rule of the ruleMap, for the copies made for the references
+func (rule *tRule) origin() *tRule {
+	if rule._original != nil {
+		return rule._original
+	}
+	return rule
 }
 
 // Map
@@ -55,17 +113,35 @@ var _ tExpression = tMap{}
 var _ tMergeableExpression = tMap{}
 
 type tMap struct {
+	tSchemaNode
 	form   tMapForm
 	sizing tSizing
 }
 
 // tMapForm map-related size-agnostic content of a tMap node
 type tMapForm struct {
-	propertyMap     map[string]tExpression
-	optionalMap     map[string]tExpression
-	mapOf           tKeyValueExpression
-	mergeList       []tMergeableExpression
-	_dependencyList []string
+	propertyMap map[string]tExpression
+	optionalMap map[string]tExpression
+	mapOf       tKeyValueExpression
+	mergeList   []tMergeableExpression
+	// defaultList
+	// the `_default` values of the facultative properties, sorted by property
+	defaultList []tDefault
+}
+
+// tDefault the value used for a facultative property missing from the content
+type tDefault struct {
+	property   string
+	expression tExpression
+	// node
+	// the value, in the schema
+	node yaml.Node
+	// filename
+	// the schema file of the value
+	filename string
+	// _schemaPath
+	// the path of the value in the schema, used to report errors
+	_schemaPath []string
 }
 
 type tKeyValueExpression struct {
@@ -77,6 +153,7 @@ type tKeyValueExpression struct {
 var _ tExpression = tList{}
 
 type tList struct {
+	tSchemaNode
 	form   tListForm
 	sizing tSizing
 }
@@ -89,7 +166,7 @@ type tListForm struct {
 
 // Sizing
 type tSizing interface {
-	check(content yaml.Node, parser *tParser) []error
+	check(content yaml.Node, parser *tContentParser) []error
 }
 
 var _ tSizing = tSizingMinMax{}
@@ -122,14 +199,32 @@ var _ tExpression = tOneOf{}
 var _ tMergeableExpression = tOneOf{}
 
 type tOneOf struct {
-	optionList      []tExpression
-	_dependencyList []string
+	tSchemaNode
+	optionList []tExpression
+}
+
+// Switch
+var _ tExpression = tSwitch{}
+var _ tMergeableExpression = tSwitch{}
+
+type tSwitch struct {
+	tSchemaNode
+	// key
+	// the property whose value selects the case
+	key string
+	// caseMap
+	// maps the values of the key to the mergeable expression to use
+	caseMap map[string]tMergeableExpression
+	// caseNameList
+	// the values of caseMap, sorted
+	caseNameList []string
 }
 
 // In
 var _ tExpression = tIn{}
 
 type tIn struct {
+	tSchemaNode
 	// valueMap
 	// maps Node.Tag-s to slices of Node.Value
 	valueMap map[string][]string
@@ -139,6 +234,28 @@ type tIn struct {
 var _ tExpression = tRegex{}
 
 type tRegex struct {
+	tSchemaNode
 	regexString string
 	regex       *regexp.Regexp
 }
+
+// Range
+var _ tExpression = tRange{}
+
+type tRange struct {
+	tSchemaNode
+	rangeString string
+	// kind
+	// either "int" or "float"; the lidy default rule the content must match
+	kind string
+	min  tRangeBound
+	max  tRangeBound
+}
+
+type tRangeBound struct {
+	// present
+	// false if the range is unbounded on that side
+	present   bool
+	inclusive bool
+	value     float64
+}
@@ -0,0 +1,263 @@
+// Package lidytest runs the data-driven tests of lidy schemas, written in
+// .spec.yaml files, as Go tests.
+//
+// A spec file is a YAML map of groups. A group gives a schema, and criteria
+// listing the YAML documents that the rule main of the schema must accept or
+// reject:
+//
+//	server with a port:
+//	  schema: |-
+//	    main: { _map: { port: { _range: (1 <= int <= 65535) } } }
+//	  accept a valid port:
+//	    '{ port: 80 }': {}
+//	  reject the port 0:
+//	    '{ port: 0 }': { contain: port }
+//
+// The schema is given by `schema`, or by `expression`, which becomes the rule
+// main. `schemaTemplate` or `expressionTemplate`, with a `<name>List` of
+// values, give a schema for each value, substituted for `${name}`. The
+// criteria start with `accept` or `reject`; their test cases are a map of
+// documents to checks, or a list of [document, checks] pairs. The check
+// `contain` requires the errors to mention a text, and `count` requires a
+// number of errors.
+//
+// A spec file with a top-level `target` tests schemas rather than documents:
+// the test cases are schemas, or expressions, or regexes, for the target
+// `document`, `expression` or `regex.checker`, that lidy must accept or
+// reject.
+//
+// The groups and the criteria whose name starts with `PENDING ` or `SKIP `
+// are skipped, and so are the criteria whose test cases are a reference,
+// such as `'@any'`. The prefix `FOCUS ` is ignored; use `go test -run`
+// instead.
+package lidytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ditrit/lidy"
+)
+
+// SpecFile -- the groups of a .spec.yaml file
+type SpecFile struct {
+	// Name the filename
+	Name string
+	// Target empty for the files testing documents; document, expression or regex.checker for the files testing schemas
+	Target string
+	// GroupList the groups, in the order of the file
+	GroupList []Group
+}
+
+// Group -- a group of criteria sharing a schema
+type Group struct {
+	// Name the key of the group, without the PENDING, SKIP or FOCUS prefix
+	Name string
+	// Position file:line:column of the key of the group
+	Position string
+	// Pending true if the group is skipped
+	Pending bool
+	// SchemaList the schemas that the test cases are checked against; one per value of the *List of a template. Empty in the files testing schemas
+	SchemaList []Schema
+	// CriterionList the criteria, in the order of the file
+	CriterionList []Criterion
+}
+
+// Schema -- a schema of a group
+type Schema struct {
+	// Value the value substituted in the template, if any
+	Value string
+	// Text the schema document
+	Text string
+}
+
+// Criterion -- test cases which must all be accepted, or all be rejected
+type Criterion struct {
+	// Name the key of the criterion, without the PENDING, SKIP or FOCUS prefix
+	Name string
+	// Position file:line:column of the key of the criterion
+	Position string
+	// Pending true if the criterion is skipped
+	Pending bool
+	// Reject true if the name starts with reject, false if it starts with accept
+	Reject bool
+	// CaseList the test cases, in the order of the file
+	CaseList []Case
+	// Reference the name of a list of test cases, e.g. `@any`, given instead of the test cases. Not supported yet; the criterion is skipped
+	Reference string
+}
+
+// Case -- a document, or a schema, to accept or to reject
+type Case struct {
+	// Text the document, or the schema
+	Text string
+	// Position file:line:column of the test case
+	Position string
+	// Contain a text that the errors must contain, if not empty
+	Contain string
+	// Count the number of errors expected, if not 0
+	Count int
+}
+
+// Run -- run the .spec.yaml files found in the directory and its
+// subdirectories, each as a subtest of t
+func Run(t *testing.T, root string) {
+	t.Helper()
+
+	filenameList := []string{}
+	err := filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(filename, ".spec.yaml") {
+			filenameList = append(filenameList, filename)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filenameList) == 0 {
+		t.Fatalf("no .spec.yaml file found in %s", root)
+	}
+
+	for _, filename := range filenameList {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		file := lidy.NewFile(filepath.ToSlash(filename), content)
+		t.Run(filepath.ToSlash(filename), func(t *testing.T) {
+			RunFile(t, file)
+		})
+	}
+}
+
+// RunFile -- run the groups of a .spec.yaml file, each as a subtest of t
+func RunFile(t *testing.T, file lidy.File) {
+	t.Helper()
+
+	specFile, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specFile.Run(t)
+}
+
+// Run -- run the groups of the spec file, each as a subtest of t. The
+// failures report the position of the test case
+func (specFile SpecFile) Run(t *testing.T) {
+	for _, group := range specFile.GroupList {
+		group := group
+		t.Run(group.Name, func(t *testing.T) {
+			if group.Pending {
+				t.Skipf("%s: pending", group.Position)
+			}
+
+			if specFile.Target != "" {
+				group.runSchemaTest(t, specFile.Target)
+			} else {
+				group.runContentTest(t)
+			}
+		})
+	}
+}
+
+// runSchemaTest checks that the test cases, which are schemas, are accepted
+// or rejected by lidy
+func (group Group) runSchemaTest(t *testing.T, target string) {
+	group.runCriterionList(t, func(t *testing.T, criterion Criterion, testCase Case) {
+		text := testCase.Text
+		switch target {
+		case "expression":
+			text = expressionSchema(text)
+		case "regex.checker":
+			text = expressionSchema("_regex: '" + text + "'")
+		}
+
+		erl := lidy.NewParser(testCase.Position, []byte(text)).Schema()
+		checkResult(t, criterion, testCase, erl)
+	})
+}
+
+// runContentTest checks that the test cases, which are documents, are
+// accepted or rejected by each schema of the group
+func (group Group) runContentTest(t *testing.T) {
+	for _, schema := range group.SchemaList {
+		schema := schema
+		run := func(t *testing.T) {
+			parser := lidy.NewParser(group.Position, []byte(schema.Text))
+
+			erl := parser.Schema()
+			if len(erl) > 0 {
+				t.Fatalf("%s: the schema of the group is invalid: %s\n%s", group.Position, erl[0].Error(), schema.Text)
+			}
+
+			group.runCriterionList(t, func(t *testing.T, criterion Criterion, testCase Case) {
+				_, erl := parser.Parse(lidy.NewFile(testCase.Position, []byte(testCase.Text)))
+				checkResult(t, criterion, testCase, erl)
+			})
+		}
+
+		if schema.Value == "" {
+			run(t)
+		} else {
+			t.Run(schema.Value, run)
+		}
+	}
+}
+
+func (group Group) runCriterionList(t *testing.T, check func(t *testing.T, criterion Criterion, testCase Case)) {
+	for _, criterion := range group.CriterionList {
+		criterion := criterion
+		t.Run(criterion.Name, func(t *testing.T) {
+			if criterion.Pending {
+				t.Skipf("%s: pending", criterion.Position)
+			}
+			if criterion.Reference != "" {
+				t.Skipf("%s: the reference %s is not supported yet", criterion.Position, criterion.Reference)
+			}
+
+			for k, testCase := range criterion.CaseList {
+				testCase := testCase
+				t.Run(fmt.Sprintf("#%d", k), func(t *testing.T) {
+					check(t, criterion, testCase)
+				})
+			}
+		})
+	}
+}
+
+func checkResult(t *testing.T, criterion Criterion, testCase Case, erl []error) {
+	if !criterion.Reject {
+		if len(erl) > 0 {
+			t.Errorf("%s: expected no error, got %d error(s), the first one being: %s\n%s", testCase.Position, len(erl), erl[0].Error(), testCase.Text)
+		}
+		return
+	}
+
+	if len(erl) == 0 {
+		t.Errorf("%s: expected an error\n%s", testCase.Position, testCase.Text)
+		return
+	}
+
+	if testCase.Count > 0 && len(erl) != testCase.Count {
+		t.Errorf("%s: expected %d error(s), got %d, the first one being: %s\n%s", testCase.Position, testCase.Count, len(erl), erl[0].Error(), testCase.Text)
+	}
+
+	if testCase.Contain == "" {
+		return
+	}
+
+	for _, err := range erl {
+		if strings.Contains(err.Error(), testCase.Contain) {
+			return
+		}
+	}
+	t.Errorf("%s: expected an error containing '%s', got: %s\n%s", testCase.Position, testCase.Contain, erl[0].Error(), testCase.Text)
+}
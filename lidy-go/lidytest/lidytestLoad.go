@@ -0,0 +1,259 @@
+package lidytest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	"gopkg.in/yaml.v3"
+)
+
+// lidytestLoad.go
+//
+// Read the .spec.yaml files into SpecFile values
+
+// Load -- read a .spec.yaml file. The error reports the position of what is
+// malformed
+func Load(file lidy.File) (SpecFile, error) {
+	specFile := SpecFile{Name: file.Name()}
+
+	document := yaml.Node{}
+	err := yaml.Unmarshal(file.Content(), &document)
+	if err != nil {
+		return specFile, fmt.Errorf("%s: %s", file.Name(), err.Error())
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) != 1 || document.Content[0].Kind != yaml.MappingNode {
+		return specFile, fmt.Errorf("%s: expected the spec file to be a YAML map of groups", file.Name())
+	}
+	root := document.Content[0]
+
+	loader := tLoader{filename: file.Name()}
+
+	for k := 0; k+1 < len(root.Content); k += 2 {
+		key, value := root.Content[k], root.Content[k+1]
+
+		if key.Value == "target" {
+			switch value.Value {
+			case "document", "expression", "regex.checker":
+				specFile.Target = value.Value
+			default:
+				return specFile, loader.error(value, "expected the target to be document, expression or regex.checker")
+			}
+		}
+	}
+
+	for k := 0; k+1 < len(root.Content); k += 2 {
+		key, value := root.Content[k], root.Content[k+1]
+		if key.Value == "target" {
+			continue
+		}
+
+		group, err := loader.group(key, value, specFile.Target != "")
+		if err != nil {
+			return specFile, err
+		}
+		specFile.GroupList = append(specFile.GroupList, group)
+	}
+
+	return specFile, nil
+}
+
+type tLoader struct {
+	filename string
+}
+
+func (loader tLoader) position(node *yaml.Node) string {
+	return fmt.Sprintf("%s:%d:%d", loader.filename, node.Line, node.Column)
+}
+
+func (loader tLoader) error(node *yaml.Node, message string) error {
+	return fmt.Errorf("%s: %s", loader.position(node), message)
+}
+
+func (loader tLoader) group(key *yaml.Node, value *yaml.Node, schemaTest bool) (Group, error) {
+	group := Group{
+		Position: loader.position(key),
+	}
+	group.Name, group.Pending = trimFlag(key.Value)
+
+	// the pending groups are neither read nor run
+	if group.Pending {
+		return group, nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return group, loader.error(value, "expected the group to be a YAML map")
+	}
+
+	template := ""
+	isTemplate := false
+	valueName := ""
+	valueList := []string{}
+
+	for k := 0; k+1 < len(value.Content); k += 2 {
+		entryKey, entryValue := value.Content[k], value.Content[k+1]
+		name := entryKey.Value
+
+		// the criteria are sentences; the other entries are single words
+		if strings.Contains(name, " ") || name == "accept" || name == "reject" {
+			criterion, err := loader.criterion(entryKey, entryValue)
+			if err != nil {
+				return group, err
+			}
+			group.CriterionList = append(group.CriterionList, criterion)
+			continue
+		}
+
+		if schemaTest {
+			return group, loader.error(entryKey, fmt.Sprintf("unexpected key '%s' in a group of schema tests", name))
+		}
+
+		switch {
+		case name == "expression":
+			template = expressionSchema(entryValue.Value)
+		case name == "schema":
+			template = entryValue.Value
+		case name == "expressionTemplate":
+			template = expressionSchema(entryValue.Value)
+			isTemplate = true
+		case name == "schemaTemplate":
+			template = entryValue.Value
+			isTemplate = true
+		case strings.HasSuffix(name, "List") && entryValue.Kind == yaml.SequenceNode:
+			valueName = strings.TrimSuffix(name, "List")
+			for _, item := range entryValue.Content {
+				valueList = append(valueList, item.Value)
+			}
+		default:
+			return group, loader.error(entryKey, fmt.Sprintf("unexpected key '%s'; expected expression, schema, expressionTemplate, schemaTemplate, a *List, or a criterion starting with accept or reject", name))
+		}
+	}
+
+	if schemaTest {
+		return group, nil
+	}
+
+	switch {
+	case template == "":
+		return group, loader.error(key, "expected the group to have an expression, a schema, or a template")
+	case isTemplate && valueName == "":
+		return group, loader.error(key, "expected the template of the group to come with a *List of values")
+	case !isTemplate:
+		group.SchemaList = []Schema{{Text: template}}
+	default:
+		for _, substitution := range valueList {
+			group.SchemaList = append(group.SchemaList, Schema{
+				Value: substitution,
+				Text:  strings.ReplaceAll(template, "${"+valueName+"}", substitution),
+			})
+		}
+	}
+
+	return group, nil
+}
+
+func (loader tLoader) criterion(key *yaml.Node, value *yaml.Node) (Criterion, error) {
+	criterion := Criterion{
+		Position: loader.position(key),
+	}
+	criterion.Name, criterion.Pending = trimFlag(key.Value)
+
+	// the pending criteria are neither read nor run
+	if criterion.Pending {
+		return criterion, nil
+	}
+
+	switch {
+	case strings.HasPrefix(criterion.Name, "accept"):
+	case strings.HasPrefix(criterion.Name, "reject"):
+		criterion.Reject = true
+	default:
+		return criterion, loader.error(key, fmt.Sprintf("expected the criterion name '%s' to start with accept or reject", criterion.Name))
+	}
+
+	switch value.Kind {
+	case yaml.ScalarNode:
+		// a reference to a list of test cases, e.g. '@any'
+		criterion.Reference = value.Value
+		return criterion, nil
+	case yaml.MappingNode:
+		// text: { contain: ... }
+		for k := 0; k+1 < len(value.Content); k += 2 {
+			testCase, err := loader.testCase(value.Content[k], value.Content[k+1])
+			if err != nil {
+				return criterion, err
+			}
+			criterion.CaseList = append(criterion.CaseList, testCase)
+		}
+	case yaml.SequenceNode:
+		// - [text, { contain: ... }]
+		for _, pair := range value.Content {
+			if pair.Kind != yaml.SequenceNode || len(pair.Content) == 0 || len(pair.Content) > 2 {
+				return criterion, loader.error(pair, "expected a test case pair, [text, { contain: ... }]")
+			}
+			check := &yaml.Node{Kind: yaml.MappingNode}
+			if len(pair.Content) == 2 {
+				check = pair.Content[1]
+			}
+			testCase, err := loader.testCase(pair.Content[0], check)
+			if err != nil {
+				return criterion, err
+			}
+			criterion.CaseList = append(criterion.CaseList, testCase)
+		}
+	default:
+		return criterion, loader.error(value, "expected the test cases to be a YAML map or a YAML list")
+	}
+
+	if len(criterion.CaseList) == 0 {
+		return criterion, loader.error(key, "expected the criterion to have at least one test case")
+	}
+
+	return criterion, nil
+}
+
+func (loader tLoader) testCase(text *yaml.Node, check *yaml.Node) (Case, error) {
+	testCase := Case{
+		Text:     text.Value,
+		Position: loader.position(text),
+	}
+
+	if check.Kind != yaml.MappingNode {
+		return testCase, loader.error(check, "expected the checks of the test case to be a YAML map, e.g. {} or { contain: ... }")
+	}
+
+	for k := 0; k+1 < len(check.Content); k += 2 {
+		switch check.Content[k].Value {
+		case "contain":
+			testCase.Contain = check.Content[k+1].Value
+		case "count":
+			count, err := strconv.Atoi(check.Content[k+1].Value)
+			if err != nil || count <= 0 {
+				return testCase, loader.error(check.Content[k+1], "expected the count to be a positive integer")
+			}
+			testCase.Count = count
+		default:
+			return testCase, loader.error(check.Content[k], fmt.Sprintf("unknown check '%s'; expected contain or count", check.Content[k].Value))
+		}
+	}
+
+	return testCase, nil
+}
+
+// expressionSchema makes a schema whose rule main is the expression
+func expressionSchema(expression string) string {
+	return "main:" + strings.ReplaceAll("\n"+expression, "\n", "\n  ")
+}
+
+// trimFlag removes the PENDING, SKIP or FOCUS prefix of a name, and tells
+// whether the group or the criterion is pending
+func trimFlag(name string) (string, bool) {
+	for _, flag := range []string{"PENDING ", "SKIP "} {
+		if strings.HasPrefix(name, flag) {
+			return strings.TrimPrefix(name, flag), true
+		}
+	}
+	return strings.TrimPrefix(name, "FOCUS "), false
+}
@@ -0,0 +1,103 @@
+package lidytest
+
+import (
+	"testing"
+
+	"github.com/ditrit/lidy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLidytest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "lidytest Suite")
+}
+
+const serverSpec = `
+server with a port:
+  schema: |-
+    main: { _map: { port: { _range: (1 <= int <= 65535) } } }
+  accept a valid port:
+    '{ port: 80 }': {}
+  reject the port 0:
+    '{ port: 0 }': { contain: port, count: 1 }
+${keyword} as a key:
+  expressionTemplate: '_map: { "${keyword}": int }'
+  keywordList: [_map, _list]
+  reject:
+    - ['{}', { contain: to find a property }]
+PENDING not ready:
+  check it: 1
+`
+
+// TestRunFile runs a spec file through RunFile, the way a user of the
+// package does
+func TestRunFile(t *testing.T) {
+	RunFile(t, lidy.NewFile("server.spec.yaml", []byte(serverSpec)))
+}
+
+var _ = Describe("Load", func() {
+	It("reads the groups, the criteria and the test cases, with their positions", func() {
+		specFile, err := Load(lidy.NewFile("server.spec.yaml", []byte(serverSpec)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(specFile.Target).To(BeEmpty())
+		Expect(specFile.GroupList).To(HaveLen(3))
+
+		server := specFile.GroupList[0]
+		Expect(server.Name).To(Equal("server with a port"))
+		Expect(server.Position).To(Equal("server.spec.yaml:2:1"))
+		Expect(server.SchemaList).To(HaveLen(1))
+		Expect(server.CriterionList).To(HaveLen(2))
+		Expect(server.CriterionList[1]).To(Equal(Criterion{
+			Name:     "reject the port 0",
+			Position: "server.spec.yaml:7:3",
+			Reject:   true,
+			CaseList: []Case{{Text: "{ port: 0 }", Position: "server.spec.yaml:8:5", Contain: "port", Count: 1}},
+		}))
+
+		keyword := specFile.GroupList[1]
+		Expect(keyword.SchemaList).To(Equal([]Schema{
+			{Value: "_map", Text: "main:\n  _map: { \"_map\": int }"},
+			{Value: "_list", Text: "main:\n  _map: { \"_list\": int }"},
+		}))
+		Expect(keyword.CriterionList[0].CaseList[0].Contain).To(Equal("to find a property"))
+
+		Expect(specFile.GroupList[2].Pending).To(BeTrue())
+	})
+
+	It("reads the files testing schemas", func() {
+		specFile, err := Load(lidy.NewFile("regex.spec.yaml", []byte(`
+target: regex.checker
+regexes:
+  accept valid regexes:
+    '^a+$': {}
+  reject invalid regexes:
+    '(': {}
+  PENDING accept the lookaheads: '@lookahead'
+`)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(specFile.Target).To(Equal("regex.checker"))
+		Expect(specFile.GroupList[0].SchemaList).To(BeEmpty())
+		Expect(specFile.GroupList[0].CriterionList).To(HaveLen(3))
+		Expect(specFile.GroupList[0].CriterionList[2].Pending).To(BeTrue())
+	})
+
+	It("reports the position of what is malformed", func() {
+		for text, message := range map[string]string{
+			"a: { expression: int, check: {} }":                   "spec.yaml:1:23: unexpected key 'check'",
+			"a: { expression: int, test it: { x: {} } }":          "spec.yaml:1:23: expected the criterion name 'test it' to start with accept or reject",
+			"a: { expression: int, accept: { x: { y: 1 } } }":     "spec.yaml:1:38: unknown check 'y'",
+			"a: { expression: int, reject: { x: { count: 0 } } }": "spec.yaml:1:45: expected the count to be a positive integer",
+			"a: { accept: { x: {} } }":                            "spec.yaml:1:1: expected the group to have an expression",
+			"a: { expressionTemplate: int, accept: { x: {} } }":   "spec.yaml:1:1: expected the template of the group to come with a *List",
+			"target: file": "spec.yaml:1:9: expected the target to be document, expression or regex.checker",
+			"[]":           "spec.yaml: expected the spec file to be a YAML map of groups",
+		} {
+			_, err := Load(lidy.NewFile("spec.yaml", []byte(text)))
+			Expect(err).To(HaveOccurred(), text)
+			Expect(err.Error()).To(HavePrefix(message), text)
+		}
+	})
+})
@@ -0,0 +1,507 @@
+// Package lsp implements a Language Server Protocol server for lidy schemas,
+// and for the YAML documents they check. It is run by `lidy lsp`, which
+// talks to the editor through stdin and stdout.
+//
+// For the content documents, the server publishes the errors of Parse, and
+// provides the completion of the keys of the map at the cursor, and the
+// description of the rule applying to the node under the cursor, on hover.
+// For the schemas, it publishes the errors of Schema, and provides the
+// completion of the lidy keywords and of the rule names, and the definition
+// of the rule references.
+//
+// A content document names its schema, relative to its directory, and
+// optionally its target rule, in a comment:
+//
+//	# lidy-schema: ../schema/config.yaml main
+//
+// The editor can also associate schemas with the documents through the
+// initializationOptions, where the paths are relative to the root of the
+// workspace, and the file patterns are matched against the path of the
+// documents relative to the root, or against their name:
+//
+//	{ "schemas": [{ "schema": "schema/config.yaml", "target": "main", "files": ["config/*.yaml"] }] }
+//
+// The files named .lidy.yaml, and those associated with a content document,
+// are schemas. The other files are ignored.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ditrit/lidy"
+)
+
+// regexModeline -- the comment naming the schema of a content document
+var regexModeline = *regexp.MustCompile(`(?m)^#\s*lidy-schema:\s*(\S+)(?:[ \t]+(\S+))?[ \t]*\r?$`)
+
+// tServerOption -- the initializationOptions of the client
+type tServerOption struct {
+	Schemas []tAssociation `json:"schemas"`
+}
+
+// tAssociation -- a schema, and the documents it checks
+type tAssociation struct {
+	// Schema the schema file, relative to the root of the workspace
+	Schema string `json:"schema"`
+	// Target the rule of the schema checking the documents; main by default
+	Target string `json:"target"`
+	// Files the patterns of the documents, see path.Match
+	Files []string `json:"files"`
+}
+
+// tDocument -- a document opened in the editor
+type tDocument struct {
+	uri  string
+	text string
+}
+
+type tServer struct {
+	connection      tConnection
+	rootPath        string
+	associationList []tAssociation
+	// documentMap
+	// the open documents, by path. Their text replaces the content of the
+	// files on disk
+	documentMap map[string]tDocument
+	// writeError
+	// the first error met while writing to the client, which ends the server
+	writeError error
+}
+
+// Serve -- run the server, reading the messages of the client from reader,
+// and writing the responses and the notifications to writer. It returns
+// when the client sends `exit`, or when the reader is closed
+func Serve(reader io.Reader, writer io.Writer) error {
+	server := tServer{
+		connection: tConnection{
+			reader: bufio.NewReader(reader),
+			writer: writer,
+		},
+		documentMap: map[string]tDocument{},
+	}
+
+	for server.writeError == nil {
+		body, err := server.connection.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		message := tMessage{}
+		err = json.Unmarshal(body, &message)
+		if err != nil {
+			server.check(server.connection.respondError(json.RawMessage("null"), codeParseError, err.Error()))
+			continue
+		}
+
+		if message.Method == "exit" {
+			return nil
+		}
+
+		server.handle(message)
+	}
+
+	return server.writeError
+}
+
+// handle dispatches a message to its handler, and responds to the requests
+func (server *tServer) handle(message tMessage) {
+	var result interface{}
+	var err error
+
+	switch message.Method {
+	case "initialize":
+		result, err = server.initialize(message.Params)
+	case "shutdown":
+		result = nil
+	case "textDocument/didOpen":
+		err = server.didOpen(message.Params)
+	case "textDocument/didChange":
+		err = server.didChange(message.Params)
+	case "textDocument/didSave":
+		server.publishDiagnostics()
+	case "textDocument/didClose":
+		err = server.didClose(message.Params)
+	case "textDocument/hover":
+		result, err = server.hover(message.Params)
+	case "textDocument/completion":
+		result, err = server.completion(message.Params)
+	case "textDocument/definition":
+		result, err = server.definition(message.Params)
+	default:
+		if message.isRequest() {
+			server.check(server.connection.respondError(message.Id, codeMethodNotFound, "unsupported method "+message.Method))
+		}
+		return
+	}
+
+	// the notifications get no response, even when their parameters are invalid
+	if !message.isRequest() {
+		return
+	}
+
+	if err != nil {
+		server.check(server.connection.respondError(message.Id, codeInvalidParams, err.Error()))
+		return
+	}
+	server.check(server.connection.respond(message.Id, result))
+}
+
+// check records the first error met while writing to the client
+func (server *tServer) check(err error) {
+	if server.writeError == nil {
+		server.writeError = err
+	}
+}
+
+func (server *tServer) initialize(params json.RawMessage) (interface{}, error) {
+	initializeParams := tInitializeParams{}
+	err := json.Unmarshal(params, &initializeParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if initializeParams.RootUri != "" {
+		server.rootPath = uriToPath(initializeParams.RootUri)
+	}
+	server.associationList = initializeParams.InitializationOptions.Schemas
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":   textDocumentSyncFull,
+			"hoverProvider":      true,
+			"completionProvider": map[string]interface{}{},
+			"definitionProvider": true,
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "lidy",
+		},
+	}, nil
+}
+
+func (server *tServer) didOpen(params json.RawMessage) error {
+	didOpenParams := tDidOpenParams{}
+	err := json.Unmarshal(params, &didOpenParams)
+	if err != nil {
+		return err
+	}
+
+	item := didOpenParams.TextDocument
+	server.documentMap[uriToPath(item.Uri)] = tDocument{uri: item.Uri, text: item.Text}
+	server.publishDiagnostics()
+
+	return nil
+}
+
+func (server *tServer) didChange(params json.RawMessage) error {
+	didChangeParams := tDidChangeParams{}
+	err := json.Unmarshal(params, &didChangeParams)
+	if err != nil {
+		return err
+	}
+
+	// the server asks for the full text; the last change is the current text
+	changeList := didChangeParams.ContentChanges
+	if len(changeList) == 0 {
+		return nil
+	}
+
+	uri := didChangeParams.TextDocument.Uri
+	server.documentMap[uriToPath(uri)] = tDocument{uri: uri, text: changeList[len(changeList)-1].Text}
+	server.publishDiagnostics()
+
+	return nil
+}
+
+func (server *tServer) didClose(params json.RawMessage) error {
+	textDocumentParams := tTextDocumentParams{}
+	err := json.Unmarshal(params, &textDocumentParams)
+	if err != nil {
+		return err
+	}
+
+	uri := textDocumentParams.TextDocument.Uri
+	delete(server.documentMap, uriToPath(uri))
+
+	server.check(server.connection.notify("textDocument/publishDiagnostics", tPublishDiagnosticsParams{
+		Uri:         uri,
+		Diagnostics: []tDiagnostic{},
+	}))
+	server.publishDiagnostics()
+
+	return nil
+}
+
+// publishDiagnostics checks every open document. A change to a schema
+// changes the errors of the documents it checks, so they are all checked
+// again
+func (server *tServer) publishDiagnostics() {
+	pathList := make([]string, 0, len(server.documentMap))
+	for documentPath := range server.documentMap {
+		pathList = append(pathList, documentPath)
+	}
+	sort.Strings(pathList)
+
+	for _, documentPath := range pathList {
+		document := server.documentMap[documentPath]
+
+		var diagnosticList []tDiagnostic
+		if schemaPath, target, ok := server.schemaOf(documentPath, document.text); ok {
+			diagnosticList = server.diagnoseContent(documentPath, document.text, schemaPath, target)
+		} else if server.isSchema(documentPath) {
+			diagnosticList = server.diagnoseSchema(documentPath, document.text)
+		} else {
+			continue
+		}
+
+		server.check(server.connection.notify("textDocument/publishDiagnostics", tPublishDiagnosticsParams{
+			Uri:         document.uri,
+			Diagnostics: diagnosticList,
+		}))
+	}
+}
+
+// textDocumentPosition reads the parameters of hover, completion and
+// definition, and returns the path and the text of the document
+func (server *tServer) textDocumentPosition(params json.RawMessage) (tTextDocumentPositionParams, string, string, error) {
+	positionParams := tTextDocumentPositionParams{}
+	err := json.Unmarshal(params, &positionParams)
+	if err != nil {
+		return positionParams, "", "", err
+	}
+
+	documentPath := uriToPath(positionParams.TextDocument.Uri)
+	text, err := server.readFile(documentPath)
+
+	return positionParams, documentPath, string(text), err
+}
+
+func (server *tServer) hover(params json.RawMessage) (interface{}, error) {
+	positionParams, documentPath, text, err := server.textDocumentPosition(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if schemaPath, target, ok := server.schemaOf(documentPath, text); ok {
+		return server.hoverContent(documentPath, text, schemaPath, target, positionParams.Position), nil
+	}
+
+	return nil, nil
+}
+
+func (server *tServer) completion(params json.RawMessage) (interface{}, error) {
+	positionParams, documentPath, text, err := server.textDocumentPosition(params)
+	if err != nil {
+		return nil, err
+	}
+
+	itemList := []tCompletionItem{}
+	if schemaPath, target, ok := server.schemaOf(documentPath, text); ok {
+		itemList = server.completeContent(documentPath, text, schemaPath, target, positionParams.Position)
+	} else if server.isSchema(documentPath) {
+		itemList = server.completeSchema(documentPath, text, positionParams.Position)
+	}
+
+	return tCompletionList{Items: itemList}, nil
+}
+
+func (server *tServer) definition(params json.RawMessage) (interface{}, error) {
+	positionParams, documentPath, text, err := server.textDocumentPosition(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, ok := server.schemaOf(documentPath, text); !ok && server.isSchema(documentPath) {
+		if location, found := server.defineSchema(documentPath, text, positionParams.Position); found {
+			return location, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// schemaOf returns the schema and the target rule of a content document,
+// named by the comment of the document, or by the initializationOptions
+func (server *tServer) schemaOf(documentPath string, text string) (string, string, bool) {
+	if match := regexModeline.FindStringSubmatch(text); match != nil {
+		target := match[2]
+		if target == "" {
+			target = "main"
+		}
+		return path.Join(path.Dir(documentPath), match[1]), target, true
+	}
+
+	relativePath := strings.TrimPrefix(documentPath, server.rootPath+"/")
+
+	for _, association := range server.associationList {
+		for _, pattern := range association.Files {
+			matchPath, _ := path.Match(pattern, relativePath)
+			matchName, _ := path.Match(pattern, path.Base(documentPath))
+
+			if matchPath || matchName {
+				target := association.Target
+				if target == "" {
+					target = "main"
+				}
+				return path.Join(server.rootPath, association.Schema), target, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// isSchema tells whether the document is a schema: a .lidy.yaml file, or a
+// schema associated with a content document
+func (server *tServer) isSchema(documentPath string) bool {
+	if strings.HasSuffix(documentPath, ".lidy.yaml") || strings.HasSuffix(documentPath, ".lidy.yml") {
+		return true
+	}
+
+	for _, association := range server.associationList {
+		if path.Join(server.rootPath, association.Schema) == documentPath {
+			return true
+		}
+	}
+
+	for contentPath, document := range server.documentMap {
+		if schemaPath, _, ok := server.schemaOf(contentPath, document.text); ok && schemaPath == documentPath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readFile returns the text of an open document, or else the content of the
+// file on disk
+func (server *tServer) readFile(filename string) ([]byte, error) {
+	if document, ok := server.documentMap[filename]; ok {
+		return []byte(document.text), nil
+	}
+
+	return os.ReadFile(filepath.FromSlash(filename))
+}
+
+// newParser creates a parser for a schema file, which reads the imported
+// files through readFile
+func (server *tServer) newParser(schemaPath string, text []byte) lidy.Parser {
+	return lidy.NewParser(schemaPath, text).Loader(server.readFile)
+}
+
+// regexYamlLine -- the line given by the YAML syntax errors, e.g. `yaml: line 3: could not find expected ':'`
+var regexYamlLine = *regexp.MustCompile(`^yaml: line (\d+):`)
+
+// newDiagnostic converts an error of lidy to a diagnostic. The errors without
+// a position are put on the line given by their text, or on the first line
+func newDiagnostic(text tText, err error) tDiagnostic {
+	var contentError *lidy.ContentError
+	var schemaError *lidy.SchemaError
+
+	switch {
+	case errors.As(err, &contentError):
+		diagnostic := positionDiagnostic(text, contentError, errorSummary(contentError.Expected, contentError.Actual, contentError.RuleName))
+		diagnostic.Code = string(contentError.Code)
+		for _, nested := range contentError.Nested {
+			diagnostic.Message += "\n- " + strings.ReplaceAll(nestedSummary(nested), "\n", "\n  ")
+		}
+		return diagnostic
+	case errors.As(err, &schemaError):
+		diagnostic := positionDiagnostic(text, schemaError, errorSummary(schemaError.Expected, schemaError.Actual, schemaError.RuleName))
+		diagnostic.Code = string(schemaError.Code)
+		return diagnostic
+	}
+
+	line := 1
+	if match := regexYamlLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ = strconv.Atoi(match[1])
+	}
+
+	return lineDiagnostic(text, line, err.Error())
+}
+
+// positionDiagnostic is a diagnostic spanning the node of the error
+func positionDiagnostic(text tText, position lidy.Position, message string) tDiagnostic {
+	return tDiagnostic{
+		Range: tRange{
+			Start: text.position(position.Line(), position.Column()),
+			End:   text.position(position.LineEnd(), position.ColumnEnd()),
+		},
+		Severity: diagnosticSeverityError,
+		Source:   "lidy",
+		Message:  message,
+	}
+}
+
+// newWarningDiagnostic is the diagnostic of a warning of the content
+func newWarningDiagnostic(text tText, warning lidy.Warning) tDiagnostic {
+	var contentWarning *lidy.ContentWarning
+	if !errors.As(warning, &contentWarning) {
+		diagnostic := lineDiagnostic(text, 1, warning.Error())
+		diagnostic.Severity = diagnosticSeverityWarning
+		return diagnostic
+	}
+
+	message := "deprecated"
+	if contentWarning.RuleName != "" {
+		message = fmt.Sprintf("deprecated (rule %s)", contentWarning.RuleName)
+	}
+	if contentWarning.Message != "" {
+		message += ": " + contentWarning.Message
+	}
+
+	diagnostic := positionDiagnostic(text, contentWarning, message)
+	diagnostic.Severity = diagnosticSeverityWarning
+	return diagnostic
+}
+
+// lineDiagnostic is a diagnostic spanning a line, given as a lidy line
+func lineDiagnostic(text tText, line int, message string) tDiagnostic {
+	return tDiagnostic{
+		Range:    text.lineRange(line),
+		Severity: diagnosticSeverityError,
+		Source:   "lidy",
+		Message:  message,
+	}
+}
+
+// errorSummary is the message of a diagnostic, the position and the path
+// being shown by the editor
+func errorSummary(expected string, actual string, ruleName string) string {
+	// the descriptions of the expressions may span several lines
+	message := "expected " + strings.Join(strings.Fields(expected), " ")
+	if actual != "" {
+		message += ", got " + actual
+	}
+	if ruleName != "" {
+		message += " (rule " + ruleName + ")"
+	}
+	return message
+}
+
+// nestedSummary is the message of a nested error, with its position
+func nestedSummary(err error) string {
+	var contentError *lidy.ContentError
+	if !errors.As(err, &contentError) {
+		return err.Error()
+	}
+
+	message := fmt.Sprintf("%d:%d %s: %s", contentError.Line(), contentError.Column(), contentError.Path, errorSummary(contentError.Expected, contentError.Actual, contentError.RuleName))
+	for _, nested := range contentError.Nested {
+		message += "\n- " + strings.ReplaceAll(nestedSummary(nested), "\n", "\n  ")
+	}
+	return message
+}
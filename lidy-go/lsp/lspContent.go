@@ -0,0 +1,169 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ditrit/lidy"
+)
+
+// lspContent.go
+//
+// The features of the content documents: the errors and warnings of Parse, the
+// description of the rule under the cursor, and the completion of the keys
+
+// diagnoseContent checks a content document against its schema
+func (server *tServer) diagnoseContent(documentPath string, text string, schemaPath string, target string) []tDiagnostic {
+	documentText := newText(text)
+
+	parser, diagnosticList := server.contentParser(documentText, schemaPath, target)
+	if parser == nil {
+		return diagnosticList
+	}
+
+	_, warningList, erl := parser.ParseWithWarnings(lidy.NewFile(documentPath, []byte(text)))
+
+	diagnosticList = []tDiagnostic{}
+	for _, err := range erl {
+		diagnosticList = append(diagnosticList, newDiagnostic(documentText, err))
+	}
+	for _, warning := range warningList {
+		diagnosticList = append(diagnosticList, newWarningDiagnostic(documentText, warning))
+	}
+
+	return diagnosticList
+}
+
+// contentParser creates the parser of the schema of a content document. If
+// the schema cannot be read or is invalid, it returns nil, and a diagnostic
+// on the first line of the document
+func (server *tServer) contentParser(documentText tText, schemaPath string, target string) (lidy.Parser, []tDiagnostic) {
+	schema, err := server.readFile(schemaPath)
+	if err != nil {
+		return nil, []tDiagnostic{lineDiagnostic(documentText, 1, fmt.Sprintf("cannot read the schema: %s", err))}
+	}
+
+	parser := server.newParser(schemaPath, schema).Target(target)
+
+	erl := parser.Schema()
+	if len(erl) > 0 {
+		return nil, []tDiagnostic{lineDiagnostic(documentText, 1, fmt.Sprintf(
+			"the schema %s has %d error(s), the first one being: %s", schemaPath, len(erl), erl[0].Error(),
+		))}
+	}
+
+	return parser, nil
+}
+
+// hoverContent describes the rule applying to the node under the cursor
+func (server *tServer) hoverContent(documentPath string, text string, schemaPath string, target string, position tPosition) interface{} {
+	documentText := newText(text)
+
+	parser, _ := server.contentParser(documentText, schemaPath, target)
+	if parser == nil {
+		return nil
+	}
+
+	location, erl := parser.Locate(lidy.NewFile(documentPath, []byte(text)), position.Line+1, documentText.column(position)+1)
+	if len(erl) > 0 {
+		return nil
+	}
+
+	title := fmt.Sprintf("`%s`", location.Path)
+	if location.RuleName != "" {
+		title = fmt.Sprintf("rule **%s**, at `%s`", location.RuleName, location.Path)
+	}
+
+	if location.Annotation.Deprecated {
+		title += " (deprecated)"
+	}
+	if location.Annotation.Description != "" {
+		title += "\n\n" + location.Annotation.Description
+	}
+
+	return tHover{Contents: tMarkupContent{
+		Kind:  "markdown",
+		Value: title + "\n\n```\n" + location.Description + "\n```",
+	}}
+}
+
+// completeContent proposes the keys that the map at the cursor may still
+// get. The word being typed is removed before the document is read, so that
+// the document is valid YAML. No key is proposed after a key, where a value
+// is expected
+func (server *tServer) completeContent(documentPath string, text string, schemaPath string, target string, position tPosition) []tCompletionItem {
+	documentText := newText(text)
+
+	start, patchedText := removeWord(documentText, position)
+
+	lineStart := strings.TrimLeft(string(documentText.line(position.Line)[:start]), " \t-")
+	if lineStart != "" {
+		return []tCompletionItem{}
+	}
+
+	parser, _ := server.contentParser(documentText, schemaPath, target)
+	if parser == nil {
+		return []tCompletionItem{}
+	}
+
+	// a document without nodes is not YAML; it is completed as an empty map
+	if isBlank(patchedText) {
+		patchedText = "{}"
+	}
+
+	location, erl := parser.Locate(lidy.NewFile(documentPath, []byte(patchedText)), position.Line+1, start+1)
+	if len(erl) > 0 {
+		return []tCompletionItem{}
+	}
+
+	itemList := []tCompletionItem{}
+	for _, key := range location.KeyList {
+		itemList = append(itemList, tCompletionItem{
+			Label:      key,
+			Kind:       completionItemKindProperty,
+			Detail:     location.RuleName,
+			InsertText: key + ": ",
+		})
+	}
+
+	return itemList
+}
+
+// removeWord removes the word around the position from the text. It returns
+// the index of the first character of the word in its line, and the text
+func removeWord(text tText, position tPosition) (int, string) {
+	runeList := text.line(position.Line)
+	column := text.column(position)
+
+	start := column
+	for start > 0 && isWordRune(runeList[start-1]) {
+		start--
+	}
+	end := column
+	for end < len(runeList) && isWordRune(runeList[end]) {
+		end++
+	}
+
+	lineList := append([]string{}, text.lineList...)
+	if position.Line < len(lineList) {
+		lineList[position.Line] = string(runeList[:start]) + string(runeList[end:])
+	}
+
+	return start, strings.Join(lineList, "\n")
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || r == '$' ||
+		('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')
+}
+
+// isBlank tells whether the text only has blank lines and comments
+func isBlank(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
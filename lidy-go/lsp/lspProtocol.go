@@ -0,0 +1,273 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// lspProtocol.go
+//
+// The JSON-RPC messages of the Language Server Protocol, their framing, and
+// the conversion of the positions
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// LSP enumerations
+const (
+	textDocumentSyncFull = 1
+
+	diagnosticSeverityError   = 1
+	diagnosticSeverityWarning = 2
+
+	completionItemKindProperty  = 10
+	completionItemKindKeyword   = 14
+	completionItemKindReference = 18
+)
+
+// tMessage -- a request, a response or a notification, as read
+type tMessage struct {
+	Id     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// isRequest tells whether the message expects a response
+func (message tMessage) isRequest() bool {
+	return len(message.Id) > 0 && string(message.Id) != "null"
+}
+
+type tResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type tPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type tRange struct {
+	Start tPosition `json:"start"`
+	End   tPosition `json:"end"`
+}
+
+type tLocation struct {
+	Uri   string `json:"uri"`
+	Range tRange `json:"range"`
+}
+
+type tTextDocumentIdentifier struct {
+	Uri string `json:"uri"`
+}
+
+type tTextDocumentItem struct {
+	Uri     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+type tInitializeParams struct {
+	RootUri               string        `json:"rootUri"`
+	InitializationOptions tServerOption `json:"initializationOptions"`
+}
+
+type tDidOpenParams struct {
+	TextDocument tTextDocumentItem `json:"textDocument"`
+}
+
+type tDidChangeParams struct {
+	TextDocument   tTextDocumentItem `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type tTextDocumentParams struct {
+	TextDocument tTextDocumentIdentifier `json:"textDocument"`
+}
+
+type tTextDocumentPositionParams struct {
+	TextDocument tTextDocumentIdentifier `json:"textDocument"`
+	Position     tPosition               `json:"position"`
+}
+
+type tDiagnostic struct {
+	Range    tRange `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type tPublishDiagnosticsParams struct {
+	Uri         string        `json:"uri"`
+	Diagnostics []tDiagnostic `json:"diagnostics"`
+}
+
+type tMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type tHover struct {
+	Contents tMarkupContent `json:"contents"`
+}
+
+type tCompletionItem struct {
+	Label      string `json:"label"`
+	Kind       int    `json:"kind"`
+	Detail     string `json:"detail,omitempty"`
+	InsertText string `json:"insertText,omitempty"`
+}
+
+type tCompletionList struct {
+	IsIncomplete bool              `json:"isIncomplete"`
+	Items        []tCompletionItem `json:"items"`
+}
+
+// tConnection -- reads and writes the messages, framed by a Content-Length header
+type tConnection struct {
+	reader *bufio.Reader
+	writer io.Writer
+}
+
+// read reads the next message. It returns io.EOF when the input is closed
+func (connection *tConnection) read() ([]byte, error) {
+	header, err := textproto.NewReader(connection.reader).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header '%s'", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(connection.reader, body)
+	return body, err
+}
+
+// write writes a message
+func (connection *tConnection) write(message map[string]interface{}) error {
+	message["jsonrpc"] = "2.0"
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(connection.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (connection *tConnection) respond(id json.RawMessage, result interface{}) error {
+	return connection.write(map[string]interface{}{"id": id, "result": result})
+}
+
+func (connection *tConnection) respondError(id json.RawMessage, code int, message string) error {
+	return connection.write(map[string]interface{}{"id": id, "error": tResponseError{Code: code, Message: message}})
+}
+
+func (connection *tConnection) notify(method string, params interface{}) error {
+	return connection.write(map[string]interface{}{"method": method, "params": params})
+}
+
+// uriToPath converts a file URI to a slash-separated path. Other URIs are
+// kept as they are
+func uriToPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+
+	path := parsed.Path
+	// file:///C:/dir/file.yaml
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	return path
+}
+
+// pathToUri converts a slash-separated path to a file URI
+func pathToUri(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// tText -- the lines of a document, to convert the positions of lidy, whose
+// lines and columns start at 1 and count characters, to those of the LSP,
+// which start at 0 and count UTF-16 code units
+type tText struct {
+	lineList []string
+}
+
+func newText(text string) tText {
+	return tText{lineList: strings.Split(text, "\n")}
+}
+
+func (text tText) line(line int) []rune {
+	if line < 0 || line >= len(text.lineList) {
+		return nil
+	}
+	return []rune(strings.TrimSuffix(text.lineList[line], "\r"))
+}
+
+// position converts a lidy line and column to an LSP position
+func (text tText) position(line int, column int) tPosition {
+	if line < 1 {
+		return tPosition{}
+	}
+
+	runeList := text.line(line - 1)
+	if column-1 > len(runeList) {
+		column = len(runeList) + 1
+	}
+	if column < 1 {
+		column = 1
+	}
+
+	return tPosition{Line: line - 1, Character: len(utf16.Encode(runeList[:column-1]))}
+}
+
+// column converts an LSP position to the index of the character in its line
+func (text tText) column(position tPosition) int {
+	runeList := text.line(position.Line)
+
+	unitCount := 0
+	for k, r := range runeList {
+		if unitCount >= position.Character {
+			return k
+		}
+		unitCount += len(utf16.Encode([]rune{r}))
+	}
+
+	return len(runeList)
+}
+
+// lineRange is the range of a whole line, given as a lidy line
+func (text tText) lineRange(line int) tRange {
+	start := text.position(line, 1)
+	return tRange{Start: start, End: text.position(line, len(text.line(line-1))+1)}
+}
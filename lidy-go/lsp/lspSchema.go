@@ -0,0 +1,307 @@
+package lsp
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ditrit/lidy"
+	"gopkg.in/yaml.v3"
+)
+
+// lspSchema.go
+//
+// The features of the schemas: the errors of Schema, the completion of the
+// lidy keywords and of the rule names, and the definition of the rule
+// references
+
+// keywordList -- the lidy keywords, which are the keys of the checker forms
+var keywordList = []string{
+	"_map", "_mapFacultative", "_mapOf", "_merge", "_default",
+	"_list", "_listFacultative", "_listOf",
+	"_oneOf", "_discriminator", "_switch",
+	"_in", "_regex", "_range",
+	"_min", "_max", "_nb",
+	"_rule", "_description", "_examples", "_deprecated",
+}
+
+// defaultRuleNameList -- the lidy default rules, see lidyDefaultRule.go
+var defaultRuleNameList = []string{
+	"any", "binary", "boolean", "float", "int", "nullType", "string", "timestamp",
+}
+
+// tSchemaContext -- what the schema expects at a position
+type tSchemaContext int
+
+const (
+	// contextNone -- a name chosen by the author of the schema, e.g. a rule declaration or a property, or a scalar argument
+	contextNone tSchemaContext = iota
+	// contextKeyword -- a key of a checker form
+	contextKeyword
+	// contextExpression -- an expression, e.g. a rule reference
+	contextExpression
+)
+
+// tSchemaPlace -- the kind of node being walked
+type tSchemaPlace int
+
+const (
+	// placeDocument -- the root of the schema, a map of rule declarations
+	placeDocument tSchemaPlace = iota
+	// placeExpression -- an expression
+	placeExpression
+	// placeExpressionList -- a list of expressions, e.g. the value of `_oneOf`
+	placeExpressionList
+	// placeNameMap -- a map of names to expressions, e.g. the value of `_map`
+	placeNameMap
+	// placeMapOf -- the value of `_mapOf`, a map of expressions to expressions
+	placeMapOf
+)
+
+// tSchemaWalker -- finds the context of a position of a schema, with the
+// same rules as lidy.Locate
+type tSchemaWalker struct {
+	lidy.Cursor
+}
+
+// tDeclaration -- a rule declared by a schema file or by the files it imports
+type tDeclaration struct {
+	// name the name of the rule in the schema, prefixed with the import aliases
+	name     string
+	path     string
+	keyNode  *yaml.Node
+	fileText tText
+}
+
+// diagnoseSchema checks a schema. The errors found in the imported files are
+// put on the first line
+func (server *tServer) diagnoseSchema(documentPath string, text string) []tDiagnostic {
+	documentText := newText(text)
+
+	erl := server.newParser(documentPath, []byte(text)).Schema()
+
+	diagnosticList := []tDiagnostic{}
+	for _, err := range erl {
+		diagnostic := newDiagnostic(documentText, err)
+
+		if position, ok := err.(interface{ Filename() string }); ok && position.Filename() != documentPath {
+			diagnostic = lineDiagnostic(documentText, 1, fmt.Sprintf("in %s: %s", position.Filename(), diagnostic.Message))
+		}
+
+		diagnosticList = append(diagnosticList, diagnostic)
+	}
+
+	return diagnosticList
+}
+
+// completeSchema proposes the keywords where a checker form is expected, and
+// the rule names where an expression is expected
+func (server *tServer) completeSchema(documentPath string, text string, position tPosition) []tCompletionItem {
+	documentText := newText(text)
+
+	start, patchedText := removeWord(documentText, position)
+
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(patchedText), &root)
+	if err != nil || len(root.Content) == 0 {
+		return []tCompletionItem{}
+	}
+
+	walker := tSchemaWalker{lidy.Cursor{Line: position.Line + 1, Column: start + 1}}
+	context, _ := walker.walk(root.Content[0], placeDocument)
+
+	itemList := []tCompletionItem{}
+
+	if context == contextKeyword {
+		for _, keyword := range keywordList {
+			itemList = append(itemList, tCompletionItem{
+				Label:      keyword,
+				Kind:       completionItemKindKeyword,
+				InsertText: keyword + ": ",
+			})
+		}
+	}
+
+	if context == contextExpression {
+		for _, ruleName := range defaultRuleNameList {
+			itemList = append(itemList, tCompletionItem{
+				Label:  ruleName,
+				Kind:   completionItemKindReference,
+				Detail: "lidy default rule",
+			})
+		}
+
+		for _, declaration := range server.declarationList(documentPath, root.Content[0], "", map[string]bool{}) {
+			itemList = append(itemList, tCompletionItem{
+				Label:  declaration.name,
+				Kind:   completionItemKindReference,
+				Detail: declaration.path,
+			})
+		}
+	}
+
+	return itemList
+}
+
+// defineSchema finds the declaration of the rule referred to at the position
+func (server *tServer) defineSchema(documentPath string, text string, position tPosition) (tLocation, bool) {
+	documentText := newText(text)
+
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(text), &root)
+	if err != nil || len(root.Content) == 0 {
+		return tLocation{}, false
+	}
+
+	walker := tSchemaWalker{lidy.Cursor{Line: position.Line + 1, Column: documentText.column(position) + 1}}
+	context, node := walker.walk(root.Content[0], placeDocument)
+
+	if context != contextExpression || node == nil || node.Kind != yaml.ScalarNode || !walker.isOn(node) {
+		return tLocation{}, false
+	}
+
+	for _, declaration := range server.declarationList(documentPath, root.Content[0], "", map[string]bool{}) {
+		if declaration.name == node.Value {
+			keyNode := declaration.keyNode
+			return tLocation{
+				Uri: pathToUri(declaration.path),
+				Range: tRange{
+					Start: declaration.fileText.position(keyNode.Line, keyNode.Column),
+					End:   declaration.fileText.position(keyNode.Line, keyNode.Column+len([]rune(keyNode.Value))),
+				},
+			}, true
+		}
+	}
+
+	return tLocation{}, false
+}
+
+// declarationList lists the rules declared by a schema file, and those of
+// the files it imports, prefixed with their alias, sorted by name
+func (server *tServer) declarationList(schemaPath string, root *yaml.Node, prefix string, visitingSet map[string]bool) []tDeclaration {
+	if root.Kind != yaml.MappingNode || visitingSet[schemaPath] {
+		return nil
+	}
+	visitingSet[schemaPath] = true
+
+	text, _ := server.readFile(schemaPath)
+	fileText := newText(string(text))
+
+	declarationList := []tDeclaration{}
+
+	for k := 0; k+1 < len(root.Content); k += 2 {
+		key, value := root.Content[k], root.Content[k+1]
+
+		if key.Value != "_import" {
+			declarationList = append(declarationList, tDeclaration{
+				// `name:` and `name::exportName` declare the rule name
+				name:     prefix + strings.SplitN(key.Value, ":", 2)[0],
+				path:     schemaPath,
+				keyNode:  key,
+				fileText: fileText,
+			})
+			continue
+		}
+
+		if value.Kind != yaml.MappingNode {
+			continue
+		}
+
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			alias, filename := value.Content[j].Value, value.Content[j+1].Value
+			importPath := path.Join(path.Dir(schemaPath), filename)
+
+			content, err := server.readFile(importPath)
+			if err != nil {
+				continue
+			}
+
+			importRoot := yaml.Node{}
+			err = yaml.Unmarshal(content, &importRoot)
+			if err != nil || len(importRoot.Content) == 0 {
+				continue
+			}
+
+			declarationList = append(declarationList, server.declarationList(importPath, importRoot.Content[0], prefix+alias+".", visitingSet)...)
+		}
+	}
+
+	delete(visitingSet, schemaPath)
+
+	sort.SliceStable(declarationList, func(i, j int) bool {
+		return declarationList[i].name < declarationList[j].name
+	})
+
+	return declarationList
+}
+
+// walk descends the node down to the innermost node containing the
+// position, and returns the context of the position, and the node under the
+// position, if any
+func (walker tSchemaWalker) walk(node *yaml.Node, place tSchemaPlace) (tSchemaContext, *yaml.Node) {
+	switch place {
+	case placeDocument:
+		k := walker.EntryAt(node)
+		if k < 0 || walker.isOn(node.Content[k]) || node.Content[k].Value == "_import" {
+			return contextNone, nil
+		}
+		return walker.walk(node.Content[k+1], placeExpression)
+	case placeExpression:
+		switch node.Kind {
+		case yaml.ScalarNode:
+			// a map being written below the key
+			if node.Line < walker.Line {
+				return contextKeyword, nil
+			}
+			return contextExpression, node
+		case yaml.MappingNode:
+			k := walker.EntryAt(node)
+			if k < 0 {
+				return contextKeyword, nil
+			}
+			if walker.isOn(node.Content[k]) {
+				return contextKeyword, node.Content[k]
+			}
+
+			switch node.Content[k].Value {
+			case "_map", "_mapFacultative", "_switch":
+				return walker.walk(node.Content[k+1], placeNameMap)
+			case "_mapOf":
+				return walker.walk(node.Content[k+1], placeMapOf)
+			case "_merge", "_list", "_listFacultative", "_oneOf":
+				return walker.walk(node.Content[k+1], placeExpressionList)
+			case "_listOf", "_rule":
+				return walker.walk(node.Content[k+1], placeExpression)
+			}
+		}
+	case placeExpressionList:
+		if k := walker.ItemAt(node); k >= 0 {
+			return walker.walk(node.Content[k], placeExpression)
+		}
+		if node.Kind == yaml.SequenceNode {
+			return contextExpression, nil
+		}
+	case placeNameMap:
+		k := walker.EntryAt(node)
+		if k >= 0 && !walker.isOn(node.Content[k]) {
+			return walker.walk(node.Content[k+1], placeExpression)
+		}
+	case placeMapOf:
+		k := walker.EntryAt(node)
+		if k < 0 {
+			return contextExpression, nil
+		}
+		if walker.isOn(node.Content[k]) {
+			return contextExpression, node.Content[k]
+		}
+		return walker.walk(node.Content[k+1], placeExpression)
+	}
+
+	return contextNone, nil
+}
+
+// isOn tells whether the position is on the text of a scalar node
+func (walker tSchemaWalker) isOn(node *yaml.Node) bool {
+	return node.Line == walker.Line && node.Column <= walker.Column && walker.Column <= node.Column+len([]rune(node.Value))
+}
@@ -0,0 +1,321 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLsp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "lsp Suite")
+}
+
+const serverSchema = `main: config
+config:
+  _map:
+    name: string
+    servers: { _listOf: net.server }
+  _mapFacultative:
+    debug: boolean
+_import:
+  net: net.yaml
+`
+
+const netSchema = `server:
+  _map:
+    host: string
+    port: { _range: (1 <= int <= 65535) }
+`
+
+// tClient -- the editor side of the connection
+type tClient struct {
+	connection tConnection
+	writer     *io.PipeWriter
+	done       chan error
+	// bodyChannel the messages of the server, read as soon as they are written,
+	// since the pipes do not buffer them
+	bodyChannel chan []byte
+	lastId      int
+	// diagnosticMap the last diagnostics published for each URI
+	diagnosticMap map[string][]tDiagnostic
+}
+
+func newClient(initializationOptions interface{}, rootPath string) *tClient {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	client := &tClient{
+		connection:    tConnection{reader: bufio.NewReader(clientReader), writer: clientWriter},
+		writer:        clientWriter,
+		done:          make(chan error, 1),
+		bodyChannel:   make(chan []byte, 64),
+		diagnosticMap: map[string][]tDiagnostic{},
+	}
+
+	go func() {
+		for {
+			body, err := client.connection.read()
+			if err != nil {
+				close(client.bodyChannel)
+				return
+			}
+			client.bodyChannel <- body
+		}
+	}()
+
+	go func() {
+		client.done <- Serve(serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+
+	client.request("initialize", map[string]interface{}{
+		"rootUri":               pathToUri(rootPath),
+		"initializationOptions": initializationOptions,
+	}, nil)
+
+	return client
+}
+
+func (client *tClient) notify(method string, params interface{}) {
+	Expect(client.connection.notify(method, params)).To(Succeed())
+}
+
+// request sends a request and reads its result, recording the diagnostics
+// published meanwhile. It returns the error of the response, if any
+func (client *tClient) request(method string, params interface{}, result interface{}) *tResponseError {
+	client.lastId++
+	id, _ := json.Marshal(client.lastId)
+
+	Expect(client.connection.write(map[string]interface{}{"id": json.RawMessage(id), "method": method, "params": params})).To(Succeed())
+
+	for body := range client.bodyChannel {
+		message := struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *tResponseError `json:"error"`
+		}{}
+		Expect(json.Unmarshal(body, &message)).To(Succeed())
+
+		if message.Method == "textDocument/publishDiagnostics" {
+			params := tPublishDiagnosticsParams{}
+			Expect(json.Unmarshal(message.Params, &params)).To(Succeed())
+			client.diagnosticMap[params.Uri] = params.Diagnostics
+			continue
+		}
+
+		Expect(string(message.Id)).To(Equal(string(id)))
+		if result != nil && message.Error == nil {
+			Expect(json.Unmarshal(message.Result, result)).To(Succeed())
+		}
+		return message.Error
+	}
+
+	Fail("the server closed the connection")
+	return nil
+}
+
+// open opens a document, and waits for its diagnostics, by sending a request
+func (client *tClient) open(path string, text string) string {
+	uri := pathToUri(path)
+	client.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": text},
+	})
+	client.request("shutdown", nil, nil)
+	return uri
+}
+
+func (client *tClient) completion(uri string, line int, character int) []string {
+	completionList := tCompletionList{}
+	Expect(client.request("textDocument/completion", positionParams(uri, line, character), &completionList)).To(BeNil())
+
+	labelList := []string{}
+	for _, item := range completionList.Items {
+		labelList = append(labelList, item.Label)
+	}
+	return labelList
+}
+
+func (client *tClient) close() {
+	client.notify("exit", nil)
+	Expect(<-client.done).To(Succeed())
+	client.writer.Close()
+}
+
+func positionParams(uri string, line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+var _ = Describe("The language server", func() {
+	var directory string
+	var client *tClient
+
+	BeforeEach(func() {
+		var err error
+		directory, err = os.MkdirTemp("", "lidy-lsp")
+		Expect(err).NotTo(HaveOccurred())
+		directory = filepath.ToSlash(directory)
+
+		Expect(os.WriteFile(filepath.Join(directory, "config.lidy.yaml"), []byte(serverSchema), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(directory, "net.yaml"), []byte(netSchema), 0644)).To(Succeed())
+
+		client = newClient(nil, directory)
+	})
+
+	AfterEach(func() {
+		client.close()
+		os.RemoveAll(directory)
+	})
+
+	Describe("on the content documents", func() {
+		It("publishes the errors of Parse, on the faulty node", func() {
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: config.lidy.yaml\nname: a\nservers:\n  - { host: h, port: 0 }\n")
+
+			diagnosticList := client.diagnosticMap[uri]
+			Expect(diagnosticList).To(HaveLen(1))
+			Expect(diagnosticList[0].Code).To(Equal("range"))
+			Expect(diagnosticList[0].Range).To(Equal(tRange{
+				Start: tPosition{Line: 3, Character: 21},
+				End:   tPosition{Line: 3, Character: 22},
+			}))
+			Expect(diagnosticList[0].Message).To(ContainSubstring("(rule net.server)"))
+		})
+
+		It("publishes the uses of deprecated expressions as warnings", func() {
+			Expect(os.WriteFile(filepath.Join(directory, "old.lidy.yaml"), []byte(
+				"main:\n  _mapFacultative:\n    name: { _rule: string, _deprecated: use title, _description: The name }\n",
+			), 0644)).To(Succeed())
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: old.lidy.yaml\nname: a\n")
+
+			diagnosticList := client.diagnosticMap[uri]
+			Expect(diagnosticList).To(HaveLen(1))
+			Expect(diagnosticList[0].Severity).To(Equal(diagnosticSeverityWarning))
+			Expect(diagnosticList[0].Range.Start).To(Equal(tPosition{Line: 1, Character: 6}))
+			Expect(diagnosticList[0].Message).To(Equal("deprecated (rule main): use title"))
+
+			hover := tHover{}
+			Expect(client.request("textDocument/hover", positionParams(uri, 1, 6), &hover)).To(BeNil())
+			Expect(hover.Contents.Value).To(HavePrefix("rule **main**, at `/name` (deprecated)\n\nThe name\n\n"))
+		})
+
+		It("reports a schema which cannot be read on the first line", func() {
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: missing.yaml\nname: a\n")
+
+			Expect(client.diagnosticMap[uri]).To(HaveLen(1))
+			Expect(client.diagnosticMap[uri][0].Range.Start.Line).To(Equal(0))
+			Expect(client.diagnosticMap[uri][0].Message).To(HavePrefix("cannot read the schema"))
+		})
+
+		It("completes the keys of the map at the cursor", func() {
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: config.lidy.yaml\nname: a\nse\nservers: []\n")
+			// the keys of the map are not proposed again
+			Expect(client.completion(uri, 2, 2)).To(Equal([]string{"debug"}))
+			// after a key, a value is expected
+			Expect(client.completion(uri, 1, 7)).To(BeEmpty())
+
+			uri = client.open(directory+"/config.yaml", "# lidy-schema: config.lidy.yaml\nservers:\n  - host: h\n    \n")
+			Expect(client.completion(uri, 3, 4)).To(Equal([]string{"port"}))
+		})
+
+		It("completes an empty document", func() {
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: config.lidy.yaml\n")
+
+			Expect(client.completion(uri, 1, 0)).To(Equal([]string{"debug", "name", "servers"}))
+		})
+
+		It("describes the rule of the node under the cursor", func() {
+			uri := client.open(directory+"/config.yaml", "# lidy-schema: config.lidy.yaml\nname: a\nservers:\n  - { host: h, port: 80 }\n")
+
+			hover := tHover{}
+			Expect(client.request("textDocument/hover", positionParams(uri, 3, 6), &hover)).To(BeNil())
+			Expect(hover.Contents.Value).To(HavePrefix("rule **net.server**, at `/servers/0/host`\n\n```\nRule string (lidy default rule)"))
+		})
+
+		It("finds the schema through the initializationOptions", func() {
+			client.close()
+			client = newClient(map[string]interface{}{
+				"schemas": []interface{}{map[string]interface{}{"schema": "net.yaml", "target": "server", "files": []string{"*.server.yaml"}}},
+			}, directory)
+
+			uri := client.open(directory+"/a.server.yaml", "host: h\n")
+			Expect(client.diagnosticMap[uri]).To(HaveLen(1))
+			Expect(client.diagnosticMap[uri][0].Code).To(Equal("missingProperty"))
+
+			// the associated schema is checked too
+			Expect(client.diagnosticMap[pathToUri(directory+"/net.yaml")]).To(BeNil())
+			netUri := client.open(directory+"/net.yaml", "server: unknown\n")
+			Expect(client.diagnosticMap[netUri]).To(HaveLen(1))
+			Expect(client.diagnosticMap[uri][0].Message).To(ContainSubstring("the schema"))
+		})
+	})
+
+	Describe("on the schemas", func() {
+		It("publishes the errors of Schema", func() {
+			uri := client.open(directory+"/config.lidy.yaml", "main: { _map: { a: strin } }\n")
+
+			diagnosticList := client.diagnosticMap[uri]
+			Expect(diagnosticList).To(HaveLen(1))
+			Expect(diagnosticList[0].Code).To(Equal("unknownRule"))
+			Expect(diagnosticList[0].Range.Start).To(Equal(tPosition{Line: 0, Character: 19}))
+		})
+
+		It("publishes the YAML syntax errors on their line", func() {
+			uri := client.open(directory+"/config.lidy.yaml", "main: string\nother: [\n")
+
+			Expect(client.diagnosticMap[uri]).To(HaveLen(1))
+			Expect(client.diagnosticMap[uri][0].Range.Start.Line).To(BeNumerically(">=", 1))
+		})
+
+		It("completes the keywords and the rule names", func() {
+			uri := client.open(directory+"/config.lidy.yaml", serverSchema+"extra:\n  _ma\n")
+			Expect(client.completion(uri, 10, 5)).To(ContainElements("_map", "_mapFacultative", "_listOf"))
+
+			uri = client.open(directory+"/config.lidy.yaml", serverSchema+"extra:\n  _mapOf: { s }\n")
+			Expect(client.completion(uri, 4, 28)).To(ContainElements("config", "extra", "net.server", "string", "any"))
+			Expect(client.completion(uri, 10, 13)).To(ContainElement("net.server"))
+			// the names of the properties are free
+			Expect(client.completion(uri, 3, 4)).To(BeEmpty())
+		})
+
+		It("finds the declarations of the rules, in the imported files too", func() {
+			uri := client.open(directory+"/config.lidy.yaml", serverSchema)
+
+			location := tLocation{}
+			Expect(client.request("textDocument/definition", positionParams(uri, 0, 8), &location)).To(BeNil())
+			Expect(location).To(Equal(tLocation{Uri: uri, Range: tRange{
+				Start: tPosition{Line: 1, Character: 0},
+				End:   tPosition{Line: 1, Character: 6},
+			}}))
+
+			Expect(client.request("textDocument/definition", positionParams(uri, 4, 30), &location)).To(BeNil())
+			Expect(location.Uri).To(Equal(pathToUri(directory + "/net.yaml")))
+			Expect(location.Range.Start).To(Equal(tPosition{Line: 0, Character: 0}))
+		})
+	})
+
+	It("rejects the unsupported requests", func() {
+		responseError := client.request("workspace/symbol", map[string]interface{}{}, nil)
+		Expect(responseError).NotTo(BeNil())
+		Expect(responseError.Code).To(Equal(codeMethodNotFound))
+	})
+})
+
+var _ = Describe("The positions", func() {
+	It("count UTF-16 code units", func() {
+		text := newText("é: 𝄞x\n")
+
+		Expect(text.position(1, 5)).To(Equal(tPosition{Line: 0, Character: 5}))
+		Expect(text.column(tPosition{Line: 0, Character: 5})).To(Equal(4))
+	})
+})